@@ -54,6 +54,10 @@ const (
 	// QueryTypeOpenSessions is the build in query type for Client.QueryWorkflow() call. Use this query type to get all open
 	// sessions in the workflow. The result will be a list of SessionInfo encoded in the encoded.Value.
 	QueryTypeOpenSessions string = internal.QueryTypeOpenSessions
+
+	// QueryTypeQueryTypes is the build in query type for Client.QueryWorkflow() call. Use this query type to get the query
+	// types registered by the workflow. The result will be a list of QueryHandlerInfo encoded in the encoded.Value.
+	QueryTypeQueryTypes string = internal.QueryTypeQueryTypes
 )
 
 type (
@@ -63,6 +67,16 @@ type (
 	// ConnectionOptions are optional parameters that can be specified in ClientOptions
 	ConnectionOptions = internal.ConnectionOptions
 
+	// QueryHandlerInfo describes a query handler registered by a workflow.
+	// The result of the QueryTypeQueryTypes query is a list of QueryHandlerInfo.
+	QueryHandlerInfo = internal.QueryHandlerInfo
+
+	// HeadersProvider returns a map of gRPC headers that should be used on every request.
+	HeadersProvider = internal.HeadersProvider
+
+	// HeadersRefresher can be implemented by HeadersProvider to refresh headers when server responds with Unauthenticated error.
+	HeadersRefresher = internal.HeadersRefresher
+
 	// StartWorkflowOptions configuration parameters for starting a workflow execution.
 	StartWorkflowOptions = internal.StartWorkflowOptions
 
@@ -334,6 +348,24 @@ type (
 		//  - QueryFailError
 		QueryWorkflowWithOptions(ctx context.Context, request *QueryWorkflowWithOptionsRequest) (*QueryWorkflowWithOptionsResponse, error)
 
+		// UpdateWorkflow sends an update to a workflow in execution and waits for the update handler registered with
+		// workflow.SetUpdateHandler to complete. It returns the handler result, or the error returned by the update
+		// validator or handler. Parameter workflowID is required, other parameters are optional.
+		// - workflow ID of the workflow.
+		// - runID can be default(empty string). if empty string then it will pick the running execution of that workflow ID.
+		// - updateName is the name of the update.
+		// - args... are the optional update handler parameters.
+		// The result of the update is polled through a query, so the call blocks until ctx is done if the workflow
+		// never handles the update. Use a context with deadline to limit the wait.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - InternalServiceError
+		//  - EntityNotExistError
+		//  - QueryFailError
+		//  - ApplicationError
+		//  - PanicError
+		UpdateWorkflow(ctx context.Context, workflowID string, runID string, updateName string, args ...interface{}) (encoded.Value, error)
+
 		// DescribeWorkflowExecution returns information about the specified workflow execution.
 		// - runID can be default(empty string). if empty string then it will pick the last running execution of that workflow ID.
 		//
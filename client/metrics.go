@@ -0,0 +1,66 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package client
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/uber-go/tally"
+
+	"go.temporal.io/temporal/internal"
+)
+
+type (
+	// MetricsHandler is an abstraction of a metrics backend used by the SDK to emit metrics.
+	// Set it as Options.MetricsHandler to report client and worker metrics.
+	MetricsHandler = internal.MetricsHandler
+
+	// MetricsCounter is a monotonically increasing metric.
+	MetricsCounter = internal.MetricsCounter
+
+	// MetricsGauge is a metric that reports the last set value.
+	MetricsGauge = internal.MetricsGauge
+
+	// MetricsTimer is a metric that records latencies.
+	MetricsTimer = internal.MetricsTimer
+
+	// MetricsHistogram is a metric that counts observed values into buckets.
+	MetricsHistogram = internal.MetricsHistogram
+
+	// PrometheusMetricsHandlerOptions are optional parameters for NewPrometheusMetricsHandler.
+	PrometheusMetricsHandlerOptions = internal.PrometheusMetricsHandlerOptions
+)
+
+// NewTallyMetricsHandler creates a MetricsHandler that reports metrics to the tally scope.
+func NewTallyMetricsHandler(scope tally.Scope) MetricsHandler {
+	return internal.NewTallyMetricsHandler(scope)
+}
+
+// NewPrometheusMetricsHandler creates a MetricsHandler that reports metrics as Prometheus collectors
+// registered with the registerer, prometheus.DefaultRegisterer if nil. Timers, including latency metrics
+// such as temporal_activity_execution_latency, are reported as histograms of seconds. Tags that are not declared
+// in options.AdditionalTagKeys or attached by the SDK are dropped.
+func NewPrometheusMetricsHandler(registerer prometheus.Registerer, options PrometheusMetricsHandlerOptions) MetricsHandler {
+	return internal.NewPrometheusMetricsHandler(registerer, options)
+}
@@ -46,9 +46,90 @@ type (
 	// Temporal support using different DataConverters for different activity/childWorkflow in same workflow.
 	//   2. Activity/Workflow worker that run these activity/childWorkflow, through worker.Options.
 	DataConverter = internal.DataConverter
+
+	// PayloadConverter converts single value of specific type to/from payload with a single encoding.
+	// PayloadConverters are combined in CompositeDataConverter. Implement it to support custom encodings (i.e. msgpack).
+	PayloadConverter = internal.PayloadConverter
+
+	// CompositeDataConverter applies PayloadConverters in specified order.
+	CompositeDataConverter = internal.CompositeDataConverter
+
+	// PayloadCodec transforms payload bytes and metadata, i.e. to compress or encrypt them.
+	PayloadCodec = internal.PayloadCodec
+
+	// HeaderCodec is implemented by DataConverters which transform header fields written by context propagators,
+	// i.e. to encrypt them. A DataConverter which wraps CodecDataConverter must implement HeaderCodec by delegating
+	// to the wrapped DataConverter, otherwise header fields are sent as is.
+	HeaderCodec = internal.HeaderCodec
+
+	// CodecDataConverter wraps DataConverter and applies a chain of PayloadCodecs to every payload.
+	// It is applied to workflow and activity inputs and results, signals, queries, memos, heartbeat details,
+	// failure details and headers written by context propagators.
+	CodecDataConverter = internal.CodecDataConverter
 )
 
 // GetDefaultDataConverter return default data converter used by Temporal worker
 func GetDefaultDataConverter() DataConverter {
 	return internal.DefaultDataConverter
 }
+
+// NewCompositeDataConverter creates new instance of CompositeDataConverter from ordered list of PayloadConverters.
+// Order is important on encoding because the first converter which supports the value is used.
+// On decoding converter is selected by "encoding" payload metadata.
+// If several converters have the same encoding, the last one is used at the position of the first one.
+// To opt in to binary protobuf encoding use i.e.
+//
+//	NewCompositeDataConverter(NewNilPayloadConverter(), NewByteSlicePayloadConverter(), NewProtoPayloadConverter(), NewJSONPayloadConverter())
+func NewCompositeDataConverter(payloadConverters ...PayloadConverter) *CompositeDataConverter {
+	return internal.NewCompositeDataConverter(payloadConverters...)
+}
+
+// NewNilPayloadConverter creates new payload converter for nil values.
+func NewNilPayloadConverter() PayloadConverter {
+	return internal.NewNilPayloadConverter()
+}
+
+// NewByteSlicePayloadConverter creates new payload converter for []byte values.
+func NewByteSlicePayloadConverter() PayloadConverter {
+	return internal.NewByteSlicePayloadConverter()
+}
+
+// NewProtoPayloadConverter creates new payload converter for gogo and golang protobuf messages
+// which uses protobuf binary format.
+func NewProtoPayloadConverter() PayloadConverter {
+	return internal.NewProtoPayloadConverter()
+}
+
+// NewProtoJSONPayloadConverter creates new payload converter for gogo and golang protobuf messages
+// which uses protobuf JSON mapping.
+func NewProtoJSONPayloadConverter() PayloadConverter {
+	return internal.NewProtoJSONPayloadConverter()
+}
+
+// NewJSONPayloadConverter creates new payload converter which uses encoding/json for any value.
+func NewJSONPayloadConverter() PayloadConverter {
+	return internal.NewJSONPayloadConverter()
+}
+
+// NewCodecDataConverter creates new DataConverter which applies codecs to every payload produced by parent DataConverter.
+// On encoding codecs are applied in specified order, on decoding in reverse order,
+// i.e. NewCodecDataConverter(dc, gzipCodec, aesCodec) compresses and then encrypts payloads.
+func NewCodecDataConverter(parent DataConverter, codecs ...PayloadCodec) *CodecDataConverter {
+	return internal.NewCodecDataConverter(parent, codecs...)
+}
+
+// NewGzipPayloadCodec creates new PayloadCodec which compresses payloads using gzip.
+func NewGzipPayloadCodec() PayloadCodec {
+	return internal.NewGzipPayloadCodec()
+}
+
+// NewGzipPayloadCodecWithLevel creates new PayloadCodec which compresses payloads using gzip with specified level.
+func NewGzipPayloadCodecWithLevel(level int) (PayloadCodec, error) {
+	return internal.NewGzipPayloadCodecWithLevel(level)
+}
+
+// NewAESGCMPayloadCodec creates new PayloadCodec which encrypts payloads using AES-GCM with the key identified by keyID.
+// keys must contain all keys which might be needed to decrypt existing payloads.
+func NewAESGCMPayloadCodec(keyID string, keys map[string][]byte) (PayloadCodec, error) {
+	return internal.NewAESGCMPayloadCodec(keyID, keys)
+}
@@ -0,0 +1,63 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package interceptors
+
+import (
+	"github.com/opentracing/opentracing-go"
+
+	"go.temporal.io/temporal/internal"
+)
+
+type (
+	// OpenTelemetryTracingOptions are optional parameters for NewOpenTelemetryTracing.
+	OpenTelemetryTracingOptions = internal.OpenTelemetryTracingOptions
+
+	// OpenTelemetryTracing creates OpenTelemetry spans for client calls, workflow and activity executions.
+	// Span context is carried in Temporal headers using W3C trace context by default.
+	// Usage:
+	//  tracing := interceptors.NewOpenTelemetryTracing(interceptors.OpenTelemetryTracingOptions{})
+	//  c, err := client.NewClient(client.Options{
+	//  	ContextPropagators:              []workflow.ContextPropagator{tracing.ContextPropagator()},
+	//  	ClientInterceptorChainFactories: []interceptors.ClientInterceptorFactory{tracing.ClientInterceptorFactory()},
+	//  })
+	//  w := worker.New(c, "tasklist", worker.Options{
+	//  	WorkflowInterceptorChainFactories: []interceptors.WorkflowInterceptorFactory{tracing.WorkflowInterceptorFactory()},
+	//  	ActivityInterceptorChainFactories: []interceptors.ActivityInterceptorFactory{tracing.ActivityInterceptorFactory()},
+	//  })
+	OpenTelemetryTracing = internal.OpenTelemetryTracing
+)
+
+// NewOpenTelemetryTracing creates OpenTelemetryTracing.
+func NewOpenTelemetryTracing(options OpenTelemetryTracingOptions) *OpenTelemetryTracing {
+	return internal.NewOpenTelemetryTracing(options)
+}
+
+// NewTracingWorkflowInterceptorFactory returns a factory of interceptors that emit opentracing spans for workflow
+// execution, activities, local activities, child workflows, external signals, timers and received signals.
+// Spans are not emitted while the workflow is replaying. The span context is passed to activities and child
+// workflows by the tracing context propagator which is added automatically when client.Options.Tracer is set.
+func NewTracingWorkflowInterceptorFactory(tracer opentracing.Tracer) WorkflowInterceptorFactory {
+	return internal.NewTracingWorkflowInterceptorFactory(tracer)
+}
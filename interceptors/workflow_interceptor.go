@@ -41,7 +41,43 @@ type (
 	// Use workflow.IsReplaying(ctx) to filter out duplicated calls.
 	WorkflowInterceptor = internal.WorkflowInterceptor
 
+	// WorkflowSignalInterceptor is an optional interface a WorkflowInterceptor can implement to intercept delivery
+	// of a signal to the workflow before it is put into the signal channel. It is called outside of the workflow
+	// coroutines, so the implementation must not block.
+	WorkflowSignalInterceptor = internal.WorkflowSignalInterceptor
+
+	// WorkflowUpdateInterceptor is an optional interface a WorkflowInterceptor can implement to intercept
+	// workflow.SetUpdateHandler.
+	WorkflowUpdateInterceptor = internal.WorkflowUpdateInterceptor
+
 	// WorkflowInterceptorBase is a noop implementation of WorkflowInterceptor that just forwards requests
 	// to the next link in an interceptor chain. To be used as base implementation of interceptors.
 	WorkflowInterceptorBase = internal.WorkflowInterceptorBase
+
+	// ActivityInterceptorFactory is used to create a single link in the activity interceptor chain
+	ActivityInterceptorFactory = internal.ActivityInterceptorFactory
+
+	// ActivityInterceptor is an interface that can be implemented to intercept calls to the activity function
+	// as well calls done by the activity code. It is used for both activities and local activities.
+	// Use ActivityInterceptorBase as a base struct for implementations that do not want to implement every method.
+	// Interceptor implementation must forward calls to the next in the interceptor chain.
+	ActivityInterceptor = internal.ActivityInterceptor
+
+	// ActivityInterceptorBase is a noop implementation of ActivityInterceptor that just forwards requests
+	// to the next link in an interceptor chain. To be used as base implementation of interceptors.
+	ActivityInterceptorBase = internal.ActivityInterceptorBase
+
+	// ClientInterceptorFactory is used to create a single link in the client interceptor chain
+	ClientInterceptorFactory = internal.ClientInterceptorFactory
+
+	// ClientInterceptor is an interface that can be implemented to intercept calls done through the client.Client.
+	// Interceptor methods receive arguments before they are encoded, so an implementation can validate, log or
+	// replace them, as well as reject the call by returning an error without calling the next link.
+	// Use ClientInterceptorBase as a base struct for implementations that do not want to implement every method.
+	// Interceptor implementation must forward calls to the next in the interceptor chain.
+	ClientInterceptor = internal.ClientInterceptor
+
+	// ClientInterceptorBase is a noop implementation of ClientInterceptor that just forwards requests
+	// to the next link in an interceptor chain. To be used as base implementation of interceptors.
+	ClientInterceptorBase = internal.ClientInterceptorBase
 )
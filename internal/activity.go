@@ -133,6 +133,11 @@ type (
 
 // GetActivityInfo returns information about currently executing activity.
 func GetActivityInfo(ctx context.Context) ActivityInfo {
+	i := getActivityInterceptor(ctx)
+	return i.GetActivityInfo(ctx)
+}
+
+func (a *activityEnvironmentInterceptor) GetActivityInfo(ctx context.Context) ActivityInfo {
 	env := getActivityEnv(ctx)
 	return ActivityInfo{
 		ActivityID:         env.activityID,
@@ -172,14 +177,24 @@ func GetHeartbeatDetails(ctx context.Context, d ...interface{}) error {
 
 // GetActivityLogger returns a logger that can be used in activity
 func GetActivityLogger(ctx context.Context) *zap.Logger {
+	i := getActivityInterceptor(ctx)
+	return i.GetActivityLogger(ctx)
+}
+
+func (a *activityEnvironmentInterceptor) GetActivityLogger(ctx context.Context) *zap.Logger {
 	env := getActivityEnv(ctx)
 	return env.logger
 }
 
 // GetActivityMetricsScope returns a metrics scope that can be used in activity
 func GetActivityMetricsScope(ctx context.Context) tally.Scope {
+	i := getActivityInterceptor(ctx)
+	return i.GetActivityMetricsScope(ctx)
+}
+
+func (a *activityEnvironmentInterceptor) GetActivityMetricsScope(ctx context.Context) tally.Scope {
 	env := getActivityEnv(ctx)
-	return env.metricsScope
+	return metricsScopeFromHandler(env.metricsHandler)
 }
 
 // GetWorkerStopChannel returns a read-only channel. The closure of this channel indicates the activity worker is stopping.
@@ -200,6 +215,11 @@ func GetWorkerStopChannel(ctx context.Context) <-chan struct{} {
 // details - the details that you provided here can be seen in the worflow when it receives TimeoutError, you
 // can check error TimeoutType()/Details().
 func RecordActivityHeartbeat(ctx context.Context, details ...interface{}) {
+	i := getActivityInterceptor(ctx)
+	i.RecordActivityHeartbeat(ctx, details...)
+}
+
+func (a *activityEnvironmentInterceptor) RecordActivityHeartbeat(ctx context.Context, details ...interface{}) {
 	env := getActivityEnv(ctx)
 	if env.isLocalActivity {
 		// no-op for local activity
@@ -239,7 +259,7 @@ func WithActivityTask(
 	taskList string,
 	invoker ServiceInvoker,
 	logger *zap.Logger,
-	scope tally.Scope,
+	metricsHandler MetricsHandler,
 	dataConverter DataConverter,
 	workerStopChannel <-chan struct{},
 	contextPropagators []ContextPropagator,
@@ -277,7 +297,7 @@ func WithActivityTask(
 			RunID: task.WorkflowExecution.RunId,
 			ID:    task.WorkflowExecution.WorkflowId},
 		logger:             logger,
-		metricsScope:       scope,
+		metricsHandler:     metricsHandler,
 		deadline:           deadline,
 		heartbeatTimeout:   heartbeatTimeout,
 		scheduledTimestamp: scheduled,
@@ -52,6 +52,10 @@ const (
 	// QueryTypeOpenSessions is the build in query type for Client.QueryWorkflow() call. Use this query type to get all open
 	// sessions in the workflow. The result will be a list of SessionInfo encoded in the EncodedValue.
 	QueryTypeOpenSessions string = "__open_sessions"
+
+	// QueryTypeQueryTypes is the build in query type for Client.QueryWorkflow() call. Use this query type to get the query
+	// types registered by the workflow. The result will be a list of QueryHandlerInfo encoded in the EncodedValue.
+	QueryTypeQueryTypes string = "__query_types"
 )
 
 type (
@@ -296,6 +300,24 @@ type (
 		//  - QueryFailError
 		QueryWorkflowWithOptions(ctx context.Context, request *QueryWorkflowWithOptionsRequest) (*QueryWorkflowWithOptionsResponse, error)
 
+		// UpdateWorkflow sends an update to a workflow in execution and waits for the update handler registered with
+		// workflow.SetUpdateHandler to complete. It returns the handler result, or the error returned by the update
+		// validator or handler. Parameter workflowID is required, other parameters are optional.
+		// - workflow ID of the workflow.
+		// - runID can be default(empty string). if empty string then it will pick the running execution of that workflow ID.
+		// - updateName is the name of the update.
+		// - args... are the optional update handler parameters.
+		// The result of the update is polled through a query until the update completes. It fails if the workflow
+		// closes before the update completes. Use a context with deadline to limit the wait.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - InternalServiceError
+		//  - EntityNotExistError
+		//  - QueryFailError
+		//  - ApplicationError
+		//  - PanicError
+		UpdateWorkflow(ctx context.Context, workflowID string, runID string, updateName string, args ...interface{}) (Value, error)
+
 		// DescribeWorkflowExecution returns information about the specified workflow execution.
 		// The errors it can return:
 		//  - BadRequestError
@@ -364,15 +386,22 @@ type (
 		// default: no metrics.
 		MetricsScope tally.Scope
 
+		// Optional: Metrics handler to report metrics to, takes precedence over MetricsScope if both are set.
+		// Use NewPrometheusMetricsHandler to report latencies as Prometheus histograms instead of tally timers:
+		// handler := client.NewPrometheusMetricsHandler(prometheus.DefaultRegisterer, client.PrometheusMetricsHandlerOptions{})
+		// default: no metrics.
+		MetricsHandler MetricsHandler
+
 		// Optional: Sets an identify that can be used to track this host for debugging.
 		// default: default identity that include hostname, groupName and process ID.
 		Identity string
 
 		// Optional: Sets DataConverter to customize serialization/deserialization of arguments in Temporal
-		// default: defaultDataConverter, an combination of thriftEncoder and jsonEncoder
+		// default: DefaultDataConverter, a CompositeDataConverter of []byte and JSON payload converters
 		DataConverter DataConverter
 
-		// Optional: Sets opentracing Tracer that is to be used to emit tracing information
+		// Optional: Sets opentracing Tracer that is to be used to emit tracing information.
+		// For OpenTelemetry use interceptors.NewOpenTelemetryTracing instead.
 		// default: no tracer - opentracing.NoopTracer
 		Tracer opentracing.Tracer
 
@@ -383,6 +412,20 @@ type (
 		// Optional: Sets options for server connection that allow users to control features of connections such as TLS settings.
 		// default: no extra options
 		ConnectionOptions ConnectionOptions
+
+		// Optional: HeadersProvider will be invoked on every outgoing gRPC request and gives user ability to
+		// set custom request headers (e.g. rotating bearer tokens or tenant IDs). It applies to all calls made
+		// by the client, namespace client and workers created from the client, including long polls and heartbeats.
+		// If the provider also implements HeadersRefresher, requests rejected with Unauthenticated error are
+		// retried once after headers are refreshed.
+		// default: no headers provider
+		HeadersProvider HeadersProvider
+
+		// Optional: Sets ClientInterceptorChainFactories that are used to intercept every workflow and activity call
+		// made through the client, such as ExecuteWorkflow, SignalWorkflow, ListWorkflow or CompleteActivity. Interceptors see arguments
+		// before they are encoded and can modify or reject the calls. The first factory creates the outermost link.
+		// default: no interceptors
+		ClientInterceptorChainFactories []ClientInterceptorFactory
 	}
 
 	// StartWorkflowOptions configuration parameters for starting a workflow execution.
@@ -550,7 +593,8 @@ func NewClient(options ClientOptions) (Client, error) {
 		options.Namespace = DefaultNamespace
 	}
 
-	options.MetricsScope = tagScope(options.MetricsScope, tagNamespace, options.Namespace, clientImplHeaderName, clientImplHeaderValue)
+	applyMetricsHandler(&options)
+	options.MetricsHandler = tagMetricsHandler(options.MetricsHandler, tagNamespace, options.Namespace, clientImplHeaderName, clientImplHeaderValue)
 
 	if options.HostPort == "" {
 		options.HostPort = LocalHostPort
@@ -565,11 +609,18 @@ func NewClient(options ClientOptions) (Client, error) {
 	return NewServiceClient(workflowservice.NewWorkflowServiceClient(connection), connection, options), nil
 }
 
+// applyMetricsHandler sets MetricsHandler to a handler reporting to MetricsScope if no handler is set.
+func applyMetricsHandler(options *ClientOptions) {
+	if options.MetricsHandler == nil {
+		options.MetricsHandler = NewTallyMetricsHandler(options.MetricsScope)
+	}
+}
+
 func newDialParameters(options *ClientOptions) dialParameters {
 	return dialParameters{
 		UserOptions:          options.ConnectionOptions,
 		HostPort:             options.HostPort,
-		RequiredInterceptors: requiredInterceptors(options.MetricsScope),
+		RequiredInterceptors: requiredInterceptors(options.MetricsHandler, options.HeadersProvider),
 		DefaultServiceConfig: defaultServiceConfig,
 	}
 }
@@ -585,6 +636,8 @@ func NewServiceClient(workflowServiceClient workflowservice.WorkflowServiceClien
 		options.Identity = getWorkerIdentity("")
 	}
 
+	applyMetricsHandler(&options)
+
 	if options.DataConverter == nil {
 		options.DataConverter = getDefaultDataConverter()
 	}
@@ -595,23 +648,26 @@ func NewServiceClient(workflowServiceClient workflowservice.WorkflowServiceClien
 		options.Tracer = opentracing.NoopTracer{}
 	}
 
-	return &WorkflowClient{
+	client := &WorkflowClient{
 		workflowService:    workflowServiceClient,
 		connectionCloser:   connectionCloser,
 		namespace:          options.Namespace,
 		registry:           newRegistry(),
-		metricsScope:       metrics.NewTaggedScope(options.MetricsScope),
+		metricsHandler:     metrics.NewTaggedHandler(options.MetricsHandler),
 		logger:             options.Logger,
 		identity:           options.Identity,
 		dataConverter:      options.DataConverter,
 		contextPropagators: options.ContextPropagators,
 		tracer:             options.Tracer,
 	}
+	client.interceptor = newClientInterceptors(client, options.ClientInterceptorChainFactories)
+	return client
 }
 
 // NewNamespaceClient creates an instance of a namespace client, to manager lifecycle of namespaces.
 func NewNamespaceClient(options ClientOptions) (NamespaceClient, error) {
-	options.MetricsScope = tagScope(options.MetricsScope, clientImplHeaderName, clientImplHeaderValue)
+	applyMetricsHandler(&options)
+	options.MetricsHandler = tagMetricsHandler(options.MetricsHandler, clientImplHeaderName, clientImplHeaderValue)
 
 	if options.HostPort == "" {
 		options.HostPort = LocalHostPort
@@ -633,7 +689,7 @@ func newNamespaceServiceClient(workflowServiceClient workflowservice.WorkflowSer
 	return &namespaceClient{
 		workflowService:  workflowServiceClient,
 		connectionCloser: clientConn,
-		metricsScope:     options.MetricsScope,
+		metricsHandler:   options.MetricsHandler,
 		logger:           options.Logger,
 		identity:         options.Identity,
 	}
@@ -0,0 +1,447 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const (
+	// ignoreDirective suppresses the report of the code on the same or the next line. In the doc comment of a function
+	// it excludes the whole function from the check.
+	ignoreDirective = "//workflowcheck:ignore"
+
+	sdkPath = "go.temporal.io/temporal"
+)
+
+// Analyzer reports code that is not deterministic in workflow functions.
+var Analyzer = &analysis.Analyzer{
+	Name: "workflowcheck",
+	Doc: `report code that is not deterministic in workflow functions
+
+Workflow functions are the functions registered with RegisterWorkflow and the functions
+that take workflow.Context as the first parameter. The check covers the workflow functions
+and all the functions they call. It reports native go statements, channel operations and
+select statements, iteration over maps, time.Now, time.Sleep and timers, math/rand and
+direct I/O. Add the ` + ignoreDirective + ` comment to the reported line or the line above
+it to suppress the report, or to the doc comment of a function to skip the function.`,
+	Run:       run,
+	FactTypes: []analysis.Fact{new(nondeterministicFact)},
+}
+
+type (
+	// nondeterministicFact is exported for the functions that are not deterministic, so their calls from workflow
+	// functions of other packages are reported.
+	nondeterministicFact struct {
+		Reason string
+	}
+
+	issue struct {
+		pos     token.Pos
+		message string
+	}
+
+	checker struct {
+		pass  *analysis.Pass
+		decls map[*types.Func]*ast.FuncDecl
+		// ignored holds the lines with the ignore directive by file name
+		ignored  map[string]map[int]bool
+		reasons  map[*types.Func]string
+		visiting map[*types.Func]bool
+		reported map[token.Pos]bool
+	}
+)
+
+// timeFuncs are the functions of the time package that are not deterministic and their workflow replacements.
+var timeFuncs = map[string]string{
+	"Now":       "workflow.Now",
+	"Since":     "workflow.Now",
+	"Until":     "workflow.Now",
+	"Sleep":     "workflow.Sleep",
+	"After":     "workflow.NewTimer",
+	"AfterFunc": "workflow.NewTimer",
+	"NewTimer":  "workflow.NewTimer",
+	"NewTicker": "workflow.NewTimer",
+	"Tick":      "workflow.NewTimer",
+}
+
+// randomFuncs are the functions that return random values by package.
+var randomFuncs = map[string][]string{
+	"math/rand": {"ExpFloat64", "Float32", "Float64", "Int", "Int31", "Int31n", "Int63", "Int63n", "Intn",
+		"NormFloat64", "Perm", "Read", "Seed", "Shuffle", "Uint32", "Uint64"},
+	"crypto/rand": {"Int", "Prime", "Read"},
+}
+
+// ioFuncs are the functions that perform I/O by package.
+var ioFuncs = map[string][]string{
+	"os": {"Chdir", "Chmod", "Create", "Environ", "Getenv", "Getwd", "Hostname", "LookupEnv", "Lstat", "Mkdir",
+		"MkdirAll", "Open", "OpenFile", "ReadDir", "ReadFile", "Readlink", "Remove", "RemoveAll", "Rename", "Stat",
+		"WriteFile"},
+	"io/ioutil": {"ReadDir", "ReadFile", "TempDir", "TempFile", "WriteFile"},
+	"os/exec":   {"Command", "CommandContext", "LookPath"},
+	"net":       {"Dial", "DialTimeout", "Listen", "ListenPacket", "LookupAddr", "LookupHost", "LookupIP"},
+	"net/http":  {"Get", "Head", "ListenAndServe", "Post", "PostForm"},
+	"fmt":       {"Print", "Printf", "Println", "Scan", "Scanf", "Scanln"},
+}
+
+func (*nondeterministicFact) AFact() {}
+
+func (f *nondeterministicFact) String() string {
+	return "nondeterministic: " + f.Reason
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	path := pass.Pkg.Path()
+	// the standard library is checked with the lists of functions above and the SDK is trusted
+	if isStandardPackage(path) || isSDKPackage(path) {
+		return nil, nil
+	}
+
+	c := &checker{
+		pass:     pass,
+		decls:    make(map[*types.Func]*ast.FuncDecl),
+		ignored:  make(map[string]map[int]bool),
+		reasons:  make(map[*types.Func]string),
+		visiting: make(map[*types.Func]bool),
+		reported: make(map[token.Pos]bool),
+	}
+	var roots []ast.Node
+	for _, file := range pass.Files {
+		c.collectIgnored(file)
+		for _, decl := range file.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Body != nil {
+				if fn, ok := pass.TypesInfo.Defs[fd.Name].(*types.Func); ok {
+					c.decls[fn] = fd
+				}
+			}
+		}
+	}
+	for _, file := range pass.Files {
+		roots = append(roots, c.findWorkflows(file)...)
+	}
+
+	for fn := range c.decls {
+		if reason := c.reason(fn); reason != "" {
+			pass.ExportObjectFact(fn, &nondeterministicFact{Reason: reason})
+		}
+	}
+
+	visited := make(map[*types.Func]bool)
+	for _, root := range roots {
+		c.check(root, visited)
+	}
+	return nil, nil
+}
+
+// findWorkflows returns the bodies of the workflow functions declared in the file.
+func (c *checker) findWorkflows(file *ast.File) []ast.Node {
+	var roots []ast.Node
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Body != nil && !hasIgnoreDirective(n.Doc) && c.isWorkflowSignature(c.pass.TypesInfo.Defs[n.Name].Type()) {
+				roots = append(roots, n.Body)
+			}
+		case *ast.FuncLit:
+			if c.isWorkflowSignature(c.pass.TypesInfo.TypeOf(n)) {
+				roots = append(roots, n.Body)
+			}
+		case *ast.CallExpr:
+			if len(n.Args) == 0 || !isRegisterWorkflow(typeutil.Callee(c.pass.TypesInfo, n)) {
+				break
+			}
+			switch w := astutil.Unparen(n.Args[0]).(type) {
+			case *ast.FuncLit:
+				roots = append(roots, w.Body)
+			case *ast.Ident, *ast.SelectorExpr:
+				fn := c.funcOf(w)
+				if fn == nil {
+					break
+				}
+				if decl, ok := c.decls[fn]; ok {
+					if !hasIgnoreDirective(decl.Doc) {
+						roots = append(roots, decl.Body)
+					}
+				} else if reason := c.importedReason(fn); reason != "" {
+					c.report(issue{pos: w.Pos(), message: fmt.Sprintf("workflow %s is not deterministic: %s", funcName(fn), reason)})
+				}
+			}
+		}
+		return true
+	})
+	return roots
+}
+
+// check reports the issues of the workflow function body and the functions of the package it calls.
+func (c *checker) check(body ast.Node, visited map[*types.Func]bool) {
+	issues, callees := c.inspect(body)
+	for _, i := range issues {
+		c.report(i)
+	}
+	for _, fn := range callees {
+		if visited[fn] {
+			continue
+		}
+		visited[fn] = true
+		if decl := c.decls[fn]; !hasIgnoreDirective(decl.Doc) {
+			c.check(decl.Body, visited)
+		}
+	}
+}
+
+// reason returns the description of the first nondeterministic operation the function performs directly or through
+// the functions it calls, or empty string if the function is deterministic.
+func (c *checker) reason(fn *types.Func) string {
+	if reason, ok := c.reasons[fn]; ok {
+		return reason
+	}
+	decl := c.decls[fn]
+	if c.visiting[fn] || hasIgnoreDirective(decl.Doc) {
+		return ""
+	}
+	c.visiting[fn] = true
+	defer delete(c.visiting, fn)
+
+	var reason string
+	issues, callees := c.inspect(decl.Body)
+	if len(issues) > 0 {
+		reason = issues[0].message
+	}
+	for _, callee := range callees {
+		if reason != "" {
+			break
+		}
+		reason = c.reason(callee)
+	}
+	c.reasons[fn] = reason
+	return reason
+}
+
+// inspect returns the nondeterministic operations of the function body and the functions of the package it calls.
+func (c *checker) inspect(body ast.Node) ([]issue, []*types.Func) {
+	var issues []issue
+	var callees []*types.Func
+	add := func(pos token.Pos, format string, args ...interface{}) {
+		if !c.isIgnored(pos) {
+			issues = append(issues, issue{pos: pos, message: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.GoStmt:
+			add(n.Pos(), "go statement is not deterministic in workflow code, use workflow.Go")
+		case *ast.SendStmt:
+			add(n.Pos(), "channel send is not deterministic in workflow code, use workflow.Channel")
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				add(n.Pos(), "channel receive is not deterministic in workflow code, use workflow.Channel")
+			}
+		case *ast.SelectStmt:
+			add(n.Pos(), "select statement is not deterministic in workflow code, use workflow.Selector")
+			// the channel operations of the select cases are covered by the select itself
+			for _, clause := range n.Body.List {
+				for _, stmt := range clause.(*ast.CommClause).Body {
+					ast.Inspect(stmt, visit)
+				}
+			}
+			return false
+		case *ast.RangeStmt:
+			switch c.pass.TypesInfo.TypeOf(n.X).Underlying().(type) {
+			case *types.Map:
+				add(n.Pos(), "iteration over map is not deterministic in workflow code, iterate over sorted keys")
+			case *types.Chan:
+				add(n.Pos(), "range over channel is not deterministic in workflow code, use workflow.Channel")
+			}
+		case *ast.CallExpr:
+			fn := typeutil.StaticCallee(c.pass.TypesInfo, n)
+			if fn == nil || fn.Pkg() == nil {
+				break
+			}
+			if fn.Pkg() == c.pass.Pkg {
+				if _, ok := c.decls[fn]; ok && !c.isIgnored(n.Pos()) {
+					callees = append(callees, fn)
+				}
+			} else if message := c.callMessage(fn); message != "" {
+				add(n.Pos(), "%s", message)
+			}
+		}
+		return true
+	}
+	ast.Inspect(body, visit)
+	return issues, callees
+}
+
+// callMessage returns the description of the call of the function declared in another package if the call is not
+// deterministic.
+func (c *checker) callMessage(fn *types.Func) string {
+	path := fn.Pkg().Path()
+	if isStandardPackage(path) {
+		if isMethod(fn) {
+			return ""
+		}
+		if path == "time" {
+			if replacement, ok := timeFuncs[fn.Name()]; ok {
+				return fmt.Sprintf("%s is not deterministic in workflow code, use %s", funcName(fn), replacement)
+			}
+		}
+		if contains(randomFuncs[path], fn.Name()) {
+			return fmt.Sprintf("%s is not deterministic in workflow code, use workflow.SideEffect", funcName(fn))
+		}
+		if contains(ioFuncs[path], fn.Name()) {
+			if path == "fmt" {
+				return fmt.Sprintf("%s performs I/O which is not deterministic in workflow code, use workflow.GetLogger", funcName(fn))
+			}
+			return fmt.Sprintf("%s performs I/O which is not deterministic in workflow code, use an activity", funcName(fn))
+		}
+		return ""
+	}
+	if reason := c.importedReason(fn); reason != "" {
+		return fmt.Sprintf("call of %s is not deterministic: %s", funcName(fn), reason)
+	}
+	return ""
+}
+
+func (c *checker) importedReason(fn *types.Func) string {
+	var fact nondeterministicFact
+	if c.pass.ImportObjectFact(fn, &fact) {
+		return fact.Reason
+	}
+	return ""
+}
+
+func (c *checker) report(i issue) {
+	if c.reported[i.pos] {
+		return
+	}
+	c.reported[i.pos] = true
+	c.pass.Reportf(i.pos, "%s", i.message)
+}
+
+// isWorkflowSignature returns true if the first parameter of the function is workflow.Context.
+func (c *checker) isWorkflowSignature(t types.Type) bool {
+	sig, ok := t.(*types.Signature)
+	if !ok || sig.Params().Len() == 0 {
+		return false
+	}
+	named, ok := types.Unalias(sig.Params().At(0).Type()).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	path := named.Obj().Pkg().Path()
+	return named.Obj().Name() == "Context" && (path == sdkPath+"/workflow" || path == sdkPath+"/internal")
+}
+
+func (c *checker) funcOf(expr ast.Expr) *types.Func {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		fn, _ := c.pass.TypesInfo.Uses[expr].(*types.Func)
+		return fn
+	case *ast.SelectorExpr:
+		fn, _ := c.pass.TypesInfo.Uses[expr.Sel].(*types.Func)
+		return fn
+	}
+	return nil
+}
+
+func (c *checker) collectIgnored(file *ast.File) {
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if strings.HasPrefix(comment.Text, ignoreDirective) {
+				position := c.pass.Fset.Position(comment.Pos())
+				if c.ignored[position.Filename] == nil {
+					c.ignored[position.Filename] = make(map[int]bool)
+				}
+				c.ignored[position.Filename][position.Line] = true
+			}
+		}
+	}
+}
+
+func (c *checker) isIgnored(pos token.Pos) bool {
+	position := c.pass.Fset.Position(pos)
+	lines := c.ignored[position.Filename]
+	return lines[position.Line] || lines[position.Line-1]
+}
+
+func hasIgnoreDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, comment := range doc.List {
+		if strings.HasPrefix(comment.Text, ignoreDirective) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRegisterWorkflow returns true for the RegisterWorkflow methods of the SDK worker, replayer and test environment.
+func isRegisterWorkflow(obj types.Object) bool {
+	fn, ok := obj.(*types.Func)
+	if !ok || fn.Pkg() == nil || !isSDKPackage(fn.Pkg().Path()) {
+		return false
+	}
+	return fn.Name() == "RegisterWorkflow" || fn.Name() == "RegisterWorkflowWithOptions"
+}
+
+func isSDKPackage(path string) bool {
+	return path == sdkPath || strings.HasPrefix(path, sdkPath+"/")
+}
+
+// isStandardPackage returns true for the packages of the standard library, their paths have no dot in the first
+// element.
+func isStandardPackage(path string) bool {
+	return !strings.Contains(strings.SplitN(path, "/", 2)[0], ".")
+}
+
+func isMethod(fn *types.Func) bool {
+	return fn.Type().(*types.Signature).Recv() != nil
+}
+
+func funcName(fn *types.Func) string {
+	if isMethod(fn) {
+		return fn.FullName()
+	}
+	return fn.Pkg().Path() + "." + fn.Name()
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package a
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"time"
+
+	"go.temporal.io/temporal/worker"
+	"go.temporal.io/temporal/workflow"
+
+	"example.com/lib"
+)
+
+func Workflow(ctx workflow.Context) error { // want Workflow:"nondeterministic: go statement"
+	go func() {}() // want "go statement is not deterministic in workflow code, use workflow.Go"
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		time.Sleep(time.Second) // want "time.Sleep is not deterministic in workflow code, use workflow.Sleep"
+	})
+	_ = workflow.Now(ctx)
+	_ = time.Now()                 // want "time.Now is not deterministic in workflow code, use workflow.Now"
+	_ = rand.Intn(10)              // want "math/rand.Intn is not deterministic in workflow code, use workflow.SideEffect"
+	_, _ = ioutil.ReadFile("file") // want "io/ioutil.ReadFile performs I/O which is not deterministic in workflow code, use an activity"
+	_ = time.Duration(10) * time.Second
+
+	ch := make(chan int, 1)
+	ch <- 1        // want "channel send is not deterministic"
+	<-ch           // want "channel receive is not deterministic"
+	for range ch { // want "range over channel is not deterministic"
+	}
+	select { // want "select statement is not deterministic in workflow code, use workflow.Selector"
+	case v := <-ch:
+		_ = v
+	default:
+	}
+
+	m := map[string]int{}
+	for k := range m { // want "iteration over map is not deterministic in workflow code, iterate over sorted keys"
+		_ = k
+	}
+	_ = sortedKeys(m)
+	_ = lib.Add(1, 2)
+	_ = lib.Timestamp() // want "call of example.com/lib.Timestamp is not deterministic: time.Now is not deterministic"
+	_ = lib.Debug()
+	_ = time.Now() //workflowcheck:ignore
+	//workflowcheck:ignore
+	_ = time.Now()
+	return helper()
+}
+
+func sortedKeys(m map[string]int) []string {
+	var keys []string
+	//workflowcheck:ignore the keys are sorted
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func helper() error { // want helper:"nondeterministic: time.Sleep is not deterministic"
+	time.Sleep(lib.Jitter()) // want "time.Sleep is not deterministic" "call of example.com/lib.Jitter is not deterministic"
+	return nil
+}
+
+func RegisteredWorkflow() error { // want RegisteredWorkflow:"nondeterministic: go statement"
+	go helper() // want "go statement is not deterministic"
+	return nil
+}
+
+func UnregisteredWorkflow(ctx workflow.Context) { // want UnregisteredWorkflow:"nondeterministic: time.Now is not deterministic"
+	_ = time.Now() // want "time.Now is not deterministic in workflow code, use workflow.Now"
+}
+
+func NotWorkflow() { // want NotWorkflow:"nondeterministic: go statement"
+	go func() {}()
+}
+
+//workflowcheck:ignore the workflow is never replayed
+func IgnoredWorkflow(ctx workflow.Context) {
+	_ = time.Now()
+}
+
+func Register(w worker.Worker) { // want Register:"nondeterministic: time.Now is not deterministic"
+	w.RegisterWorkflow(Workflow)
+	w.RegisterWorkflow(RegisteredWorkflow)
+	w.RegisterWorkflow(IgnoredWorkflow)
+	w.RegisterWorkflow(lib.Timestamp) // want "workflow example.com/lib.Timestamp is not deterministic: time.Now is not deterministic"
+	w.RegisterWorkflow(func() {
+		_ = time.Now() // want "time.Now is not deterministic"
+	})
+}
@@ -88,6 +88,12 @@ const (
 	WorkerStartCounter = TemporalMetricsPrefix + "worker_start"
 	PollerStartCounter = TemporalMetricsPrefix + "poller_start"
 
+	WorkerTaskSlotsAvailable  = TemporalMetricsPrefix + "worker_task_slots_available"
+	WorkerTaskSlotsUsed       = TemporalMetricsPrefix + "worker_task_slots_used"
+	WorkerTaskSlotWaitLatency = TemporalMetricsPrefix + "worker_task_slot_wait_latency" // measure time a poller waits for a free slot
+	PollersInFlight           = TemporalMetricsPrefix + "pollers_in_flight"
+	PollersTarget             = TemporalMetricsPrefix + "pollers_target" // number of pollers allowed by poller auto scaling
+
 	TemporalRequest        = TemporalMetricsPrefix + "request"
 	TemporalError          = TemporalMetricsPrefix + "error"
 	TemporalLatency        = TemporalMetricsPrefix + "latency"
@@ -0,0 +1,344 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber-go/tally"
+)
+
+type (
+	// Handler is an abstraction of a metrics backend used by the SDK to emit metrics.
+	// Implementations must be safe for concurrent use.
+	Handler interface {
+		// WithTags returns a handler that attaches the given tags to every metric it creates
+		// in addition to the tags of this handler.
+		WithTags(tags map[string]string) Handler
+
+		// Counter returns a counter with the given name.
+		Counter(name string) Counter
+
+		// Gauge returns a gauge with the given name.
+		Gauge(name string) Gauge
+
+		// Timer returns a timer with the given name. Backends that do not have a native
+		// timer type are expected to report timers as latency histograms.
+		Timer(name string) Timer
+
+		// Histogram returns a histogram with the given name and bucket upper bounds.
+		// Durations are recorded into the histogram in seconds.
+		Histogram(name string, buckets []float64) Histogram
+	}
+
+	// Counter is a monotonically increasing metric.
+	Counter interface {
+		// Inc increments the counter by the delta.
+		Inc(delta int64)
+	}
+
+	// Gauge is a metric that reports the last set value.
+	Gauge interface {
+		// Update sets the gauge value.
+		Update(value float64)
+	}
+
+	// Timer is a metric that records latencies.
+	Timer interface {
+		// Record records the duration.
+		Record(d time.Duration)
+	}
+
+	// Histogram is a metric that counts observed values into buckets.
+	Histogram interface {
+		// RecordValue records the value.
+		RecordValue(value float64)
+		// RecordDuration records the duration in seconds.
+		RecordDuration(d time.Duration)
+	}
+
+	// TaggedHandler provides handlers with tags, caching them by the tags.
+	TaggedHandler struct {
+		Handler
+		*sync.Map
+	}
+
+	tallyHandler struct {
+		scope tally.Scope
+	}
+
+	tallyHistogram struct {
+		histogram tally.Histogram
+	}
+
+	// prefixHandler prepends a prefix to the metric names of handlers that have no notion of sub scopes.
+	prefixHandler struct {
+		handler Handler
+		prefix  string
+	}
+
+	replayAwareHandler struct {
+		isReplay *bool
+		handler  Handler
+	}
+
+	replayAwareHandlerCounter struct {
+		isReplay *bool
+		counter  Counter
+	}
+
+	replayAwareHandlerGauge struct {
+		isReplay *bool
+		gauge    Gauge
+	}
+
+	replayAwareHandlerTimer struct {
+		isReplay *bool
+		timer    Timer
+	}
+
+	replayAwareHandlerHistogram struct {
+		isReplay  *bool
+		histogram Histogram
+	}
+
+	noopHandler struct{}
+
+	// noopMetric implements every metric type and drops the values.
+	noopMetric struct{}
+)
+
+// PrefixSeparator joins the prefix and the metric name of handlers created by WithPrefix.
+// An underscore keeps names valid for Prometheus.
+const PrefixSeparator = "_"
+
+// NoopHandler is a handler that drops all metrics.
+var NoopHandler Handler = noopHandler{}
+
+var _ Handler = (*tallyHandler)(nil)
+var _ Handler = (*prefixHandler)(nil)
+var _ Handler = (*replayAwareHandler)(nil)
+
+// NewTallyHandler creates a Handler that reports metrics to the tally scope.
+func NewTallyHandler(scope tally.Scope) Handler {
+	if scope == nil {
+		scope = tally.NoopScope
+	}
+	return &tallyHandler{scope: scope}
+}
+
+// TallyScope returns the tally scope the handler reports to if it was created by NewTallyHandler.
+func TallyScope(handler Handler) (tally.Scope, bool) {
+	if h, ok := handler.(*tallyHandler); ok {
+		return h.scope, true
+	}
+	return nil, false
+}
+
+// WithPrefix returns a handler that prepends the prefix to the names of the metrics it creates.
+// Handlers created by NewTallyHandler use a sub scope, so the name is joined with the separator of the scope.
+func WithPrefix(handler Handler, prefix string) Handler {
+	if h, ok := handler.(*tallyHandler); ok {
+		return &tallyHandler{scope: h.scope.SubScope(prefix)}
+	}
+	return &prefixHandler{handler: handler, prefix: prefix}
+}
+
+// NewReplayAwareHandler wraps a handler and skips recording metrics when isReplay is true.
+// This is designed to be used by only by workflowEnvironmentImpl so we suppress metrics while replaying history events.
+// Parameter isReplay is a pointer to workflowEnvironmentImpl.isReplay which will be updated when replaying history events.
+func NewReplayAwareHandler(isReplay *bool, handler Handler) Handler {
+	return &replayAwareHandler{isReplay: isReplay, handler: handler}
+}
+
+// NewTaggedHandler creates a new TaggedHandler
+func NewTaggedHandler(handler Handler) *TaggedHandler {
+	if handler == nil {
+		handler = NoopHandler
+	}
+	return &TaggedHandler{Handler: handler, Map: &sync.Map{}}
+}
+
+// GetTaggedHandler returns a handler with one or multiple tags,
+// input should be key value pairs like: GetTaggedHandler(tag1, val1, tag2, val2).
+func (th *TaggedHandler) GetTaggedHandler(keyValuePairs ...string) Handler {
+	if len(keyValuePairs)%2 != 0 {
+		panic("GetTaggedHandler key value are not in pairs")
+	}
+	if th.Map == nil {
+		th.Map = &sync.Map{}
+	}
+
+	var key strings.Builder
+	tags := make(map[string]string, len(keyValuePairs)/2)
+	for i := 0; i < len(keyValuePairs); i += 2 {
+		// tag name is part of the key to prevent collision of the same values of different tags
+		key.WriteString(keyValuePairs[i] + ":" + keyValuePairs[i+1] + "-")
+		tags[keyValuePairs[i]] = keyValuePairs[i+1]
+	}
+
+	handler, _ := th.LoadOrStore(key.String(), th.Handler.WithTags(tags))
+	return handler.(Handler)
+}
+
+func (h *tallyHandler) WithTags(tags map[string]string) Handler {
+	return &tallyHandler{scope: h.scope.Tagged(tags)}
+}
+
+func (h *tallyHandler) Counter(name string) Counter {
+	return h.scope.Counter(name)
+}
+
+func (h *tallyHandler) Gauge(name string) Gauge {
+	return h.scope.Gauge(name)
+}
+
+func (h *tallyHandler) Timer(name string) Timer {
+	return h.scope.Timer(name)
+}
+
+func (h *tallyHandler) Histogram(name string, buckets []float64) Histogram {
+	return &tallyHistogram{histogram: h.scope.Histogram(name, tally.ValueBuckets(buckets))}
+}
+
+func (h *tallyHistogram) RecordValue(value float64) {
+	h.histogram.RecordValue(value)
+}
+
+func (h *tallyHistogram) RecordDuration(d time.Duration) {
+	// Buckets are value buckets, so durations are recorded in seconds to match them.
+	h.histogram.RecordValue(d.Seconds())
+}
+
+func (h *prefixHandler) name(name string) string {
+	return h.prefix + PrefixSeparator + name
+}
+
+func (h *prefixHandler) WithTags(tags map[string]string) Handler {
+	return &prefixHandler{handler: h.handler.WithTags(tags), prefix: h.prefix}
+}
+
+func (h *prefixHandler) Counter(name string) Counter {
+	return h.handler.Counter(h.name(name))
+}
+
+func (h *prefixHandler) Gauge(name string) Gauge {
+	return h.handler.Gauge(h.name(name))
+}
+
+func (h *prefixHandler) Timer(name string) Timer {
+	return h.handler.Timer(h.name(name))
+}
+
+func (h *prefixHandler) Histogram(name string, buckets []float64) Histogram {
+	return h.handler.Histogram(h.name(name), buckets)
+}
+
+func (h *replayAwareHandler) WithTags(tags map[string]string) Handler {
+	return &replayAwareHandler{isReplay: h.isReplay, handler: h.handler.WithTags(tags)}
+}
+
+func (h *replayAwareHandler) Counter(name string) Counter {
+	return &replayAwareHandlerCounter{isReplay: h.isReplay, counter: h.handler.Counter(name)}
+}
+
+func (h *replayAwareHandler) Gauge(name string) Gauge {
+	return &replayAwareHandlerGauge{isReplay: h.isReplay, gauge: h.handler.Gauge(name)}
+}
+
+func (h *replayAwareHandler) Timer(name string) Timer {
+	return &replayAwareHandlerTimer{isReplay: h.isReplay, timer: h.handler.Timer(name)}
+}
+
+func (h *replayAwareHandler) Histogram(name string, buckets []float64) Histogram {
+	return &replayAwareHandlerHistogram{isReplay: h.isReplay, histogram: h.handler.Histogram(name, buckets)}
+}
+
+func (c *replayAwareHandlerCounter) Inc(delta int64) {
+	if *c.isReplay {
+		return
+	}
+	c.counter.Inc(delta)
+}
+
+func (g *replayAwareHandlerGauge) Update(value float64) {
+	if *g.isReplay {
+		return
+	}
+	g.gauge.Update(value)
+}
+
+func (t *replayAwareHandlerTimer) Record(d time.Duration) {
+	if *t.isReplay {
+		return
+	}
+	t.timer.Record(d)
+}
+
+func (h *replayAwareHandlerHistogram) RecordValue(value float64) {
+	if *h.isReplay {
+		return
+	}
+	h.histogram.RecordValue(value)
+}
+
+func (h *replayAwareHandlerHistogram) RecordDuration(d time.Duration) {
+	if *h.isReplay {
+		return
+	}
+	h.histogram.RecordDuration(d)
+}
+
+func (noopHandler) WithTags(map[string]string) Handler {
+	return NoopHandler
+}
+
+func (noopHandler) Counter(string) Counter {
+	return noopMetric{}
+}
+
+func (noopHandler) Gauge(string) Gauge {
+	return noopMetric{}
+}
+
+func (noopHandler) Timer(string) Timer {
+	return noopMetric{}
+}
+
+func (noopHandler) Histogram(string, []float64) Histogram {
+	return noopMetric{}
+}
+
+func (noopMetric) Inc(int64) {}
+
+func (noopMetric) Update(float64) {}
+
+func (noopMetric) Record(time.Duration) {}
+
+func (noopMetric) RecordValue(float64) {}
+
+func (noopMetric) RecordDuration(time.Duration) {}
@@ -0,0 +1,88 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func Test_ReplayAwareHandler(t *testing.T) {
+	t.Parallel()
+	scope := tally.NewTestScope("", nil)
+	isReplay := true
+	handler := NewReplayAwareHandler(&isReplay, NewTallyHandler(scope)).WithTags(map[string]string{"tag": "value"})
+	emit := func() {
+		handler.Counter("counter").Inc(3)
+		handler.Gauge("gauge").Update(3)
+		handler.Timer("timer").Record(time.Second)
+		handler.Histogram("histogram", []float64{1, 10}).RecordValue(5)
+	}
+
+	emit()
+	snapshot := scope.Snapshot()
+	require.Equal(t, int64(0), snapshot.Counters()["counter+tag=value"].Value())
+	require.Equal(t, float64(0), snapshot.Gauges()["gauge+tag=value"].Value())
+	require.Empty(t, snapshot.Timers()["timer+tag=value"].Values())
+	require.Equal(t, int64(0), snapshot.Histograms()["histogram+tag=value"].Values()[10])
+
+	isReplay = false
+	emit()
+	snapshot = scope.Snapshot()
+	require.Equal(t, int64(3), snapshot.Counters()["counter+tag=value"].Value())
+	require.Equal(t, float64(3), snapshot.Gauges()["gauge+tag=value"].Value())
+	require.Equal(t, []time.Duration{time.Second}, snapshot.Timers()["timer+tag=value"].Values())
+	require.Equal(t, int64(1), snapshot.Histograms()["histogram+tag=value"].Values()[10])
+}
+
+func Test_WithPrefix(t *testing.T) {
+	t.Parallel()
+	scope := tally.NewTestScope("", nil)
+	tallyHandler := NewTallyHandler(scope)
+	WithPrefix(tallyHandler, "prefix").Counter("counter").Inc(1)
+	require.Contains(t, scope.Snapshot().Counters(), "prefix"+tally.DefaultSeparator+"counter+")
+
+	// handlers other than tally have no separator of their own
+	WithPrefix(&replayAwareHandler{isReplay: new(bool), handler: tallyHandler}, "prefix").Counter("counter").Inc(1)
+	require.Contains(t, scope.Snapshot().Counters(), "prefix"+PrefixSeparator+"counter+")
+}
+
+func Test_TaggedHandler(t *testing.T) {
+	t.Parallel()
+	scope := tally.NewTestScope("", nil)
+	tagged := NewTaggedHandler(NewTallyHandler(scope))
+	handler := tagged.GetTaggedHandler("tag1", "value1", "tag2", "value2")
+	require.Same(t, handler, tagged.GetTaggedHandler("tag1", "value1", "tag2", "value2"))
+	require.NotSame(t, handler, tagged.GetTaggedHandler("tag1", "value2", "tag2", "value1"))
+
+	handler.Counter("counter").Inc(1)
+	require.Contains(t, scope.Snapshot().Counters(), "counter+tag1=value1,tag2=value2")
+	require.Panics(t, func() {
+		tagged.GetTaggedHandler("tag1")
+	})
+}
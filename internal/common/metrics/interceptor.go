@@ -30,52 +30,51 @@ import (
 	"sync"
 	"time"
 
-	"github.com/uber-go/tally"
 	"google.golang.org/grpc"
 )
 
 type (
-	scopeInterceptor struct {
-		scope       tally.Scope
-		childScopes map[string]tally.Scope
-		mutex       sync.Mutex
+	handlerInterceptor struct {
+		handler       Handler
+		childHandlers map[string]Handler
+		mutex         sync.Mutex
 	}
 )
 
-// NewScopeInterceptor creates new metrics scope interceptor.
-func NewScopeInterceptor(scope tally.Scope) grpc.UnaryClientInterceptor {
-	scopeInterceptor := &scopeInterceptor{scope: scope, childScopes: make(map[string]tally.Scope)}
+// NewHandlerInterceptor creates new metrics handler interceptor.
+func NewHandlerInterceptor(handler Handler) grpc.UnaryClientInterceptor {
+	handlerInterceptor := &handlerInterceptor{handler: handler, childHandlers: make(map[string]Handler)}
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		scope := scopeInterceptor.getOperationScope(method)
+		scope := handlerInterceptor.getOperationScope(method)
 		err := invoker(ctx, method, req, reply, cc, opts...)
 		scope.handleError(err)
 		return err
 	}
 }
 
-func (w *scopeInterceptor) getOperationScope(method string) *operationScope {
+func (w *handlerInterceptor) getOperationScope(method string) *operationScope {
 	scopeName := w.convertMethodToScope(method)
-	scope := w.getScope(scopeName)
-	scope.Counter(TemporalRequest).Inc(1)
+	handler := w.getHandler(scopeName)
+	handler.Counter(TemporalRequest).Inc(1)
 
-	return &operationScope{scope: scope, startTime: time.Now()}
+	return &operationScope{handler: handler, startTime: time.Now()}
 }
 
-func (w *scopeInterceptor) convertMethodToScope(method string) string {
+func (w *handlerInterceptor) convertMethodToScope(method string) string {
 	// method is something like "/workflowservice.WorkflowService/RegisterNamespace"
 	methodStart := strings.LastIndex(method, "/") + 1
 	return TemporalMetricsPrefix + method[methodStart:]
 }
 
-func (w *scopeInterceptor) getScope(scopeName string) tally.Scope {
-	// TODO: maps are not thread-safe even for read. Should we pre-build child scopes instead of getting lock on every request?
+func (w *handlerInterceptor) getHandler(scopeName string) Handler {
+	// TODO: maps are not thread-safe even for read. Should we pre-build child handlers instead of getting lock on every request?
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
-	scope, ok := w.childScopes[scopeName]
+	handler, ok := w.childHandlers[scopeName]
 	if ok {
-		return scope
+		return handler
 	}
-	scope = w.scope.SubScope(scopeName)
-	w.childScopes[scopeName] = scope
-	return scope
+	handler = WithPrefix(w.handler, scopeName)
+	w.childHandlers[scopeName] = handler
+	return handler
 }
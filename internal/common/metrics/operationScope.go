@@ -27,19 +27,18 @@ package metrics
 import (
 	"time"
 
-	"github.com/uber-go/tally"
 	"go.temporal.io/temporal-proto/serviceerror"
 )
 
 type (
 	operationScope struct {
-		scope     tally.Scope
+		handler   Handler
 		startTime time.Time
 	}
 )
 
 func (s *operationScope) handleError(err error) {
-	s.scope.Timer(TemporalLatency).Record(time.Since(s.startTime))
+	s.handler.Timer(TemporalLatency).Record(time.Since(s.startTime))
 	if err != nil {
 		switch err.(type) {
 		case *serviceerror.NotFound,
@@ -47,9 +46,9 @@ func (s *operationScope) handleError(err error) {
 			*serviceerror.NamespaceAlreadyExists,
 			*serviceerror.WorkflowExecutionAlreadyStarted,
 			*serviceerror.QueryFailed:
-			s.scope.Counter(TemporalInvalidRequest).Inc(1)
+			s.handler.Counter(TemporalInvalidRequest).Inc(1)
 		default:
-			s.scope.Counter(TemporalError).Inc(1)
+			s.handler.Counter(TemporalError).Inc(1)
 		}
 	}
 }
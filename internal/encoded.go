@@ -25,18 +25,20 @@
 package internal
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"reflect"
 
 	commonpb "go.temporal.io/temporal-proto/common/v1"
 )
 
 const (
-	metadataEncoding     = "encoding"
-	metadataEncodingRaw  = "raw"
-	metadataEncodingJSON = "json"
+	metadataEncoding          = "encoding"
+	metadataEncodingRaw       = "raw"
+	metadataEncodingJSON      = "json"
+	metadataEncodingNil       = "binary/null"
+	metadataEncodingProto     = "binary/protobuf"
+	metadataEncodingProtoJSON = "json/protobuf"
+	metadataMessageType       = "messageType"
 )
 
 type (
@@ -77,13 +79,24 @@ type (
 		FromPayloads(input *commonpb.Payloads, valuePtrs ...interface{}) error
 	}
 
-	defaultDataConverter struct {
+	// CompositeDataConverter applies PayloadConverters in specified order.
+	// On encoding, the first converter which supports the value is used and its encoding is written to payload metadata.
+	// On decoding, the converter is selected by the "encoding" payload metadata.
+	CompositeDataConverter struct {
+		payloadConverters map[string]PayloadConverter
+		orderedEncodings  []string
 	}
 )
 
 var (
 	// DefaultDataConverter is default data converter used by Temporal worker.
-	DefaultDataConverter = &defaultDataConverter{}
+	// It passes []byte values as is and encodes everything else, including nil values and protobuf messages, to JSON.
+	// Nil, protobuf and protobuf JSON payload converters are opt-in, because payloads encoded by them
+	// can't be decoded by older workers and clients.
+	DefaultDataConverter = NewCompositeDataConverter(
+		NewByteSlicePayloadConverter(),
+		NewJSONPayloadConverter(),
+	)
 
 	// ErrMetadataIsNotSet is returned when metadata is not set.
 	ErrMetadataIsNotSet = errors.New("metadata is not set")
@@ -97,6 +110,20 @@ var (
 	ErrUnableToDecodeJSON = errors.New("unable to decode JSON")
 	// ErrUnableToSetBytes is returned when unable to set []byte value.
 	ErrUnableToSetBytes = errors.New("unable to set []byte value")
+	// ErrUnableToEncodeProto is returned when unable to encode protobuf message.
+	ErrUnableToEncodeProto = errors.New("unable to encode protobuf message")
+	// ErrUnableToDecodeProto is returned when unable to decode protobuf message.
+	ErrUnableToDecodeProto = errors.New("unable to decode protobuf message")
+	// ErrUnableToEncodeProtoJSON is returned when unable to encode protobuf message to JSON.
+	ErrUnableToEncodeProtoJSON = errors.New("unable to encode protobuf message to JSON")
+	// ErrUnableToDecodeProtoJSON is returned when unable to decode protobuf message from JSON.
+	ErrUnableToDecodeProtoJSON = errors.New("unable to decode protobuf message from JSON")
+	// ErrValuePtrIsNotPointer is returned when value pointer is not a pointer.
+	ErrValuePtrIsNotPointer = errors.New("not a pointer type")
+	// ErrValuePtrIsNotProtoMessage is returned when value pointer doesn't point to protobuf message.
+	ErrValuePtrIsNotProtoMessage = errors.New("not a protobuf message")
+	// ErrUnableToFindConverter is returned when there is no payload converter which supports the value.
+	ErrUnableToFindConverter = errors.New("unable to find converter")
 )
 
 // getDefaultDataConverter return default data converter used by Temporal worker.
@@ -104,7 +131,28 @@ func getDefaultDataConverter() DataConverter {
 	return DefaultDataConverter
 }
 
-func (dc *defaultDataConverter) ToPayloads(values ...interface{}) (*commonpb.Payloads, error) {
+// NewCompositeDataConverter creates new instance of CompositeDataConverter from ordered list of PayloadConverters.
+// Order is important on encoding because the first converter which supports the value is used.
+// If several converters have the same encoding, the last one replaces the others for both encoding and decoding,
+// but it is tried on encoding at the position of the first one.
+func NewCompositeDataConverter(payloadConverters ...PayloadConverter) *CompositeDataConverter {
+	dc := &CompositeDataConverter{
+		payloadConverters: make(map[string]PayloadConverter, len(payloadConverters)),
+	}
+
+	for _, payloadConverter := range payloadConverters {
+		encoding := payloadConverter.Encoding()
+		if _, ok := dc.payloadConverters[encoding]; !ok {
+			dc.orderedEncodings = append(dc.orderedEncodings, encoding)
+		}
+		dc.payloadConverters[encoding] = payloadConverter
+	}
+
+	return dc
+}
+
+// ToPayloads converts a list of values.
+func (dc *CompositeDataConverter) ToPayloads(values ...interface{}) (*commonpb.Payloads, error) {
 	if len(values) == 0 {
 		return nil, nil
 	}
@@ -122,7 +170,8 @@ func (dc *defaultDataConverter) ToPayloads(values ...interface{}) (*commonpb.Pay
 	return result, nil
 }
 
-func (dc *defaultDataConverter) FromPayloads(payloads *commonpb.Payloads, valuePtrs ...interface{}) error {
+// FromPayloads converts to a list of values of different types.
+func (dc *CompositeDataConverter) FromPayloads(payloads *commonpb.Payloads, valuePtrs ...interface{}) error {
 	if payloads == nil {
 		return nil
 	}
@@ -141,32 +190,24 @@ func (dc *defaultDataConverter) FromPayloads(payloads *commonpb.Payloads, valueP
 	return nil
 }
 
-func (dc *defaultDataConverter) ToPayload(value interface{}) (*commonpb.Payload, error) {
-	var payload *commonpb.Payload
-	if bytes, isByteSlice := value.([]byte); isByteSlice {
-		payload = &commonpb.Payload{
-			Metadata: map[string][]byte{
-				metadataEncoding: []byte(metadataEncodingRaw),
-			},
-			Data: bytes,
-		}
-	} else {
-		data, err := json.Marshal(value)
+// ToPayload converts single value to payload using the first payload converter which supports it.
+func (dc *CompositeDataConverter) ToPayload(value interface{}) (*commonpb.Payload, error) {
+	for _, encoding := range dc.orderedEncodings {
+		payloadConverter := dc.payloadConverters[encoding]
+		payload, err := payloadConverter.ToPayload(value)
 		if err != nil {
-			return nil, fmt.Errorf("%w: %v", ErrUnableToEncodeJSON, err)
+			return nil, err
 		}
-		payload = &commonpb.Payload{
-			Metadata: map[string][]byte{
-				metadataEncoding: []byte(metadataEncodingJSON),
-			},
-			Data: data,
+		if payload != nil {
+			return payload, nil
 		}
 	}
 
-	return payload, nil
+	return nil, fmt.Errorf("value: %v of type: %T: %w", value, value, ErrUnableToFindConverter)
 }
 
-func (dc *defaultDataConverter) FromPayload(payload *commonpb.Payload, valuePtr interface{}) error {
+// FromPayload converts single value from payload using payload converter selected by payload encoding.
+func (dc *CompositeDataConverter) FromPayload(payload *commonpb.Payload, valuePtr interface{}) error {
 	if payload == nil {
 		return nil
 	}
@@ -183,21 +224,10 @@ func (dc *defaultDataConverter) FromPayload(payload *commonpb.Payload, valuePtr
 		return ErrEncodingIsNotSet
 	}
 
-	switch encoding {
-	case metadataEncodingRaw:
-		valueBytes := reflect.ValueOf(valuePtr).Elem()
-		if !valueBytes.CanSet() {
-			return ErrUnableToSetBytes
-		}
-		valueBytes.SetBytes(payload.GetData())
-	case metadataEncodingJSON:
-		err := json.Unmarshal(payload.GetData(), valuePtr)
-		if err != nil {
-			return fmt.Errorf("%w: %v", ErrUnableToDecodeJSON, err)
-		}
-	default:
+	payloadConverter, ok := dc.payloadConverters[encoding]
+	if !ok {
 		return fmt.Errorf("encoding %s: %w", encoding, ErrEncodingIsNotSupported)
 	}
 
-	return nil
+	return payloadConverter.FromPayload(payload, valuePtr)
 }
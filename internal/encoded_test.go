@@ -27,9 +27,11 @@ package internal
 import (
 	"bytes"
 	"encoding/gob"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -184,3 +186,160 @@ func TestDecodeArg(t *testing.T) {
 	require.NoError(t, err)
 	require.Error(t, decodeArg(dc, b, &r))
 }
+
+func newProtoDataConverter() DataConverter {
+	return NewCompositeDataConverter(
+		NewNilPayloadConverter(),
+		NewByteSlicePayloadConverter(),
+		NewProtoPayloadConverter(),
+		NewProtoJSONPayloadConverter(),
+		NewJSONPayloadConverter(),
+	)
+}
+
+func TestDefaultDataConverter_Compatibility(t *testing.T) {
+	t.Parallel()
+	dc := getDefaultDataConverter()
+
+	// Payloads must stay decodable by older workers, so everything except []byte is encoded to JSON.
+	execution := &commonpb.WorkflowExecution{WorkflowId: "wid", RunId: "rid"}
+	payload, err := dc.ToPayload(execution)
+	require.NoError(t, err)
+	require.Equal(t, metadataEncodingJSON, string(payload.GetMetadata()[metadataEncoding]))
+	jsonData, err := json.Marshal(execution)
+	require.NoError(t, err)
+	require.Equal(t, jsonData, payload.GetData())
+
+	var result commonpb.WorkflowExecution
+	require.NoError(t, dc.FromPayload(payload, &result))
+	require.Equal(t, *execution, result)
+
+	payload, err = dc.ToPayload(nil)
+	require.NoError(t, err)
+	require.Equal(t, metadataEncodingJSON, string(payload.GetMetadata()[metadataEncoding]))
+	require.Equal(t, "null", string(payload.GetData()))
+
+	payload, err = dc.ToPayload([]byte("data"))
+	require.NoError(t, err)
+	require.Equal(t, metadataEncodingRaw, string(payload.GetMetadata()[metadataEncoding]))
+	require.Equal(t, "data", string(payload.GetData()))
+
+	err = dc.FromPayload(newPayload(nil, NewProtoPayloadConverter()), &result)
+	require.True(t, errors.Is(err, ErrEncodingIsNotSupported))
+}
+
+func TestCompositeDataConverter_SameEncoding(t *testing.T) {
+	t.Parallel()
+	// The last converter replaces the first one, but is tried at the position of the first one.
+	dc := NewCompositeDataConverter(NewJSONPayloadConverter(), &upperCasePayloadConverter{}, &jsonUpperCasePayloadConverter{})
+
+	payload, err := dc.ToPayload("value")
+	require.NoError(t, err)
+	require.Equal(t, metadataEncodingJSON, string(payload.GetMetadata()[metadataEncoding]))
+	require.Equal(t, `"VALUE"`, string(payload.GetData()))
+}
+
+func TestProtoDataConverter_ProtoMessage(t *testing.T) {
+	t.Parallel()
+	dc := newProtoDataConverter()
+
+	execution := &commonpb.WorkflowExecution{WorkflowId: "wid", RunId: "rid"}
+	payload, err := dc.ToPayload(execution)
+	require.NoError(t, err)
+	require.Equal(t, metadataEncodingProto, string(payload.GetMetadata()[metadataEncoding]))
+	require.Equal(t, "temporal.common.v1.WorkflowExecution", string(payload.GetMetadata()[metadataMessageType]))
+
+	var ptrResult *commonpb.WorkflowExecution
+	require.NoError(t, dc.FromPayload(payload, &ptrResult))
+	require.Equal(t, execution, ptrResult)
+
+	var result commonpb.WorkflowExecution
+	require.NoError(t, dc.FromPayload(payload, &result))
+	require.Equal(t, *execution, result)
+
+	var s string
+	require.True(t, errors.Is(dc.FromPayload(payload, &s), ErrValuePtrIsNotProtoMessage))
+}
+
+func TestProtoDataConverter_Nil(t *testing.T) {
+	t.Parallel()
+	dc := newProtoDataConverter()
+
+	var execution *commonpb.WorkflowExecution
+	payload, err := dc.ToPayload(execution)
+	require.NoError(t, err)
+	require.Equal(t, metadataEncodingNil, string(payload.GetMetadata()[metadataEncoding]))
+
+	result := &commonpb.WorkflowExecution{WorkflowId: "wid"}
+	require.NoError(t, dc.FromPayload(payload, &result))
+	require.Nil(t, result)
+}
+
+func TestProtoJSONPayloadConverter(t *testing.T) {
+	t.Parallel()
+	dc := NewCompositeDataConverter(NewProtoJSONPayloadConverter(), NewProtoPayloadConverter(), NewJSONPayloadConverter())
+
+	execution := &commonpb.WorkflowExecution{WorkflowId: "wid", RunId: "rid"}
+	payload, err := dc.ToPayload(execution)
+	require.NoError(t, err)
+	require.Equal(t, metadataEncodingProtoJSON, string(payload.GetMetadata()[metadataEncoding]))
+	require.JSONEq(t, `{"workflowId":"wid","runId":"rid"}`, string(payload.GetData()))
+
+	var result commonpb.WorkflowExecution
+	require.NoError(t, dc.FromPayload(payload, &result))
+	require.Equal(t, *execution, result)
+}
+
+// upperCasePayloadConverter is a custom payload converter used to test registration of user encodings.
+type upperCasePayloadConverter struct{}
+
+func (c *upperCasePayloadConverter) ToPayload(value interface{}) (*commonpb.Payload, error) {
+	if s, ok := value.(string); ok {
+		return newPayload([]byte(strings.ToUpper(s)), c), nil
+	}
+	return nil, nil
+}
+
+func (c *upperCasePayloadConverter) FromPayload(payload *commonpb.Payload, valuePtr interface{}) error {
+	*valuePtr.(*string) = strings.ToLower(string(payload.GetData()))
+	return nil
+}
+
+func (c *upperCasePayloadConverter) Encoding() string {
+	return "text/uppercase"
+}
+
+// jsonUpperCasePayloadConverter replaces JSONPayloadConverter and upper cases strings.
+type jsonUpperCasePayloadConverter struct {
+	JSONPayloadConverter
+}
+
+func (c *jsonUpperCasePayloadConverter) ToPayload(value interface{}) (*commonpb.Payload, error) {
+	if s, ok := value.(string); ok {
+		value = strings.ToUpper(s)
+	}
+	return c.JSONPayloadConverter.ToPayload(value)
+}
+
+func TestCompositeDataConverter_CustomEncoding(t *testing.T) {
+	t.Parallel()
+	dc := NewCompositeDataConverter(&upperCasePayloadConverter{}, NewJSONPayloadConverter())
+
+	payloads, err := dc.ToPayloads("value", 42)
+	require.NoError(t, err)
+	require.Equal(t, "text/uppercase", string(payloads.Payloads[0].GetMetadata()[metadataEncoding]))
+	require.Equal(t, "VALUE", string(payloads.Payloads[0].GetData()))
+	require.Equal(t, metadataEncodingJSON, string(payloads.Payloads[1].GetMetadata()[metadataEncoding]))
+
+	var s string
+	var i int
+	require.NoError(t, dc.FromPayloads(payloads, &s, &i))
+	require.Equal(t, "value", s)
+	require.Equal(t, 42, i)
+
+	_, err = NewCompositeDataConverter(&upperCasePayloadConverter{}).ToPayload(42)
+	require.True(t, errors.Is(err, ErrUnableToFindConverter))
+
+	err = dc.FromPayload(newPayload(nil, NewProtoPayloadConverter()), &s)
+	require.True(t, errors.Is(err, ErrEncodingIsNotSupported))
+}
@@ -29,10 +29,11 @@ import (
 	"crypto/tls"
 
 	"github.com/gogo/status"
-	"github.com/uber-go/tally"
 	"go.temporal.io/temporal-proto/serviceerror"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 
 	"go.temporal.io/temporal/internal/common/metrics"
 )
@@ -41,6 +42,34 @@ type (
 	// ConnectionOptions is provided by SDK consumers to control optional connection params.
 	ConnectionOptions struct {
 		TLS *tls.Config
+
+		// Optional: Unary client interceptors which are chained after the SDK required interceptors
+		// (metrics and error conversion) for every call made through the connection.
+		// default: no extra interceptors
+		UnaryInterceptors []grpc.UnaryClientInterceptor
+
+		// Optional: Stream client interceptors which are chained for every streaming call made through the connection.
+		// default: no extra interceptors
+		StreamInterceptors []grpc.StreamClientInterceptor
+
+		// Optional: Additional gRPC dial options such as keepalive parameters, max message sizes or custom authority.
+		// They are applied after the SDK options, so they can override them (e.g. security options or service config).
+		// default: no extra dial options
+		DialOptions []grpc.DialOption
+	}
+
+	// HeadersProvider returns a map of gRPC headers (metadata) that should be attached to every request
+	// made to the server, including long polls and heartbeats issued by workers.
+	// It is called for every request, so implementations that talk to an external token service must cache the result.
+	HeadersProvider interface {
+		GetHeaders(ctx context.Context) (map[string]string, error)
+	}
+
+	// HeadersRefresher can optionally be implemented by a HeadersProvider which supports credential rotation.
+	// RefreshHeaders is called when the server rejects a request with Unauthenticated error, after that
+	// headers are requested again from the provider and the request is retried once.
+	HeadersRefresher interface {
+		RefreshHeaders(ctx context.Context) error
 	}
 
 	// dialParameters are passed to GRPCDialer and must be used to create gRPC connection.
@@ -66,15 +95,29 @@ func dial(params dialParameters) (*grpc.ClientConn, error) {
 		grpcSecurityOptions = grpc.WithTransportCredentials(credentials.NewTLS(params.UserOptions.TLS))
 	}
 
-	return grpc.Dial(params.HostPort,
+	unaryInterceptors := make([]grpc.UnaryClientInterceptor, 0, len(params.RequiredInterceptors)+len(params.UserOptions.UnaryInterceptors))
+	unaryInterceptors = append(unaryInterceptors, params.RequiredInterceptors...)
+	unaryInterceptors = append(unaryInterceptors, params.UserOptions.UnaryInterceptors...)
+
+	opts := []grpc.DialOption{
 		grpcSecurityOptions,
-		grpc.WithChainUnaryInterceptor(params.RequiredInterceptors...),
+		grpc.WithChainUnaryInterceptor(unaryInterceptors...),
 		grpc.WithDefaultServiceConfig(params.DefaultServiceConfig),
-	)
+	}
+	if len(params.UserOptions.StreamInterceptors) > 0 {
+		opts = append(opts, grpc.WithChainStreamInterceptor(params.UserOptions.StreamInterceptors...))
+	}
+	opts = append(opts, params.UserOptions.DialOptions...)
+
+	return grpc.Dial(params.HostPort, opts...)
 }
 
-func requiredInterceptors(metricScope tally.Scope) []grpc.UnaryClientInterceptor {
-	return []grpc.UnaryClientInterceptor{metrics.NewScopeInterceptor(metricScope), errorInterceptor}
+func requiredInterceptors(metricsHandler MetricsHandler, headersProvider HeadersProvider) []grpc.UnaryClientInterceptor {
+	interceptors := []grpc.UnaryClientInterceptor{metrics.NewHandlerInterceptor(metricsHandler), errorInterceptor}
+	if headersProvider != nil {
+		interceptors = append(interceptors, headersProviderInterceptor(headersProvider))
+	}
+	return interceptors
 }
 
 func errorInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
@@ -82,3 +125,38 @@ func errorInterceptor(ctx context.Context, method string, req, reply interface{}
 	err = serviceerror.FromStatus(status.Convert(err))
 	return err
 }
+
+// headersProviderInterceptor must be chained after errorInterceptor because it relies on raw gRPC status codes.
+func headersProviderInterceptor(headersProvider HeadersProvider) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctxWithHeaders, err := contextWithProvidedHeaders(ctx, headersProvider)
+		if err != nil {
+			return err
+		}
+		err = invoker(ctxWithHeaders, method, req, reply, cc, opts...)
+		refresher, ok := headersProvider.(HeadersRefresher)
+		if !ok || status.Code(err) != codes.Unauthenticated {
+			return err
+		}
+
+		if refreshErr := refresher.RefreshHeaders(ctx); refreshErr != nil {
+			return err
+		}
+		ctxWithHeaders, err = contextWithProvidedHeaders(ctx, headersProvider)
+		if err != nil {
+			return err
+		}
+		return invoker(ctxWithHeaders, method, req, reply, cc, opts...)
+	}
+}
+
+func contextWithProvidedHeaders(ctx context.Context, headersProvider HeadersProvider) (context.Context, error) {
+	headers, err := headersProvider.GetHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		ctx = metadata.AppendToOutgoingContext(ctx, k, v)
+	}
+	return ctx, nil
+}
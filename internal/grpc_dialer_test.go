@@ -34,6 +34,7 @@ import (
 	"go.temporal.io/temporal-proto/serviceerror"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 )
 
 func TestErrorWrapper_SimpleError(t *testing.T) {
@@ -67,3 +68,87 @@ func TestErrorWrapper_ErrorWithFailure(t *testing.T) {
 	require.Equal("rId", weasErr.RunId)
 	require.Equal("srId", weasErr.StartRequestId)
 }
+
+func TestDial_UserInterceptorsAreChainedAfterRequired(t *testing.T) {
+	require := require.New(t)
+
+	var calls []string
+	recordingInterceptor := func(name string) grpc.UnaryClientInterceptor {
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			calls = append(calls, name)
+			return status.Error(codes.NotFound, "short-circuited by "+name)
+		}
+	}
+	required := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		calls = append(calls, "required")
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	conn, err := dial(dialParameters{
+		HostPort: LocalHostPort,
+		UserOptions: ConnectionOptions{
+			UnaryInterceptors: []grpc.UnaryClientInterceptor{recordingInterceptor("user")},
+			DialOptions:       []grpc.DialOption{grpc.WithAuthority("temporal.test")},
+		},
+		RequiredInterceptors: []grpc.UnaryClientInterceptor{required, errorInterceptor},
+		DefaultServiceConfig: defaultServiceConfig,
+	})
+	require.NoError(err)
+	defer func() { _ = conn.Close() }()
+
+	err = conn.Invoke(context.Background(), "/workflowservice.WorkflowService/DescribeNamespace", nil, nil)
+	require.Equal([]string{"required", "user"}, calls)
+	require.IsType(&serviceerror.NotFound{}, err)
+	require.Equal("short-circuited by user", err.Error())
+}
+
+type testHeadersProvider struct {
+	token     string
+	refreshes int
+}
+
+func (p *testHeadersProvider) GetHeaders(context.Context) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + p.token}, nil
+}
+
+func (p *testHeadersProvider) RefreshHeaders(context.Context) error {
+	p.refreshes++
+	p.token = "fresh"
+	return nil
+}
+
+func TestHeadersProviderInterceptor_SetsHeaders(t *testing.T) {
+	require := require.New(t)
+
+	provider := &testHeadersProvider{token: "fresh"}
+	err := headersProviderInterceptor(provider)(context.Background(), "method", "request", "reply", nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, ok := metadata.FromOutgoingContext(ctx)
+			require.True(ok)
+			require.Equal([]string{"Bearer fresh"}, md.Get("authorization"))
+			return nil
+		})
+
+	require.NoError(err)
+	require.Equal(0, provider.refreshes)
+}
+
+func TestHeadersProviderInterceptor_RefreshesOnUnauthenticated(t *testing.T) {
+	require := require.New(t)
+
+	provider := &testHeadersProvider{token: "expired"}
+	var seenTokens []string
+	err := headersProviderInterceptor(provider)(context.Background(), "method", "request", "reply", nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			seenTokens = append(seenTokens, md.Get("authorization")...)
+			if provider.token == "expired" {
+				return status.Error(codes.Unauthenticated, "token expired")
+			}
+			return nil
+		})
+
+	require.NoError(err)
+	require.Equal(1, provider.refreshes)
+	require.Equal([]string{"Bearer expired", "Bearer fresh"}, seenTokens)
+}
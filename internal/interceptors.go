@@ -25,9 +25,13 @@
 package internal
 
 import (
+	"context"
 	"time"
 
 	"github.com/uber-go/tally"
+	commonpb "go.temporal.io/temporal-proto/common/v1"
+	enumspb "go.temporal.io/temporal-proto/enums/v1"
+	"go.temporal.io/temporal-proto/workflowservice/v1"
 	"go.uber.org/zap"
 )
 
@@ -73,7 +77,25 @@ type WorkflowInterceptor interface {
 	GetLastCompletionResult(ctx Context, d ...interface{}) error
 }
 
+// WorkflowSignalInterceptor is an optional interface a WorkflowInterceptor can implement to intercept delivery of
+// a signal to the workflow before it is put into the signal channel. WorkflowInterceptorBase implements it by
+// forwarding to the next interceptor, so the calls reach every interceptor of the chain that embeds it.
+type WorkflowSignalInterceptor interface {
+	// HandleSignal is called outside of the workflow coroutines, so the interceptor must not block.
+	// Arg is not decoded as its type is known only to the code that receives the signal from the channel.
+	HandleSignal(ctx Context, signalName string, arg *commonpb.Payloads)
+}
+
+// WorkflowUpdateInterceptor is an optional interface a WorkflowInterceptor can implement to intercept
+// workflow.SetUpdateHandler. WorkflowInterceptorBase implements it by forwarding to the next interceptor,
+// so the calls reach every interceptor of the chain that embeds it.
+type WorkflowUpdateInterceptor interface {
+	SetUpdateHandler(ctx Context, updateName string, handler interface{}, validator interface{}) error
+}
+
 var _ WorkflowInterceptor = (*WorkflowInterceptorBase)(nil)
+var _ WorkflowSignalInterceptor = (*WorkflowInterceptorBase)(nil)
+var _ WorkflowUpdateInterceptor = (*WorkflowInterceptorBase)(nil)
 
 // WorkflowInterceptorBase is a helper type that can simplify creation of WorkflowInterceptors
 type WorkflowInterceptorBase struct {
@@ -85,6 +107,12 @@ func (t *WorkflowInterceptorBase) ExecuteWorkflow(ctx Context, workflowType stri
 	return t.Next.ExecuteWorkflow(ctx, workflowType, args...)
 }
 
+// HandleSignal forwards to t.Next if it implements WorkflowSignalInterceptor,
+// otherwise the signal is put into the signal channel directly.
+func (t *WorkflowInterceptorBase) HandleSignal(ctx Context, signalName string, arg *commonpb.Payloads) {
+	handleSignal(ctx, t.Next, signalName, arg)
+}
+
 // ExecuteActivity forwards to t.Next
 func (t *WorkflowInterceptorBase) ExecuteActivity(ctx Context, activityType string, args ...interface{}) Future {
 	return t.Next.ExecuteActivity(ctx, activityType, args...)
@@ -170,6 +198,12 @@ func (t *WorkflowInterceptorBase) SetQueryHandler(ctx Context, queryType string,
 	return t.Next.SetQueryHandler(ctx, queryType, handler)
 }
 
+// SetUpdateHandler forwards to t.Next if it implements WorkflowUpdateInterceptor,
+// otherwise the handler is set directly.
+func (t *WorkflowInterceptorBase) SetUpdateHandler(ctx Context, updateName string, handler interface{}, validator interface{}) error {
+	return interceptSetUpdateHandler(ctx, t.Next, updateName, handler, validator)
+}
+
 // IsReplaying forwards to t.Next
 func (t *WorkflowInterceptorBase) IsReplaying(ctx Context) bool {
 	return t.Next.IsReplaying(ctx)
@@ -184,3 +218,237 @@ func (t *WorkflowInterceptorBase) HasLastCompletionResult(ctx Context) bool {
 func (t *WorkflowInterceptorBase) GetLastCompletionResult(ctx Context, d ...interface{}) error {
 	return t.Next.GetLastCompletionResult(ctx, d...)
 }
+
+// ActivityInterceptorFactory is used to create a single link in the activity interceptor chain
+type ActivityInterceptorFactory interface {
+	// NewInterceptor creates an interceptor instance. The created instance must delegate every call to
+	// the next parameter for activity code function correctly.
+	NewInterceptor(info *ActivityInfo, next ActivityInterceptor) ActivityInterceptor
+}
+
+// ActivityInterceptor is an interface that can be implemented to intercept calls to the activity function
+// as well as calls done by the activity code. It is used for both regular and local activities.
+// Use worker.ActivityInterceptorBase as a base struct for implementations that do not want to implement every method.
+// Interceptor implementation must forward calls to the next in the interceptor chain.
+// Calling activity package functions (i.e. activity.GetInfo) with the same context from inside of the
+// corresponding interceptor method leads to infinite recursion, use Next instead.
+type ActivityInterceptor interface {
+	// ExecuteActivity intercepts activity function invocation. Results contain all values returned by the activity
+	// function including the error, so an interceptor can replace them (i.e. to translate panics to errors).
+	// ActivityType argument is for information purposes only and should not be mutated.
+	ExecuteActivity(ctx context.Context, activityType string, args ...interface{}) []interface{}
+
+	GetActivityInfo(ctx context.Context) ActivityInfo
+	GetActivityLogger(ctx context.Context) *zap.Logger
+	GetActivityMetricsScope(ctx context.Context) tally.Scope
+	RecordActivityHeartbeat(ctx context.Context, details ...interface{})
+}
+
+var _ ActivityInterceptor = (*ActivityInterceptorBase)(nil)
+
+// ActivityInterceptorBase is a helper type that can simplify creation of ActivityInterceptors
+type ActivityInterceptorBase struct {
+	Next ActivityInterceptor
+}
+
+// ExecuteActivity forwards to t.Next
+func (t *ActivityInterceptorBase) ExecuteActivity(ctx context.Context, activityType string, args ...interface{}) []interface{} {
+	return t.Next.ExecuteActivity(ctx, activityType, args...)
+}
+
+// GetActivityInfo forwards to t.Next
+func (t *ActivityInterceptorBase) GetActivityInfo(ctx context.Context) ActivityInfo {
+	return t.Next.GetActivityInfo(ctx)
+}
+
+// GetActivityLogger forwards to t.Next
+func (t *ActivityInterceptorBase) GetActivityLogger(ctx context.Context) *zap.Logger {
+	return t.Next.GetActivityLogger(ctx)
+}
+
+// GetActivityMetricsScope forwards to t.Next
+func (t *ActivityInterceptorBase) GetActivityMetricsScope(ctx context.Context) tally.Scope {
+	return t.Next.GetActivityMetricsScope(ctx)
+}
+
+// RecordActivityHeartbeat forwards to t.Next
+func (t *ActivityInterceptorBase) RecordActivityHeartbeat(ctx context.Context, details ...interface{}) {
+	t.Next.RecordActivityHeartbeat(ctx, details...)
+}
+
+// ClientInterceptorFactory is used to create a single link in the client interceptor chain
+type ClientInterceptorFactory interface {
+	// NewInterceptor creates an interceptor instance. The created instance must delegate every call to
+	// the next parameter for client calls to reach the server.
+	NewInterceptor(next ClientInterceptor) ClientInterceptor
+}
+
+// ClientInterceptor is an interface that can be implemented to intercept calls done through the Client.
+// Interceptor methods receive arguments before they are encoded by the DataConverter, so an implementation
+// can validate, log or replace them. Returning an error without calling Next rejects the call.
+// Use interceptors.ClientInterceptorBase as a base struct for implementations that do not want to implement every method.
+// Interceptor implementation must forward calls to the next in the interceptor chain.
+type ClientInterceptor interface {
+	// ExecuteWorkflow intercepts Client.ExecuteWorkflow. Options and header can be mutated before forwarding.
+	// Header already contains the fields written by the configured ContextPropagators.
+	ExecuteWorkflow(ctx context.Context, options *StartWorkflowOptions, workflowType string, header *commonpb.Header,
+		args ...interface{}) (WorkflowRun, error)
+
+	// StartWorkflow intercepts Client.StartWorkflow. Options and header can be mutated before forwarding.
+	StartWorkflow(ctx context.Context, options *StartWorkflowOptions, workflowType string, header *commonpb.Header,
+		args ...interface{}) (*WorkflowExecution, error)
+
+	GetWorkflow(ctx context.Context, workflowID string, runID string) WorkflowRun
+	GetWorkflowHistory(ctx context.Context, workflowID string, runID string, isLongPoll bool,
+		filterType enumspb.HistoryEventFilterType) HistoryEventIterator
+
+	// SignalWithStartWorkflow intercepts Client.SignalWithStartWorkflow. Options and header can be mutated
+	// before forwarding.
+	SignalWithStartWorkflow(ctx context.Context, workflowID string, signalName string, signalArg interface{},
+		options *StartWorkflowOptions, workflowType string, header *commonpb.Header, workflowArgs ...interface{}) (*WorkflowExecution, error)
+
+	SignalWorkflow(ctx context.Context, workflowID string, runID string, signalName string, arg interface{}) error
+	CancelWorkflow(ctx context.Context, workflowID string, runID string) error
+	TerminateWorkflow(ctx context.Context, workflowID string, runID string, reason string, details ...interface{}) error
+
+	// QueryWorkflow intercepts both Client.QueryWorkflow and Client.QueryWorkflowWithOptions.
+	QueryWorkflow(ctx context.Context, request *QueryWorkflowWithOptionsRequest) (*QueryWorkflowWithOptionsResponse, error)
+
+	CompleteActivity(ctx context.Context, taskToken []byte, result interface{}, err error) error
+	CompleteActivityByID(ctx context.Context, namespace, workflowID, runID, activityID string, result interface{}, err error) error
+	RecordActivityHeartbeat(ctx context.Context, taskToken []byte, details ...interface{}) error
+	RecordActivityHeartbeatByID(ctx context.Context, namespace, workflowID, runID, activityID string, details ...interface{}) error
+
+	ListClosedWorkflow(ctx context.Context, request *workflowservice.ListClosedWorkflowExecutionsRequest) (*workflowservice.ListClosedWorkflowExecutionsResponse, error)
+	ListOpenWorkflow(ctx context.Context, request *workflowservice.ListOpenWorkflowExecutionsRequest) (*workflowservice.ListOpenWorkflowExecutionsResponse, error)
+	ListWorkflow(ctx context.Context, request *workflowservice.ListWorkflowExecutionsRequest) (*workflowservice.ListWorkflowExecutionsResponse, error)
+	ListArchivedWorkflow(ctx context.Context, request *workflowservice.ListArchivedWorkflowExecutionsRequest) (*workflowservice.ListArchivedWorkflowExecutionsResponse, error)
+	ScanWorkflow(ctx context.Context, request *workflowservice.ScanWorkflowExecutionsRequest) (*workflowservice.ScanWorkflowExecutionsResponse, error)
+	CountWorkflow(ctx context.Context, request *workflowservice.CountWorkflowExecutionsRequest) (*workflowservice.CountWorkflowExecutionsResponse, error)
+	GetSearchAttributes(ctx context.Context) (*workflowservice.GetSearchAttributesResponse, error)
+	DescribeWorkflowExecution(ctx context.Context, workflowID, runID string) (*workflowservice.DescribeWorkflowExecutionResponse, error)
+	DescribeTaskList(ctx context.Context, taskList string, taskListType enumspb.TaskListType) (*workflowservice.DescribeTaskListResponse, error)
+}
+
+var _ ClientInterceptor = (*ClientInterceptorBase)(nil)
+
+// ClientInterceptorBase is a helper type that can simplify creation of ClientInterceptors
+type ClientInterceptorBase struct {
+	Next ClientInterceptor
+}
+
+// ExecuteWorkflow forwards to t.Next
+func (t *ClientInterceptorBase) ExecuteWorkflow(ctx context.Context, options *StartWorkflowOptions, workflowType string,
+	header *commonpb.Header, args ...interface{}) (WorkflowRun, error) {
+	return t.Next.ExecuteWorkflow(ctx, options, workflowType, header, args...)
+}
+
+// StartWorkflow forwards to t.Next
+func (t *ClientInterceptorBase) StartWorkflow(ctx context.Context, options *StartWorkflowOptions, workflowType string,
+	header *commonpb.Header, args ...interface{}) (*WorkflowExecution, error) {
+	return t.Next.StartWorkflow(ctx, options, workflowType, header, args...)
+}
+
+// GetWorkflow forwards to t.Next
+func (t *ClientInterceptorBase) GetWorkflow(ctx context.Context, workflowID string, runID string) WorkflowRun {
+	return t.Next.GetWorkflow(ctx, workflowID, runID)
+}
+
+// GetWorkflowHistory forwards to t.Next
+func (t *ClientInterceptorBase) GetWorkflowHistory(ctx context.Context, workflowID string, runID string, isLongPoll bool,
+	filterType enumspb.HistoryEventFilterType) HistoryEventIterator {
+	return t.Next.GetWorkflowHistory(ctx, workflowID, runID, isLongPoll, filterType)
+}
+
+// SignalWithStartWorkflow forwards to t.Next
+func (t *ClientInterceptorBase) SignalWithStartWorkflow(ctx context.Context, workflowID string, signalName string, signalArg interface{},
+	options *StartWorkflowOptions, workflowType string, header *commonpb.Header, workflowArgs ...interface{}) (*WorkflowExecution, error) {
+	return t.Next.SignalWithStartWorkflow(ctx, workflowID, signalName, signalArg, options, workflowType, header, workflowArgs...)
+}
+
+// SignalWorkflow forwards to t.Next
+func (t *ClientInterceptorBase) SignalWorkflow(ctx context.Context, workflowID string, runID string, signalName string, arg interface{}) error {
+	return t.Next.SignalWorkflow(ctx, workflowID, runID, signalName, arg)
+}
+
+// CancelWorkflow forwards to t.Next
+func (t *ClientInterceptorBase) CancelWorkflow(ctx context.Context, workflowID string, runID string) error {
+	return t.Next.CancelWorkflow(ctx, workflowID, runID)
+}
+
+// TerminateWorkflow forwards to t.Next
+func (t *ClientInterceptorBase) TerminateWorkflow(ctx context.Context, workflowID string, runID string, reason string, details ...interface{}) error {
+	return t.Next.TerminateWorkflow(ctx, workflowID, runID, reason, details...)
+}
+
+// QueryWorkflow forwards to t.Next
+func (t *ClientInterceptorBase) QueryWorkflow(ctx context.Context, request *QueryWorkflowWithOptionsRequest) (*QueryWorkflowWithOptionsResponse, error) {
+	return t.Next.QueryWorkflow(ctx, request)
+}
+
+// CompleteActivity forwards to t.Next
+func (t *ClientInterceptorBase) CompleteActivity(ctx context.Context, taskToken []byte, result interface{}, err error) error {
+	return t.Next.CompleteActivity(ctx, taskToken, result, err)
+}
+
+// CompleteActivityByID forwards to t.Next
+func (t *ClientInterceptorBase) CompleteActivityByID(ctx context.Context, namespace, workflowID, runID, activityID string,
+	result interface{}, err error) error {
+	return t.Next.CompleteActivityByID(ctx, namespace, workflowID, runID, activityID, result, err)
+}
+
+// RecordActivityHeartbeat forwards to t.Next
+func (t *ClientInterceptorBase) RecordActivityHeartbeat(ctx context.Context, taskToken []byte, details ...interface{}) error {
+	return t.Next.RecordActivityHeartbeat(ctx, taskToken, details...)
+}
+
+// RecordActivityHeartbeatByID forwards to t.Next
+func (t *ClientInterceptorBase) RecordActivityHeartbeatByID(ctx context.Context, namespace, workflowID, runID, activityID string,
+	details ...interface{}) error {
+	return t.Next.RecordActivityHeartbeatByID(ctx, namespace, workflowID, runID, activityID, details...)
+}
+
+// ListClosedWorkflow forwards to t.Next
+func (t *ClientInterceptorBase) ListClosedWorkflow(ctx context.Context, request *workflowservice.ListClosedWorkflowExecutionsRequest) (*workflowservice.ListClosedWorkflowExecutionsResponse, error) {
+	return t.Next.ListClosedWorkflow(ctx, request)
+}
+
+// ListOpenWorkflow forwards to t.Next
+func (t *ClientInterceptorBase) ListOpenWorkflow(ctx context.Context, request *workflowservice.ListOpenWorkflowExecutionsRequest) (*workflowservice.ListOpenWorkflowExecutionsResponse, error) {
+	return t.Next.ListOpenWorkflow(ctx, request)
+}
+
+// ListWorkflow forwards to t.Next
+func (t *ClientInterceptorBase) ListWorkflow(ctx context.Context, request *workflowservice.ListWorkflowExecutionsRequest) (*workflowservice.ListWorkflowExecutionsResponse, error) {
+	return t.Next.ListWorkflow(ctx, request)
+}
+
+// ListArchivedWorkflow forwards to t.Next
+func (t *ClientInterceptorBase) ListArchivedWorkflow(ctx context.Context, request *workflowservice.ListArchivedWorkflowExecutionsRequest) (*workflowservice.ListArchivedWorkflowExecutionsResponse, error) {
+	return t.Next.ListArchivedWorkflow(ctx, request)
+}
+
+// ScanWorkflow forwards to t.Next
+func (t *ClientInterceptorBase) ScanWorkflow(ctx context.Context, request *workflowservice.ScanWorkflowExecutionsRequest) (*workflowservice.ScanWorkflowExecutionsResponse, error) {
+	return t.Next.ScanWorkflow(ctx, request)
+}
+
+// CountWorkflow forwards to t.Next
+func (t *ClientInterceptorBase) CountWorkflow(ctx context.Context, request *workflowservice.CountWorkflowExecutionsRequest) (*workflowservice.CountWorkflowExecutionsResponse, error) {
+	return t.Next.CountWorkflow(ctx, request)
+}
+
+// GetSearchAttributes forwards to t.Next
+func (t *ClientInterceptorBase) GetSearchAttributes(ctx context.Context) (*workflowservice.GetSearchAttributesResponse, error) {
+	return t.Next.GetSearchAttributes(ctx)
+}
+
+// DescribeWorkflowExecution forwards to t.Next
+func (t *ClientInterceptorBase) DescribeWorkflowExecution(ctx context.Context, workflowID, runID string) (*workflowservice.DescribeWorkflowExecutionResponse, error) {
+	return t.Next.DescribeWorkflowExecution(ctx, workflowID, runID)
+}
+
+// DescribeTaskList forwards to t.Next
+func (t *ClientInterceptorBase) DescribeTaskList(ctx context.Context, taskList string, taskListType enumspb.TaskListType) (*workflowservice.DescribeTaskListResponse, error) {
+	return t.Next.DescribeTaskList(ctx, taskList, taskListType)
+}
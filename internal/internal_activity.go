@@ -34,7 +34,6 @@ import (
 	"time"
 
 	"github.com/opentracing/opentracing-go"
-	"github.com/uber-go/tally"
 	commonpb "go.temporal.io/temporal-proto/common/v1"
 	"go.uber.org/zap"
 )
@@ -97,6 +96,7 @@ type (
 		DataConverter DataConverter
 		Attempt       int32
 		ScheduledTime time.Time
+		Header        *commonpb.Header // written by context propagators, not encoded as it never leaves the worker
 	}
 
 	// AsyncActivityClient for requesting activity execution
@@ -126,7 +126,7 @@ type (
 		activityType       ActivityType
 		serviceInvoker     ServiceInvoker
 		logger             *zap.Logger
-		metricsScope       tally.Scope
+		metricsHandler     MetricsHandler
 		isLocalActivity    bool
 		heartbeatTimeout   time.Duration
 		deadline           time.Time
@@ -141,6 +141,13 @@ type (
 		workerStopChannel  <-chan struct{}
 		contextPropagators []ContextPropagator
 		tracer             opentracing.Tracer
+		interceptors       []ActivityInterceptorFactory
+	}
+
+	// activityEnvironmentInterceptor is the last link in the activity interceptor chain.
+	// It invokes the activity function and implements activity package functions.
+	activityEnvironmentInterceptor struct {
+		executor *activityExecutor
 	}
 
 	// context.WithValue need this type instead of basic type string to avoid lint error
@@ -151,6 +158,7 @@ const (
 	activityEnvContextKey          contextKey = "activityEnv"
 	activityOptionsContextKey      contextKey = "activityOptions"
 	localActivityOptionsContextKey contextKey = "localActivityOptions"
+	activityInterceptorContextKey  contextKey = "activityInterceptor"
 )
 
 func getActivityEnv(ctx context.Context) *activityEnvironment {
@@ -161,6 +169,68 @@ func getActivityEnv(ctx context.Context) *activityEnvironment {
 	return env.(*activityEnvironment)
 }
 
+func getActivityInterceptor(ctx context.Context) ActivityInterceptor {
+	if interceptor, ok := ctx.Value(activityInterceptorContextKey).(ActivityInterceptor); ok {
+		return interceptor
+	}
+	return &activityEnvironmentInterceptor{}
+}
+
+// newActivityInterceptors creates the interceptor chain for a single activity execution and stores it in the context.
+// If the chain already exists in the context (i.e. activity function is wrapped by the test environment)
+// or context is not an activity context, only environment interceptor is returned.
+func newActivityInterceptors(ctx context.Context, executor *activityExecutor) (context.Context, ActivityInterceptor) {
+	envInterceptor := &activityEnvironmentInterceptor{executor: executor}
+	env, ok := ctx.Value(activityEnvContextKey).(*activityEnvironment)
+	if !ok || ctx.Value(activityInterceptorContextKey) != nil {
+		return ctx, envInterceptor
+	}
+
+	var interceptor ActivityInterceptor = envInterceptor
+	if len(env.interceptors) > 0 {
+		info := envInterceptor.GetActivityInfo(ctx)
+		for i := len(env.interceptors) - 1; i >= 0; i-- {
+			interceptor = env.interceptors[i].NewInterceptor(&info, interceptor)
+		}
+	}
+	return context.WithValue(ctx, activityInterceptorContextKey, interceptor), interceptor
+}
+
+func (a *activityEnvironmentInterceptor) ExecuteActivity(ctx context.Context, _ string, args ...interface{}) []interface{} {
+	retValues := a.executor.executeWithActualArgsWithoutParseResult(ctx, args)
+	results := make([]interface{}, 0, len(retValues))
+	for _, r := range retValues {
+		results = append(results, r.Interface())
+	}
+	return results
+}
+
+// activityResultsToValues converts results returned by the interceptor chain back to values of activity function result types.
+func activityResultsToValues(fn interface{}, results []interface{}) ([]reflect.Value, error) {
+	fnType := reflect.TypeOf(fn)
+	if len(results) != fnType.NumOut() {
+		return nil, fmt.Errorf(
+			"activity interceptor returned %d results for function: %v which returns %d results",
+			len(results), getFunctionName(fn), fnType.NumOut())
+	}
+
+	values := make([]reflect.Value, 0, len(results))
+	for i, r := range results {
+		value := reflect.New(fnType.Out(i)).Elem()
+		if r != nil {
+			rValue := reflect.ValueOf(r)
+			if !rValue.Type().AssignableTo(value.Type()) {
+				return nil, fmt.Errorf(
+					"activity interceptor returned result %d of type: %s which is not assignable to type: %s",
+					i, rValue.Type(), value.Type())
+			}
+			value.Set(rValue)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
 func getActivityOptions(ctx Context) *ExecuteActivityOptions {
 	eap := ctx.Value(activityOptionsContextKey)
 	if eap == nil {
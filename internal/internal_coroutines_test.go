@@ -712,6 +712,52 @@ func TestPanic(t *testing.T) {
 	require.Contains(t, panicError.StackTrace(), "temporal/internal.TestPanic")
 }
 
+func TestDeadlockDetection(t *testing.T) {
+	blocked := make(chan struct{})
+	defer close(blocked)
+	d, _ := newDispatcher(createRootTestContext(), func(ctx Context) {
+		GoNamed(ctx, "child", func(ctx Context) {
+			<-blocked // native channel doesn't yield
+		})
+		c := NewNamedChannel(ctx, "forever_blocked")
+		c.Receive(ctx, nil) // blocked forever
+	})
+	d.deadlockDetectionTimeout = 10 * time.Millisecond
+	err := d.ExecuteUntilAllBlocked()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Potential deadlock detected")
+	panicError, ok := err.(*workflowPanicError)
+	require.True(t, ok)
+	stack := panicError.StackTrace()
+	require.Contains(t, stack, "coroutine child [running]:")
+	require.Contains(t, stack, "temporal/internal.TestDeadlockDetection")
+	require.Contains(t, stack, "coroutine 1 [blocked on forever_blocked.Receive]:")
+	d.Close()
+}
+
+func TestDeadlockDetection_AbandonedCoroutineExits(t *testing.T) {
+	blocked := make(chan struct{})
+	exited := make(chan interface{}, 1)
+	d, _ := newDispatcher(createRootTestContext(), func(ctx Context) {
+		defer func() { exited <- recover() }()
+		<-blocked // native channel doesn't yield
+		c := NewNamedChannel(ctx, "abandoned")
+		c.Receive(ctx, nil) // exits instead of blocking or failing
+	})
+	d.deadlockDetectionTimeout = 10 * time.Millisecond
+	err := d.ExecuteUntilAllBlocked()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Potential deadlock detected")
+	d.Close()
+	close(blocked)
+	select {
+	case r := <-exited:
+		require.Nil(t, r)
+	case <-time.After(time.Second):
+		require.Fail(t, "abandoned coroutine didn't exit at its next yield")
+	}
+}
+
 func TestAwait(t *testing.T) {
 	flag := false
 	d, _ := newDispatcher(createRootTestContext(), func(ctx Context) {
@@ -1285,3 +1331,136 @@ func TestChainedFuture(t *testing.T) {
 	require.NoError(t, env.GetWorkflowResult(&out))
 	require.Equal(t, 5, out)
 }
+
+func TestMutex(t *testing.T) {
+	var history []string
+	var c Channel
+	d, _ := newDispatcher(createRootTestContext(), func(ctx Context) {
+		mutex := NewMutex(ctx)
+		c = NewNamedChannel(ctx, "critical")
+		for i := 1; i <= 3; i++ {
+			name := fmt.Sprintf("c%v", i)
+			GoNamed(ctx, name, func(ctx Context) {
+				require.NoError(t, mutex.Lock(ctx))
+				history = append(history, name+"-locked")
+				c.Receive(ctx, nil) // blocking call inside the critical section
+				history = append(history, name+"-unlocked")
+				mutex.Unlock()
+			})
+		}
+	})
+	defer d.Close()
+	requireNoExecuteErr(t, d.ExecuteUntilAllBlocked())
+	require.Equal(t, []string{"c1-locked"}, history)
+	stack := d.StackTrace()
+	require.Contains(t, stack, "coroutine c1 [blocked on critical.Receive]:")
+	require.Contains(t, stack, "coroutine c2 [blocked on mutex-1.Lock]:")
+	require.Contains(t, stack, "coroutine c3 [blocked on mutex-1.Lock]:")
+
+	for i := 0; i < 3; i++ {
+		require.True(t, c.SendAsync(nil))
+		requireNoExecuteErr(t, d.ExecuteUntilAllBlocked())
+	}
+	require.True(t, d.IsDone(), d.StackTrace())
+	expected := []string{
+		"c1-locked",
+		"c1-unlocked",
+		"c2-locked",
+		"c2-unlocked",
+		"c3-locked",
+		"c3-unlocked",
+	}
+	require.EqualValues(t, expected, history)
+}
+
+func TestMutex_Cancel(t *testing.T) {
+	var lockErr error
+	var cancel CancelFunc
+	d, _ := newDispatcher(createRootTestContext(), func(ctx Context) {
+		mutex := NewMutex(ctx)
+		require.True(t, mutex.TryLock())
+		var childCtx Context
+		childCtx, cancel = WithCancel(ctx)
+		Go(childCtx, func(ctx Context) {
+			lockErr = mutex.Lock(ctx)
+		})
+		_ = Await(ctx, func() bool { return lockErr != nil })
+		// The canceled waiter doesn't hold the mutex.
+		mutex.Unlock()
+		require.True(t, mutex.TryLock())
+		mutex.Unlock()
+		require.Panics(t, mutex.Unlock)
+	})
+	defer d.Close()
+	requireNoExecuteErr(t, d.ExecuteUntilAllBlocked())
+	require.False(t, d.IsDone())
+	require.Contains(t, d.StackTrace(), "[blocked on mutex-1.Lock]")
+
+	cancel()
+	requireNoExecuteErr(t, d.ExecuteUntilAllBlocked())
+	require.True(t, d.IsDone(), d.StackTrace())
+	require.Error(t, lockErr)
+	_, ok := lockErr.(*CanceledError)
+	require.True(t, ok, lockErr)
+}
+
+func TestSemaphore(t *testing.T) {
+	var history []string
+	var c Channel
+	d, _ := newDispatcher(createRootTestContext(), func(ctx Context) {
+		semaphore := NewSemaphore(ctx, 3)
+		c = NewNamedChannel(ctx, "work")
+		acquire := func(name string, n int64) {
+			GoNamed(ctx, name, func(ctx Context) {
+				require.NoError(t, semaphore.Acquire(ctx, n))
+				history = append(history, fmt.Sprintf("%v-acquired-%v", name, n))
+				c.Receive(ctx, nil)
+				semaphore.Release(n)
+			})
+		}
+		acquire("c1", 2)
+		acquire("c2", 2)
+		// c3 fits into the remaining permit, but waits behind c2.
+		acquire("c3", 1)
+		require.Panics(t, func() { _ = semaphore.Acquire(ctx, 4) })
+	})
+	defer d.Close()
+	requireNoExecuteErr(t, d.ExecuteUntilAllBlocked())
+	require.Equal(t, []string{"c1-acquired-2"}, history)
+	require.Contains(t, d.StackTrace(), "coroutine c2 [blocked on semaphore-1.Acquire]:")
+	require.Contains(t, d.StackTrace(), "coroutine c3 [blocked on semaphore-1.Acquire]:")
+
+	require.True(t, c.SendAsync(nil))
+	requireNoExecuteErr(t, d.ExecuteUntilAllBlocked())
+	require.Equal(t, []string{"c1-acquired-2", "c2-acquired-2", "c3-acquired-1"}, history)
+
+	require.True(t, c.SendAsync(nil))
+	require.True(t, c.SendAsync(nil))
+	requireNoExecuteErr(t, d.ExecuteUntilAllBlocked())
+	require.True(t, d.IsDone(), d.StackTrace())
+}
+
+func TestSemaphore_InvalidPermits(t *testing.T) {
+	d, _ := newDispatcher(createRootTestContext(), func(ctx Context) {
+		require.PanicsWithValue(t, "NewSemaphore of 0 permits, the number of permits must be positive",
+			func() { NewSemaphore(ctx, 0) })
+		require.Panics(t, func() { NewSemaphore(ctx, -1) })
+
+		semaphore := NewSemaphore(ctx, 2)
+		require.PanicsWithValue(t, "semaphore-1.Acquire of 0 permits, the number of permits must be positive",
+			func() { _ = semaphore.Acquire(ctx, 0) })
+		require.Panics(t, func() { semaphore.TryAcquire(-1) })
+		require.Panics(t, func() { semaphore.Release(0) })
+
+		require.True(t, semaphore.TryAcquire(1))
+		require.PanicsWithValue(t, "semaphore-1.Release of 2 permits exceeds the 1 acquired permits",
+			func() { semaphore.Release(2) })
+		// The failed Release does not change the acquired permits.
+		require.False(t, semaphore.TryAcquire(2))
+		semaphore.Release(1)
+		require.True(t, semaphore.TryAcquire(2))
+	})
+	defer d.Close()
+	requireNoExecuteErr(t, d.ExecuteUntilAllBlocked())
+	require.True(t, d.IsDone(), d.StackTrace())
+}
@@ -830,7 +830,13 @@ func (h *decisionsHelper) getActivityID(event *historypb.HistoryEvent) string {
 
 func (h *decisionsHelper) recordVersionMarker(changeID string, version Version, dc DataConverter) decisionStateMachine {
 	markerID := fmt.Sprintf("%v_%v", versionMarkerName, changeID)
+	decision := h.newMarkerDecisionStateMachine(markerID, newVersionMarkerAttributes(changeID, version, dc))
+	h.addDecision(decision)
+	return decision
+}
 
+// newVersionMarkerAttributes returns the marker recorded by the first GetVersion call for the changeID.
+func newVersionMarkerAttributes(changeID string, version Version, dc DataConverter) *decisionpb.RecordMarkerDecisionAttributes {
 	changeIDPayload, err := dc.ToPayloads(changeID)
 	if err != nil {
 		panic(err)
@@ -841,17 +847,13 @@ func (h *decisionsHelper) recordVersionMarker(changeID string, version Version,
 		panic(err)
 	}
 
-	recordMarker := &decisionpb.RecordMarkerDecisionAttributes{
+	return &decisionpb.RecordMarkerDecisionAttributes{
 		MarkerName: versionMarkerName,
 		Details: map[string]*commonpb.Payloads{
 			versionMarkerChangeIDName: changeIDPayload,
 			versionMarkerDataName:     versionPayload,
 		},
 	}
-
-	decision := h.newMarkerDecisionStateMachine(markerID, recordMarker)
-	h.addDecision(decision)
-	return decision
 }
 
 func (h *decisionsHelper) handleVersionMarker(eventID int64, changeID string) {
@@ -869,21 +871,25 @@ func (h *decisionsHelper) handleVersionMarker(eventID int64, changeID string) {
 
 func (h *decisionsHelper) recordSideEffectMarker(sideEffectID int64, data *commonpb.Payloads, dc DataConverter) decisionStateMachine {
 	markerID := fmt.Sprintf("%v_%v", sideEffectMarkerName, sideEffectID)
+	decision := h.newMarkerDecisionStateMachine(markerID, newSideEffectMarkerAttributes(sideEffectID, data, dc))
+	h.addDecision(decision)
+	return decision
+}
+
+// newSideEffectMarkerAttributes returns the marker recorded for the result of a SideEffect call.
+func newSideEffectMarkerAttributes(sideEffectID int64, data *commonpb.Payloads, dc DataConverter) *decisionpb.RecordMarkerDecisionAttributes {
 	sideEffectIDPayload, err := dc.ToPayloads(sideEffectID)
 	if err != nil {
 		panic(err)
 	}
 
-	attributes := &decisionpb.RecordMarkerDecisionAttributes{
+	return &decisionpb.RecordMarkerDecisionAttributes{
 		MarkerName: sideEffectMarkerName,
 		Details: map[string]*commonpb.Payloads{
 			sideEffectMarkerIDName:   sideEffectIDPayload,
 			sideEffectMarkerDataName: data,
 		},
 	}
-	decision := h.newMarkerDecisionStateMachine(markerID, attributes)
-	h.addDecision(decision)
-	return decision
 }
 
 func (h *decisionsHelper) recordLocalActivityMarker(activityID string, details map[string]*commonpb.Payloads, failure *failurepb.Failure) decisionStateMachine {
@@ -900,22 +906,25 @@ func (h *decisionsHelper) recordLocalActivityMarker(activityID string, details m
 
 func (h *decisionsHelper) recordMutableSideEffectMarker(mutableSideEffectID string, data *commonpb.Payloads, dc DataConverter) decisionStateMachine {
 	markerID := fmt.Sprintf("%v_%v", mutableSideEffectMarkerName, mutableSideEffectID)
+	decision := h.newMarkerDecisionStateMachine(markerID, newMutableSideEffectMarkerAttributes(mutableSideEffectID, data, dc))
+	h.addDecision(decision)
+	return decision
+}
 
+// newMutableSideEffectMarkerAttributes returns the marker recorded when the value of a MutableSideEffect changes.
+func newMutableSideEffectMarkerAttributes(mutableSideEffectID string, data *commonpb.Payloads, dc DataConverter) *decisionpb.RecordMarkerDecisionAttributes {
 	mutableSideEffectIDPayload, err := dc.ToPayloads(mutableSideEffectID)
 	if err != nil {
 		panic(err)
 	}
 
-	attributes := &decisionpb.RecordMarkerDecisionAttributes{
+	return &decisionpb.RecordMarkerDecisionAttributes{
 		MarkerName: mutableSideEffectMarkerName,
 		Details: map[string]*commonpb.Payloads{
 			sideEffectMarkerIDName:   mutableSideEffectIDPayload,
 			sideEffectMarkerDataName: data,
 		},
 	}
-	decision := h.newMarkerDecisionStateMachine(markerID, attributes)
-	h.addDecision(decision)
-	return decision
 }
 
 func (h *decisionsHelper) startChildWorkflowExecution(attributes *decisionpb.StartChildWorkflowExecutionDecisionAttributes) decisionStateMachine {
@@ -121,11 +121,14 @@ type (
 		isReplay              bool // flag to indicate if workflow is in replay mode
 		enableLoggingInReplay bool // flag to indicate if workflow should enable logging in replay mode
 
+		metricsHandler     MetricsHandler
 		metricsScope       tally.Scope
 		registry           *registry
 		dataConverter      DataConverter
 		contextPropagators []ContextPropagator
 		tracer             opentracing.Tracer
+
+		deadlockDetectionTimeout time.Duration
 	}
 
 	localActivityTask struct {
@@ -190,11 +193,12 @@ func newWorkflowExecutionEventHandler(
 	completeHandler completionHandler,
 	logger *zap.Logger,
 	enableLoggingInReplay bool,
-	scope tally.Scope,
+	metricsHandler MetricsHandler,
 	registry *registry,
 	dataConverter DataConverter,
 	contextPropagators []ContextPropagator,
 	tracer opentracing.Tracer,
+	deadlockDetectionTimeout time.Duration,
 ) workflowExecutionEventHandler {
 	context := &workflowEnvironmentImpl{
 		workflowInfo:          workflowInfo,
@@ -211,6 +215,8 @@ func newWorkflowExecutionEventHandler(
 		dataConverter:         dataConverter,
 		contextPropagators:    contextPropagators,
 		tracer:                tracer,
+
+		deadlockDetectionTimeout: deadlockDetectionTimeout,
 	}
 	context.logger = logger.With(
 		zapcore.Field{Key: tagWorkflowType, Type: zapcore.StringType, String: workflowInfo.WorkflowType.Name},
@@ -218,9 +224,10 @@ func newWorkflowExecutionEventHandler(
 		zapcore.Field{Key: tagRunID, Type: zapcore.StringType, String: workflowInfo.WorkflowExecution.RunID},
 	).WithOptions(zap.WrapCore(wrapLogger(&context.isReplay, &context.enableLoggingInReplay)))
 
-	if scope != nil {
-		context.metricsScope = tagScope(metrics.WrapScope(&context.isReplay, scope, context),
-			tagWorkflowType, workflowInfo.WorkflowType.Name)
+	if metricsHandler != nil {
+		metricsHandler = tagMetricsHandler(metricsHandler, tagWorkflowType, workflowInfo.WorkflowType.Name)
+		context.metricsHandler = metrics.NewReplayAwareHandler(&context.isReplay, metricsHandler)
+		context.metricsScope = metrics.WrapScope(&context.isReplay, metricsScopeFromHandler(metricsHandler), context)
 	}
 
 	return &workflowExecutionEventHandlerImpl{context, nil}
@@ -423,6 +430,10 @@ func (wc *workflowEnvironmentImpl) GetMetricsScope() tally.Scope {
 	return wc.metricsScope
 }
 
+func (wc *workflowEnvironmentImpl) GetMetricsHandler() MetricsHandler {
+	return wc.metricsHandler
+}
+
 func (wc *workflowEnvironmentImpl) GetDataConverter() DataConverter {
 	return wc.dataConverter
 }
@@ -685,13 +696,20 @@ func (wc *workflowEnvironmentImpl) MutableSideEffect(id string, f func() interfa
 }
 
 func (wc *workflowEnvironmentImpl) isEqualValue(newValue interface{}, encodedOldValue *commonpb.Payloads, equals func(a, b interface{}) bool) bool {
+	return isEqualValue(newValue, encodedOldValue, equals, wc.GetDataConverter())
+}
+
+func isEqualValue(newValue interface{}, encodedOldValue *commonpb.Payloads, equals func(a, b interface{}) bool, dc DataConverter) bool {
 	if newValue == nil {
 		// new value is nil
-		newEncodedValue := wc.encodeValue(nil)
+		newEncodedValue, err := encodeArg(dc, nil)
+		if err != nil {
+			panic(err)
+		}
 		return proto.Equal(newEncodedValue, encodedOldValue)
 	}
 
-	oldValue := decodeValue(newEncodedValue(encodedOldValue, wc.GetDataConverter()), newValue)
+	oldValue := decodeValue(newEncodedValue(encodedOldValue, dc), newValue)
 	return equals(newValue, oldValue)
 }
 
@@ -747,6 +765,10 @@ func (wc *workflowEnvironmentImpl) GetRegistry() *registry {
 	return wc.registry
 }
 
+func (wc *workflowEnvironmentImpl) GetDeadlockDetectionTimeout() time.Duration {
+	return wc.deadlockDetectionTimeout
+}
+
 func (weh *workflowExecutionEventHandlerImpl) ProcessEvent(
 	event *historypb.HistoryEvent,
 	isReplay bool,
@@ -757,7 +779,7 @@ func (weh *workflowExecutionEventHandlerImpl) ProcessEvent(
 	}
 	defer func() {
 		if p := recover(); p != nil {
-			weh.metricsScope.Counter(metrics.DecisionTaskPanicCounter).Inc(1)
+			weh.metricsHandler.Counter(metrics.DecisionTaskPanicCounter).Inc(1)
 			topLine := fmt.Sprintf("process event for %s [panic]:", weh.workflowInfo.TaskListName)
 			st := getStackTraceRaw(topLine, 7, 0)
 			weh.logger.Error("ProcessEvent panic.",
@@ -1180,8 +1202,6 @@ func (weh *workflowExecutionEventHandlerImpl) handleLocalActivityMarker(details
 }
 
 func (weh *workflowExecutionEventHandlerImpl) ProcessLocalActivityResult(lar *localActivityResult) error {
-	details := make(map[string]*commonpb.Payloads)
-
 	// convert local activity result and error to marker data
 	lamd := localActivityMarkerData{
 		ActivityID:   lar.task.activityID,
@@ -1191,16 +1211,11 @@ func (weh *workflowExecutionEventHandlerImpl) ProcessLocalActivityResult(lar *lo
 	}
 	if lar.err != nil {
 		lamd.Backoff = lar.backoff
-	} else {
-		details[localActivityMarkerResultDetailsName] = lar.result
 	}
-
-	// encode marker data
-	markerData, err := weh.encodeArg(lamd)
+	details, err := encodeLocalActivityMarkerDetails(lamd, lar.result, lar.err, weh.GetDataConverter())
 	if err != nil {
 		return err
 	}
-	details[localActivityMarkerDataDetailsName] = markerData
 
 	// create marker event for local activity result
 	markerEvent := &historypb.HistoryEvent{
@@ -1216,6 +1231,23 @@ func (weh *workflowExecutionEventHandlerImpl) ProcessLocalActivityResult(lar *lo
 	return weh.ProcessEvent(markerEvent, false, false)
 }
 
+// encodeLocalActivityMarkerDetails returns the details of the marker recorded for a local activity result. The result
+// is only recorded when the local activity did not fail.
+func encodeLocalActivityMarkerDetails(lamd localActivityMarkerData, result *commonpb.Payloads, err error, dc DataConverter) (map[string]*commonpb.Payloads, error) {
+	details := make(map[string]*commonpb.Payloads)
+	if err == nil {
+		details[localActivityMarkerResultDetailsName] = result
+	}
+
+	// encode marker data
+	markerData, err := encodeArg(dc, lamd)
+	if err != nil {
+		return nil, err
+	}
+	details[localActivityMarkerDataDetailsName] = markerData
+	return details, nil
+}
+
 func (weh *workflowExecutionEventHandlerImpl) handleWorkflowExecutionSignaled(
 	attributes *historypb.WorkflowExecutionSignaledEventAttributes) {
 	weh.signalHandler(attributes.GetSignalName(), attributes.Input)
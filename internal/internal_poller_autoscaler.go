@@ -0,0 +1,142 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+// All code in this file is private to the package.
+
+import (
+	"sync"
+
+	"go.temporal.io/temporal/internal/common/metrics"
+)
+
+const unknownBacklogCountHint = -1
+
+type (
+	// pollerScalingTask is implemented by tasks returned from taskPoller.PollTask that report the outcome of a poll
+	// to the pollerAutoScaler.
+	pollerScalingTask interface {
+		// isEmpty returns true if the poll completed without a task.
+		isEmpty() bool
+		// getBacklogCountHint returns the approximate number of tasks left in the task list,
+		// or unknownBacklogCountHint if the service doesn't report it.
+		getBacklogCountHint() int64
+	}
+
+	// pollerAutoScaler limits the number of concurrent polls of a baseWorker to a target that moves between
+	// minPollers and maxPollers depending on poll results.
+	pollerAutoScaler struct {
+		sync.Mutex
+		cond           *sync.Cond
+		minPollers     int
+		maxPollers     int
+		targetPollers  int
+		activePollers  int
+		stopped        bool
+		metricsHandler MetricsHandler
+	}
+)
+
+func newPollerAutoScaler(minPollers, maxPollers int, metricsHandler MetricsHandler) *pollerAutoScaler {
+	if maxPollers < 1 {
+		maxPollers = 1
+	}
+	if minPollers < 1 {
+		minPollers = 1
+	}
+	if minPollers > maxPollers {
+		minPollers = maxPollers
+	}
+	s := &pollerAutoScaler{
+		minPollers:     minPollers,
+		maxPollers:     maxPollers,
+		targetPollers:  minPollers,
+		metricsHandler: metricsHandler,
+	}
+	s.cond = sync.NewCond(s)
+	s.metricsHandler.Gauge(metrics.PollersTarget).Update(float64(s.targetPollers))
+	return s
+}
+
+// acquire blocks until the number of active polls is below the target. It returns false if the scaler is stopped.
+func (s *pollerAutoScaler) acquire() bool {
+	s.Lock()
+	defer s.Unlock()
+	for !s.stopped && s.activePollers >= s.targetPollers {
+		s.cond.Wait()
+	}
+	if s.stopped {
+		return false
+	}
+	s.activePollers++
+	return true
+}
+
+// release completes a poll started with acquire and adjusts the target by the poll result. A poll without a task
+// scales pollers down. A poll with a task scales pollers up if there are free slots to process more tasks and
+// the task list may have a backlog, that is the service reported a backlog or doesn't report backlog at all.
+// Polls that failed or were not made leave the target unchanged.
+func (s *pollerAutoScaler) release(task interface{}, err error, hasFreeSlots bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.activePollers--
+	if scalingTask, ok := task.(pollerScalingTask); ok && err == nil {
+		target := s.targetPollers
+		if scalingTask.isEmpty() {
+			target--
+		} else if hasFreeSlots && scalingTask.getBacklogCountHint() != 0 {
+			target++
+		}
+		if target >= s.minPollers && target <= s.maxPollers && target != s.targetPollers {
+			s.targetPollers = target
+			s.metricsHandler.Gauge(metrics.PollersTarget).Update(float64(s.targetPollers))
+		}
+	}
+	s.cond.Broadcast()
+}
+
+// stop unblocks all pollers waiting in acquire.
+func (s *pollerAutoScaler) stop() {
+	s.Lock()
+	s.stopped = true
+	s.Unlock()
+	s.cond.Broadcast()
+}
+
+func (t *workflowTask) isEmpty() bool {
+	return t.task == nil
+}
+
+func (t *workflowTask) getBacklogCountHint() int64 {
+	return t.task.GetBacklogCountHint()
+}
+
+func (t *activityTask) isEmpty() bool {
+	return t.task == nil
+}
+
+func (t *activityTask) getBacklogCountHint() int64 {
+	return unknownBacklogCountHint
+}
@@ -0,0 +1,397 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/golang/mock/gomock"
+	decisionpb "go.temporal.io/temporal-proto/decision/v1"
+	enumspb "go.temporal.io/temporal-proto/enums/v1"
+	historypb "go.temporal.io/temporal-proto/history/v1"
+	"go.temporal.io/temporal-proto/workflowservice/v1"
+	"go.temporal.io/temporal-proto/workflowservicemock/v1"
+	"go.uber.org/zap"
+)
+
+// ReplayOutcome is the outcome of replaying a single workflow history.
+type ReplayOutcome int
+
+const (
+	// ReplayOutcomeOK means the workflow code replayed the history successfully.
+	ReplayOutcomeOK ReplayOutcome = iota
+	// ReplayOutcomeNondeterminism means the decisions made by the workflow code do not match the history.
+	ReplayOutcomeNondeterminism
+	// ReplayOutcomePanic means the workflow code panicked during the replay.
+	ReplayOutcomePanic
+	// ReplayOutcomeResultMismatch means the replay completed the workflow differently than the history did.
+	ReplayOutcomeResultMismatch
+	// ReplayOutcomeError means the history could not be replayed, for example it is corrupted or its workflow type
+	// is not registered.
+	ReplayOutcomeError
+)
+
+type (
+	// ReplayHistory is a workflow history to replay. Name identifies the history in the replay report.
+	ReplayHistory struct {
+		Name    string
+		History *historypb.History
+	}
+
+	// ReplayHistoryIterator provides histories to WorkflowReplayer.ReplayWorkflowHistories.
+	ReplayHistoryIterator interface {
+		// HasNext return whether this iterator has next history
+		HasNext() bool
+		// Next returns the next history. An error stops the replay.
+		Next() (*ReplayHistory, error)
+	}
+
+	// ReplayWorkflowHistoriesOptions configure WorkflowReplayer.ReplayWorkflowHistories.
+	ReplayWorkflowHistoriesOptions struct {
+		// Optional: The number of histories replayed in parallel.
+		// default: runtime.NumCPU()
+		Concurrency int
+	}
+
+	// ReplayResult is the result of replaying a single workflow history.
+	ReplayResult struct {
+		Name    string
+		Outcome ReplayOutcome
+		// Error describes the failure, nil for ReplayOutcomeOK.
+		Error error
+		// Decision is the replay decision that does not match the history. It is set for ReplayOutcomeNondeterminism
+		// unless the decision is missing.
+		Decision *decisionpb.Decision
+		// Event is the history event that does not match the replay decision. It is set for
+		// ReplayOutcomeNondeterminism unless the decision is extra.
+		Event *historypb.HistoryEvent
+		// EventID is the ID of the event at which the replay diverged from the history. It is set for
+		// ReplayOutcomeNondeterminism in the strict replay mode, see WorkflowReplayerOptions.EnableStrictReplay.
+		EventID int64
+	}
+
+	// ReplayReport is the report of WorkflowReplayer.ReplayWorkflowHistories. Results are in the order of the
+	// replayed histories.
+	ReplayReport struct {
+		Results []ReplayResult
+	}
+
+	sliceReplayHistoryIterator struct {
+		histories []*ReplayHistory
+	}
+
+	directoryReplayHistoryIterator struct {
+		files []string
+	}
+
+	listWorkflowReplayHistoryIterator struct {
+		ctx           context.Context
+		client        Client
+		request       *workflowservice.ListWorkflowExecutionsRequest
+		executions    []WorkflowExecution
+		nextPageToken []byte
+		initialized   bool
+		err           error
+	}
+)
+
+// String returns the name of the outcome.
+func (o ReplayOutcome) String() string {
+	switch o {
+	case ReplayOutcomeOK:
+		return "OK"
+	case ReplayOutcomeNondeterminism:
+		return "Nondeterminism"
+	case ReplayOutcomePanic:
+		return "Panic"
+	case ReplayOutcomeResultMismatch:
+		return "ResultMismatch"
+	case ReplayOutcomeError:
+		return "Error"
+	}
+	return fmt.Sprintf("ReplayOutcome(%d)", int(o))
+}
+
+// Failed returns results of the histories that did not replay successfully.
+func (r *ReplayReport) Failed() []ReplayResult {
+	var failed []ReplayResult
+	for _, result := range r.Results {
+		if result.Outcome != ReplayOutcomeOK {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// String summarizes the report listing all the failed histories.
+func (r *ReplayReport) String() string {
+	failed := r.Failed()
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "replayed %d histories, %d failed", len(r.Results), len(failed))
+	for _, result := range failed {
+		_, _ = fmt.Fprintf(&b, "\n%s: %v: %v", result.Name, result.Outcome, result.Error)
+	}
+	return b.String()
+}
+
+// NewReplayHistoryIterator returns an iterator over the given histories.
+func NewReplayHistoryIterator(histories ...*ReplayHistory) ReplayHistoryIterator {
+	return &sliceReplayHistoryIterator{histories: histories}
+}
+
+func (it *sliceReplayHistoryIterator) HasNext() bool {
+	return len(it.histories) > 0
+}
+
+func (it *sliceReplayHistoryIterator) Next() (*ReplayHistory, error) {
+	if len(it.histories) == 0 {
+		return nil, errors.New("no more histories")
+	}
+	history := it.histories[0]
+	it.histories = it.histories[1:]
+	return history, nil
+}
+
+// NewReplayHistoryIteratorFromDirectory returns an iterator over the JSON history files in the given directory sorted
+// by name. Files are loaded one by one as the iterator advances. The name of a history is the name of its file.
+func NewReplayHistoryIteratorFromDirectory(dir string) (ReplayHistoryIterator, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	it := &directoryReplayHistoryIterator{}
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".json") {
+			it.files = append(it.files, filepath.Join(dir, file.Name()))
+		}
+	}
+	sort.Strings(it.files)
+	return it, nil
+}
+
+// WriteHistoryToJSONFile writes the history as the JSON file read by NewReplayHistoryIteratorFromDirectory. The file
+// is written to a temporary file renamed when complete, so readers never see a partially written file.
+func WriteHistoryToJSONFile(history *historypb.History, jsonfileName string) error {
+	marshaler := jsonpb.Marshaler{Indent: "  "}
+	data, err := marshaler.MarshalToString(history)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(jsonfileName), 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(jsonfileName), filepath.Base(jsonfileName)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+	_, err = file.WriteString(data + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), jsonfileName)
+}
+
+func (it *directoryReplayHistoryIterator) HasNext() bool {
+	return len(it.files) > 0
+}
+
+func (it *directoryReplayHistoryIterator) Next() (*ReplayHistory, error) {
+	if len(it.files) == 0 {
+		return nil, errors.New("no more histories")
+	}
+	file := it.files[0]
+	it.files = it.files[1:]
+	history, err := extractHistoryFromFile(file, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load history from %v: %w", file, err)
+	}
+	return &ReplayHistory{Name: filepath.Base(file), History: history}, nil
+}
+
+// NewReplayHistoryIteratorFromListWorkflow returns an iterator over the histories of the workflow executions returned
+// by Client.ListWorkflow for the given request. Histories are loaded through Client.GetWorkflowHistory one by one as
+// the iterator advances. The name of a history is "<workflow ID>/<run ID>".
+func NewReplayHistoryIteratorFromListWorkflow(ctx context.Context, client Client, request *workflowservice.ListWorkflowExecutionsRequest) ReplayHistoryIterator {
+	return &listWorkflowReplayHistoryIterator{ctx: ctx, client: client, request: request}
+}
+
+func (it *listWorkflowReplayHistoryIterator) HasNext() bool {
+	for it.err == nil && len(it.executions) == 0 && (!it.initialized || len(it.nextPageToken) > 0) {
+		it.initialized = true
+		request := *it.request
+		request.NextPageToken = it.nextPageToken
+		response, err := it.client.ListWorkflow(it.ctx, &request)
+		if err != nil {
+			it.err = err
+			break
+		}
+		for _, info := range response.Executions {
+			it.executions = append(it.executions, WorkflowExecution{
+				ID:    info.GetExecution().GetWorkflowId(),
+				RunID: info.GetExecution().GetRunId(),
+			})
+		}
+		it.nextPageToken = response.NextPageToken
+	}
+	return it.err != nil || len(it.executions) > 0
+}
+
+func (it *listWorkflowReplayHistoryIterator) Next() (*ReplayHistory, error) {
+	if !it.HasNext() {
+		return nil, errors.New("no more histories")
+	}
+	if it.err != nil {
+		return nil, it.err
+	}
+	execution := it.executions[0]
+	it.executions = it.executions[1:]
+
+	history := &historypb.History{}
+	events := it.client.GetWorkflowHistory(it.ctx, execution.ID, execution.RunID, false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for events.HasNext() {
+		event, err := events.Next()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load history of %v/%v: %w", execution.ID, execution.RunID, err)
+		}
+		history.Events = append(history.Events, event)
+	}
+	return &ReplayHistory{Name: execution.ID + "/" + execution.RunID, History: history}, nil
+}
+
+// ReplayWorkflowHistories replays every history provided by the iterator and reports the result of each replay.
+// Histories are replayed in parallel. The replay stops on the first iterator error or when ctx is done, and the
+// report of the histories replayed so far is returned together with the error.
+// The logger is an optional parameter. Defaults to the noop logger.
+func (aw *WorkflowReplayer) ReplayWorkflowHistories(ctx context.Context, logger *zap.Logger, iterator ReplayHistoryIterator, options ReplayWorkflowHistoriesOptions) (*ReplayReport, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	report := &ReplayReport{}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	var err error
+	for iterator.HasNext() {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		var history *ReplayHistory
+		if history, err = iterator.Next(); err != nil {
+			break
+		}
+
+		lock.Lock()
+		index := len(report.Results)
+		report.Results = append(report.Results, ReplayResult{Name: history.Name})
+		lock.Unlock()
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			result := aw.replayForReport(logger, history)
+			lock.Lock()
+			report.Results[index] = result
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+	return report, err
+}
+
+func (aw *WorkflowReplayer) replayForReport(logger *zap.Logger, history *ReplayHistory) (result ReplayResult) {
+	result.Name = history.Name
+	defer func() {
+		if p := recover(); p != nil {
+			result.Outcome = ReplayOutcomePanic
+			result.Error = newPanicError(p, getStackTraceRaw("replay [panic]:", 7, 0))
+		}
+	}()
+
+	controller := gomock.NewController(logger.Sugar())
+	service := workflowservicemock.NewMockWorkflowServiceClient(controller)
+	resp, err := aw.processReplayTask(logger, service, ReplayNamespace, history.History)
+	if err != nil {
+		result.Error = err
+		var nonDeterministicErr *nonDeterministicError
+		if errors.As(err, &nonDeterministicErr) {
+			result.Outcome = ReplayOutcomeNondeterminism
+			result.Decision = nonDeterministicErr.decision
+			result.Event = nonDeterministicErr.event
+			result.EventID = nonDeterministicErr.eventID
+		} else if isStateMachineIllegalStatePanic(err) {
+			result.Outcome = ReplayOutcomeNondeterminism
+		} else {
+			result.Outcome = ReplayOutcomeError
+		}
+		return result
+	}
+
+	if failedReq, ok := resp.(*workflowservice.RespondDecisionTaskFailedRequest); ok {
+		result.Outcome = ReplayOutcomePanic
+		result.Error = convertFailureToError(failedReq.GetFailure(), aw.options.DataConverter)
+		return result
+	}
+
+	if err := checkReplayResult(resp, history.History.Events[len(history.History.Events)-1]); err != nil {
+		result.Outcome = ReplayOutcomeResultMismatch
+		result.Error = err
+	}
+	return result
+}
+
+func isStateMachineIllegalStatePanic(err error) bool {
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.value == nil {
+		return false
+	}
+	_, ok := panicErr.value.(stateMachineIllegalStatePanic)
+	return ok
+}
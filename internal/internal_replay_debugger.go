@@ -0,0 +1,202 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	decisionpb "go.temporal.io/temporal-proto/decision/v1"
+	historypb "go.temporal.io/temporal-proto/history/v1"
+)
+
+// ReplayStepType is the type of the replay step passed to ReplayDebugger.
+type ReplayStepType int
+
+const (
+	// ReplayStepEvent is taken after a history event is applied to the replayed workflow. DecisionTaskScheduled events
+	// are not applied to the workflow, so no step is taken for them.
+	ReplayStepEvent ReplayStepType = iota
+	// ReplayStepDecision is taken for every decision the replayed workflow code produces, including the decision that
+	// closes the workflow when the workflow code returns. ReplayStep.Event is the history event that unblocked the
+	// workflow code.
+	ReplayStepDecision
+)
+
+type (
+	// ReplayDebugger is invoked by WorkflowReplayer at every step of the replay, see
+	// WorkflowReplayerOptions.ReplayDebugger. Returning an error stops the replay, and the error is returned by the
+	// replay method.
+	ReplayDebugger func(step *ReplayStep) error
+
+	// ReplayStep is the state of the replayed workflow at a single step of the replay. It is valid only during the
+	// ReplayDebugger call.
+	ReplayStep struct {
+		Type ReplayStepType
+		// Event is the last history event applied to the replayed workflow.
+		Event *historypb.HistoryEvent
+		// Decision is the decision produced by the workflow code. It is set for ReplayStepDecision only.
+		Decision *decisionpb.Decision
+		// PendingDecisions are the decision state machines of the replayed workflow that are not completed yet.
+		PendingDecisions []ReplayDecisionState
+		// StackTrace contains the stack traces of all the workflow coroutines, the same as returned by the
+		// QueryTypeStackTrace query.
+		StackTrace string
+		// WorkflowInfo is the info of the replayed workflow as seen by the workflow code.
+		WorkflowInfo WorkflowInfo
+		// Now is the workflow time as returned by workflow.Now.
+		Now time.Time
+		// IsReplaying is false for the events the workflow code sees for the first time.
+		IsReplaying bool
+		// Versions are the versions of the changes returned by workflow.GetVersion so far.
+		Versions map[string]Version
+
+		eventHandler *workflowExecutionEventHandlerImpl
+	}
+
+	// ReplayDecisionState is the state of a decision state machine of the replayed workflow.
+	ReplayDecisionState struct {
+		// Type is the type of the state machine, like Activity or Timer.
+		Type string
+		// ID is the ID of the state machine, like activity or timer ID.
+		ID string
+		// State is the current state, like Created or Initiated.
+		State string
+		// Decision is the decision the state machine sends in the current state, or nil.
+		Decision *decisionpb.Decision
+	}
+
+	// replayDebugSession invokes ReplayDebugger during the replay of a single history.
+	replayDebugSession struct {
+		debugger ReplayDebugger
+		// reported holds the state in which the decision of a state machine was reported
+		reported map[decisionStateMachine]decisionState
+		// lastEvent is the last event applied to the replayed workflow
+		lastEvent *historypb.HistoryEvent
+	}
+)
+
+// String returns the name of the step type.
+func (t ReplayStepType) String() string {
+	switch t {
+	case ReplayStepEvent:
+		return "Event"
+	case ReplayStepDecision:
+		return "Decision"
+	}
+	return fmt.Sprintf("ReplayStepType(%d)", int(t))
+}
+
+// Query executes the query handler registered by the replayed workflow and returns its result. It can be used to
+// inspect the workflow state at the current step.
+func (s *ReplayStep) Query(queryType string, args ...interface{}) (Value, error) {
+	dc := s.eventHandler.GetDataConverter()
+	input, err := encodeArgs(dc, args)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.eventHandler.ProcessQuery(queryType, input)
+	if err != nil {
+		return nil, err
+	}
+	return newEncodedValue(result, dc), nil
+}
+
+func newReplayDebugSession(debugger ReplayDebugger) *replayDebugSession {
+	if debugger == nil {
+		return nil
+	}
+	return &replayDebugSession{
+		debugger: debugger,
+		reported: make(map[decisionStateMachine]decisionState),
+	}
+}
+
+// onEvent is called after the event is processed by the event handler. It reports the event and then every decision
+// that the event produced.
+func (s *replayDebugSession) onEvent(event *historypb.HistoryEvent, eventHandler *workflowExecutionEventHandlerImpl) error {
+	s.lastEvent = event
+	if err := s.debugger(s.newStep(ReplayStepEvent, event, nil, eventHandler)); err != nil {
+		return err
+	}
+
+	for curr := eventHandler.decisionsHelper.orderedDecisions.Front(); curr != nil; curr = curr.Next() {
+		d := curr.Value.(decisionStateMachine)
+		decision := d.getDecision()
+		if decision == nil {
+			continue
+		}
+		if state, ok := s.reported[d]; ok && state == d.getState() {
+			continue
+		}
+		s.reported[d] = d.getState()
+		if err := s.debugger(s.newStep(ReplayStepDecision, event, decision, eventHandler)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onWorkflowClosed reports the decision that completes, fails, cancels or continues as new the workflow.
+func (s *replayDebugSession) onWorkflowClosed(decision *decisionpb.Decision, eventHandler *workflowExecutionEventHandlerImpl) error {
+	return s.debugger(s.newStep(ReplayStepDecision, s.lastEvent, decision, eventHandler))
+}
+
+func (s *replayDebugSession) newStep(
+	stepType ReplayStepType,
+	event *historypb.HistoryEvent,
+	decision *decisionpb.Decision,
+	eventHandler *workflowExecutionEventHandlerImpl,
+) *ReplayStep {
+	step := &ReplayStep{
+		Type:         stepType,
+		Event:        event,
+		Decision:     decision,
+		WorkflowInfo: *eventHandler.workflowInfo,
+		Now:          eventHandler.Now(),
+		IsReplaying:  eventHandler.IsReplaying(),
+		Versions:     make(map[string]Version, len(eventHandler.changeVersions)),
+		eventHandler: eventHandler,
+	}
+	for changeID, version := range eventHandler.changeVersions {
+		step.Versions[changeID] = version
+	}
+	for curr := eventHandler.decisionsHelper.orderedDecisions.Front(); curr != nil; curr = curr.Next() {
+		d := curr.Value.(decisionStateMachine)
+		if d.isDone() {
+			continue
+		}
+		step.PendingDecisions = append(step.PendingDecisions, ReplayDecisionState{
+			Type:     d.getID().decisionType.String(),
+			ID:       d.getID().id,
+			State:    d.getState().String(),
+			Decision: d.getDecision(),
+		})
+	}
+	if eventHandler.workflowDefinition != nil {
+		step.StackTrace = eventHandler.StackTrace()
+	}
+	return step
+}
@@ -121,19 +121,22 @@ type (
 
 	// workflowTaskHandlerImpl is the implementation of WorkflowTaskHandler
 	workflowTaskHandlerImpl struct {
-		namespace              string
-		metricsScope           *metrics.TaggedScope
-		ppMgr                  pressurePointMgr
-		logger                 *zap.Logger
-		identity               string
-		enableLoggingInReplay  bool
-		disableStickyExecution bool
-		registry               *registry
-		laTunnel               *localActivityTunnel
-		workflowPanicPolicy    WorkflowPanicPolicy
-		dataConverter          DataConverter
-		contextPropagators     []ContextPropagator
-		tracer                 opentracing.Tracer
+		namespace                string
+		metricsHandler           *metrics.TaggedHandler
+		ppMgr                    pressurePointMgr
+		logger                   *zap.Logger
+		identity                 string
+		enableLoggingInReplay    bool
+		disableStickyExecution   bool
+		registry                 *registry
+		laTunnel                 *localActivityTunnel
+		workflowPanicPolicy      WorkflowPanicPolicy
+		dataConverter            DataConverter
+		contextPropagators       []ContextPropagator
+		tracer                   opentracing.Tracer
+		strictReplay             bool
+		replayDebugger           ReplayDebugger
+		deadlockDetectionTimeout time.Duration
 	}
 
 	activityProvider func(name string) activity
@@ -143,7 +146,7 @@ type (
 		taskListName       string
 		identity           string
 		service            workflowservice.WorkflowServiceClient
-		metricsScope       *metrics.TaggedScope
+		metricsHandler     *metrics.TaggedHandler
 		logger             *zap.Logger
 		userContext        context.Context
 		registry           *registry
@@ -152,6 +155,7 @@ type (
 		workerStopCh       <-chan struct{}
 		contextPropagators []ContextPropagator
 		tracer             opentracing.Tracer
+		interceptors       []ActivityInterceptorFactory
 	}
 
 	// history wrapper method to help information about events.
@@ -378,18 +382,21 @@ func isPreloadMarkerEvent(event *historypb.HistoryEvent) bool {
 func newWorkflowTaskHandler(params workerExecutionParameters, ppMgr pressurePointMgr, registry *registry) WorkflowTaskHandler {
 	ensureRequiredParams(&params)
 	return &workflowTaskHandlerImpl{
-		namespace:              params.Namespace,
-		logger:                 params.Logger,
-		ppMgr:                  ppMgr,
-		metricsScope:           metrics.NewTaggedScope(params.MetricsScope),
-		identity:               params.Identity,
-		enableLoggingInReplay:  params.EnableLoggingInReplay,
-		disableStickyExecution: params.DisableStickyExecution,
-		registry:               registry,
-		workflowPanicPolicy:    params.WorkflowPanicPolicy,
-		dataConverter:          params.DataConverter,
-		contextPropagators:     params.ContextPropagators,
-		tracer:                 params.Tracer,
+		namespace:                params.Namespace,
+		logger:                   params.Logger,
+		ppMgr:                    ppMgr,
+		metricsHandler:           metrics.NewTaggedHandler(params.MetricsHandler),
+		identity:                 params.Identity,
+		enableLoggingInReplay:    params.EnableLoggingInReplay,
+		disableStickyExecution:   params.DisableStickyExecution,
+		registry:                 registry,
+		workflowPanicPolicy:      params.WorkflowPanicPolicy,
+		dataConverter:            params.DataConverter,
+		contextPropagators:       params.ContextPropagators,
+		tracer:                   params.Tracer,
+		strictReplay:             params.StrictReplay,
+		replayDebugger:           params.ReplayDebugger,
+		deadlockDetectionTimeout: params.DeadlockDetectionTimeout,
 	}
 }
 
@@ -571,11 +578,12 @@ func (w *workflowExecutionContextImpl) createEventHandler() {
 		w.completeWorkflow,
 		w.wth.logger,
 		w.wth.enableLoggingInReplay,
-		w.wth.metricsScope,
+		w.wth.metricsHandler,
 		w.wth.registry,
 		w.wth.dataConverter,
 		w.wth.contextPropagators,
 		w.wth.tracer,
+		w.wth.deadlockDetectionTimeout,
 	)
 	w.eventHandler.Store(eventHandler)
 }
@@ -641,12 +649,12 @@ func (wth *workflowTaskHandlerImpl) getOrCreateWorkflowContext(
 	task *workflowservice.PollForDecisionTaskResponse,
 	historyIterator HistoryIterator,
 ) (workflowContext *workflowExecutionContextImpl, err error) {
-	metricsScope := wth.metricsScope.GetTaggedScope(tagWorkflowType, task.WorkflowType.GetName())
+	metricsHandler := wth.metricsHandler.GetTaggedHandler(tagWorkflowType, task.WorkflowType.GetName())
 	defer func() {
 		if err == nil && workflowContext != nil && workflowContext.laTunnel == nil {
 			workflowContext.laTunnel = wth.laTunnel
 		}
-		metricsScope.Gauge(metrics.StickyCacheSize).Update(float64(getWorkflowCache().Size()))
+		metricsHandler.Gauge(metrics.StickyCacheSize).Update(float64(getWorkflowCache().Size()))
 	}()
 
 	runID := task.WorkflowExecution.GetRunId()
@@ -663,10 +671,10 @@ func (wth *workflowTaskHandlerImpl) getOrCreateWorkflowContext(
 		workflowContext.Lock()
 		if task.Query != nil && !isFullHistory {
 			// query task and we have a valid cached state
-			metricsScope.Counter(metrics.StickyCacheHit).Inc(1)
+			metricsHandler.Counter(metrics.StickyCacheHit).Inc(1)
 		} else if history.Events[0].GetEventId() == workflowContext.previousStartedEventID+1 {
 			// non query task and we have a valid cached state
-			metricsScope.Counter(metrics.StickyCacheHit).Inc(1)
+			metricsHandler.Counter(metrics.StickyCacheHit).Inc(1)
 		} else {
 			// non query task and cached state is missing events, we need to discard the cached state and rebuild one.
 			_ = workflowContext.ResetIfStale(task, historyIterator)
@@ -675,7 +683,7 @@ func (wth *workflowTaskHandlerImpl) getOrCreateWorkflowContext(
 		if !isFullHistory {
 			// we are getting partial history task, but cached state was already evicted.
 			// we need to reset history so we get events from beginning to replay/rebuild the state
-			metricsScope.Counter(metrics.StickyCacheMiss).Inc(1)
+			metricsHandler.Counter(metrics.StickyCacheMiss).Inc(1)
 			if _, err = resetHistory(task, historyIterator); err != nil {
 				return
 			}
@@ -820,6 +828,11 @@ func (w *workflowExecutionContextImpl) ProcessWorkflowTask(workflowTask *workflo
 	var respondEvents []*historypb.HistoryEvent
 
 	skipReplayCheck := w.skipReplayCheck()
+	// In strict mode decisions of every replayed decision task are matched with the decision events of the next batch.
+	strictReplay := w.wth.strictReplay && !skipReplayCheck
+	var strictDecisions []*decisionpb.Decision
+	var nonDeterministicErr error
+	debugSession := newReplayDebugSession(w.wth.replayDebugger)
 	// Process events
 ProcessEvents:
 	for {
@@ -831,6 +844,12 @@ ProcessEvents:
 		if len(reorderedEvents) == 0 {
 			break ProcessEvents
 		}
+		if strictDecisions != nil {
+			if nonDeterministicErr = matchDecisionTaskWithHistory(strictDecisions, reorderedEvents, false); nonDeterministicErr != nil {
+				break ProcessEvents
+			}
+			strictDecisions = nil
+		}
 		if binaryChecksum == "" {
 			w.workflowInfo.BinaryChecksum = getBinaryChecksum()
 		} else {
@@ -844,6 +863,11 @@ ProcessEvents:
 				if err != nil {
 					return nil, err
 				}
+				if debugSession != nil {
+					if err := debugSession.onEvent(m, eventHandler); err != nil {
+						return nil, err
+					}
+				}
 				if w.isWorkflowCompleted {
 					break ProcessEvents
 				}
@@ -872,6 +896,11 @@ ProcessEvents:
 			if err != nil {
 				return nil, err
 			}
+			if debugSession != nil {
+				if err := debugSession.onEvent(event, eventHandler); err != nil {
+					return nil, err
+				}
+			}
 			if w.isWorkflowCompleted {
 				break ProcessEvents
 			}
@@ -884,6 +913,11 @@ ProcessEvents:
 				if err != nil {
 					return nil, err
 				}
+				if debugSession != nil {
+					if err := debugSession.onEvent(m, eventHandler); err != nil {
+						return nil, err
+					}
+				}
 				if w.isWorkflowCompleted {
 					break ProcessEvents
 				}
@@ -895,6 +929,22 @@ ProcessEvents:
 			if len(eventDecisions) > 0 && !skipReplayCheck {
 				replayDecisions = append(replayDecisions, eventDecisions...)
 			}
+			if strictReplay {
+				strictDecisions = append([]*decisionpb.Decision{}, eventDecisions...)
+			}
+		}
+	}
+
+	var workflowPanicErr *workflowPanicError
+	if strictReplay && nonDeterministicErr == nil && w.isWorkflowCompleted && !errors.As(w.err, &workflowPanicErr) {
+		// decisions of the decision task that completed the workflow are matched with the rest of the history,
+		// the workflow completion itself is not a decision yet. Workflow panic fails the decision task instead.
+		nextEvents, _, _, err := reorderedHistory.NextDecisionEvents()
+		if err != nil {
+			return nil, err
+		}
+		if len(nextEvents) > 0 {
+			nonDeterministicErr = matchDecisionTaskWithHistory(eventHandler.decisionsHelper.getDecisions(false), nextEvents, true)
 		}
 	}
 
@@ -905,8 +955,7 @@ ProcessEvents:
 	// activity task completed), but the corresponding decider code that start the event has been removed. In that case
 	// the replay of that event will panic on the decision state machine and the workflow will be marked as completed
 	// with the panic error.
-	var nonDeterministicErr error
-	if !skipReplayCheck && !w.isWorkflowCompleted {
+	if nonDeterministicErr == nil && !skipReplayCheck && !w.isWorkflowCompleted {
 		// check if decisions from reply matches to the history events
 		if err := matchReplayWithHistory(replayDecisions, respondEvents); err != nil {
 			nonDeterministicErr = err
@@ -921,7 +970,7 @@ ProcessEvents:
 	}
 
 	if nonDeterministicErr != nil {
-		w.wth.metricsScope.GetTaggedScope(tagWorkflowType, task.WorkflowType.GetName()).Counter(metrics.NonDeterministicError).Inc(1)
+		w.wth.metricsHandler.GetTaggedHandler(tagWorkflowType, task.WorkflowType.GetName()).Counter(metrics.NonDeterministicError).Inc(1)
 		w.wth.logger.Error("non-deterministic-error",
 			zap.String(tagWorkflowType, task.WorkflowType.GetName()),
 			zap.String(tagWorkflowID, task.WorkflowExecution.GetWorkflowId()),
@@ -942,6 +991,15 @@ ProcessEvents:
 		}
 	}
 
+	if debugSession != nil && w.isWorkflowCompleted {
+		// the decision that closes the workflow is not produced by the event handler
+		if closeDecision := w.wth.newWorkflowCloseDecision(w); closeDecision != nil {
+			if err := debugSession.onWorkflowClosed(closeDecision, eventHandler); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	return w.CompleteDecisionTask(workflowTask, true), nil
 }
 
@@ -1115,8 +1173,8 @@ func (w *workflowExecutionContextImpl) ResetIfStale(task *workflowservice.PollFo
 			zap.Int64("TaskStartedEventID", task.GetStartedEventId()),
 			zap.Int64("TaskPreviousStartedEventID", task.GetPreviousStartedEventId()))
 
-		w.wth.metricsScope.
-			GetTaggedScope(tagWorkflowType, task.WorkflowType.GetName()).
+		w.wth.metricsHandler.
+			GetTaggedHandler(tagWorkflowType, task.WorkflowType.GetName()).
 			Counter(metrics.StickyCacheStall).Inc(1)
 
 		w.clearState()
@@ -1163,6 +1221,59 @@ func skipDeterministicCheckForUpsertChangeVersion(events []*historypb.HistoryEve
 	return false
 }
 
+// nonDeterministicError is returned by matchReplayWithHistory when replay decisions do not match history events.
+type nonDeterministicError struct {
+	reason   string
+	eventID  int64                   // ID of the event where the replay diverged, set only by strict replay
+	decision *decisionpb.Decision    // nil if the replay decision is missing
+	event    *historypb.HistoryEvent // nil if the replay decision is extra
+}
+
+func (e *nonDeterministicError) Error() string {
+	if e.eventID > 0 {
+		return fmt.Sprintf("nondeterministic workflow at event ID %d: %s", e.eventID, e.reason)
+	}
+	return "nondeterministic workflow: " + e.reason
+}
+
+// matchDecisionTaskWithHistory matches decisions of a single decision task with the decision events of the events
+// batch that follows the decision task. Workflow close events are skipped when the close decision is not made yet.
+func matchDecisionTaskWithHistory(decisions []*decisionpb.Decision, events []*historypb.HistoryEvent, skipCloseEvents bool) error {
+	var decisionEvents []*historypb.HistoryEvent
+	var eventID int64
+	for _, e := range events {
+		if eventID == 0 || e.GetEventType() == enumspb.EVENT_TYPE_DECISION_TASK_COMPLETED {
+			eventID = e.GetEventId()
+		}
+		if !isDecisionEvent(e.GetEventType()) || (skipCloseEvents && isWorkflowCloseEvent(e.GetEventType())) {
+			continue
+		}
+		decisionEvents = append(decisionEvents, e)
+	}
+
+	err := matchReplayWithHistory(decisions, decisionEvents)
+	var nonDeterministicErr *nonDeterministicError
+	if errors.As(err, &nonDeterministicErr) {
+		nonDeterministicErr.eventID = eventID
+		if nonDeterministicErr.event != nil {
+			nonDeterministicErr.eventID = nonDeterministicErr.event.GetEventId()
+		}
+	}
+	return err
+}
+
+func isWorkflowCloseEvent(eventType enumspb.EventType) bool {
+	switch eventType {
+	case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED,
+		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED,
+		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CANCELED,
+		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CONTINUED_AS_NEW:
+		return true
+	default:
+		return false
+	}
+}
+
 func matchReplayWithHistory(replayDecisions []*decisionpb.Decision, historyEvents []*historypb.HistoryEvent) error {
 	di := 0
 	hi := 0
@@ -1193,16 +1304,26 @@ matchLoop:
 		}
 
 		if d == nil {
-			return fmt.Errorf("nondeterministic workflow: missing replay decision for %s", util.HistoryEventToString(e))
+			return &nonDeterministicError{
+				reason: fmt.Sprintf("missing replay decision for %s", util.HistoryEventToString(e)),
+				event:  e,
+			}
 		}
 
 		if e == nil {
-			return fmt.Errorf("nondeterministic workflow: extra replay decision for %s", util.DecisionToString(d))
+			return &nonDeterministicError{
+				reason:   fmt.Sprintf("extra replay decision for %s", util.DecisionToString(d)),
+				decision: d,
+			}
 		}
 
 		if !isDecisionMatchEvent(d, e, false) {
-			return fmt.Errorf("nondeterministic workflow: history event is %s, replay decision is %s",
-				util.HistoryEventToString(e), util.DecisionToString(d))
+			return &nonDeterministicError{
+				reason: fmt.Sprintf("history event is %s, replay decision is %s",
+					util.HistoryEventToString(e), util.DecisionToString(d)),
+				decision: d,
+				event:    e,
+			}
 		}
 
 		di++
@@ -1418,6 +1539,52 @@ func checkNamespacesInDecisionAndEvent(eventNamespace, decisionNamespace string)
 	return eventNamespace != decisionNamespace
 }
 
+// newWorkflowCloseDecision returns the decision that closes the workflow execution, or nil if the workflow is not
+// completed. Workflow panic fails the decision task instead, so there is no close decision for it.
+func (wth *workflowTaskHandlerImpl) newWorkflowCloseDecision(workflowContext *workflowExecutionContextImpl) *decisionpb.Decision {
+	var closeDecision *decisionpb.Decision
+	var canceledErr *CanceledError
+	var contErr *ContinueAsNewError
+	var workflowPanicErr *workflowPanicError
+
+	if errors.As(workflowContext.err, &workflowPanicErr) {
+		return nil
+	} else if errors.As(workflowContext.err, &canceledErr) {
+		// Workflow cancelled
+		closeDecision = createNewDecision(enumspb.DECISION_TYPE_CANCEL_WORKFLOW_EXECUTION)
+		closeDecision.Attributes = &decisionpb.Decision_CancelWorkflowExecutionDecisionAttributes{CancelWorkflowExecutionDecisionAttributes: &decisionpb.CancelWorkflowExecutionDecisionAttributes{
+			Details: convertErrDetailsToPayloads(canceledErr.details, wth.dataConverter),
+		}}
+	} else if errors.As(workflowContext.err, &contErr) {
+		// Continue as new error.
+		closeDecision = createNewDecision(enumspb.DECISION_TYPE_CONTINUE_AS_NEW_WORKFLOW_EXECUTION)
+		closeDecision.Attributes = &decisionpb.Decision_ContinueAsNewWorkflowExecutionDecisionAttributes{ContinueAsNewWorkflowExecutionDecisionAttributes: &decisionpb.ContinueAsNewWorkflowExecutionDecisionAttributes{
+			WorkflowType:               &commonpb.WorkflowType{Name: contErr.params.WorkflowType.Name},
+			Input:                      contErr.params.Input,
+			TaskList:                   &tasklistpb.TaskList{Name: contErr.params.TaskListName},
+			WorkflowRunTimeoutSeconds:  contErr.params.WorkflowRunTimeoutSeconds,
+			WorkflowTaskTimeoutSeconds: contErr.params.WorkflowTaskTimeoutSeconds,
+			Header:                     contErr.params.Header,
+			Memo:                       workflowContext.workflowInfo.Memo,
+			SearchAttributes:           workflowContext.workflowInfo.SearchAttributes,
+		}}
+	} else if workflowContext.err != nil {
+		// Workflow failures
+		closeDecision = createNewDecision(enumspb.DECISION_TYPE_FAIL_WORKFLOW_EXECUTION)
+		failure := convertErrorToFailure(workflowContext.err, wth.dataConverter)
+		closeDecision.Attributes = &decisionpb.Decision_FailWorkflowExecutionDecisionAttributes{FailWorkflowExecutionDecisionAttributes: &decisionpb.FailWorkflowExecutionDecisionAttributes{
+			Failure: failure,
+		}}
+	} else if workflowContext.isWorkflowCompleted {
+		// Workflow completion
+		closeDecision = createNewDecision(enumspb.DECISION_TYPE_COMPLETE_WORKFLOW_EXECUTION)
+		closeDecision.Attributes = &decisionpb.Decision_CompleteWorkflowExecutionDecisionAttributes{CompleteWorkflowExecutionDecisionAttributes: &decisionpb.CompleteWorkflowExecutionDecisionAttributes{
+			Result: workflowContext.result,
+		}}
+	}
+	return closeDecision
+}
+
 func (wth *workflowTaskHandlerImpl) completeWorkflow(
 	eventHandler *workflowExecutionEventHandlerImpl,
 	task *workflowservice.PollForDecisionTaskResponse,
@@ -1446,13 +1613,13 @@ func (wth *workflowTaskHandlerImpl) completeWorkflow(
 		return queryCompletedRequest
 	}
 
-	metricsScope := wth.metricsScope.GetTaggedScope(tagWorkflowType, eventHandler.workflowEnvironmentImpl.workflowInfo.WorkflowType.Name)
+	metricsHandler := wth.metricsHandler.GetTaggedHandler(tagWorkflowType, eventHandler.workflowEnvironmentImpl.workflowInfo.WorkflowType.Name)
 
 	// fail decision task on decider panic
 	var workflowPanicErr *workflowPanicError
 	if errors.As(workflowContext.err, &workflowPanicErr) {
 		// Workflow panic
-		metricsScope.Counter(metrics.DecisionTaskPanicCounter).Inc(1)
+		metricsHandler.Counter(metrics.DecisionTaskPanicCounter).Inc(1)
 		wth.logger.Error("Workflow panic.",
 			zap.String(tagWorkflowID, task.WorkflowExecution.GetWorkflowId()),
 			zap.String(tagRunID, task.WorkflowExecution.GetRunId()),
@@ -1462,52 +1629,22 @@ func (wth *workflowTaskHandlerImpl) completeWorkflow(
 	}
 
 	// complete decision task
-	var closeDecision *decisionpb.Decision
-	var canceledErr *CanceledError
-	var contErr *ContinueAsNewError
-
-	if errors.As(workflowContext.err, &canceledErr) {
-		// Workflow cancelled
-		metricsScope.Counter(metrics.WorkflowCanceledCounter).Inc(1)
-		closeDecision = createNewDecision(enumspb.DECISION_TYPE_CANCEL_WORKFLOW_EXECUTION)
-		closeDecision.Attributes = &decisionpb.Decision_CancelWorkflowExecutionDecisionAttributes{CancelWorkflowExecutionDecisionAttributes: &decisionpb.CancelWorkflowExecutionDecisionAttributes{
-			Details: convertErrDetailsToPayloads(canceledErr.details, wth.dataConverter),
-		}}
-	} else if errors.As(workflowContext.err, &contErr) {
-		// Continue as new error.
-		metricsScope.Counter(metrics.WorkflowContinueAsNewCounter).Inc(1)
-		closeDecision = createNewDecision(enumspb.DECISION_TYPE_CONTINUE_AS_NEW_WORKFLOW_EXECUTION)
-		closeDecision.Attributes = &decisionpb.Decision_ContinueAsNewWorkflowExecutionDecisionAttributes{ContinueAsNewWorkflowExecutionDecisionAttributes: &decisionpb.ContinueAsNewWorkflowExecutionDecisionAttributes{
-			WorkflowType:               &commonpb.WorkflowType{Name: contErr.params.WorkflowType.Name},
-			Input:                      contErr.params.Input,
-			TaskList:                   &tasklistpb.TaskList{Name: contErr.params.TaskListName},
-			WorkflowRunTimeoutSeconds:  contErr.params.WorkflowRunTimeoutSeconds,
-			WorkflowTaskTimeoutSeconds: contErr.params.WorkflowTaskTimeoutSeconds,
-			Header:                     contErr.params.Header,
-			Memo:                       workflowContext.workflowInfo.Memo,
-			SearchAttributes:           workflowContext.workflowInfo.SearchAttributes,
-		}}
-	} else if workflowContext.err != nil {
-		// Workflow failures
-		metricsScope.Counter(metrics.WorkflowFailedCounter).Inc(1)
-		closeDecision = createNewDecision(enumspb.DECISION_TYPE_FAIL_WORKFLOW_EXECUTION)
-		failure := convertErrorToFailure(workflowContext.err, wth.dataConverter)
-		closeDecision.Attributes = &decisionpb.Decision_FailWorkflowExecutionDecisionAttributes{FailWorkflowExecutionDecisionAttributes: &decisionpb.FailWorkflowExecutionDecisionAttributes{
-			Failure: failure,
-		}}
-	} else if workflowContext.isWorkflowCompleted {
-		// Workflow completion
-		metricsScope.Counter(metrics.WorkflowCompletedCounter).Inc(1)
-		closeDecision = createNewDecision(enumspb.DECISION_TYPE_COMPLETE_WORKFLOW_EXECUTION)
-		closeDecision.Attributes = &decisionpb.Decision_CompleteWorkflowExecutionDecisionAttributes{CompleteWorkflowExecutionDecisionAttributes: &decisionpb.CompleteWorkflowExecutionDecisionAttributes{
-			Result: workflowContext.result,
-		}}
+	closeDecision := wth.newWorkflowCloseDecision(workflowContext)
+	switch closeDecision.GetDecisionType() {
+	case enumspb.DECISION_TYPE_CANCEL_WORKFLOW_EXECUTION:
+		metricsHandler.Counter(metrics.WorkflowCanceledCounter).Inc(1)
+	case enumspb.DECISION_TYPE_CONTINUE_AS_NEW_WORKFLOW_EXECUTION:
+		metricsHandler.Counter(metrics.WorkflowContinueAsNewCounter).Inc(1)
+	case enumspb.DECISION_TYPE_FAIL_WORKFLOW_EXECUTION:
+		metricsHandler.Counter(metrics.WorkflowFailedCounter).Inc(1)
+	case enumspb.DECISION_TYPE_COMPLETE_WORKFLOW_EXECUTION:
+		metricsHandler.Counter(metrics.WorkflowCompletedCounter).Inc(1)
 	}
 
 	if closeDecision != nil {
 		decisions = append(decisions, closeDecision)
 		elapsed := time.Since(workflowContext.workflowStartTime)
-		metricsScope.Timer(metrics.WorkflowEndToEndLatency).Record(elapsed)
+		metricsHandler.Timer(metrics.WorkflowEndToEndLatency).Record(elapsed)
 		forceNewDecision = false
 	}
 
@@ -1586,7 +1723,7 @@ func newActivityTaskHandlerWithCustomProvider(
 		identity:           params.Identity,
 		service:            service,
 		logger:             params.Logger,
-		metricsScope:       metrics.NewTaggedScope(params.MetricsScope),
+		metricsHandler:     metrics.NewTaggedHandler(params.MetricsHandler),
 		userContext:        params.UserContext,
 		registry:           registry,
 		activityProvider:   activityProvider,
@@ -1594,6 +1731,7 @@ func newActivityTaskHandlerWithCustomProvider(
 		workerStopCh:       params.WorkerStopChannel,
 		contextPropagators: params.ContextPropagators,
 		tracer:             params.Tracer,
+		interceptors:       params.ActivityInterceptors,
 	}
 }
 
@@ -1757,8 +1895,9 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *workflowservice.
 
 	workflowType := t.WorkflowType.GetName()
 	activityType := t.ActivityType.GetName()
-	metricsScope := getMetricsScopeForActivity(ath.metricsScope, workflowType, activityType)
-	ctx := WithActivityTask(canCtx, t, taskList, invoker, ath.logger, metricsScope, ath.dataConverter, ath.workerStopCh, ath.contextPropagators, ath.tracer)
+	metricsHandler := getMetricsHandlerForActivity(ath.metricsHandler, workflowType, activityType)
+	ctx := WithActivityTask(canCtx, t, taskList, invoker, ath.logger, metricsHandler, ath.dataConverter, ath.workerStopCh, ath.contextPropagators, ath.tracer)
+	getActivityEnv(ctx).interceptors = ath.interceptors
 
 	activityImplementation := ath.getActivity(activityType)
 	if activityImplementation == nil {
@@ -1778,16 +1917,20 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *workflowservice.
 				zap.String(tagActivityType, activityType),
 				zap.String("PanicError", fmt.Sprintf("%v", p)),
 				zap.String("PanicStack", st))
-			metricsScope.Counter(metrics.ActivityTaskPanicCounter).Inc(1)
+			metricsHandler.Counter(metrics.ActivityTaskPanicCounter).Inc(1)
 			panicErr := newPanicError(p, st)
 			result, err = convertActivityResultToRespondRequest(ath.identity, t.TaskToken, nil, panicErr, ath.dataConverter), nil
 		}
 	}()
 
 	// propagate context information into the activity context from the headers
+	header, err := decodeHeader(ath.dataConverter, t.Header)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode header %v", err)
+	}
 	for _, ctxProp := range ath.contextPropagators {
 		var err error
-		if ctx, err = ctxProp.Extract(ctx, NewHeaderReader(t.Header)); err != nil {
+		if ctx, err = ctxProp.Extract(ctx, NewHeaderReader(header)); err != nil {
 			return nil, fmt.Errorf("unable to propagate context %v", err)
 		}
 	}
@@ -36,7 +36,6 @@ import (
 	"github.com/gogo/protobuf/types"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pborman/uuid"
-	"github.com/uber-go/tally"
 	commonpb "go.temporal.io/temporal-proto/common/v1"
 	enumspb "go.temporal.io/temporal-proto/enums/v1"
 	historypb "go.temporal.io/temporal-proto/history/v1"
@@ -75,14 +74,14 @@ type (
 	// workflowTaskPoller implements polling/processing a workflow task
 	workflowTaskPoller struct {
 		basePoller
-		namespace     string
-		taskListName  string
-		identity      string
-		service       workflowservice.WorkflowServiceClient
-		taskHandler   WorkflowTaskHandler
-		metricsScope  tally.Scope
-		logger        *zap.Logger
-		dataConverter DataConverter
+		namespace      string
+		taskListName   string
+		identity       string
+		service        workflowservice.WorkflowServiceClient
+		taskHandler    WorkflowTaskHandler
+		metricsHandler MetricsHandler
+		logger         *zap.Logger
+		dataConverter  DataConverter
 
 		stickyUUID                   string
 		disableStickyExecution       bool
@@ -102,36 +101,37 @@ type (
 		identity            string
 		service             workflowservice.WorkflowServiceClient
 		taskHandler         ActivityTaskHandler
-		metricsScope        *metrics.TaggedScope
+		metricsHandler      *metrics.TaggedHandler
 		logger              *zap.Logger
 		activitiesPerSecond float64
 	}
 
 	historyIteratorImpl struct {
-		iteratorFunc  func(nextPageToken []byte) (*historypb.History, []byte, error)
-		execution     *commonpb.WorkflowExecution
-		nextPageToken []byte
-		namespace     string
-		service       workflowservice.WorkflowServiceClient
-		metricsScope  tally.Scope
-		maxEventID    int64
+		iteratorFunc   func(nextPageToken []byte) (*historypb.History, []byte, error)
+		execution      *commonpb.WorkflowExecution
+		nextPageToken  []byte
+		namespace      string
+		service        workflowservice.WorkflowServiceClient
+		metricsHandler MetricsHandler
+		maxEventID     int64
 	}
 
 	localActivityTaskPoller struct {
 		basePoller
-		handler      *localActivityTaskHandler
-		metricsScope tally.Scope
-		logger       *zap.Logger
-		laTunnel     *localActivityTunnel
+		handler        *localActivityTaskHandler
+		metricsHandler MetricsHandler
+		logger         *zap.Logger
+		laTunnel       *localActivityTunnel
 	}
 
 	localActivityTaskHandler struct {
 		userContext        context.Context
-		metricsScope       *metrics.TaggedScope
+		metricsHandler     *metrics.TaggedHandler
 		logger             *zap.Logger
 		dataConverter      DataConverter
 		contextPropagators []ContextPropagator
 		tracer             opentracing.Tracer
+		interceptors       []ActivityInterceptorFactory
 	}
 
 	localActivityResult struct {
@@ -226,7 +226,7 @@ func newWorkflowTaskPoller(taskHandler WorkflowTaskHandler, service workflowserv
 		taskListName:                 params.TaskList,
 		identity:                     params.Identity,
 		taskHandler:                  taskHandler,
-		metricsScope:                 params.MetricsScope,
+		metricsHandler:               params.MetricsHandler,
 		logger:                       params.Logger,
 		dataConverter:                params.DataConverter,
 		stickyUUID:                   uuid.New(),
@@ -285,7 +285,7 @@ func (wtp *workflowTaskPoller) processWorkflowTask(task *workflowTask) error {
 			task,
 			func(response interface{}, startTime time.Time) (*workflowTask, error) {
 				wtp.logger.Debug("Force RespondDecisionTaskCompleted.", zap.Int64("TaskStartedEventID", task.task.GetStartedEventId()))
-				wtp.metricsScope.Counter(metrics.DecisionTaskForceCompleted).Inc(1)
+				wtp.metricsHandler.Counter(metrics.DecisionTaskForceCompleted).Inc(1)
 				heartbeatResponse, err := wtp.RespondTaskCompletedWithMetrics(response, nil, task.task, startTime)
 				if err != nil {
 					return nil, err
@@ -322,7 +322,7 @@ func (wtp *workflowTaskPoller) processWorkflowTask(task *workflowTask) error {
 func (wtp *workflowTaskPoller) processResetStickinessTask(rst *resetStickinessTask) error {
 	tchCtx, cancel := newChannelContext(context.Background())
 	defer cancel()
-	wtp.metricsScope.Counter(metrics.StickyCacheEvict).Inc(1)
+	wtp.metricsHandler.Counter(metrics.StickyCacheEvict).Inc(1)
 	if _, err := wtp.service.ResetStickyTaskList(tchCtx, rst.task); err != nil {
 		wtp.logger.Warn("ResetStickyTaskList failed",
 			zap.String(tagWorkflowID, rst.task.Execution.GetWorkflowId()),
@@ -337,7 +337,7 @@ func (wtp *workflowTaskPoller) processResetStickinessTask(rst *resetStickinessTa
 func (wtp *workflowTaskPoller) RespondTaskCompletedWithMetrics(completedRequest interface{}, taskErr error, task *workflowservice.PollForDecisionTaskResponse, startTime time.Time) (response *workflowservice.RespondDecisionTaskCompletedResponse, err error) {
 
 	if taskErr != nil {
-		wtp.metricsScope.Counter(metrics.DecisionExecutionFailedCounter).Inc(1)
+		wtp.metricsHandler.Counter(metrics.DecisionExecutionFailedCounter).Inc(1)
 		wtp.logger.Warn("Failed to process decision task.",
 			zap.String(tagWorkflowType, task.WorkflowType.GetName()),
 			zap.String(tagWorkflowID, task.WorkflowExecution.GetWorkflowId()),
@@ -346,17 +346,17 @@ func (wtp *workflowTaskPoller) RespondTaskCompletedWithMetrics(completedRequest
 		// convert err to DecisionTaskFailed
 		completedRequest = errorToFailDecisionTask(task.TaskToken, taskErr, wtp.identity, wtp.dataConverter)
 	} else {
-		wtp.metricsScope.Counter(metrics.DecisionTaskCompletedCounter).Inc(1)
+		wtp.metricsHandler.Counter(metrics.DecisionTaskCompletedCounter).Inc(1)
 	}
 
-	wtp.metricsScope.Timer(metrics.DecisionExecutionLatency).Record(time.Since(startTime))
+	wtp.metricsHandler.Timer(metrics.DecisionExecutionLatency).Record(time.Since(startTime))
 
 	responseStartTime := time.Now()
 	if response, err = wtp.RespondTaskCompleted(completedRequest, task); err != nil {
-		wtp.metricsScope.Counter(metrics.DecisionResponseFailedCounter).Inc(1)
+		wtp.metricsHandler.Counter(metrics.DecisionResponseFailedCounter).Inc(1)
 		return
 	}
-	wtp.metricsScope.Timer(metrics.DecisionResponseLatency).Record(time.Since(responseStartTime))
+	wtp.metricsHandler.Timer(metrics.DecisionResponseLatency).Record(time.Since(responseStartTime))
 
 	return
 }
@@ -417,18 +417,19 @@ func (wtp *workflowTaskPoller) RespondTaskCompleted(completedRequest interface{}
 func newLocalActivityPoller(params workerExecutionParameters, laTunnel *localActivityTunnel) *localActivityTaskPoller {
 	handler := &localActivityTaskHandler{
 		userContext:        params.UserContext,
-		metricsScope:       metrics.NewTaggedScope(params.MetricsScope),
+		metricsHandler:     metrics.NewTaggedHandler(params.MetricsHandler),
 		logger:             params.Logger,
 		dataConverter:      params.DataConverter,
 		contextPropagators: params.ContextPropagators,
 		tracer:             params.Tracer,
+		interceptors:       params.ActivityInterceptors,
 	}
 	return &localActivityTaskPoller{
-		basePoller:   basePoller{stopC: params.WorkerStopChannel},
-		handler:      handler,
-		metricsScope: params.MetricsScope,
-		logger:       params.Logger,
-		laTunnel:     laTunnel,
+		basePoller:     basePoller{stopC: params.WorkerStopChannel},
+		handler:        handler,
+		metricsHandler: params.MetricsHandler,
+		logger:         params.Logger,
+		laTunnel:       laTunnel,
 	}
 }
 
@@ -458,9 +459,9 @@ func (latp *localActivityTaskPoller) ProcessTask(task interface{}) error {
 func (lath *localActivityTaskHandler) executeLocalActivityTask(task *localActivityTask) (result *localActivityResult) {
 	workflowType := task.params.WorkflowInfo.WorkflowType.Name
 	activityType := task.params.ActivityType
-	metricsScope := getMetricsScopeForLocalActivity(lath.metricsScope, workflowType, activityType)
+	metricsHandler := getMetricsHandlerForLocalActivity(lath.metricsHandler, workflowType, activityType)
 
-	metricsScope.Counter(metrics.LocalActivityTotalCounter).Inc(1)
+	metricsHandler.Counter(metrics.LocalActivityTotalCounter).Inc(1)
 
 	ae := activityExecutor{name: activityType, fn: task.params.ActivityFn}
 
@@ -479,10 +480,11 @@ func (lath *localActivityTaskHandler) executeLocalActivityTask(task *localActivi
 		activityID:        fmt.Sprintf("%v", task.activityID),
 		workflowExecution: task.params.WorkflowInfo.WorkflowExecution,
 		logger:            lath.logger,
-		metricsScope:      metricsScope,
+		metricsHandler:    metricsHandler,
 		isLocalActivity:   true,
 		dataConverter:     lath.dataConverter,
 		attempt:           task.attempt,
+		interceptors:      lath.interceptors,
 	})
 
 	// panic handler
@@ -496,7 +498,7 @@ func (lath *localActivityTaskHandler) executeLocalActivityTask(task *localActivi
 				zap.String(tagActivityType, activityType),
 				zap.String("PanicError", fmt.Sprintf("%v", p)),
 				zap.String("PanicStack", st))
-			metricsScope.Counter(metrics.LocalActivityPanicCounter).Inc(1)
+			metricsHandler.Counter(metrics.LocalActivityPanicCounter).Inc(1)
 			panicErr := newPanicError(p, st)
 			result = &localActivityResult{
 				task:   task,
@@ -505,10 +507,18 @@ func (lath *localActivityTaskHandler) executeLocalActivityTask(task *localActivi
 			}
 		}
 		if result.err != nil {
-			metricsScope.Counter(metrics.LocalActivityFailedCounter).Inc(1)
+			metricsHandler.Counter(metrics.LocalActivityFailedCounter).Inc(1)
 		}
 	}()
 
+	// propagate context information into the local activity context from the headers
+	for _, ctxProp := range lath.contextPropagators {
+		var err error
+		if ctx, err = ctxProp.Extract(ctx, NewHeaderReader(task.params.Header)); err != nil {
+			return &localActivityResult{task: task, err: fmt.Errorf("unable to propagate context %v", err)}
+		}
+	}
+
 	timeout := task.params.ScheduleToCloseTimeoutSeconds
 	if task.params.StartToCloseTimeoutSeconds != 0 && task.params.StartToCloseTimeoutSeconds < timeout {
 		timeout = task.params.StartToCloseTimeoutSeconds
@@ -541,7 +551,7 @@ func (lath *localActivityTaskHandler) executeLocalActivityTask(task *localActivi
 		laResult, err = ae.ExecuteWithActualArgs(ctx, task.params.InputArgs)
 		executionLatency := time.Since(laStartTime)
 		close(ch)
-		metricsScope.Timer(metrics.LocalActivityExecutionLatency).Record(executionLatency)
+		metricsHandler.Timer(metrics.LocalActivityExecutionLatency).Record(executionLatency)
 		if executionLatency > timeoutDuration {
 			// If local activity takes longer than expected timeout, the context would already be DeadlineExceeded and
 			// the result would be discarded. Print a warning in this case.
@@ -565,10 +575,10 @@ WaitResult:
 
 		// context is done
 		if ctx.Err() == context.Canceled {
-			metricsScope.Counter(metrics.LocalActivityCanceledCounter).Inc(1)
+			metricsHandler.Counter(metrics.LocalActivityCanceledCounter).Inc(1)
 			return &localActivityResult{err: ErrCanceled, task: task}
 		} else if ctx.Err() == context.DeadlineExceeded {
-			metricsScope.Counter(metrics.LocalActivityTimeoutCounter).Inc(1)
+			metricsHandler.Counter(metrics.LocalActivityTimeoutCounter).Inc(1)
 			return &localActivityResult{err: ErrDeadlineExceeded, task: task}
 		} else {
 			// should not happen
@@ -642,7 +652,7 @@ func (wtp *workflowTaskPoller) getNextPollRequest() (request *workflowservice.Po
 // Poll for a single workflow task from the service
 func (wtp *workflowTaskPoller) poll(ctx context.Context) (interface{}, error) {
 	startTime := time.Now()
-	wtp.metricsScope.Counter(metrics.DecisionPollCounter).Inc(1)
+	wtp.metricsHandler.Counter(metrics.DecisionPollCounter).Inc(1)
 
 	traceLog(func() {
 		wtp.logger.Debug("workflowTaskPoller::Poll")
@@ -654,16 +664,16 @@ func (wtp *workflowTaskPoller) poll(ctx context.Context) (interface{}, error) {
 	response, err := wtp.service.PollForDecisionTask(ctx, request)
 	if err != nil {
 		if isServiceTransientError(err) {
-			wtp.metricsScope.Counter(metrics.DecisionPollTransientFailedCounter).Inc(1)
+			wtp.metricsHandler.Counter(metrics.DecisionPollTransientFailedCounter).Inc(1)
 		} else {
-			wtp.metricsScope.Counter(metrics.DecisionPollFailedCounter).Inc(1)
+			wtp.metricsHandler.Counter(metrics.DecisionPollFailedCounter).Inc(1)
 		}
 		wtp.updateBacklog(request.TaskList.GetKind(), 0)
 		return nil, err
 	}
 
 	if response == nil || len(response.TaskToken) == 0 {
-		wtp.metricsScope.Counter(metrics.DecisionPollNoTaskCounter).Inc(1)
+		wtp.metricsHandler.Counter(metrics.DecisionPollNoTaskCounter).Inc(1)
 		wtp.updateBacklog(request.TaskList.GetKind(), 0)
 		return &workflowTask{}, nil
 	}
@@ -683,22 +693,22 @@ func (wtp *workflowTaskPoller) poll(ctx context.Context) (interface{}, error) {
 			zap.Bool("IsQueryTask", response.Query != nil))
 	})
 
-	wtp.metricsScope.Counter(metrics.DecisionPollSucceedCounter).Inc(1)
-	wtp.metricsScope.Timer(metrics.DecisionPollLatency).Record(time.Since(startTime))
+	wtp.metricsHandler.Counter(metrics.DecisionPollSucceedCounter).Inc(1)
+	wtp.metricsHandler.Timer(metrics.DecisionPollLatency).Record(time.Since(startTime))
 
 	scheduledToStartLatency := time.Duration(response.GetStartedTimestamp() - response.GetScheduledTimestamp())
-	wtp.metricsScope.Timer(metrics.DecisionScheduledToStartLatency).Record(scheduledToStartLatency)
+	wtp.metricsHandler.Timer(metrics.DecisionScheduledToStartLatency).Record(scheduledToStartLatency)
 	return task, nil
 }
 
 func (wtp *workflowTaskPoller) toWorkflowTask(response *workflowservice.PollForDecisionTaskResponse) *workflowTask {
 	historyIterator := &historyIteratorImpl{
-		nextPageToken: response.NextPageToken,
-		execution:     response.WorkflowExecution,
-		namespace:     wtp.namespace,
-		service:       wtp.service,
-		metricsScope:  wtp.metricsScope,
-		maxEventID:    response.GetStartedEventId(),
+		nextPageToken:  response.NextPageToken,
+		execution:      response.WorkflowExecution,
+		namespace:      wtp.namespace,
+		service:        wtp.service,
+		metricsHandler: wtp.metricsHandler,
+		maxEventID:     response.GetStartedEventId(),
 	}
 	task := &workflowTask{
 		task:            response,
@@ -715,7 +725,7 @@ func (h *historyIteratorImpl) GetNextPage() (*historypb.History, error) {
 			h.namespace,
 			h.execution,
 			h.maxEventID,
-			h.metricsScope)
+			h.metricsHandler)
 	}
 
 	history, token, err := h.iteratorFunc(h.nextPageToken)
@@ -740,10 +750,10 @@ func newGetHistoryPageFunc(
 	namespace string,
 	execution *commonpb.WorkflowExecution,
 	atDecisionTaskCompletedEventID int64,
-	metricsScope tally.Scope,
+	metricsHandler MetricsHandler,
 ) func(nextPageToken []byte) (*historypb.History, []byte, error) {
 	return func(nextPageToken []byte) (*historypb.History, []byte, error) {
-		metricsScope.Counter(metrics.WorkflowGetHistoryCounter).Inc(1)
+		metricsHandler.Counter(metrics.WorkflowGetHistoryCounter).Inc(1)
 		startTime := time.Now()
 		var resp *workflowservice.GetWorkflowExecutionHistoryResponse
 		err := backoff.Retry(ctx,
@@ -760,12 +770,12 @@ func newGetHistoryPageFunc(
 				return err1
 			}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
 		if err != nil {
-			metricsScope.Counter(metrics.WorkflowGetHistoryFailedCounter).Inc(1)
+			metricsHandler.Counter(metrics.WorkflowGetHistoryFailedCounter).Inc(1)
 			return nil, nil, err
 		}
 
-		metricsScope.Counter(metrics.WorkflowGetHistorySucceedCounter).Inc(1)
-		metricsScope.Timer(metrics.WorkflowGetHistoryLatency).Record(time.Since(startTime))
+		metricsHandler.Counter(metrics.WorkflowGetHistorySucceedCounter).Inc(1)
+		metricsHandler.Timer(metrics.WorkflowGetHistoryLatency).Record(time.Since(startTime))
 
 		var h *historypb.History
 
@@ -803,7 +813,7 @@ func newActivityTaskPoller(taskHandler ActivityTaskHandler, service workflowserv
 		taskListName:        params.TaskList,
 		identity:            params.Identity,
 		logger:              params.Logger,
-		metricsScope:        metrics.NewTaggedScope(params.MetricsScope),
+		metricsHandler:      metrics.NewTaggedHandler(params.MetricsHandler),
 		activitiesPerSecond: params.TaskListActivitiesPerSecond,
 	}
 }
@@ -812,7 +822,7 @@ func newActivityTaskPoller(taskHandler ActivityTaskHandler, service workflowserv
 func (atp *activityTaskPoller) poll(ctx context.Context) (interface{}, error) {
 	startTime := time.Now()
 
-	atp.metricsScope.Counter(metrics.ActivityPollCounter).Inc(1)
+	atp.metricsHandler.Counter(metrics.ActivityPollCounter).Inc(1)
 
 	traceLog(func() {
 		atp.logger.Debug("activityTaskPoller::Poll")
@@ -827,22 +837,22 @@ func (atp *activityTaskPoller) poll(ctx context.Context) (interface{}, error) {
 	response, err := atp.service.PollForActivityTask(ctx, request)
 	if err != nil {
 		if isServiceTransientError(err) {
-			atp.metricsScope.Counter(metrics.ActivityPollTransientFailedCounter).Inc(1)
+			atp.metricsHandler.Counter(metrics.ActivityPollTransientFailedCounter).Inc(1)
 		} else {
-			atp.metricsScope.Counter(metrics.ActivityPollFailedCounter).Inc(1)
+			atp.metricsHandler.Counter(metrics.ActivityPollFailedCounter).Inc(1)
 		}
 		return nil, err
 	}
 	if response == nil || len(response.TaskToken) == 0 {
-		atp.metricsScope.Counter(metrics.ActivityPollNoTaskCounter).Inc(1)
+		atp.metricsHandler.Counter(metrics.ActivityPollNoTaskCounter).Inc(1)
 		return &activityTask{}, nil
 	}
 
-	atp.metricsScope.Counter(metrics.ActivityPollSucceedCounter).Inc(1)
-	atp.metricsScope.Timer(metrics.ActivityPollLatency).Record(time.Since(startTime))
+	atp.metricsHandler.Counter(metrics.ActivityPollSucceedCounter).Inc(1)
+	atp.metricsHandler.Timer(metrics.ActivityPollLatency).Record(time.Since(startTime))
 
 	scheduledToStartLatency := time.Duration(response.GetStartedTimestamp() - response.GetScheduledTimestampOfThisAttempt())
-	atp.metricsScope.Timer(metrics.ActivityScheduledToStartLatency).Record(scheduledToStartLatency)
+	atp.metricsHandler.Timer(metrics.ActivityScheduledToStartLatency).Record(scheduledToStartLatency)
 
 	return &activityTask{task: response, pollStartTime: startTime}, nil
 }
@@ -874,16 +884,16 @@ func (atp *activityTaskPoller) ProcessTask(task interface{}) error {
 
 	workflowType := activityTask.task.WorkflowType.GetName()
 	activityType := activityTask.task.ActivityType.GetName()
-	metricsScope := getMetricsScopeForActivity(atp.metricsScope, workflowType, activityType)
+	metricsHandler := getMetricsHandlerForActivity(atp.metricsHandler, workflowType, activityType)
 
 	executionStartTime := time.Now()
 	// Process the activity task.
 	request, err := atp.taskHandler.Execute(atp.taskListName, activityTask.task)
 	if err != nil {
-		metricsScope.Counter(metrics.ActivityExecutionFailedCounter).Inc(1)
+		metricsHandler.Counter(metrics.ActivityExecutionFailedCounter).Inc(1)
 		return err
 	}
-	metricsScope.Timer(metrics.ActivityExecutionLatency).Record(time.Since(executionStartTime))
+	metricsHandler.Timer(metrics.ActivityExecutionLatency).Record(time.Since(executionStartTime))
 
 	if request == ErrActivityResultPending {
 		return nil
@@ -895,21 +905,21 @@ func (atp *activityTaskPoller) ProcessTask(task interface{}) error {
 	}
 
 	responseStartTime := time.Now()
-	reportErr := reportActivityComplete(context.Background(), atp.service, request, metricsScope)
+	reportErr := reportActivityComplete(context.Background(), atp.service, request, metricsHandler)
 	if reportErr != nil {
-		metricsScope.Counter(metrics.ActivityResponseFailedCounter).Inc(1)
+		metricsHandler.Counter(metrics.ActivityResponseFailedCounter).Inc(1)
 		traceLog(func() {
 			atp.logger.Debug("reportActivityComplete failed", zap.Error(reportErr))
 		})
 		return reportErr
 	}
 
-	metricsScope.Timer(metrics.ActivityResponseLatency).Record(time.Since(responseStartTime))
-	metricsScope.Timer(metrics.ActivityEndToEndLatency).Record(time.Since(activityTask.pollStartTime))
+	metricsHandler.Timer(metrics.ActivityResponseLatency).Record(time.Since(responseStartTime))
+	metricsHandler.Timer(metrics.ActivityEndToEndLatency).Record(time.Since(activityTask.pollStartTime))
 	return nil
 }
 
-func reportActivityComplete(ctx context.Context, service workflowservice.WorkflowServiceClient, request interface{}, metricsScope tally.Scope) error {
+func reportActivityComplete(ctx context.Context, service workflowservice.WorkflowServiceClient, request interface{}, metricsHandler MetricsHandler) error {
 	if request == nil {
 		// nothing to report
 		return nil
@@ -948,18 +958,18 @@ func reportActivityComplete(ctx context.Context, service workflowservice.Workflo
 	if reportErr == nil {
 		switch request.(type) {
 		case *workflowservice.RespondActivityTaskCanceledRequest:
-			metricsScope.Counter(metrics.ActivityTaskCanceledCounter).Inc(1)
+			metricsHandler.Counter(metrics.ActivityTaskCanceledCounter).Inc(1)
 		case *workflowservice.RespondActivityTaskFailedRequest:
-			metricsScope.Counter(metrics.ActivityTaskFailedCounter).Inc(1)
+			metricsHandler.Counter(metrics.ActivityTaskFailedCounter).Inc(1)
 		case *workflowservice.RespondActivityTaskCompletedRequest:
-			metricsScope.Counter(metrics.ActivityTaskCompletedCounter).Inc(1)
+			metricsHandler.Counter(metrics.ActivityTaskCompletedCounter).Inc(1)
 		}
 	}
 
 	return reportErr
 }
 
-func reportActivityCompleteByID(ctx context.Context, service workflowservice.WorkflowServiceClient, request interface{}, metricsScope tally.Scope) error {
+func reportActivityCompleteByID(ctx context.Context, service workflowservice.WorkflowServiceClient, request interface{}, metricsHandler MetricsHandler) error {
 	if request == nil {
 		// nothing to report
 		return nil
@@ -998,11 +1008,11 @@ func reportActivityCompleteByID(ctx context.Context, service workflowservice.Wor
 	if reportErr == nil {
 		switch request.(type) {
 		case *workflowservice.RespondActivityTaskCanceledByIdRequest:
-			metricsScope.Counter(metrics.ActivityTaskCanceledByIDCounter).Inc(1)
+			metricsHandler.Counter(metrics.ActivityTaskCanceledByIDCounter).Inc(1)
 		case *workflowservice.RespondActivityTaskFailedByIdRequest:
-			metricsScope.Counter(metrics.ActivityTaskFailedByIDCounter).Inc(1)
+			metricsHandler.Counter(metrics.ActivityTaskFailedByIDCounter).Inc(1)
 		case *workflowservice.RespondActivityTaskCompletedByIdRequest:
-			metricsScope.Counter(metrics.ActivityTaskCompletedByIDCounter).Inc(1)
+			metricsHandler.Counter(metrics.ActivityTaskCompletedByIDCounter).Inc(1)
 		}
 	}
 
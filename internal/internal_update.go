@@ -0,0 +1,268 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	commonpb "go.temporal.io/temporal-proto/common/v1"
+	failurepb "go.temporal.io/temporal-proto/failure/v1"
+)
+
+const (
+	// updateSignalName is the name of the signal used by Client.UpdateWorkflow to deliver update requests.
+	updateSignalName = "__update"
+
+	// updateResultQueryType is the build in query type used by Client.UpdateWorkflow to poll for update outcome.
+	updateResultQueryType = "__update_result"
+
+	// updateResultsMaxSize is the number of completed update results kept by a workflow execution.
+	// Results of the oldest completed updates are dropped first.
+	updateResultsMaxSize = 1000
+)
+
+type (
+	// updateRequest is the argument of the updateSignalName signal.
+	updateRequest struct {
+		ID   string
+		Name string
+		Args *commonpb.Payloads
+	}
+
+	// updateResult is the result of the updateResultQueryType query.
+	updateResult struct {
+		Completed bool
+		Result    *commonpb.Payloads
+		Failure   []byte // serialized failurepb.Failure
+	}
+
+	updateHandler struct {
+		fn            interface{}
+		validator     interface{}
+		updateName    string
+		dataConverter DataConverter
+	}
+
+	// updateState holds update handlers and outcomes of a workflow execution. It is shared by all the contexts
+	// derived from the workflow root context.
+	updateState struct {
+		handlers  map[string]*updateHandler
+		results   map[string]*updateResult
+		completed []string // IDs of completed updates in the order of completion
+		started   bool
+	}
+)
+
+func newUpdateState() *updateState {
+	return &updateState{
+		handlers: make(map[string]*updateHandler),
+		results:  make(map[string]*updateResult),
+	}
+}
+
+// setUpdateHandler sets update handler for given updateName and starts the coroutine that processes update requests.
+func setUpdateHandler(ctx Context, updateName string, handler interface{}, validator interface{}) error {
+	uh := &updateHandler{
+		fn:            handler,
+		validator:     validator,
+		updateName:    updateName,
+		dataConverter: getDataConverterFromWorkflowContext(ctx),
+	}
+	if err := uh.validateHandlerFn(); err != nil {
+		return err
+	}
+
+	s := getWorkflowEnvOptions(ctx).updates
+	s.handlers[updateName] = uh
+	if !s.started {
+		s.started = true
+		GoNamed(ctx, "update dispatcher", s.dispatch)
+	}
+	return nil
+}
+
+// dispatch receives update requests in the order of their signals. Each request is validated synchronously
+// and then handled by its own coroutine.
+func (s *updateState) dispatch(ctx Context) {
+	ch := getWorkflowEnvOptions(ctx).getSignalChannel(ctx, updateSignalName)
+	dc := getDataConverterFromWorkflowContext(ctx)
+	for {
+		var request updateRequest
+		ch.Receive(ctx, &request)
+		if _, ok := s.results[request.ID]; ok {
+			// duplicated request, for example signal retried by the client
+			continue
+		}
+		s.results[request.ID] = &updateResult{}
+
+		handler, ok := s.handlers[request.Name]
+		if !ok {
+			s.complete(request.ID, nil, fmt.Errorf("unknown update %v. KnownUpdates=%v", request.Name, s.names()), dc)
+			continue
+		}
+		args, err := decodeArgs(dc, reflect.TypeOf(handler.fn), request.Args)
+		if err != nil {
+			err = fmt.Errorf("unable to decode the input for update: %v, with error: %w", request.Name, err)
+			s.complete(request.ID, nil, err, dc)
+			continue
+		}
+		if err := handler.validate(ctx, args); err != nil {
+			s.complete(request.ID, nil, err, dc)
+			continue
+		}
+		requestID := request.ID
+		Go(ctx, func(ctx Context) {
+			value, err := handler.execute(ctx, args)
+			s.complete(requestID, value, err, dc)
+		})
+	}
+}
+
+// complete records outcome of the update and drops the oldest completed results above updateResultsMaxSize.
+// A dropped result can no longer be read and a retried request with its ID is handled again.
+func (s *updateState) complete(requestID string, value *commonpb.Payloads, err error, dc DataConverter) {
+	s.results[requestID].complete(value, err, dc)
+	s.completed = append(s.completed, requestID)
+	if len(s.completed) > updateResultsMaxSize {
+		delete(s.results, s.completed[0])
+		s.completed = s.completed[1:]
+	}
+}
+
+func (s *updateState) names() []string {
+	var names []string
+	for name := range s.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// queryResult handles the updateResultQueryType query. Outcome of a request that is not processed yet is
+// reported as not completed. That includes requests received before the workflow set its first update handler,
+// as their signals stay buffered until the handler is set.
+func (s *updateState) queryResult(dc DataConverter, queryArgs *commonpb.Payloads) (*commonpb.Payloads, error) {
+	var requestID string
+	if err := dc.FromPayloads(queryArgs, &requestID); err != nil {
+		return nil, fmt.Errorf("unable to decode the input for queryType: %v, with error: %w", updateResultQueryType, err)
+	}
+	result, ok := s.results[requestID]
+	if !ok {
+		result = &updateResult{}
+	}
+	return encodeArg(dc, result)
+}
+
+func (r *updateResult) complete(value *commonpb.Payloads, err error, dc DataConverter) {
+	r.Completed = true
+	r.Result = value
+	if err != nil {
+		failure, marshalErr := convertErrorToFailure(err, dc).Marshal()
+		if marshalErr != nil {
+			panic(marshalErr)
+		}
+		r.Failure = failure
+	}
+}
+
+// get returns update result or error returned by the update handler or validator.
+func (r *updateResult) get(dc DataConverter) (Value, error) {
+	if len(r.Failure) > 0 {
+		failure := &failurepb.Failure{}
+		if err := failure.Unmarshal(r.Failure); err != nil {
+			return nil, err
+		}
+		return nil, convertFailureToError(failure, dc)
+	}
+	return newEncodedValue(r.Result, dc), nil
+}
+
+func (h *updateHandler) validateHandlerFn() error {
+	if strings.HasPrefix(h.updateName, "__") {
+		return errors.New("updateName starts with '__' is reserved for internal use")
+	}
+	fnType := reflect.TypeOf(h.fn)
+	if fnType == nil {
+		return errors.New("update handler must be a function")
+	}
+	if err := validateFnFormat(fnType, true); err != nil {
+		return fmt.Errorf("invalid update handler: %w", err)
+	}
+	if h.validator == nil {
+		return nil
+	}
+
+	validatorType := reflect.TypeOf(h.validator)
+	if validatorType.Kind() != reflect.Func {
+		return fmt.Errorf("update validator must be a function but was %s", validatorType.Kind())
+	}
+	if validatorType.NumIn() != fnType.NumIn() {
+		return fmt.Errorf("update validator must accept the same %d arguments as the handler, found %d",
+			fnType.NumIn(), validatorType.NumIn())
+	}
+	for i := 0; i < fnType.NumIn(); i++ {
+		if validatorType.In(i) != fnType.In(i) {
+			return fmt.Errorf("update validator argument %d must be of type %v but was %v",
+				i, fnType.In(i), validatorType.In(i))
+		}
+	}
+	if validatorType.NumOut() != 1 || !isError(validatorType.Out(0)) {
+		return errors.New("update validator must return just error")
+	}
+	return nil
+}
+
+func (h *updateHandler) validate(ctx Context, args []reflect.Value) error {
+	if h.validator == nil {
+		return nil
+	}
+	retValues := reflect.ValueOf(h.validator).Call(append([]reflect.Value{reflect.ValueOf(ctx)}, args...))
+	if retValues[0].IsNil() {
+		return nil
+	}
+	return retValues[0].Interface().(error)
+}
+
+func (h *updateHandler) execute(ctx Context, args []reflect.Value) (*commonpb.Payloads, error) {
+	retValues := reflect.ValueOf(h.fn).Call(append([]reflect.Value{reflect.ValueOf(ctx)}, args...))
+
+	// we already verified (in validateHandlerFn()) that the handler returns result, error or just error
+	errValue := retValues[len(retValues)-1]
+	if !errValue.IsNil() {
+		return nil, errValue.Interface().(error)
+	}
+	if len(retValues) == 1 {
+		return nil, nil
+	}
+	retValue := retValues[0]
+	if retValue.Kind() == reflect.Ptr && retValue.IsNil() {
+		return nil, nil
+	}
+	return encodeArg(h.dataConverter, retValue.Interface())
+}
@@ -0,0 +1,50 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateState_CompletedResultsAreBounded(t *testing.T) {
+	s := newUpdateState()
+	dc := getDefaultDataConverter()
+	s.results["pending"] = &updateResult{}
+	for i := 0; i <= updateResultsMaxSize; i++ {
+		id := fmt.Sprintf("update-%v", i)
+		s.results[id] = &updateResult{}
+		s.complete(id, nil, nil, dc)
+	}
+
+	require.Len(t, s.completed, updateResultsMaxSize)
+	require.NotContains(t, s.results, "update-0")
+	require.True(t, s.results["update-1"].Completed)
+	require.True(t, s.results[fmt.Sprintf("update-%v", updateResultsMaxSize)].Completed)
+	// Results of updates that are not completed are not dropped.
+	require.Contains(t, s.results, "pending")
+}
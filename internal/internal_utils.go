@@ -35,7 +35,6 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/uber-go/tally"
 	"google.golang.org/grpc/metadata"
 
 	"go.temporal.io/temporal/internal/common/metrics"
@@ -167,14 +166,14 @@ func getKillSignal() <-chan os.Signal {
 	return c
 }
 
-// getMetricsScopeForActivity return properly tagged tally scope for activity
-func getMetricsScopeForActivity(ts *metrics.TaggedScope, workflowType, activityType string) tally.Scope {
-	return ts.GetTaggedScope(tagWorkflowType, workflowType, tagActivityType, activityType)
+// getMetricsHandlerForActivity returns properly tagged metrics handler for activity
+func getMetricsHandlerForActivity(ts *metrics.TaggedHandler, workflowType, activityType string) MetricsHandler {
+	return ts.GetTaggedHandler(tagWorkflowType, workflowType, tagActivityType, activityType)
 }
 
-// getMetricsScopeForLocalActivity return properly tagged tally scope for local activity
-func getMetricsScopeForLocalActivity(ts *metrics.TaggedScope, workflowType, localActivityType string) tally.Scope {
-	return ts.GetTaggedScope(tagWorkflowType, workflowType, tagLocalActivityType, localActivityType)
+// getMetricsHandlerForLocalActivity returns properly tagged metrics handler for local activity
+func getMetricsHandlerForLocalActivity(ts *metrics.TaggedHandler, workflowType, localActivityType string) MetricsHandler {
+	return ts.GetTaggedHandler(tagWorkflowType, workflowType, tagLocalActivityType, localActivityType)
 }
 
 func getStringID(intID int64) string {
@@ -34,7 +34,9 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"strconv"
@@ -47,7 +49,6 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pborman/uuid"
-	"github.com/uber-go/tally"
 	enumspb "go.temporal.io/temporal-proto/enums/v1"
 
 	commonpb "go.temporal.io/temporal-proto/common/v1"
@@ -66,7 +67,8 @@ import (
 const (
 	// Set to 2 pollers for now, can adjust later if needed. The typical RTT (round-trip time) is below 1ms within data
 	// center. And the poll API latency is about 5ms. With 2 poller, we could achieve around 300~400 RPS.
-	defaultConcurrentPollRoutineSize = 2
+	defaultConcurrentPollRoutineSize    = 2
+	defaultMinConcurrentPollRoutineSize = 1
 
 	defaultMaxConcurrentActivityExecutionSize = 1000   // Large concurrent activity execution size (1k)
 	defaultWorkerActivitiesPerSecond          = 100000 // Large activity executions/sec (unlimited)
@@ -142,6 +144,9 @@ type (
 		// MaxConcurrentActivityPollers is the max number of pollers for activity task list
 		MaxConcurrentActivityPollers int
 
+		// MinConcurrentActivityPollers is the min number of pollers for activity task list when PollerAutoScaling is set
+		MinConcurrentActivityPollers int
+
 		// Defines how many concurrent decision task executions by this worker.
 		ConcurrentDecisionTaskExecutionSize int
 
@@ -151,6 +156,12 @@ type (
 		// MaxConcurrentDecisionPollers is the max number of pollers for decision task list
 		MaxConcurrentDecisionPollers int
 
+		// MinConcurrentDecisionPollers is the min number of pollers for decision task list when PollerAutoScaling is set
+		MinConcurrentDecisionPollers int
+
+		// PollerAutoScaling scales the number of activity and decision pollers between the min and max by poll results
+		PollerAutoScaling bool
+
 		// Defines how many concurrent local activity executions by this worker.
 		ConcurrentLocalActivityExecutionSize int
 
@@ -164,7 +175,7 @@ type (
 		// a default option.
 		Identity string
 
-		MetricsScope tally.Scope
+		MetricsHandler MetricsHandler
 
 		Logger *zap.Logger
 
@@ -188,6 +199,16 @@ type (
 		// The default behavior is to block workflow execution until the problem is fixed.
 		WorkflowPanicPolicy WorkflowPanicPolicy
 
+		// StrictReplay matches replay decisions with history events at every decision task instead of once per
+		// workflow task. Used by WorkflowReplayer.
+		StrictReplay bool
+
+		// ReplayDebugger is invoked at every step of the replay. Used by WorkflowReplayer.
+		ReplayDebugger ReplayDebugger
+
+		// DeadlockDetectionTimeout is the maximum time a workflow coroutine can run without yielding.
+		DeadlockDetectionTimeout time.Duration
+
 		DataConverter DataConverter
 
 		// WorkerStopTimeout is the time delay before hard terminate worker
@@ -202,6 +223,9 @@ type (
 		ContextPropagators []ContextPropagator
 
 		Tracer opentracing.Tracer
+
+		// ActivityInterceptors are factories used to instantiate activity interceptor chain
+		ActivityInterceptors []ActivityInterceptorFactory
 	}
 )
 
@@ -224,9 +248,9 @@ func ensureRequiredParams(params *workerExecutionParameters) {
 		params.Logger = logger
 		params.Logger.Info("No logger configured for temporal worker. Created default one.")
 	}
-	if params.MetricsScope == nil {
-		params.MetricsScope = tally.NoopScope
-		params.Logger.Info("No metrics scope configured for temporal worker. Use NoopScope as default.")
+	if params.MetricsHandler == nil {
+		params.MetricsHandler = metrics.NoopHandler
+		params.Logger.Info("No metrics handler configured for temporal worker. Use noop handler as default.")
 	}
 	if params.DataConverter == nil {
 		params.DataConverter = getDefaultDataConverter()
@@ -285,6 +309,8 @@ func newWorkflowTaskWorkerInternal(taskHandler WorkflowTaskHandler, service work
 	poller := newWorkflowTaskPoller(taskHandler, service, params)
 	worker := newBaseWorker(baseWorkerOptions{
 		pollerCount:       params.MaxConcurrentDecisionPollers,
+		minPollerCount:    params.MinConcurrentDecisionPollers,
+		pollerAutoScaling: params.PollerAutoScaling,
 		pollerRate:        defaultPollerRate,
 		maxConcurrentTask: params.ConcurrentDecisionTaskExecutionSize,
 		maxTaskPerSecond:  params.WorkerDecisionTasksPerSecond,
@@ -293,7 +319,7 @@ func newWorkflowTaskWorkerInternal(taskHandler WorkflowTaskHandler, service work
 		workerType:        "DecisionWorker",
 		stopTimeout:       params.WorkerStopTimeout},
 		params.Logger,
-		params.MetricsScope,
+		params.MetricsHandler,
 		nil,
 	)
 
@@ -316,7 +342,7 @@ func newWorkflowTaskWorkerInternal(taskHandler WorkflowTaskHandler, service work
 		workerType:        "LocalActivityWorker",
 		stopTimeout:       params.WorkerStopTimeout},
 		params.Logger,
-		params.MetricsScope,
+		params.MetricsHandler,
 		nil,
 	)
 
@@ -438,6 +464,8 @@ func newActivityTaskWorker(taskHandler ActivityTaskHandler, service workflowserv
 	base := newBaseWorker(
 		baseWorkerOptions{
 			pollerCount:       workerParams.MaxConcurrentActivityPollers,
+			minPollerCount:    workerParams.MinConcurrentActivityPollers,
+			pollerAutoScaling: workerParams.PollerAutoScaling,
 			pollerRate:        defaultPollerRate,
 			maxConcurrentTask: workerParams.ConcurrentActivityExecutionSize,
 			maxTaskPerSecond:  workerParams.WorkerActivitiesPerSecond,
@@ -447,7 +475,7 @@ func newActivityTaskWorker(taskHandler ActivityTaskHandler, service workflowserv
 			stopTimeout:       workerParams.WorkerStopTimeout,
 			userContextCancel: workerParams.UserContextCancel},
 		workerParams.Logger,
-		workerParams.MetricsScope,
+		workerParams.MetricsHandler,
 		sessionTokenBucket,
 	)
 
@@ -891,31 +919,31 @@ func (ae *activityExecutor) GetFunction() interface{} {
 
 func (ae *activityExecutor) Execute(ctx context.Context, input *commonpb.Payloads) (*commonpb.Payloads, error) {
 	fnType := reflect.TypeOf(ae.fn)
-	var args []reflect.Value
 	dataConverter := getDataConverterFromActivityCtx(ctx)
 
-	// activities optionally might not take context.
-	if fnType.NumIn() > 0 && isActivityContext(fnType.In(0)) {
-		args = append(args, reflect.ValueOf(ctx))
-	}
-
 	decoded, err := decodeArgs(dataConverter, fnType, input)
 	if err != nil {
 		return nil, fmt.Errorf(
 			"unable to decode the activity function input payload with error: %w for function name: %v",
 			err, ae.name)
 	}
-	args = append(args, decoded...)
+	args := make([]interface{}, 0, len(decoded))
+	for _, arg := range decoded {
+		args = append(args, arg.Interface())
+	}
 
-	fnValue := reflect.ValueOf(ae.fn)
-	retValues := fnValue.Call(args)
-	return validateFunctionAndGetResults(ae.fn, retValues, dataConverter)
+	return ae.ExecuteWithActualArgs(ctx, args)
 }
 
 func (ae *activityExecutor) ExecuteWithActualArgs(ctx context.Context, actualArgs []interface{}) (*commonpb.Payloads, error) {
-	retValues := ae.executeWithActualArgsWithoutParseResult(ctx, actualArgs)
 	dataConverter := getDataConverterFromActivityCtx(ctx)
 
+	ctx, interceptor := newActivityInterceptors(ctx, ae)
+	results := interceptor.ExecuteActivity(ctx, ae.name, actualArgs...)
+	retValues, err := activityResultsToValues(ae.fn, results)
+	if err != nil {
+		return nil, err
+	}
 	return validateFunctionAndGetResults(ae.fn, retValues, dataConverter)
 }
 
@@ -1127,11 +1155,83 @@ func (aw *AggregatedWorker) Stop() {
 // WorkflowReplayer is used to replay workflow code from an event history
 type WorkflowReplayer struct {
 	registry *registry
+	options  WorkflowReplayerOptions
+}
+
+// WorkflowReplayerOptions are optional parameters of the WorkflowReplayer.
+type WorkflowReplayerOptions struct {
+	// Optional: Enables strict replay mode. By default decisions made by the replayed workflow code are matched with
+	// the history events once, after the whole history is replayed, and only if the replay has not completed the
+	// workflow. The strict mode matches the decisions of every decision task with the events recorded after its
+	// DecisionTaskCompleted event, so nondeterminism is detected in histories of workflows that failed, timed out or
+	// are still running as well. Nondeterministic error returned in the strict mode contains the ID of the event at
+	// which the replay diverged from the history.
+	// default: false
+	EnableStrictReplay bool
+
+	// Optional: Sets DataConverter used to decode the payloads of the replayed history. Must be the same as
+	// ClientOptions.DataConverter of the worker that executed the workflow.
+	// default: DefaultDataConverter
+	DataConverter DataConverter
+
+	// Optional: Sets ContextPropagators used to extract the context of the replayed workflow. See
+	// ClientOptions.ContextPropagators.
+	// default: nil
+	ContextPropagators []ContextPropagator
+
+	// Optional: Sets opentracing Tracer used by the replayed workflow. See ClientOptions.Tracer.
+	// default: no tracer - opentracing.NoopTracer
+	Tracer opentracing.Tracer
+
+	// Optional: Specifies factories used to instantiate workflow interceptor chain of the replayed workflow. See
+	// WorkerOptions.WorkflowInterceptorChainFactories.
+	// default: nil
+	WorkflowInterceptorChainFactories []WorkflowInterceptorFactory
+
+	// Optional: Enable logging in replay. See WorkerOptions.EnableLoggingInReplay.
+	// default: false
+	EnableLoggingInReplay bool
+
+	// Optional: Sets the maximum number of events in a history page loaded by ReplayWorkflowExecution. The complete
+	// history of the execution is loaded page by page before it is replayed.
+	// default: 0 - the page size is chosen by the server
+	HistoryPageSize int32
+
+	// Optional: Sets the directory where ReplayWorkflowExecution caches the loaded histories as JSON files. The
+	// history is loaded from the service only if it is not in the cache yet, so a failing replay can be reproduced
+	// offline. The cached file can also be replayed with ReplayWorkflowHistoryFromJSONFile. Only histories of the
+	// closed executions with the RunID specified are cached, as the latest run of a workflow and the history of an
+	// open execution change over time.
+	// default: "" - histories are not cached
+	HistoryCacheDir string
+
+	// Optional: Sets the callback invoked after every history event applied to the replayed workflow and for every
+	// decision the workflow code produces. It gets the current event, the pending decisions, the stack traces of the
+	// workflow coroutines and the workflow state, so it can be used to step through the replay and find where it
+	// diverges from the history. ReplayWorkflowHistories invokes it concurrently for the histories replayed in
+	// parallel.
+	// default: nil
+	ReplayDebugger ReplayDebugger
 }
 
 // NewWorkflowReplayer creates an instance of the WorkflowReplayer
 func NewWorkflowReplayer() *WorkflowReplayer {
-	return &WorkflowReplayer{registry: newRegistry()}
+	return NewWorkflowReplayerWithOptions(WorkflowReplayerOptions{})
+}
+
+// NewWorkflowReplayerWithOptions creates an instance of the WorkflowReplayer with the provided options.
+func NewWorkflowReplayerWithOptions(options WorkflowReplayerOptions) *WorkflowReplayer {
+	if options.DataConverter == nil {
+		options.DataConverter = getDefaultDataConverter()
+	}
+	if options.Tracer != nil {
+		options.ContextPropagators = append(options.ContextPropagators, NewTracingContextPropagator(zap.NewNop(), options.Tracer))
+	} else {
+		options.Tracer = opentracing.NoopTracer{}
+	}
+	registry := newRegistry()
+	registry.SetWorkflowInterceptors(options.WorkflowInterceptorChainFactories)
+	return &WorkflowReplayer{registry: registry, options: options}
 }
 
 // RegisterWorkflow registers workflow function to replay
@@ -1190,49 +1290,119 @@ func (aw *WorkflowReplayer) ReplayPartialWorkflowHistoryFromJSONFile(logger *zap
 
 // ReplayWorkflowExecution replays workflow execution loading it from Temporal service.
 func (aw *WorkflowReplayer) ReplayWorkflowExecution(ctx context.Context, service workflowservice.WorkflowServiceClient, logger *zap.Logger, namespace string, execution WorkflowExecution) error {
-	sharedExecution := &commonpb.WorkflowExecution{
-		RunId:      execution.RunID,
-		WorkflowId: execution.ID,
-	}
-	request := &workflowservice.GetWorkflowExecutionHistoryRequest{
-		Namespace: namespace,
-		Execution: sharedExecution,
+	cacheFile := aw.getHistoryCacheFile(execution)
+	if cacheFile != "" {
+		history, err := extractHistoryFromFile(cacheFile, 0)
+		if err == nil {
+			return aw.replayWorkflowHistory(logger, service, namespace, history)
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
 	}
-	hResponse, err := service.GetWorkflowExecutionHistory(ctx, request)
+
+	history, err := aw.getWorkflowExecutionHistory(ctx, service, namespace, execution)
 	if err != nil {
 		return err
 	}
+	// the history of an open execution grows, so it would be replayed from the cache without its latest events
+	if cacheFile != "" && isWorkflowExecutionClosed(history) {
+		if err := WriteHistoryToJSONFile(history, cacheFile); err != nil {
+			return err
+		}
+	}
 
-	if hResponse.RawHistory != nil {
-		history, err := serializer.DeserializeBlobDataToHistoryEvents(hResponse.RawHistory, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	return aw.replayWorkflowHistory(logger, service, namespace, history)
+}
+
+// getWorkflowExecutionHistory loads all the pages of the workflow execution history.
+func (aw *WorkflowReplayer) getWorkflowExecutionHistory(ctx context.Context, service workflowservice.WorkflowServiceClient, namespace string, execution WorkflowExecution) (*historypb.History, error) {
+	request := &workflowservice.GetWorkflowExecutionHistoryRequest{
+		Namespace: namespace,
+		Execution: &commonpb.WorkflowExecution{
+			RunId:      execution.RunID,
+			WorkflowId: execution.ID,
+		},
+		MaximumPageSize: aw.options.HistoryPageSize,
+	}
+	history := &historypb.History{}
+	for {
+		hResponse, err := service.GetWorkflowExecutionHistory(ctx, request)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		hResponse.History = history
+		if hResponse.RawHistory != nil {
+			page, err := serializer.DeserializeBlobDataToHistoryEvents(hResponse.RawHistory, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+			if err != nil {
+				return nil, err
+			}
+
+			hResponse.History = page
+		}
+		history.Events = append(history.Events, hResponse.History.GetEvents()...)
+
+		if len(hResponse.NextPageToken) == 0 {
+			return history, nil
+		}
+		request.NextPageToken = hResponse.NextPageToken
 	}
+}
 
-	return aw.replayWorkflowHistory(logger, service, namespace, hResponse.History)
+// getHistoryCacheFile returns the name of the file the history of the execution is cached in, or empty string if the
+// history is not cached.
+func (aw *WorkflowReplayer) getHistoryCacheFile(execution WorkflowExecution) string {
+	if aw.options.HistoryCacheDir == "" || execution.RunID == "" {
+		return ""
+	}
+	// workflow ID may contain characters that are not allowed in file names
+	return filepath.Join(aw.options.HistoryCacheDir, url.PathEscape(execution.ID)+"_"+execution.RunID+".json")
+}
+
+// isWorkflowExecutionClosed returns true if the last event of the history closes the workflow execution.
+func isWorkflowExecutionClosed(history *historypb.History) bool {
+	if len(history.Events) == 0 {
+		return false
+	}
+	switch history.Events[len(history.Events)-1].GetEventType() {
+	case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED,
+		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED,
+		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CANCELED,
+		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CONTINUED_AS_NEW,
+		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_TERMINATED,
+		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_TIMED_OUT:
+		return true
+	default:
+		return false
+	}
 }
 
 func (aw *WorkflowReplayer) replayWorkflowHistory(logger *zap.Logger, service workflowservice.WorkflowServiceClient, namespace string, history *historypb.History) error {
+	resp, err := aw.processReplayTask(logger, service, namespace, history)
+	if err != nil {
+		return err
+	}
+	return checkReplayResult(resp, history.Events[len(history.Events)-1])
+}
+
+// processReplayTask replays the history as a single decision task and returns the decision task response.
+func (aw *WorkflowReplayer) processReplayTask(logger *zap.Logger, service workflowservice.WorkflowServiceClient, namespace string, history *historypb.History) (interface{}, error) {
 	taskList := "ReplayTaskList"
 	events := history.Events
 	if events == nil {
-		return errors.New("empty events")
+		return nil, errors.New("empty events")
 	}
 	if len(events) < 3 {
-		return errors.New("at least 3 events expected in the history")
+		return nil, errors.New("at least 3 events expected in the history")
 	}
 	first := events[0]
 	if first.GetEventType() != enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED {
-		return errors.New("first event is not WorkflowExecutionStarted")
+		return nil, errors.New("first event is not WorkflowExecutionStarted")
 	}
-	last := events[len(events)-1]
 
 	attr := first.GetWorkflowExecutionStartedEventAttributes()
 	if attr == nil {
-		return errors.New("corrupted WorkflowExecutionStarted")
+		return nil, errors.New("corrupted WorkflowExecutionStarted")
 	}
 	workflowType := attr.WorkflowType
 	execution := &commonpb.WorkflowExecution{
@@ -1255,27 +1425,36 @@ func (aw *WorkflowReplayer) replayWorkflowHistory(logger *zap.Logger, service wo
 		logger = zap.NewNop()
 	}
 
-	metricScope := tally.NoopScope
+	metricsHandler := metrics.NoopHandler
 	iterator := &historyIteratorImpl{
-		nextPageToken: task.NextPageToken,
-		execution:     task.WorkflowExecution,
-		namespace:     ReplayNamespace,
-		service:       service,
-		metricsScope:  metricScope,
-		maxEventID:    task.GetStartedEventId(),
+		nextPageToken:  task.NextPageToken,
+		execution:      task.WorkflowExecution,
+		namespace:      ReplayNamespace,
+		service:        service,
+		metricsHandler: metricsHandler,
+		maxEventID:     task.GetStartedEventId(),
 	}
 	params := workerExecutionParameters{
 		Namespace: namespace,
 		TaskList:  taskList,
 		Identity:  "replayID",
 		Logger:    logger,
+		// replayed executions are never continued, so they are not cached. It also keeps replays of histories
+		// with the same run ID independent of each other.
+		DisableStickyExecution: true,
+		StrictReplay:           aw.options.EnableStrictReplay,
+		ReplayDebugger:         aw.options.ReplayDebugger,
+		EnableLoggingInReplay:  aw.options.EnableLoggingInReplay,
+		DataConverter:          aw.options.DataConverter,
+		ContextPropagators:     aw.options.ContextPropagators,
+		Tracer:                 aw.options.Tracer,
 	}
 	taskHandler := newWorkflowTaskHandler(params, nil, aw.registry)
-	resp, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task, historyIterator: iterator}, nil)
-	if err != nil {
-		return err
-	}
+	return taskHandler.ProcessWorkflowTask(&workflowTask{task: task, historyIterator: iterator}, nil)
+}
 
+// checkReplayResult verifies that the replay of a closed workflow completes it the same way as the last event does.
+func checkReplayResult(resp interface{}, last *historypb.HistoryEvent) error {
 	if last.GetEventType() != enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED && last.GetEventType() != enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CONTINUED_AS_NEW {
 		return nil
 	}
@@ -1355,13 +1534,16 @@ func NewAggregatedWorker(client *WorkflowClient, taskList string, options Worker
 		ConcurrentActivityExecutionSize:      options.MaxConcurrentActivityExecutionSize,
 		WorkerActivitiesPerSecond:            options.WorkerActivitiesPerSecond,
 		MaxConcurrentActivityPollers:         options.MaxConcurrentActivityTaskPollers,
+		MinConcurrentActivityPollers:         options.MinConcurrentActivityTaskPollers,
 		ConcurrentLocalActivityExecutionSize: options.MaxConcurrentLocalActivityExecutionSize,
 		WorkerLocalActivitiesPerSecond:       options.WorkerLocalActivitiesPerSecond,
 		ConcurrentDecisionTaskExecutionSize:  options.MaxConcurrentDecisionTaskExecutionSize,
 		WorkerDecisionTasksPerSecond:         options.WorkerDecisionTasksPerSecond,
 		MaxConcurrentDecisionPollers:         options.MaxConcurrentDecisionTaskPollers,
+		MinConcurrentDecisionPollers:         options.MinConcurrentDecisionTaskPollers,
+		PollerAutoScaling:                    options.EnablePollerAutoScaling,
 		Identity:                             client.identity,
-		MetricsScope:                         client.metricsScope,
+		MetricsHandler:                       client.metricsHandler,
 		Logger:                               client.logger,
 		EnableLoggingInReplay:                options.EnableLoggingInReplay,
 		UserContext:                          backgroundActivityContext,
@@ -1370,10 +1552,12 @@ func NewAggregatedWorker(client *WorkflowClient, taskList string, options Worker
 		StickyScheduleToStartTimeout:         options.StickyScheduleToStartTimeout,
 		TaskListActivitiesPerSecond:          options.TaskListActivitiesPerSecond,
 		WorkflowPanicPolicy:                  options.NonDeterministicWorkflowPolicy,
+		DeadlockDetectionTimeout:             options.DeadlockDetectionTimeout,
 		DataConverter:                        client.dataConverter,
 		WorkerStopTimeout:                    options.WorkerStopTimeout,
 		ContextPropagators:                   client.contextPropagators,
 		Tracer:                               client.tracer,
+		ActivityInterceptors:                 options.ActivityInterceptorChainFactories,
 	}
 
 	ensureRequiredParams(&workerParams)
@@ -1429,20 +1613,20 @@ func NewAggregatedWorker(client *WorkflowClient, taskList string, options Worker
 	}
 }
 
-// tagScope with one or multiple tags, like
-// tagScope(scope, tag1, val1, tag2, val2)
-func tagScope(metricsScope tally.Scope, keyValuePairs ...string) tally.Scope {
-	if metricsScope == nil {
-		metricsScope = tally.NoopScope
+// tagMetricsHandler with one or multiple tags, like
+// tagMetricsHandler(handler, tag1, val1, tag2, val2)
+func tagMetricsHandler(metricsHandler MetricsHandler, keyValuePairs ...string) MetricsHandler {
+	if metricsHandler == nil {
+		metricsHandler = metrics.NoopHandler
 	}
 	if len(keyValuePairs)%2 != 0 {
-		panic("tagScope key value are not in pairs")
+		panic("tagMetricsHandler key value are not in pairs")
 	}
 	tagsMap := map[string]string{}
 	for i := 0; i < len(keyValuePairs); i += 2 {
 		tagsMap[keyValuePairs[i]] = keyValuePairs[i+1]
 	}
-	return metricsScope.Tagged(tagsMap)
+	return metricsHandler.WithTags(tagsMap)
 }
 
 func processTestTags(wOptions *WorkerOptions, ep *workerExecutionParameters) {
@@ -1543,6 +1727,12 @@ func setWorkerOptionsDefaults(options *WorkerOptions) {
 	if options.MaxConcurrentDecisionTaskPollers <= 0 {
 		options.MaxConcurrentDecisionTaskPollers = defaultConcurrentPollRoutineSize
 	}
+	if options.MinConcurrentActivityTaskPollers <= 0 {
+		options.MinConcurrentActivityTaskPollers = defaultMinConcurrentPollRoutineSize
+	}
+	if options.MinConcurrentDecisionTaskPollers <= 0 {
+		options.MinConcurrentDecisionTaskPollers = defaultMinConcurrentPollRoutineSize
+	}
 	if options.MaxConcurrentLocalActivityExecutionSize == 0 {
 		options.MaxConcurrentLocalActivityExecutionSize = defaultMaxConcurrentLocalActivityExecutionSize
 	}
@@ -1571,8 +1761,8 @@ func setClientDefaults(client *WorkflowClient) {
 	if client.tracer == nil {
 		client.tracer = opentracing.NoopTracer{}
 	}
-	if client.metricsScope == nil {
-		client.metricsScope = metrics.NewTaggedScope(nil)
+	if client.metricsHandler == nil {
+		client.metricsHandler = metrics.NewTaggedHandler(nil)
 	}
 }
 
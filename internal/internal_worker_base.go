@@ -34,6 +34,7 @@ import (
 	"time"
 
 	"github.com/uber-go/tally"
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"golang.org/x/time/rate"
@@ -55,6 +56,9 @@ var (
 
 var errStop = errors.New("worker stopping")
 
+// sessionWorkerType tags metrics of session slots, which are held by the session creation activity worker.
+const sessionWorkerType = "SessionWorker"
+
 type (
 	// ResultHandler that returns result
 	ResultHandler func(result *commonpb.Payloads, err error)
@@ -85,6 +89,7 @@ type (
 		ExecuteChildWorkflow(params ExecuteWorkflowParams, callback ResultHandler, startedHandler func(r WorkflowExecution, e error))
 		GetLogger() *zap.Logger
 		GetMetricsScope() tally.Scope
+		GetMetricsHandler() MetricsHandler
 		// Must be called before WorkflowDefinition.Execute returns
 		RegisterSignalHandler(handler func(name string, input *commonpb.Payloads))
 		SignalExternalWorkflow(namespace, workflowID, runID, signalName string, input *commonpb.Payloads, arg interface{}, childWorkflowOnly bool, callback ResultHandler)
@@ -97,6 +102,7 @@ type (
 		GetContextPropagators() []ContextPropagator
 		UpsertSearchAttributes(attributes map[string]interface{}) error
 		GetRegistry() *registry
+		GetDeadlockDetectionTimeout() time.Duration
 	}
 
 	// WorkflowDefinitionFactory factory for creating WorkflowDefinition instances.
@@ -122,6 +128,8 @@ type (
 	// baseWorkerOptions options to configure base worker.
 	baseWorkerOptions struct {
 		pollerCount       int
+		minPollerCount    int
+		pollerAutoScaling bool
 		pollerRate        int
 		maxConcurrentTask int
 		maxTaskPerSecond  float64
@@ -144,11 +152,15 @@ type (
 		limiterContextCancel func()
 		retrier              *backoff.ConcurrentRetrier // Service errors back off retrier
 		logger               *zap.Logger
-		metricsScope         tally.Scope
+		metricsHandler       MetricsHandler
 
 		pollerRequestCh    chan struct{}
 		taskQueueCh        chan interface{}
 		sessionTokenBucket *sessionTokenBucket
+		pollerAutoScaler   *pollerAutoScaler // nil unless poller auto scaling is enabled.
+
+		taskSlotsUsed   atomic.Int32 // Number of polled tasks being processed.
+		pollersInFlight atomic.Int32 // Number of pollers waiting for the PollTask call to return.
 	}
 
 	polledTask struct {
@@ -168,7 +180,7 @@ func createPollRetryPolicy() backoff.RetryPolicy {
 	return policy
 }
 
-func newBaseWorker(options baseWorkerOptions, logger *zap.Logger, metricsScope tally.Scope, sessionTokenBucket *sessionTokenBucket) *baseWorker {
+func newBaseWorker(options baseWorkerOptions, logger *zap.Logger, metricsHandler MetricsHandler, sessionTokenBucket *sessionTokenBucket) *baseWorker {
 	ctx, cancel := context.WithCancel(context.Background())
 	bw := &baseWorker{
 		options:         options,
@@ -176,7 +188,7 @@ func newBaseWorker(options baseWorkerOptions, logger *zap.Logger, metricsScope t
 		taskLimiter:     rate.NewLimiter(rate.Limit(options.maxTaskPerSecond), 1),
 		retrier:         backoff.NewConcurrentRetrier(pollOperationRetryPolicy),
 		logger:          logger.With(zapcore.Field{Key: tagWorkerType, Type: zapcore.StringType, String: options.workerType}),
-		metricsScope:    tagScope(metricsScope, tagWorkerType, options.workerType),
+		metricsHandler:  tagMetricsHandler(metricsHandler, tagWorkerType, options.workerType),
 		pollerRequestCh: make(chan struct{}, options.maxConcurrentTask),
 		taskQueueCh:     make(chan interface{}), // no buffer, so poller only able to poll new task after previous is dispatched.
 
@@ -187,6 +199,12 @@ func newBaseWorker(options baseWorkerOptions, logger *zap.Logger, metricsScope t
 	if options.pollerRate > 0 {
 		bw.pollLimiter = rate.NewLimiter(rate.Limit(options.pollerRate), 1)
 	}
+	if options.pollerAutoScaling {
+		bw.pollerAutoScaler = newPollerAutoScaler(options.minPollerCount, options.pollerCount, bw.metricsHandler)
+	}
+	if sessionTokenBucket != nil {
+		sessionTokenBucket.setMetricsHandler(tagMetricsHandler(metricsHandler, tagWorkerType, sessionWorkerType))
+	}
 
 	return bw
 }
@@ -197,7 +215,9 @@ func (bw *baseWorker) Start() {
 		return
 	}
 
-	bw.metricsScope.Counter(metrics.WorkerStartCounter).Inc(1)
+	bw.metricsHandler.Counter(metrics.WorkerStartCounter).Inc(1)
+	bw.updateTaskSlotsGauges(0)
+	bw.metricsHandler.Gauge(metrics.PollersInFlight).Update(0)
 
 	for i := 0; i < bw.options.pollerCount; i++ {
 		bw.stopWG.Add(1)
@@ -211,6 +231,7 @@ func (bw *baseWorker) Start() {
 	traceLog(func() {
 		bw.logger.Info("Started Worker",
 			zap.Int("PollerCount", bw.options.pollerCount),
+			zap.Bool("PollerAutoScaling", bw.options.pollerAutoScaling),
 			zap.Int("MaxConcurrentTask", bw.options.maxConcurrentTask),
 			zap.Float64("MaxTaskPerSecond", bw.options.maxTaskPerSecond),
 		)
@@ -228,13 +249,18 @@ func (bw *baseWorker) isStop() bool {
 
 func (bw *baseWorker) runPoller() {
 	defer bw.stopWG.Done()
-	bw.metricsScope.Counter(metrics.PollerStartCounter).Inc(1)
+	bw.metricsHandler.Counter(metrics.PollerStartCounter).Inc(1)
 
 	for {
+		if bw.pollerAutoScaler != nil && !bw.pollerAutoScaler.acquire() {
+			return
+		}
+		waitStartTime := time.Now()
 		select {
 		case <-bw.stopCh:
 			return
 		case <-bw.pollerRequestCh:
+			bw.metricsHandler.Timer(metrics.WorkerTaskSlotWaitLatency).Record(time.Since(waitStartTime))
 			if bw.sessionTokenBucket != nil {
 				bw.sessionTokenBucket.waitForAvailableToken()
 			}
@@ -274,7 +300,9 @@ func (bw *baseWorker) pollTask() {
 	var task interface{}
 	bw.retrier.Throttle()
 	if bw.pollLimiter == nil || bw.pollLimiter.Wait(bw.limiterContext) == nil {
+		bw.metricsHandler.Gauge(metrics.PollersInFlight).Update(float64(bw.pollersInFlight.Inc()))
 		task, err = bw.options.taskWorker.PollTask()
+		bw.metricsHandler.Gauge(metrics.PollersInFlight).Update(float64(bw.pollersInFlight.Dec()))
 		if err != nil && enableVerboseLogging {
 			bw.logger.Debug("Failed to poll for task.", zap.Error(err))
 		}
@@ -284,6 +312,10 @@ func (bw *baseWorker) pollTask() {
 			bw.retrier.Succeeded()
 		}
 	}
+	if bw.pollerAutoScaler != nil {
+		// Slots not taken by pollers or running tasks are left in pollerRequestCh.
+		bw.pollerAutoScaler.release(task, err, len(bw.pollerRequestCh) > 0)
+	}
 
 	if task != nil {
 		select {
@@ -302,10 +334,11 @@ func (bw *baseWorker) processTask(task interface{}) {
 	polledTask, isPolledTask := task.(*polledTask)
 	if isPolledTask {
 		task = polledTask.task
+		bw.updateTaskSlotsGauges(bw.taskSlotsUsed.Inc())
 	}
 	defer func() {
 		if p := recover(); p != nil {
-			bw.metricsScope.Counter(metrics.WorkerPanicCounter).Inc(1)
+			bw.metricsHandler.Counter(metrics.WorkerPanicCounter).Inc(1)
 			topLine := fmt.Sprintf("base worker for %s [panic]:", bw.options.workerType)
 			st := getStackTraceRaw(topLine, 7, 0)
 			bw.logger.Error("Unhandled panic.",
@@ -314,6 +347,7 @@ func (bw *baseWorker) processTask(task interface{}) {
 		}
 
 		if isPolledTask {
+			bw.updateTaskSlotsGauges(bw.taskSlotsUsed.Dec())
 			bw.pollerRequestCh <- struct{}{}
 		}
 	}()
@@ -327,6 +361,11 @@ func (bw *baseWorker) processTask(task interface{}) {
 	}
 }
 
+func (bw *baseWorker) updateTaskSlotsGauges(used int32) {
+	bw.metricsHandler.Gauge(metrics.WorkerTaskSlotsUsed).Update(float64(used))
+	bw.metricsHandler.Gauge(metrics.WorkerTaskSlotsAvailable).Update(float64(int32(bw.options.maxConcurrentTask) - used))
+}
+
 func (bw *baseWorker) Run() {
 	bw.Start()
 	d := <-getKillSignal()
@@ -343,6 +382,9 @@ func (bw *baseWorker) Stop() {
 	}
 	close(bw.stopCh)
 	bw.limiterContextCancel()
+	if bw.pollerAutoScaler != nil {
+		bw.pollerAutoScaler.stop()
+	}
 
 	if success := awaitWaitGroup(&bw.stopWG, bw.options.stopTimeout); !success {
 		traceLog(func() {
@@ -0,0 +1,185 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.temporal.io/temporal-proto/workflowservice/v1"
+	"go.uber.org/zap"
+
+	"go.temporal.io/temporal/internal/common/metrics"
+)
+
+type blockingTaskPoller struct {
+	tasks      chan interface{}
+	processing chan interface{}
+	release    chan struct{}
+}
+
+func newBlockingTaskPoller() *blockingTaskPoller {
+	return &blockingTaskPoller{
+		tasks:      make(chan interface{}),
+		processing: make(chan interface{}),
+		release:    make(chan struct{}),
+	}
+}
+
+func (p *blockingTaskPoller) PollTask() (interface{}, error) {
+	select {
+	case task := <-p.tasks:
+		return task, nil
+	case <-time.After(10 * time.Millisecond):
+		return nil, nil
+	}
+}
+
+func (p *blockingTaskPoller) ProcessTask(task interface{}) error {
+	p.processing <- task
+	<-p.release
+	return nil
+}
+
+func gaugeValue(scope tally.TestScope, name, workerType string) float64 {
+	gauge, ok := scope.Snapshot().Gauges()[name+"+"+tagWorkerType+"="+workerType]
+	if !ok {
+		return -1
+	}
+	return gauge.Value()
+}
+
+func TestBaseWorker_SlotsGauges(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	poller := newBlockingTaskPoller()
+	worker := newBaseWorker(baseWorkerOptions{
+		pollerCount:       1,
+		maxConcurrentTask: 2,
+		maxTaskPerSecond:  1000,
+		taskWorker:        poller,
+		workerType:        "ActivityWorker",
+		stopTimeout:       time.Second,
+	}, zap.NewNop(), NewTallyMetricsHandler(scope), nil)
+	worker.Start()
+	defer worker.Stop()
+
+	require.Equal(t, float64(2), gaugeValue(scope, metrics.WorkerTaskSlotsAvailable, "ActivityWorker"))
+	require.Equal(t, float64(0), gaugeValue(scope, metrics.WorkerTaskSlotsUsed, "ActivityWorker"))
+
+	for i := 0; i < 2; i++ {
+		poller.tasks <- i
+		<-poller.processing
+	}
+	require.Equal(t, float64(0), gaugeValue(scope, metrics.WorkerTaskSlotsAvailable, "ActivityWorker"))
+	require.Equal(t, float64(2), gaugeValue(scope, metrics.WorkerTaskSlotsUsed, "ActivityWorker"))
+	// All slots are taken, so the poller waits for a slot rather than polling.
+	require.Equal(t, float64(0), gaugeValue(scope, metrics.PollersInFlight, "ActivityWorker"))
+
+	poller.release <- struct{}{}
+	require.Eventually(t, func() bool {
+		return gaugeValue(scope, metrics.WorkerTaskSlotsUsed, "ActivityWorker") == 1
+	}, time.Second, time.Millisecond)
+	require.Equal(t, float64(1), gaugeValue(scope, metrics.WorkerTaskSlotsAvailable, "ActivityWorker"))
+	require.Eventually(t, func() bool {
+		return gaugeValue(scope, metrics.PollersInFlight, "ActivityWorker") == 1
+	}, time.Second, time.Millisecond)
+
+	timer, ok := scope.Snapshot().Timers()[metrics.WorkerTaskSlotWaitLatency+"+"+tagWorkerType+"=ActivityWorker"]
+	require.True(t, ok)
+	require.NotEmpty(t, timer.Values())
+
+	poller.release <- struct{}{}
+}
+
+func TestSessionTokenBucket_SlotsGauges(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	bucket := newSessionTokenBucket(2)
+	newBaseWorker(baseWorkerOptions{workerType: "ActivityWorker"}, zap.NewNop(), NewTallyMetricsHandler(scope), bucket)
+
+	require.Equal(t, float64(2), gaugeValue(scope, metrics.WorkerTaskSlotsAvailable, sessionWorkerType))
+	require.True(t, bucket.getToken())
+	require.Equal(t, float64(1), gaugeValue(scope, metrics.WorkerTaskSlotsAvailable, sessionWorkerType))
+	require.Equal(t, float64(1), gaugeValue(scope, metrics.WorkerTaskSlotsUsed, sessionWorkerType))
+	bucket.addToken()
+	require.Equal(t, float64(2), gaugeValue(scope, metrics.WorkerTaskSlotsAvailable, sessionWorkerType))
+	require.Equal(t, float64(0), gaugeValue(scope, metrics.WorkerTaskSlotsUsed, sessionWorkerType))
+}
+
+func TestPollerAutoScaler(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	scaler := newPollerAutoScaler(1, 3, NewTallyMetricsHandler(scope))
+	target := func() float64 {
+		return scope.Snapshot().Gauges()[metrics.PollersTarget+"+"].Value()
+	}
+	require.Equal(t, float64(1), target())
+
+	poll := func(task interface{}, err error, hasFreeSlots bool) {
+		require.True(t, scaler.acquire())
+		scaler.release(task, err, hasFreeSlots)
+	}
+	activityTaskWithTask := &activityTask{task: &workflowservice.PollForActivityTaskResponse{}}
+
+	// Tasks with free slots scale up to the max.
+	for i := 0; i < 5; i++ {
+		poll(activityTaskWithTask, nil, true)
+	}
+	require.Equal(t, float64(3), target())
+
+	// Failed polls, polls without free slots and decision tasks without backlog keep the target.
+	poll(nil, errors.New("poll failed"), true)
+	poll(activityTaskWithTask, nil, false)
+	poll(&workflowTask{task: &workflowservice.PollForDecisionTaskResponse{BacklogCountHint: 0}}, nil, true)
+	require.Equal(t, float64(3), target())
+
+	// Empty polls scale down to the min.
+	for i := 0; i < 5; i++ {
+		poll(&activityTask{}, nil, true)
+	}
+	require.Equal(t, float64(1), target())
+
+	poll(&workflowTask{task: &workflowservice.PollForDecisionTaskResponse{BacklogCountHint: 10}}, nil, true)
+	require.Equal(t, float64(2), target())
+}
+
+func TestPollerAutoScaler_LimitsConcurrentPolls(t *testing.T) {
+	scaler := newPollerAutoScaler(1, 2, metrics.NoopHandler)
+	require.True(t, scaler.acquire())
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- scaler.acquire()
+	}()
+	select {
+	case <-acquired:
+		require.Fail(t, "acquire must block while active pollers reach the target")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	scaler.stop()
+	require.False(t, <-acquired)
+}
@@ -28,7 +28,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
@@ -47,10 +49,13 @@ import (
 	namespacepb "go.temporal.io/temporal-proto/namespace/v1"
 	"go.temporal.io/temporal-proto/serviceerror"
 	tasklistpb "go.temporal.io/temporal-proto/tasklist/v1"
+	workflowpb "go.temporal.io/temporal-proto/workflow/v1"
 	"go.temporal.io/temporal-proto/workflowservice/v1"
 	"go.temporal.io/temporal-proto/workflowservicemock/v1"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+
+	"go.temporal.io/temporal/internal/common/serializer"
 )
 
 func testInternalWorkerRegister(r *registry) {
@@ -639,6 +644,362 @@ func (s *internalWorkerTestSuite) TestReplayWorkflowHistoryFromFile() {
 	require.NoError(s.T(), err)
 }
 
+func testReplayWorkflowPanic(Context) error {
+	panic("test panic")
+}
+
+func createTestReplayWorkflowHistory(workflowType string, activityType string, result *commonpb.Payloads) *historypb.History {
+	taskList := "taskList1"
+	return &historypb.History{Events: []*historypb.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &historypb.WorkflowExecutionStartedEventAttributes{
+			WorkflowType: &commonpb.WorkflowType{Name: workflowType},
+			TaskList:     &tasklistpb.TaskList{Name: taskList},
+			Input:        testEncodeFunctionArgs(getDefaultDataConverter()),
+		}),
+		createTestEventDecisionTaskScheduled(2, &historypb.DecisionTaskScheduledEventAttributes{}),
+		createTestEventDecisionTaskStarted(3),
+		createTestEventDecisionTaskCompleted(4, &historypb.DecisionTaskCompletedEventAttributes{}),
+		createTestEventActivityTaskScheduled(5, &historypb.ActivityTaskScheduledEventAttributes{
+			ActivityId:   "5",
+			ActivityType: &commonpb.ActivityType{Name: activityType},
+			TaskList:     &tasklistpb.TaskList{Name: taskList},
+		}),
+		createTestEventActivityTaskStarted(6, &historypb.ActivityTaskStartedEventAttributes{
+			ScheduledEventId: 5,
+		}),
+		createTestEventActivityTaskCompleted(7, &historypb.ActivityTaskCompletedEventAttributes{
+			ScheduledEventId: 5,
+			StartedEventId:   6,
+		}),
+		createTestEventDecisionTaskScheduled(8, &historypb.DecisionTaskScheduledEventAttributes{}),
+		createTestEventDecisionTaskStarted(9),
+		createTestEventDecisionTaskCompleted(10, &historypb.DecisionTaskCompletedEventAttributes{
+			ScheduledEventId: 8,
+			StartedEventId:   9,
+		}),
+		createTestEventWorkflowExecutionCompleted(11, &historypb.WorkflowExecutionCompletedEventAttributes{
+			Result:                       result,
+			DecisionTaskCompletedEventId: 10,
+		}),
+	}}
+}
+
+func (s *internalWorkerTestSuite) TestReplayWorkflowHistories() {
+	result, _ := DefaultDataConverter.ToPayloads("some-incorrect-result")
+	// replay decisions of the workflow that is not completed are matched to the history
+	nondeterministic := createTestReplayWorkflowHistory("testReplayWorkflow", "otherActivity", nil)
+	nondeterministic.Events = nondeterministic.Events[:7]
+	iterator := NewReplayHistoryIterator(
+		&ReplayHistory{Name: "ok", History: createTestReplayWorkflowHistory("testReplayWorkflow", "testActivity", nil)},
+		&ReplayHistory{Name: "nondeterminism", History: nondeterministic},
+		&ReplayHistory{Name: "panic", History: createTestReplayWorkflowHistory("testReplayWorkflowPanic", "testActivity", nil)},
+		&ReplayHistory{Name: "result", History: createTestReplayWorkflowHistory("testReplayWorkflow", "testActivity", result)},
+		&ReplayHistory{Name: "error", History: createTestReplayWorkflowHistory("unknownWorkflow", "testActivity", nil)},
+	)
+	replayer := NewWorkflowReplayer()
+	replayer.RegisterWorkflow(testReplayWorkflow)
+	replayer.RegisterWorkflow(testReplayWorkflowPanic)
+	report, err := replayer.ReplayWorkflowHistories(context.Background(), getLogger(), iterator, ReplayWorkflowHistoriesOptions{Concurrency: 2})
+	s.NoError(err)
+	s.Len(report.Results, 5)
+
+	s.Equal("ok", report.Results[0].Name)
+	s.Equal(ReplayOutcomeOK, report.Results[0].Outcome)
+	s.NoError(report.Results[0].Error)
+
+	nondeterminism := report.Results[1]
+	s.Equal(ReplayOutcomeNondeterminism, nondeterminism.Outcome)
+	s.Equal("testActivity", nondeterminism.Decision.GetScheduleActivityTaskDecisionAttributes().GetActivityType().GetName())
+	s.Equal(int64(5), nondeterminism.Event.GetEventId())
+
+	s.Equal(ReplayOutcomePanic, report.Results[2].Outcome)
+	s.Contains(report.Results[2].Error.Error(), "test panic")
+
+	s.Equal(ReplayOutcomeResultMismatch, report.Results[3].Outcome)
+	s.Equal(ReplayOutcomeError, report.Results[4].Outcome)
+
+	s.Len(report.Failed(), 4)
+	s.Contains(report.String(), "replayed 5 histories, 4 failed")
+}
+
+func (s *internalWorkerTestSuite) TestReplayWorkflowHistories_Directory() {
+	iterator, err := NewReplayHistoryIteratorFromDirectory("testdata")
+	s.NoError(err)
+	replayer := NewWorkflowReplayer()
+	replayer.RegisterWorkflow(testReplayWorkflowFromFile)
+	replayer.RegisterWorkflow(testReplayWorkflowFromFileParent)
+	report, err := replayer.ReplayWorkflowHistories(context.Background(), getLogger(), iterator, ReplayWorkflowHistoriesOptions{})
+	s.NoError(err)
+	s.Len(report.Results, 2)
+	s.Equal("parentWF.json", report.Results[0].Name)
+	s.Equal("sampleHistory.json", report.Results[1].Name)
+	s.Empty(report.Failed(), report.String())
+}
+
+func (s *internalWorkerTestSuite) TestReplayWorkflowHistories_ListWorkflow() {
+	client := NewServiceClient(s.service, nil, ClientOptions{})
+	listRequest := &workflowservice.ListWorkflowExecutionsRequest{Query: "WorkflowType = 'testReplayWorkflow'"}
+	gomock.InOrder(
+		s.service.EXPECT().ListWorkflowExecutions(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&workflowservice.ListWorkflowExecutionsResponse{
+				Executions: []*workflowpb.WorkflowExecutionInfo{
+					{Execution: &commonpb.WorkflowExecution{WorkflowId: "wid1", RunId: "rid1"}},
+				},
+				NextPageToken: []byte("token"),
+			}, nil),
+		s.service.EXPECT().ListWorkflowExecutions(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ interface{}, request *workflowservice.ListWorkflowExecutionsRequest, _ ...interface{}) (*workflowservice.ListWorkflowExecutionsResponse, error) {
+				s.Equal(listRequest.Query, request.Query)
+				s.Equal([]byte("token"), request.NextPageToken)
+				return &workflowservice.ListWorkflowExecutionsResponse{
+					Executions: []*workflowpb.WorkflowExecutionInfo{
+						{Execution: &commonpb.WorkflowExecution{WorkflowId: "wid2", RunId: "rid2"}},
+					},
+				}, nil
+			}),
+	)
+	history := createTestReplayWorkflowHistory("testReplayWorkflow", "testActivity", nil)
+	s.service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&workflowservice.GetWorkflowExecutionHistoryResponse{History: history}, nil).Times(2)
+
+	replayer := NewWorkflowReplayer()
+	replayer.RegisterWorkflow(testReplayWorkflow)
+	iterator := NewReplayHistoryIteratorFromListWorkflow(context.Background(), client, listRequest)
+	report, err := replayer.ReplayWorkflowHistories(context.Background(), getLogger(), iterator, ReplayWorkflowHistoriesOptions{})
+	s.NoError(err)
+	s.Len(report.Results, 2)
+	s.Equal("wid1/rid1", report.Results[0].Name)
+	s.Equal("wid2/rid2", report.Results[1].Name)
+	s.Empty(report.Failed(), report.String())
+}
+
+func (s *internalWorkerTestSuite) TestReplayWorkflowHistory_StrictReplay() {
+	// the workflow completes during replay, so only the strict replay matches its decisions with the history
+	nondeterministic := createTestReplayWorkflowHistory("testReplayWorkflow", "otherActivity", nil)
+
+	replayer := NewWorkflowReplayer()
+	replayer.RegisterWorkflow(testReplayWorkflow)
+	s.NoError(replayer.ReplayWorkflowHistory(getLogger(), nondeterministic))
+
+	replayer = NewWorkflowReplayerWithOptions(WorkflowReplayerOptions{EnableStrictReplay: true})
+	replayer.RegisterWorkflow(testReplayWorkflow)
+	s.NoError(replayer.ReplayWorkflowHistory(getLogger(), createTestReplayWorkflowHistory("testReplayWorkflow", "testActivity", nil)))
+	err := replayer.ReplayWorkflowHistory(getLogger(), nondeterministic)
+	s.Error(err)
+	s.Contains(err.Error(), "nondeterministic workflow at event ID 5")
+
+	// the history completes the workflow in place of the activity scheduled by the replay
+	missing := createTestReplayWorkflowHistory("testReplayWorkflow", "testActivity", nil)
+	missing.Events = append(missing.Events[:4], createTestEventWorkflowExecutionCompleted(5, &historypb.WorkflowExecutionCompletedEventAttributes{
+		DecisionTaskCompletedEventId: 4,
+	}))
+	err = replayer.ReplayWorkflowHistory(getLogger(), missing)
+	s.Error(err)
+	s.Contains(err.Error(), "nondeterministic workflow at event ID 5")
+}
+
+func (s *internalWorkerTestSuite) TestReplayWorkflowHistories_StrictReplay() {
+	iterator := NewReplayHistoryIterator(
+		&ReplayHistory{Name: "ok", History: createTestReplayWorkflowHistory("testReplayWorkflow", "testActivity", nil)},
+		&ReplayHistory{Name: "nondeterminism", History: createTestReplayWorkflowHistory("testReplayWorkflow", "otherActivity", nil)},
+	)
+	replayer := NewWorkflowReplayerWithOptions(WorkflowReplayerOptions{EnableStrictReplay: true})
+	replayer.RegisterWorkflow(testReplayWorkflow)
+	report, err := replayer.ReplayWorkflowHistories(context.Background(), getLogger(), iterator, ReplayWorkflowHistoriesOptions{})
+	s.NoError(err)
+	s.Len(report.Results, 2)
+	s.Equal(ReplayOutcomeOK, report.Results[0].Outcome)
+
+	nondeterminism := report.Results[1]
+	s.Equal(ReplayOutcomeNondeterminism, nondeterminism.Outcome)
+	s.Equal(int64(5), nondeterminism.EventID)
+	s.Equal("testActivity", nondeterminism.Decision.GetScheduleActivityTaskDecisionAttributes().GetActivityType().GetName())
+	s.Equal(int64(5), nondeterminism.Event.GetEventId())
+}
+
+func (s *internalWorkerTestSuite) TestReplayWorkflowExecution() {
+	history := createTestReplayWorkflowHistory("testReplayWorkflow", "testActivity", nil)
+	rawHistory, err := serializer.SerializeBatchEvents(history.Events[:5], enumspb.ENCODING_TYPE_PROTO3)
+	s.NoError(err)
+	execution := WorkflowExecution{ID: "wid/1", RunID: "rid1"}
+	gomock.InOrder(
+		s.service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ interface{}, request *workflowservice.GetWorkflowExecutionHistoryRequest, _ ...interface{}) (*workflowservice.GetWorkflowExecutionHistoryResponse, error) {
+				s.Equal(execution.ID, request.Execution.GetWorkflowId())
+				s.Equal(execution.RunID, request.Execution.GetRunId())
+				s.Equal(int32(5), request.MaximumPageSize)
+				s.Nil(request.NextPageToken)
+				return &workflowservice.GetWorkflowExecutionHistoryResponse{
+					RawHistory:    []*commonpb.DataBlob{rawHistory},
+					NextPageToken: []byte("token"),
+				}, nil
+			}),
+		s.service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ interface{}, request *workflowservice.GetWorkflowExecutionHistoryRequest, _ ...interface{}) (*workflowservice.GetWorkflowExecutionHistoryResponse, error) {
+				s.Equal([]byte("token"), request.NextPageToken)
+				return &workflowservice.GetWorkflowExecutionHistoryResponse{
+					History: &historypb.History{Events: history.Events[5:]},
+				}, nil
+			}),
+	)
+
+	cacheDir, err := ioutil.TempDir("", "replay-cache")
+	s.NoError(err)
+	defer func() { _ = os.RemoveAll(cacheDir) }()
+	replayer := NewWorkflowReplayerWithOptions(WorkflowReplayerOptions{HistoryPageSize: 5, HistoryCacheDir: cacheDir})
+	replayer.RegisterWorkflow(testReplayWorkflow)
+	s.NoError(replayer.ReplayWorkflowExecution(context.Background(), s.service, getLogger(), "namespace", execution))
+
+	// the history is replayed from the cache without calling the service
+	cacheFile := filepath.Join(cacheDir, "wid%2F1_rid1.json")
+	s.FileExists(cacheFile)
+	files, err := ioutil.ReadDir(cacheDir)
+	s.NoError(err)
+	s.Len(files, 1, "temporary file is left in the cache")
+	s.NoError(replayer.ReplayWorkflowExecution(context.Background(), s.service, getLogger(), "namespace", execution))
+	s.NoError(replayer.ReplayWorkflowHistoryFromJSONFile(getLogger(), cacheFile))
+}
+
+func (s *internalWorkerTestSuite) TestReplayWorkflowExecution_OpenExecutionNotCached() {
+	history := createTestReplayWorkflowHistory("testReplayWorkflow", "testActivity", nil)
+	history.Events = history.Events[:7]
+	s.service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&workflowservice.GetWorkflowExecutionHistoryResponse{History: history}, nil).Times(2)
+
+	cacheDir, err := ioutil.TempDir("", "replay-cache")
+	s.NoError(err)
+	defer func() { _ = os.RemoveAll(cacheDir) }()
+	replayer := NewWorkflowReplayerWithOptions(WorkflowReplayerOptions{HistoryCacheDir: cacheDir})
+	replayer.RegisterWorkflow(testReplayWorkflow)
+	execution := WorkflowExecution{ID: "wid", RunID: "rid"}
+	// the history is loaded from the service every time, as it changes until the execution is closed
+	s.NoError(replayer.ReplayWorkflowExecution(context.Background(), s.service, getLogger(), "namespace", execution))
+	s.NoError(replayer.ReplayWorkflowExecution(context.Background(), s.service, getLogger(), "namespace", execution))
+	files, err := ioutil.ReadDir(cacheDir)
+	s.NoError(err)
+	s.Empty(files)
+}
+
+func testReplayWorkflowDebug(ctx Context) error {
+	state := "started"
+	if err := SetQueryHandler(ctx, "state", func() (string, error) {
+		return state, nil
+	}); err != nil {
+		return err
+	}
+	ao := ActivityOptions{
+		ScheduleToStartTimeout: time.Second,
+		StartToCloseTimeout:    time.Second,
+	}
+	ctx = WithActivityOptions(ctx, ao)
+	err := ExecuteActivity(ctx, "testActivity").Get(ctx, nil)
+	state = "activity completed"
+	return err
+}
+
+func (s *internalWorkerTestSuite) TestReplayWorkflowHistory_Debugger() {
+	var steps []string
+	debugger := func(step *ReplayStep) error {
+		var state string
+		if value, err := step.Query("state"); err == nil {
+			s.NoError(value.Get(&state))
+		}
+		steps = append(steps, fmt.Sprintf("%v %v %v", step.Type, step.Event.GetEventId(), state))
+		s.True(step.IsReplaying)
+		s.Equal("testReplayWorkflowDebug", step.WorkflowInfo.WorkflowType.Name)
+
+		if step.Type == ReplayStepDecision && step.Event.GetEventId() == 3 {
+			s.Equal(enumspb.DECISION_TYPE_SCHEDULE_ACTIVITY_TASK, step.Decision.GetDecisionType())
+			s.Equal([]ReplayDecisionState{
+				{Type: "Activity", ID: "5", State: "Created", Decision: step.Decision},
+			}, step.PendingDecisions)
+			s.Contains(step.StackTrace, "testReplayWorkflowDebug")
+		}
+		if step.Type == ReplayStepDecision && step.Event.GetEventId() == 9 {
+			// the decision that closes the workflow
+			s.Equal(enumspb.DECISION_TYPE_COMPLETE_WORKFLOW_EXECUTION, step.Decision.GetDecisionType())
+			s.Empty(step.PendingDecisions)
+		}
+		if step.Event.GetEventId() == 5 {
+			s.Equal("Initiated", step.PendingDecisions[0].State)
+			s.Nil(step.PendingDecisions[0].Decision)
+		}
+		return nil
+	}
+	history := createTestReplayWorkflowHistory("testReplayWorkflowDebug", "testActivity", nil)
+	replayer := NewWorkflowReplayerWithOptions(WorkflowReplayerOptions{ReplayDebugger: debugger})
+	replayer.RegisterWorkflow(testReplayWorkflowDebug)
+	s.NoError(replayer.ReplayWorkflowHistory(getLogger(), history))
+	s.Equal([]string{
+		"Event 1 ",
+		"Event 3 started",
+		"Decision 3 started",
+		"Event 4 started",
+		"Event 5 started",
+		"Event 6 started",
+		"Event 7 started",
+		"Event 9 activity completed",
+		"Decision 9 activity completed",
+	}, steps)
+
+	// error returned by the debugger stops the replay
+	steps = nil
+	replayer = NewWorkflowReplayerWithOptions(WorkflowReplayerOptions{ReplayDebugger: func(step *ReplayStep) error {
+		steps = append(steps, step.Type.String())
+		if step.Type == ReplayStepDecision {
+			return errors.New("stop")
+		}
+		return nil
+	}})
+	replayer.RegisterWorkflow(testReplayWorkflowDebug)
+	s.EqualError(replayer.ReplayWorkflowHistory(getLogger(), history), "stop")
+	s.Equal([]string{"Event", "Event", "Decision"}, steps)
+}
+
+func testReplayWorkflowGreeting(_ Context, name string) (string, error) {
+	return "Hello " + name, nil
+}
+
+func (s *internalWorkerTestSuite) TestReplayWorkflowHistory_Options() {
+	dc := newTestDataConverter()
+	input, err := dc.ToPayloads("World")
+	s.NoError(err)
+	result, err := dc.ToPayloads("Hello World")
+	s.NoError(err)
+	history := &historypb.History{Events: []*historypb.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &historypb.WorkflowExecutionStartedEventAttributes{
+			WorkflowType: &commonpb.WorkflowType{Name: "testReplayWorkflowGreeting"},
+			TaskList:     &tasklistpb.TaskList{Name: "taskList1"},
+			Input:        input,
+		}),
+		createTestEventDecisionTaskScheduled(2, &historypb.DecisionTaskScheduledEventAttributes{}),
+		createTestEventDecisionTaskStarted(3),
+		createTestEventDecisionTaskCompleted(4, &historypb.DecisionTaskCompletedEventAttributes{}),
+		createTestEventWorkflowExecutionCompleted(5, &historypb.WorkflowExecutionCompletedEventAttributes{
+			Result:                       result,
+			DecisionTaskCompletedEventId: 4,
+		}),
+	}}
+
+	// the history payloads can't be decoded by the default data converter
+	replayer := NewWorkflowReplayer()
+	replayer.RegisterWorkflow(testReplayWorkflowGreeting)
+	s.Error(replayer.ReplayWorkflowHistory(getLogger(), history))
+
+	interceptorFactory := &tracingInterceptorFactory{}
+	replayer = NewWorkflowReplayerWithOptions(WorkflowReplayerOptions{
+		DataConverter:                     dc,
+		WorkflowInterceptorChainFactories: []WorkflowInterceptorFactory{interceptorFactory},
+	})
+	replayer.RegisterWorkflow(testReplayWorkflowGreeting)
+	s.NoError(replayer.ReplayWorkflowHistory(getLogger(), history))
+	s.Len(interceptorFactory.instances, 1)
+	s.Equal([]string{
+		"ExecuteWorkflow testReplayWorkflowGreeting begin",
+		"ExecuteWorkflow testReplayWorkflowGreeting end",
+	}, interceptorFactory.instances[0].trace)
+}
+
 func (s *internalWorkerTestSuite) testDecisionTaskHandlerHelper(params workerExecutionParameters) {
 	taskList := "taskList1"
 	testEvents := []*historypb.HistoryEvent{
@@ -1452,7 +1813,7 @@ func TestWorkerOptionDefaults(t *testing.T) {
 	decisionWorker := aggWorker.workflowWorker
 	require.True(t, decisionWorker.executionParameters.Identity != "")
 	require.NotNil(t, decisionWorker.executionParameters.Logger)
-	require.NotNil(t, decisionWorker.executionParameters.MetricsScope)
+	require.NotNil(t, decisionWorker.executionParameters.MetricsHandler)
 	require.Nil(t, decisionWorker.executionParameters.ContextPropagators)
 
 	expected := workerExecutionParameters{
@@ -1471,7 +1832,7 @@ func TestWorkerOptionDefaults(t *testing.T) {
 		DataConverter:                        getDefaultDataConverter(),
 		Tracer:                               opentracing.NoopTracer{},
 		Logger:                               decisionWorker.executionParameters.Logger,
-		MetricsScope:                         decisionWorker.executionParameters.MetricsScope,
+		MetricsHandler:                       decisionWorker.executionParameters.MetricsHandler,
 		Identity:                             decisionWorker.executionParameters.Identity,
 		UserContext:                          decisionWorker.executionParameters.UserContext,
 	}
@@ -1481,7 +1842,7 @@ func TestWorkerOptionDefaults(t *testing.T) {
 	activityWorker := aggWorker.activityWorker
 	require.True(t, activityWorker.executionParameters.Identity != "")
 	require.NotNil(t, activityWorker.executionParameters.Logger)
-	require.NotNil(t, activityWorker.executionParameters.MetricsScope)
+	require.NotNil(t, activityWorker.executionParameters.MetricsHandler)
 	require.Nil(t, activityWorker.executionParameters.ContextPropagators)
 	assertWorkerExecutionParamsEqual(t, expected, activityWorker.executionParameters)
 }
@@ -1495,7 +1856,7 @@ func TestWorkerOptionNonDefaults(t *testing.T) {
 		namespace:          "worker-options-test",
 		registry:           nil,
 		identity:           "143@worker-options-test-1",
-		dataConverter:      &defaultDataConverter{},
+		dataConverter:      getDefaultDataConverter(),
 		contextPropagators: nil,
 		tracer:             nil,
 		logger:             zap.NewNop(),
@@ -1536,7 +1897,7 @@ func TestWorkerOptionNonDefaults(t *testing.T) {
 		DataConverter:                        client.dataConverter,
 		Tracer:                               client.tracer,
 		Logger:                               client.logger,
-		MetricsScope:                         client.metricsScope,
+		MetricsHandler:                       client.metricsHandler,
 		Identity:                             client.identity,
 	}
 
@@ -29,8 +29,11 @@ package internal
 import (
 	"errors"
 	"fmt"
+	"os"
 	"reflect"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -48,6 +51,10 @@ const (
 	defaultSignalChannelSize = 100000 // really large buffering size(100K)
 
 	panicIllegalAccessCoroutinueState = "getState: illegal access from outside of workflow context"
+
+	// debugModeEnv is the environment variable that disables the deadlock detection when set to true, so workflow
+	// code can be stopped at debugger breakpoints.
+	debugModeEnv = "TEMPORAL_DEBUG"
 )
 
 type (
@@ -79,6 +86,23 @@ type (
 		settable Settable // used to unblock the future when all coroutines have completed
 	}
 
+	// Implements Semaphore interface
+	semaphoreImpl struct {
+		name    string             // appears in stack traces of coroutines blocked on the semaphore
+		size    int64              // the total number of permits
+		used    int64              // the number of acquired permits
+		waiters []*semaphoreWaiter // coroutines blocked in acquire in the order they called it
+	}
+
+	semaphoreWaiter struct {
+		n int64
+	}
+
+	// Implements Mutex interface
+	mutexImpl struct {
+		semaphore semaphoreImpl
+	}
+
 	// Dispatcher is a container of a set of coroutines.
 	dispatcher interface {
 		// ExecuteUntilAllBlocked executes coroutines one by one in deterministic order
@@ -153,16 +177,22 @@ type (
 		closed       bool             // indicates that owning coroutine has finished execution
 		blocked      atomic.Bool
 		panicError   *workflowPanicError // non nil if coroutine had unhandled panic
+		goroutineID  string              // ID of the goroutine running the coroutine, used to dump its stack
+		deadlocked   chan struct{}       // closed when coroutine didn't yield within the deadlock detection timeout
 	}
 
 	dispatcherImpl struct {
-		sequence         int
-		channelSequence  int // used to name channels
-		selectorSequence int // used to name channels
-		coroutines       []*coroutineState
-		executing        bool       // currently running ExecuteUntilAllBlocked. Used to avoid recursive calls to it.
-		mutex            sync.Mutex // used to synchronize executing
-		closed           bool
+		sequence          int
+		channelSequence   int // used to name channels
+		selectorSequence  int // used to name channels
+		mutexSequence     int // used to name mutexes
+		semaphoreSequence int // used to name semaphores
+		coroutines        []*coroutineState
+		executing         bool       // currently running ExecuteUntilAllBlocked. Used to avoid recursive calls to it.
+		mutex             sync.Mutex // used to synchronize executing
+		closed            bool
+		// deadlockDetectionTimeout is the maximum time a coroutine can run without yielding, 0 disables the detection.
+		deadlockDetectionTimeout time.Duration
 	}
 
 	// WorkflowOptions options passed to the workflow function
@@ -185,7 +215,8 @@ type (
 		SearchAttributes                map[string]interface{}
 		ParentClosePolicy               ParentClosePolicy
 		signalChannels                  map[string]Channel
-		queryHandlers                   map[string]func(*commonpb.Payloads) (*commonpb.Payloads, error)
+		queryHandlers                   map[string]*queryHandler
+		updates                         *updateState
 	}
 
 	// ExecuteWorkflowParams parameters of the workflow invocation
@@ -251,6 +282,8 @@ const (
 var _ Channel = (*channelImpl)(nil)
 var _ Selector = (*selectorImpl)(nil)
 var _ WaitGroup = (*waitGroupImpl)(nil)
+var _ Mutex = (*mutexImpl)(nil)
+var _ Semaphore = (*semaphoreImpl)(nil)
 var _ dispatcher = (*dispatcherImpl)(nil)
 
 var stackBuf [100000]byte
@@ -455,6 +488,8 @@ func newWorkflowInterceptors(env WorkflowEnvironment, factories []WorkflowInterc
 }
 
 func (d *syncWorkflowDefinition) Execute(env WorkflowEnvironment, header *commonpb.Header, input *commonpb.Payloads) {
+	// header which can't be decoded fails the workflow instead of panicking the decision task forever
+	header, headerErr := decodeHeader(env.GetDataConverter(), header)
 	interceptors, envInterceptor := newWorkflowInterceptors(env, env.GetRegistry().getInterceptors())
 	dispatcher, rootCtx := newDispatcher(newWorkflowContext(env, interceptors, envInterceptor), func(ctx Context) {
 		r := &workflowResult{}
@@ -466,7 +501,11 @@ func (d *syncWorkflowDefinition) Execute(env WorkflowEnvironment, header *common
 		state.yield("yield before executing to setup state")
 
 		// TODO: @shreyassrivatsan - add workflow trace span here
-		r.workflowResult, r.error = d.workflow.Execute(d.rootCtx, input)
+		if headerErr != nil {
+			r.error = fmt.Errorf("unable to decode header: %w", headerErr)
+		} else {
+			r.workflowResult, r.error = d.workflow.Execute(d.rootCtx, input)
+		}
 		rpp := getWorkflowResultPointerPointer(ctx)
 		*rpp = r
 	})
@@ -481,6 +520,9 @@ func (d *syncWorkflowDefinition) Execute(env WorkflowEnvironment, header *common
 
 	d.rootCtx, d.cancel = WithCancel(rootCtx)
 	d.dispatcher = dispatcher
+	if debugMode, _ := strconv.ParseBool(os.Getenv(debugModeEnv)); !debugMode {
+		dispatcher.deadlockDetectionTimeout = env.GetDeadlockDetectionTimeout()
+	}
 
 	getWorkflowEnvironment(d.rootCtx).RegisterCancelHandler(func() {
 		// It is ok to call this method multiple times.
@@ -489,26 +531,26 @@ func (d *syncWorkflowDefinition) Execute(env WorkflowEnvironment, header *common
 	})
 
 	getWorkflowEnvironment(d.rootCtx).RegisterSignalHandler(func(name string, result *commonpb.Payloads) {
-		eo := getWorkflowEnvOptions(d.rootCtx)
-		// We don't want this code to be blocked ever, using sendAsync().
-		ch := eo.getSignalChannel(d.rootCtx, name).(*channelImpl)
-		ok := ch.SendAsync(result)
-		if !ok {
-			panic(fmt.Sprintf("Exceeded channel buffer size for signal: %v", name))
-		}
+		handleSignal(d.rootCtx, interceptors, name, result)
 	})
 
 	getWorkflowEnvironment(d.rootCtx).RegisterQueryHandler(func(queryType string, queryArgs *commonpb.Payloads) (*commonpb.Payloads, error) {
 		eo := getWorkflowEnvOptions(d.rootCtx)
+		switch queryType {
+		case QueryTypeQueryTypes:
+			return encodeArg(getDataConverterFromWorkflowContext(d.rootCtx), eo.getQueryHandlers())
+		case updateResultQueryType:
+			return eo.updates.queryResult(getDataConverterFromWorkflowContext(d.rootCtx), queryArgs)
+		}
 		handler, ok := eo.queryHandlers[queryType]
 		if !ok {
-			keys := []string{QueryTypeStackTrace, QueryTypeOpenSessions}
+			keys := []string{QueryTypeStackTrace, QueryTypeOpenSessions, QueryTypeQueryTypes}
 			for k := range eo.queryHandlers {
 				keys = append(keys, k)
 			}
 			return nil, fmt.Errorf("unknown queryType %v. KnownQueryTypes=%v", queryType, keys)
 		}
-		return handler(queryArgs)
+		return handler.execute(queryArgs)
 	})
 }
 
@@ -570,6 +612,10 @@ func getState(ctx Context) *coroutineState {
 		panic("getState: not workflow context")
 	}
 	state := s.(*coroutineState)
+	if state.isDeadlocked() {
+		// The dispatcher abandoned the coroutine, so it exits instead of running workflow code concurrently.
+		runtime.Goexit()
+	}
 	if !state.dispatcher.executing {
 		panic(panicIllegalAccessCoroutinueState)
 	}
@@ -789,7 +835,13 @@ func (s *coroutineState) initialYield(stackDepth int, status string) {
 	}
 	keepBlocked := true
 	for keepBlocked {
-		f := <-s.unblock
+		var f unblockFunc
+		select {
+		case f = <-s.unblock:
+		case <-s.deadlocked:
+			// The dispatcher abandoned the coroutine, so it exits instead of running workflow code concurrently.
+			runtime.Goexit()
+		}
 		keepBlocked = f(status, stackDepth+1)
 	}
 	s.blocked.Swap(false)
@@ -828,18 +880,44 @@ func (s *coroutineState) unblocked() {
 	s.keptBlocked = false
 }
 
-func (s *coroutineState) call() {
+// call unblocks the coroutine and waits until it blocks again. It returns false if the coroutine doesn't block within
+// the timeout, unless the timeout is 0.
+func (s *coroutineState) call(timeout time.Duration) bool {
 	s.unblock <- func(status string, stackDepth int) bool {
 		return false // unblock
 	}
-	<-s.aboutToBlock
+	if timeout <= 0 {
+		<-s.aboutToBlock
+		return true
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-s.aboutToBlock:
+		return true
+	case <-timer.C:
+		return false
+	}
 }
 
 func (s *coroutineState) close() {
 	s.closed = true
+	if s.isDeadlocked() {
+		return // the dispatcher doesn't wait for the abandoned coroutine
+	}
 	s.aboutToBlock <- true
 }
 
+// isDeadlocked returns true if the coroutine didn't yield within the deadlock detection timeout.
+func (s *coroutineState) isDeadlocked() bool {
+	select {
+	case <-s.deadlocked:
+		return true
+	default:
+		return false
+	}
+}
+
 func (s *coroutineState) exit() {
 	if !s.closed {
 		s.unblock <- func(status string, stackDepth int) bool {
@@ -850,6 +928,9 @@ func (s *coroutineState) exit() {
 }
 
 func (s *coroutineState) stackTrace() string {
+	if s.isDeadlocked() {
+		return s.runningStackTrace()
+	}
 	if s.closed {
 		return ""
 	}
@@ -861,6 +942,34 @@ func (s *coroutineState) stackTrace() string {
 	return <-stackCh
 }
 
+// runningStackTrace returns the stack trace of the coroutine that doesn't yield. It can't be unblocked to report its
+// stack trace, so it is found in the stack traces of all the goroutines.
+func (s *coroutineState) runningStackTrace() string {
+	top := fmt.Sprintf("coroutine %s [running]:", s.name)
+	buf := make([]byte, 1<<20)
+	stacks := strings.Split(string(buf[:runtime.Stack(buf, true)]), "\n\n")
+	for _, stack := range stacks {
+		if !strings.HasPrefix(stack, "goroutine "+s.goroutineID+" [") {
+			continue
+		}
+		lines := strings.Split(strings.TrimRightFunc(stack, unicode.IsSpace), "\n")
+		if disableCleanStackTraces || len(lines) < 5 {
+			return strings.Join(lines, "\n")
+		}
+		// Omit goroutine status line and bottom two frames which is wrapping of coroutine in a goroutine.
+		lines = append([]string{top}, lines[1:len(lines)-4]...)
+		return strings.Join(lines, "\n")
+	}
+	return top
+}
+
+// getGoroutineID returns the ID of the calling goroutine as printed in its stack trace.
+func getGoroutineID() string {
+	var buf [64]byte
+	stack := strings.TrimPrefix(string(buf[:runtime.Stack(buf[:], false)]), "goroutine ")
+	return stack[:strings.IndexByte(stack, ' ')]
+}
+
 func (d *dispatcherImpl) newCoroutine(ctx Context, f func(ctx Context)) Context {
 	return d.newNamedCoroutine(ctx, fmt.Sprintf("%v", d.sequence+1), f)
 }
@@ -876,6 +985,7 @@ func (d *dispatcherImpl) newNamedCoroutine(ctx Context, name string, f func(ctx
 				crt.panicError = newWorkflowPanicError(r, st)
 			}
 		}()
+		crt.goroutineID = getGoroutineID()
 		crt.initialYield(1, "")
 		f(spawned)
 	}(state)
@@ -888,6 +998,7 @@ func (d *dispatcherImpl) newState(name string) *coroutineState {
 		dispatcher:   d,
 		aboutToBlock: make(chan bool, 1),
 		unblock:      make(chan unblockFunc),
+		deadlocked:   make(chan struct{}),
 	}
 	d.sequence++
 	d.coroutines = append(d.coroutines, c)
@@ -916,7 +1027,9 @@ func (d *dispatcherImpl) ExecuteUntilAllBlocked() (err error) {
 			if !c.closed {
 				// TODO: Support handling of panic in a coroutine by dispatcher.
 				// TODO: Dump all outstanding coroutines if one of them panics
-				c.call()
+				if !c.call(d.deadlockDetectionTimeout) {
+					return d.newDeadlockError(c)
+				}
 			}
 			// c.call() can close the context so check again
 			if c.closed {
@@ -942,6 +1055,15 @@ func (d *dispatcherImpl) ExecuteUntilAllBlocked() (err error) {
 	return nil
 }
 
+// newDeadlockError returns the error of the coroutine that didn't yield within the deadlock detection timeout. The
+// coroutine can't be unblocked anymore, so its goroutine is abandoned and exits at its next yield.
+func (d *dispatcherImpl) newDeadlockError(c *coroutineState) error {
+	close(c.deadlocked)
+	return newWorkflowPanicError(
+		fmt.Sprintf("Potential deadlock detected: workflow coroutine %q didn't yield for over %v", c.name, d.deadlockDetectionTimeout),
+		d.StackTrace())
+}
+
 func (d *dispatcherImpl) IsDone() bool {
 	return len(d.coroutines) == 0
 }
@@ -956,7 +1078,7 @@ func (d *dispatcherImpl) Close() {
 	d.mutex.Unlock()
 	for i := 0; i < len(d.coroutines); i++ {
 		c := d.coroutines[i]
-		if !c.closed {
+		if !c.isDeadlocked() && !c.closed {
 			c.exit()
 		}
 	}
@@ -966,7 +1088,7 @@ func (d *dispatcherImpl) StackTrace() string {
 	var result string
 	for i := 0; i < len(d.coroutines); i++ {
 		c := d.coroutines[i]
-		if !c.closed {
+		if c.isDeadlocked() || !c.closed {
 			if len(result) > 0 {
 				result += "\n\n"
 			}
@@ -1127,6 +1249,23 @@ func newSyncWorkflowDefinition(workflow workflow) *syncWorkflowDefinition {
 }
 
 func getValidatedWorkflowFunction(workflowFunc interface{}, args []interface{}, dataConverter DataConverter, r *registry) (*WorkflowType, *commonpb.Payloads, error) {
+	workflowType, err := getValidatedWorkflowFunctionType(workflowFunc, args, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if dataConverter == nil {
+		dataConverter = getDefaultDataConverter()
+	}
+	input, err := encodeArgs(dataConverter, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	return workflowType, input, nil
+}
+
+// getValidatedWorkflowFunctionType validates workflow function and its arguments without encoding them.
+func getValidatedWorkflowFunctionType(workflowFunc interface{}, args []interface{}, r *registry) (*WorkflowType, error) {
 	fnName := ""
 	fType := reflect.TypeOf(workflowFunc)
 	switch getKind(fType) {
@@ -1135,24 +1274,16 @@ func getValidatedWorkflowFunction(workflowFunc interface{}, args []interface{},
 
 	case reflect.Func:
 		if err := validateFunctionArgs(workflowFunc, args, true); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 		fnName = getWorkflowFunctionName(r, workflowFunc)
 
 	default:
-		return nil, nil, fmt.Errorf(
+		return nil, fmt.Errorf(
 			"invalid type 'workflowFunc' parameter provided, it can be either worker function or name of the worker type: %v",
 			workflowFunc)
 	}
-
-	if dataConverter == nil {
-		dataConverter = getDefaultDataConverter()
-	}
-	input, err := encodeArgs(dataConverter, args)
-	if err != nil {
-		return nil, nil, err
-	}
-	return &WorkflowType{Name: fnName}, input, nil
+	return &WorkflowType{Name: fnName}, nil
 }
 
 func getWorkflowEnvOptions(ctx Context) *WorkflowOptions {
@@ -1170,7 +1301,8 @@ func setWorkflowEnvOptionsIfNotExist(ctx Context) Context {
 		newOptions = *options
 	} else {
 		newOptions.signalChannels = make(map[string]Channel)
-		newOptions.queryHandlers = make(map[string]func(*commonpb.Payloads) (*commonpb.Payloads, error))
+		newOptions.queryHandlers = make(map[string]*queryHandler)
+		newOptions.updates = newUpdateState()
 	}
 	if newOptions.DataConverter == nil {
 		newOptions.DataConverter = getDefaultDataConverter()
@@ -1204,6 +1336,10 @@ func getHeadersFromContext(ctx Context) *commonpb.Header {
 	for _, ctxProp := range contextPropagators {
 		_ = ctxProp.InjectFromWorkflow(ctx, NewHeaderWriter(header))
 	}
+	if err := encodeHeader(getDataConverterFromWorkflowContext(ctx), header); err != nil {
+		// the header fields that failed to encode are dropped, the call proceeds without them
+		GetLogger(ctx).Error("Unable to encode header.", zap.Error(err))
+	}
 	return header
 }
 
@@ -1270,10 +1406,35 @@ func setQueryHandler(ctx Context, queryType string, handler interface{}) error {
 		return err
 	}
 
-	getWorkflowEnvOptions(ctx).queryHandlers[queryType] = qh.execute
+	getWorkflowEnvOptions(ctx).queryHandlers[queryType] = qh
 	return nil
 }
 
+// getQueryHandlers returns descriptions of the registered query handlers sorted by query type.
+func (wo *WorkflowOptions) getQueryHandlers() []QueryHandlerInfo {
+	result := make([]QueryHandlerInfo, 0, len(wo.queryHandlers))
+	for _, handler := range wo.queryHandlers {
+		result = append(result, handler.info())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].QueryType < result[j].QueryType
+	})
+	return result
+}
+
+func (h *queryHandler) info() QueryHandlerInfo {
+	fnType := reflect.TypeOf(h.fn)
+	argTypes := make([]string, fnType.NumIn())
+	for i := 0; i < fnType.NumIn(); i++ {
+		argTypes[i] = fnType.In(i).String()
+	}
+	return QueryHandlerInfo{
+		QueryType:  h.queryType,
+		ArgTypes:   argTypes,
+		ResultType: fnType.Out(0).String(),
+	}
+}
+
 func (h *queryHandler) validateHandlerFn() error {
 	fnType := reflect.TypeOf(h.fn)
 	if fnType.Kind() != reflect.Func {
@@ -1399,3 +1560,88 @@ func (wg *waitGroupImpl) Wait(ctx Context) {
 	}
 	wg.future, wg.settable = NewFuture(ctx)
 }
+
+// Acquire blocks until n permits are acquired or the ctx is canceled.
+func (s *semaphoreImpl) Acquire(ctx Context, n int64) error {
+	return s.acquire(ctx, n, "Acquire")
+}
+
+// TryAcquire acquires n permits if they are available and no coroutines are waiting for them.
+func (s *semaphoreImpl) TryAcquire(n int64) bool {
+	s.checkPermits("TryAcquire", n)
+	if len(s.waiters) > 0 || s.size-s.used < n {
+		return false
+	}
+	s.used += n
+	return true
+}
+
+// Release releases n permits unblocking waiting coroutines on the next dispatcher iteration.
+func (s *semaphoreImpl) Release(n int64) {
+	s.checkPermits("Release", n)
+	if n > s.used {
+		panic(fmt.Sprintf("%s.Release of %v permits exceeds the %v acquired permits", s.name, n, s.used))
+	}
+	s.used -= n
+}
+
+func (s *semaphoreImpl) checkPermits(operation string, n int64) {
+	if n <= 0 {
+		panic(fmt.Sprintf("%s.%s of %v permits, the number of permits must be positive", s.name, operation, n))
+	}
+}
+
+func (s *semaphoreImpl) acquire(ctx Context, n int64, operation string) error {
+	s.checkPermits(operation, n)
+	if n > s.size {
+		panic(fmt.Sprintf("%s.%s of %v permits exceeds the size of %v", s.name, operation, n, s.size))
+	}
+	if s.TryAcquire(n) {
+		return nil
+	}
+
+	state := getState(ctx)
+	defer state.unblocked()
+	waiter := &semaphoreWaiter{n: n}
+	s.waiters = append(s.waiters, waiter)
+	for s.waiters[0] != waiter || s.size-s.used < n {
+		doneCh := ctx.Done()
+		if doneCh != nil {
+			if _, more := doneCh.ReceiveAsyncWithMoreFlag(nil); !more {
+				s.removeWaiter(waiter)
+				return NewCanceledError(fmt.Sprintf("%s.%s context cancelled", s.name, operation))
+			}
+		}
+		state.yield(fmt.Sprintf("blocked on %s.%s", s.name, operation))
+	}
+	s.waiters = s.waiters[1:]
+	s.used += n
+	return nil
+}
+
+func (s *semaphoreImpl) removeWaiter(waiter *semaphoreWaiter) {
+	for i, w := range s.waiters {
+		if w == waiter {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Lock blocks until the mutex is acquired or the ctx is canceled.
+func (m *mutexImpl) Lock(ctx Context) error {
+	return m.semaphore.acquire(ctx, 1, "Lock")
+}
+
+// TryLock acquires the mutex if it is not locked and no coroutines are waiting for it.
+func (m *mutexImpl) TryLock() bool {
+	return m.semaphore.TryAcquire(1)
+}
+
+// Unlock releases the mutex unblocking a waiting coroutine on the next dispatcher iteration.
+func (m *mutexImpl) Unlock() {
+	if m.semaphore.used == 0 {
+		panic(fmt.Sprintf("Unlock of unlocked %s", m.semaphore.name))
+	}
+	m.semaphore.Release(1)
+}
@@ -30,11 +30,11 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/pborman/uuid"
-	"github.com/uber-go/tally"
 	enumspb "go.temporal.io/temporal-proto/enums/v1"
 	"go.uber.org/zap"
 
@@ -57,6 +57,9 @@ var _ NamespaceClient = (*namespaceClient)(nil)
 
 const (
 	defaultGetHistoryTimeoutInSecs = 65
+
+	updateResultPollInitialInterval = 50 * time.Millisecond
+	updateResultPollMaxInterval     = time.Second
 )
 
 var (
@@ -71,18 +74,25 @@ type (
 		namespace          string
 		registry           *registry
 		logger             *zap.Logger
-		metricsScope       *metrics.TaggedScope
+		metricsHandler     *metrics.TaggedHandler
 		identity           string
 		dataConverter      DataConverter
 		contextPropagators []ContextPropagator
 		tracer             opentracing.Tracer
+		interceptor        ClientInterceptor
+		interceptorOnce    sync.Once
+	}
+
+	// workflowClientInterceptor is the last link of the client interceptor chain, it makes actual service calls.
+	workflowClientInterceptor struct {
+		client *WorkflowClient
 	}
 
 	// namespaceClient is the client for managing namespaces.
 	namespaceClient struct {
 		workflowService  workflowservice.WorkflowServiceClient
 		connectionCloser io.Closer
-		metricsScope     tally.Scope
+		metricsHandler   MetricsHandler
 		logger           *zap.Logger
 		identity         string
 	}
@@ -162,9 +172,59 @@ func (wc *WorkflowClient) StartWorkflow(
 	options StartWorkflowOptions,
 	workflowFunc interface{},
 	args ...interface{},
+) (*WorkflowExecution, error) {
+	ctx, workflowType, header, err := wc.prepareStartWorkflow(ctx, &options, "StartWorkflow", workflowFunc, args)
+	if err != nil {
+		return nil, err
+	}
+	return wc.getInterceptor().StartWorkflow(ctx, &options, workflowType, header, args...)
+}
+
+// prepareStartWorkflow validates workflow function and its arguments, defaults workflow ID, creates
+// the workflow start span and builds the headers using the configured context propagators.
+func (wc *WorkflowClient) prepareStartWorkflow(
+	ctx context.Context,
+	options *StartWorkflowOptions,
+	operation string,
+	workflowFunc interface{},
+	args []interface{},
+) (context.Context, string, *commonpb.Header, error) {
+	if len(options.ID) == 0 {
+		options.ID = uuid.NewRandom().String()
+	}
+
+	// Validate type and its arguments.
+	workflowType, err := getValidatedWorkflowFunctionType(workflowFunc, args, wc.registry)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	// create a workflow start span and attach it to the context object.
+	// N.B. we need to finish this immediately as jaeger does not give us a way
+	// to recreate a span given a span context - which means we will run into
+	// issues during replay. we work around this by creating and ending the
+	// workflow start span and passing in that context to the workflow. So
+	// everything beginning with the StartWorkflowExecutionRequest will be
+	// parented by the created start workflow span.
+	ctx, span := createOpenTracingWorkflowSpan(ctx, wc.tracer, time.Now(), fmt.Sprintf("%s-%s", operation, workflowType.Name), options.ID)
+	span.Finish()
+
+	// get workflow headers from the context
+	header := wc.getWorkflowHeader(ctx)
+
+	return ctx, workflowType.Name, header, nil
+}
+
+func (wc *WorkflowClient) startWorkflow(
+	ctx context.Context,
+	options *StartWorkflowOptions,
+	workflowType string,
+	header *commonpb.Header,
+	args []interface{},
 ) (*WorkflowExecution, error) {
 	workflowID := options.ID
 	if len(workflowID) == 0 {
+		// interceptors are allowed to reset the ID
 		workflowID = uuid.NewRandom().String()
 	}
 
@@ -172,8 +232,7 @@ func (wc *WorkflowClient) StartWorkflow(
 	runTimeout := common.Int32Ceil(options.WorkflowRunTimeout.Seconds())
 	workflowTaskTimeout := common.Int32Ceil(options.WorkflowTaskTimeout.Seconds())
 
-	// Validate type and its arguments.
-	workflowType, input, err := getValidatedWorkflowFunction(workflowFunc, args, wc.dataConverter, wc.registry)
+	input, err := encodeArgs(wc.dataConverter, args)
 	if err != nil {
 		return nil, err
 	}
@@ -188,25 +247,16 @@ func (wc *WorkflowClient) StartWorkflow(
 		return nil, err
 	}
 
-	// create a workflow start span and attach it to the context object.
-	// N.B. we need to finish this immediately as jaeger does not give us a way
-	// to recreate a span given a span context - which means we will run into
-	// issues during replay. we work around this by creating and ending the
-	// workflow start span and passing in that context to the workflow. So
-	// everything beginning with the StartWorkflowExecutionRequest will be
-	// parented by the created start workflow span.
-	ctx, span := createOpenTracingWorkflowSpan(ctx, wc.tracer, time.Now(), fmt.Sprintf("StartWorkflow-%s", workflowType.Name), workflowID)
-	span.Finish()
-
-	// get workflow headers from the context
-	header := wc.getWorkflowHeader(ctx)
+	if err := encodeHeader(wc.dataConverter, header); err != nil {
+		return nil, err
+	}
 
 	// run propagators to extract information about tracing and other stuff, store in headers field
 	startRequest := &workflowservice.StartWorkflowExecutionRequest{
 		Namespace:                       wc.namespace,
 		RequestId:                       uuid.New(),
 		WorkflowId:                      workflowID,
-		WorkflowType:                    &commonpb.WorkflowType{Name: workflowType.Name},
+		WorkflowType:                    &commonpb.WorkflowType{Name: workflowType},
 		TaskList:                        &tasklistpb.TaskList{Name: options.TaskList},
 		Input:                           input,
 		WorkflowExecutionTimeoutSeconds: executionTimeout,
@@ -238,8 +288,8 @@ func (wc *WorkflowClient) StartWorkflow(
 		return nil, err
 	}
 
-	if wc.metricsScope != nil {
-		scope := wc.metricsScope.GetTaggedScope(tagTaskList, options.TaskList, tagWorkflowType, workflowType.Name)
+	if wc.metricsHandler != nil {
+		scope := wc.metricsHandler.GetTaggedHandler(tagTaskList, options.TaskList, tagWorkflowType, workflowType)
 		scope.Counter(metrics.WorkflowStartCounter).Inc(1)
 	}
 
@@ -260,83 +310,24 @@ func (wc *WorkflowClient) StartWorkflow(
 // subjected to change in the future.
 // NOTE: the context.Context should have a fairly large timeout, since workflow execution may take a while to be finished
 func (wc *WorkflowClient) ExecuteWorkflow(ctx context.Context, options StartWorkflowOptions, workflow interface{}, args ...interface{}) (WorkflowRun, error) {
-
-	// start the workflow execution
-	var runID string
-	var workflowID string
-	executionInfo, err := wc.StartWorkflow(ctx, options, workflow, args...)
+	ctx, workflowType, header, err := wc.prepareStartWorkflow(ctx, &options, "StartWorkflow", workflow, args)
 	if err != nil {
-		if e, ok := err.(*serviceerror.WorkflowExecutionAlreadyStarted); ok {
-			runID = e.RunId
-			workflowID = options.ID
-		} else {
-			return nil, err
-		}
-	} else {
-		runID = executionInfo.RunID
-		workflowID = executionInfo.ID
-	}
-
-	iterFn := func(fnCtx context.Context, fnRunID string) HistoryEventIterator {
-		return wc.GetWorkflowHistory(fnCtx, workflowID, fnRunID, true, enumspb.HISTORY_EVENT_FILTER_TYPE_CLOSE_EVENT)
+		return nil, err
 	}
-
-	return &workflowRunImpl{
-		workflowFn:    workflow,
-		workflowID:    workflowID,
-		firstRunID:    runID,
-		currentRunID:  runID,
-		iterFn:        iterFn,
-		dataConverter: wc.dataConverter,
-		registry:      wc.registry,
-	}, nil
+	return wc.getInterceptor().ExecuteWorkflow(ctx, &options, workflowType, header, args...)
 }
 
 // GetWorkflow gets a workflow execution and returns a WorkflowRun that will allow you to wait until this workflow
 // reaches the end state, such as workflow finished successfully or timeout.
 // The current timeout resolution implementation is in seconds and uses math.Ceil(d.Seconds()) as the duration. But is
 // subjected to change in the future.
-func (wc *WorkflowClient) GetWorkflow(_ context.Context, workflowID string, runID string) WorkflowRun {
-
-	iterFn := func(fnCtx context.Context, fnRunID string) HistoryEventIterator {
-		return wc.GetWorkflowHistory(fnCtx, workflowID, fnRunID, true, enumspb.HISTORY_EVENT_FILTER_TYPE_CLOSE_EVENT)
-	}
-
-	return &workflowRunImpl{
-		workflowID:    workflowID,
-		firstRunID:    runID,
-		currentRunID:  runID,
-		iterFn:        iterFn,
-		dataConverter: wc.dataConverter,
-		registry:      wc.registry,
-	}
+func (wc *WorkflowClient) GetWorkflow(ctx context.Context, workflowID string, runID string) WorkflowRun {
+	return wc.getInterceptor().GetWorkflow(ctx, workflowID, runID)
 }
 
 // SignalWorkflow signals a workflow in execution.
 func (wc *WorkflowClient) SignalWorkflow(ctx context.Context, workflowID string, runID string, signalName string, arg interface{}) error {
-	input, err := encodeArg(wc.dataConverter, arg)
-	if err != nil {
-		return err
-	}
-
-	request := &workflowservice.SignalWorkflowExecutionRequest{
-		Namespace: wc.namespace,
-		WorkflowExecution: &commonpb.WorkflowExecution{
-			WorkflowId: workflowID,
-			RunId:      runID,
-		},
-		SignalName: signalName,
-		Input:      input,
-		Identity:   wc.identity,
-	}
-
-	return backoff.Retry(ctx,
-		func() error {
-			tchCtx, cancel := newChannelContext(ctx)
-			defer cancel()
-			_, err := wc.workflowService.SignalWorkflowExecution(tchCtx, request)
-			return err
-		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	return wc.getInterceptor().SignalWorkflow(ctx, workflowID, runID, signalName, arg)
 }
 
 // SignalWithStartWorkflow sends a signal to a running workflow.
@@ -344,31 +335,12 @@ func (wc *WorkflowClient) SignalWorkflow(ctx context.Context, workflowID string,
 func (wc *WorkflowClient) SignalWithStartWorkflow(ctx context.Context, workflowID string, signalName string, signalArg interface{},
 	options StartWorkflowOptions, workflowFunc interface{}, workflowArgs ...interface{}) (*WorkflowExecution, error) {
 
-	signalInput, err := encodeArg(wc.dataConverter, signalArg)
-	if err != nil {
-		return nil, err
-	}
-
 	if workflowID == "" {
 		workflowID = uuid.NewRandom().String()
 	}
 
-	executionTimeout := common.Int32Ceil(options.WorkflowExecutionTimeout.Seconds())
-	runTimeout := common.Int32Ceil(options.WorkflowRunTimeout.Seconds())
-	taskTimeout := common.Int32Ceil(options.WorkflowTaskTimeout.Seconds())
-
 	// Validate type and its arguments.
-	workflowType, input, err := getValidatedWorkflowFunction(workflowFunc, workflowArgs, wc.dataConverter, wc.registry)
-	if err != nil {
-		return nil, err
-	}
-
-	memo, err := getWorkflowMemo(options.Memo, wc.dataConverter)
-	if err != nil {
-		return nil, err
-	}
-
-	searchAttr, err := serializeSearchAttributes(options.SearchAttributes)
+	workflowType, err := getValidatedWorkflowFunctionType(workflowFunc, workflowArgs, wc.registry)
 	if err != nil {
 		return nil, err
 	}
@@ -380,487 +352,569 @@ func (wc *WorkflowClient) SignalWithStartWorkflow(ctx context.Context, workflowI
 	// get workflow headers from the context
 	header := wc.getWorkflowHeader(ctx)
 
-	signalWithStartRequest := &workflowservice.SignalWithStartWorkflowExecutionRequest{
-		Namespace:                       wc.namespace,
-		RequestId:                       uuid.New(),
-		WorkflowId:                      workflowID,
-		WorkflowType:                    &commonpb.WorkflowType{Name: workflowType.Name},
-		TaskList:                        &tasklistpb.TaskList{Name: options.TaskList},
-		Input:                           input,
-		WorkflowExecutionTimeoutSeconds: executionTimeout,
-		WorkflowRunTimeoutSeconds:       runTimeout,
-		WorkflowTaskTimeoutSeconds:      taskTimeout,
-		SignalName:                      signalName,
-		SignalInput:                     signalInput,
-		Identity:                        wc.identity,
-		RetryPolicy:                     convertRetryPolicy(options.RetryPolicy),
-		CronSchedule:                    options.CronSchedule,
-		Memo:                            memo,
-		SearchAttributes:                searchAttr,
-		WorkflowIdReusePolicy:           options.WorkflowIDReusePolicy.toProto(),
-		Header:                          header,
-	}
-
-	var response *workflowservice.SignalWithStartWorkflowExecutionResponse
-
-	// Start creating workflow request.
-	err = backoff.Retry(ctx,
-		func() error {
-			tchCtx, cancel := newChannelContext(ctx)
-			defer cancel()
-
-			var err1 error
-			response, err1 = wc.workflowService.SignalWithStartWorkflowExecution(tchCtx, signalWithStartRequest)
-			return err1
-		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if wc.metricsScope != nil {
-		scope := wc.metricsScope.GetTaggedScope(tagTaskList, options.TaskList, tagWorkflowType, workflowType.Name)
-		scope.Counter(metrics.WorkflowSignalWithStartCounter).Inc(1)
-	}
-
-	executionInfo := &WorkflowExecution{
-		ID:    options.ID,
-		RunID: response.GetRunId()}
-	return executionInfo, nil
+	return wc.getInterceptor().SignalWithStartWorkflow(ctx, workflowID, signalName, signalArg, &options, workflowType.Name, header, workflowArgs...)
 }
 
 // CancelWorkflow cancels a workflow in execution.  It allows workflow to properly clean up and gracefully close.
 // workflowID is required, other parameters are optional.
 // If runID is omit, it will terminate currently running workflow (if there is one) based on the workflowID.
 func (wc *WorkflowClient) CancelWorkflow(ctx context.Context, workflowID string, runID string) error {
-	request := &workflowservice.RequestCancelWorkflowExecutionRequest{
-		Namespace: wc.namespace,
-		WorkflowExecution: &commonpb.WorkflowExecution{
-			WorkflowId: workflowID,
-			RunId:      runID,
-		},
-		Identity: wc.identity,
-	}
-
-	return backoff.Retry(ctx,
-		func() error {
-			tchCtx, cancel := newChannelContext(ctx)
-			defer cancel()
-			_, err := wc.workflowService.RequestCancelWorkflowExecution(tchCtx, request)
-			return err
-		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	return wc.getInterceptor().CancelWorkflow(ctx, workflowID, runID)
 }
 
 // TerminateWorkflow terminates a workflow execution.
 // workflowID is required, other parameters are optional.
 // If runID is omit, it will terminate currently running workflow (if there is one) based on the workflowID.
 func (wc *WorkflowClient) TerminateWorkflow(ctx context.Context, workflowID string, runID string, reason string, details ...interface{}) error {
-	datailsPayload, err := wc.dataConverter.ToPayloads(details...)
-	if err != nil {
-		return err
-	}
-
-	request := &workflowservice.TerminateWorkflowExecutionRequest{
-		Namespace: wc.namespace,
-		WorkflowExecution: &commonpb.WorkflowExecution{
-			WorkflowId: workflowID,
-			RunId:      runID,
-		},
-		Reason:   reason,
-		Identity: wc.identity,
-		Details:  datailsPayload,
-	}
-
-	err = backoff.Retry(ctx,
-		func() error {
-			tchCtx, cancel := newChannelContext(ctx)
-			defer cancel()
-			_, err := wc.workflowService.TerminateWorkflowExecution(tchCtx, request)
-			return err
-		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
-
-	return err
+	return wc.getInterceptor().TerminateWorkflow(ctx, workflowID, runID, reason, details...)
 }
 
 // GetWorkflowHistory return a channel which contains the history events of a given workflow
 func (wc *WorkflowClient) GetWorkflowHistory(ctx context.Context, workflowID string, runID string,
 	isLongPoll bool, filterType enumspb.HistoryEventFilterType) HistoryEventIterator {
+	return wc.getInterceptor().GetWorkflowHistory(ctx, workflowID, runID, isLongPoll, filterType)
+}
 
-	namespace := wc.namespace
-	paginate := func(nexttoken []byte) (*workflowservice.GetWorkflowExecutionHistoryResponse, error) {
-		request := &workflowservice.GetWorkflowExecutionHistoryRequest{
-			Namespace: namespace,
-			Execution: &commonpb.WorkflowExecution{
-				WorkflowId: workflowID,
-				RunId:      runID,
-			},
-			WaitForNewEvent:        isLongPoll,
-			HistoryEventFilterType: filterType,
-			NextPageToken:          nexttoken,
-		}
-
-		var response *workflowservice.GetWorkflowExecutionHistoryResponse
-		var err error
-	Loop:
-		for {
-			err = backoff.Retry(ctx,
-				func() error {
-					var err1 error
-					tchCtx, cancel := newChannelContext(ctx, func(builder *contextBuilder) {
-						if isLongPoll {
-							builder.Timeout = defaultGetHistoryTimeoutInSecs * time.Second
-						}
-					})
-					defer cancel()
-					response, err1 = wc.workflowService.GetWorkflowExecutionHistory(tchCtx, request)
+// CompleteActivity reports activity completed. activity Execute method can return activity.ErrResultPending to
+// indicate the activity is not completed when it's Execute method returns. In that case, this CompleteActivity() method
+// should be called when that activity is completed with the actual result and error. If err is nil, activity task
+// completed event will be reported; if err is CanceledError, activity task cancelled event will be reported; otherwise,
+// activity task failed event will be reported.
+func (wc *WorkflowClient) CompleteActivity(ctx context.Context, taskToken []byte, result interface{}, err error) error {
+	return wc.getInterceptor().CompleteActivity(ctx, taskToken, result, err)
+}
 
-					if err1 != nil {
-						return err1
-					}
+// CompleteActivityByID reports activity completed. Similar to CompleteActivity
+// It takes namespace name, workflowID, runID, activityID as arguments.
+func (wc *WorkflowClient) CompleteActivityByID(ctx context.Context, namespace, workflowID, runID, activityID string,
+	result interface{}, err error) error {
+	return wc.getInterceptor().CompleteActivityByID(ctx, namespace, workflowID, runID, activityID, result, err)
+}
 
-					if response.RawHistory != nil {
-						history, err := serializer.DeserializeBlobDataToHistoryEvents(response.RawHistory, filterType)
-						if err != nil {
-							return err
-						}
-						response.History = history
-					}
-					return err1
-				}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+// RecordActivityHeartbeat records heartbeat for an activity.
+func (wc *WorkflowClient) RecordActivityHeartbeat(ctx context.Context, taskToken []byte, details ...interface{}) error {
+	return wc.getInterceptor().RecordActivityHeartbeat(ctx, taskToken, details...)
+}
 
-			if err != nil {
-				return nil, err
-			}
-			if isLongPoll && len(response.History.Events) == 0 && len(response.NextPageToken) != 0 {
-				request.NextPageToken = response.NextPageToken
-				continue Loop
-			}
-			break Loop
-		}
-		return response, nil
-	}
+// RecordActivityHeartbeatByID records heartbeat for an activity.
+func (wc *WorkflowClient) RecordActivityHeartbeatByID(ctx context.Context,
+	namespace, workflowID, runID, activityID string, details ...interface{}) error {
+	return wc.getInterceptor().RecordActivityHeartbeatByID(ctx, namespace, workflowID, runID, activityID, details...)
+}
 
-	return &historyEventIteratorImpl{
-		paginate: paginate,
-	}
+// ListClosedWorkflow gets closed workflow executions based on request filters
+// The errors it can throw:
+//  - BadRequestError
+//  - InternalServiceError
+//  - EntityNotExistError
+func (wc *WorkflowClient) ListClosedWorkflow(ctx context.Context, request *workflowservice.ListClosedWorkflowExecutionsRequest) (*workflowservice.ListClosedWorkflowExecutionsResponse, error) {
+	return wc.getInterceptor().ListClosedWorkflow(ctx, request)
 }
 
-// CompleteActivity reports activity completed. activity Execute method can return activity.ErrResultPending to
-// indicate the activity is not completed when it's Execute method returns. In that case, this CompleteActivity() method
-// should be called when that activity is completed with the actual result and error. If err is nil, activity task
-// completed event will be reported; if err is CanceledError, activity task cancelled event will be reported; otherwise,
-// activity task failed event will be reported.
-func (wc *WorkflowClient) CompleteActivity(ctx context.Context, taskToken []byte, result interface{}, err error) error {
-	if taskToken == nil {
-		return errors.New("invalid task token provided")
-	}
+// ListOpenWorkflow gets open workflow executions based on request filters
+// The errors it can throw:
+//  - BadRequestError
+//  - InternalServiceError
+//  - EntityNotExistError
+func (wc *WorkflowClient) ListOpenWorkflow(ctx context.Context, request *workflowservice.ListOpenWorkflowExecutionsRequest) (*workflowservice.ListOpenWorkflowExecutionsResponse, error) {
+	return wc.getInterceptor().ListOpenWorkflow(ctx, request)
+}
 
-	var data *commonpb.Payloads
-	if result != nil {
-		var err0 error
-		data, err0 = encodeArg(wc.dataConverter, result)
-		if err0 != nil {
-			return err0
-		}
-	}
-	request := convertActivityResultToRespondRequest(wc.identity, taskToken, data, err, wc.dataConverter)
-	return reportActivityComplete(ctx, wc.workflowService, request, wc.metricsScope)
+// ListWorkflow implementation
+func (wc *WorkflowClient) ListWorkflow(ctx context.Context, request *workflowservice.ListWorkflowExecutionsRequest) (*workflowservice.ListWorkflowExecutionsResponse, error) {
+	return wc.getInterceptor().ListWorkflow(ctx, request)
 }
 
-// CompleteActivityByID reports activity completed. Similar to CompleteActivity
-// It takes namespace name, workflowID, runID, activityID as arguments.
-func (wc *WorkflowClient) CompleteActivityByID(ctx context.Context, namespace, workflowID, runID, activityID string,
-	result interface{}, err error) error {
+// ListArchivedWorkflow implementation
+func (wc *WorkflowClient) ListArchivedWorkflow(ctx context.Context, request *workflowservice.ListArchivedWorkflowExecutionsRequest) (*workflowservice.ListArchivedWorkflowExecutionsResponse, error) {
+	return wc.getInterceptor().ListArchivedWorkflow(ctx, request)
+}
 
-	if activityID == "" || workflowID == "" || namespace == "" {
-		return errors.New("empty activity or workflow id or namespace")
-	}
+// ScanWorkflow implementation
+func (wc *WorkflowClient) ScanWorkflow(ctx context.Context, request *workflowservice.ScanWorkflowExecutionsRequest) (*workflowservice.ScanWorkflowExecutionsResponse, error) {
+	return wc.getInterceptor().ScanWorkflow(ctx, request)
+}
 
-	var data *commonpb.Payloads
-	if result != nil {
-		var err0 error
-		data, err0 = encodeArg(wc.dataConverter, result)
-		if err0 != nil {
-			return err0
-		}
-	}
+// CountWorkflow implementation
+func (wc *WorkflowClient) CountWorkflow(ctx context.Context, request *workflowservice.CountWorkflowExecutionsRequest) (*workflowservice.CountWorkflowExecutionsResponse, error) {
+	return wc.getInterceptor().CountWorkflow(ctx, request)
+}
 
-	request := convertActivityResultToRespondRequestByID(wc.identity, namespace, workflowID, runID, activityID, data, err, wc.dataConverter)
-	return reportActivityCompleteByID(ctx, wc.workflowService, request, wc.metricsScope)
+// GetSearchAttributes implementation
+func (wc *WorkflowClient) GetSearchAttributes(ctx context.Context) (*workflowservice.GetSearchAttributesResponse, error) {
+	return wc.getInterceptor().GetSearchAttributes(ctx)
 }
 
-// RecordActivityHeartbeat records heartbeat for an activity.
-func (wc *WorkflowClient) RecordActivityHeartbeat(ctx context.Context, taskToken []byte, details ...interface{}) error {
-	data, err := encodeArgs(wc.dataConverter, details)
-	if err != nil {
-		return err
-	}
-	return recordActivityHeartbeat(ctx, wc.workflowService, wc.identity, taskToken, data)
+// DescribeWorkflowExecution returns information about the specified workflow execution.
+// The errors it can return:
+//  - BadRequestError
+//  - InternalServiceError
+//  - EntityNotExistError
+func (wc *WorkflowClient) DescribeWorkflowExecution(ctx context.Context, workflowID, runID string) (*workflowservice.DescribeWorkflowExecutionResponse, error) {
+	return wc.getInterceptor().DescribeWorkflowExecution(ctx, workflowID, runID)
 }
 
-// RecordActivityHeartbeatByID records heartbeat for an activity.
-func (wc *WorkflowClient) RecordActivityHeartbeatByID(ctx context.Context,
-	namespace, workflowID, runID, activityID string, details ...interface{}) error {
-	data, err := encodeArgs(wc.dataConverter, details)
+// QueryWorkflow queries a given workflow execution
+// workflowID and queryType are required, other parameters are optional.
+// - workflow ID of the workflow.
+// - runID can be default(empty string). if empty string then it will pick the running execution of that workflow ID.
+// - taskList can be default(empty string). If empty string then it will pick the taskList of the running execution of that workflow ID.
+// - queryType is the type of the query.
+// - args... are the optional query parameters.
+// The errors it can return:
+//  - BadRequestError
+//  - InternalServiceError
+//  - EntityNotExistError
+//  - QueryFailError
+func (wc *WorkflowClient) QueryWorkflow(ctx context.Context, workflowID string, runID string, queryType string, args ...interface{}) (Value, error) {
+	queryWorkflowWithOptionsRequest := &QueryWorkflowWithOptionsRequest{
+		WorkflowID: workflowID,
+		RunID:      runID,
+		QueryType:  queryType,
+		Args:       args,
+	}
+	result, err := wc.QueryWorkflowWithOptions(ctx, queryWorkflowWithOptionsRequest)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return recordActivityHeartbeatByID(ctx, wc.workflowService, wc.identity, namespace, workflowID, runID, activityID, data)
+	return result.QueryResult, nil
 }
 
-// ListClosedWorkflow gets closed workflow executions based on request filters
-// The errors it can throw:
+// UpdateWorkflow sends an update to a workflow in execution and waits for its result.
+// - workflow ID of the workflow.
+// - runID can be default(empty string). if empty string then it will pick the running execution of that workflow ID.
+// - updateName is the name of the update.
+// - args... are the optional update handler parameters.
+// It returns an error if the workflow closes before the update completes.
+// The errors it can return:
 //  - BadRequestError
 //  - InternalServiceError
 //  - EntityNotExistError
-func (wc *WorkflowClient) ListClosedWorkflow(ctx context.Context, request *workflowservice.ListClosedWorkflowExecutionsRequest) (*workflowservice.ListClosedWorkflowExecutionsResponse, error) {
-	if request.GetNamespace() == "" {
-		request.Namespace = wc.namespace
+//  - QueryFailError
+//  - ApplicationError
+//  - PanicError
+func (wc *WorkflowClient) UpdateWorkflow(ctx context.Context, workflowID string, runID string, updateName string, args ...interface{}) (Value, error) {
+	input, err := encodeArgs(wc.dataConverter, args)
+	if err != nil {
+		return nil, err
 	}
-	var response *workflowservice.ListClosedWorkflowExecutionsResponse
-	err := backoff.Retry(ctx,
-		func() error {
-			var err1 error
-			tchCtx, cancel := newChannelContext(ctx)
-			defer cancel()
-			response, err1 = wc.workflowService.ListClosedWorkflowExecutions(tchCtx, request)
-			return err1
-		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	request := updateRequest{
+		ID:   uuid.NewRandom().String(),
+		Name: updateName,
+		Args: input,
+	}
+	if err := wc.getInterceptor().SignalWorkflow(ctx, workflowID, runID, updateSignalName, request); err != nil {
+		return nil, err
+	}
+
+	pollInterval := updateResultPollInitialInterval
+	for {
+		result, err := wc.queryUpdateResult(ctx, workflowID, runID, request.ID, enumspb.QUERY_REJECT_CONDITION_NOT_OPEN)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			// The workflow is closed, so the update is never handled if it has not completed yet.
+			result, err = wc.queryUpdateResult(ctx, workflowID, runID, request.ID, enumspb.QUERY_REJECT_CONDITION_UNSPECIFIED)
+			if err != nil {
+				return nil, err
+			}
+			if result == nil || !result.Completed {
+				return nil, fmt.Errorf("workflow closed before update %v completed", updateName)
+			}
+		}
+		if result.Completed {
+			return result.get(wc.dataConverter)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+		if pollInterval *= 2; pollInterval > updateResultPollMaxInterval {
+			pollInterval = updateResultPollMaxInterval
+		}
+	}
+}
+
+// queryUpdateResult queries the outcome of the update. It returns nil if the query is rejected.
+func (wc *WorkflowClient) queryUpdateResult(ctx context.Context, workflowID string, runID string, requestID string,
+	rejectCondition enumspb.QueryRejectCondition) (*updateResult, error) {
+	response, err := wc.getInterceptor().QueryWorkflow(ctx, &QueryWorkflowWithOptionsRequest{
+		WorkflowID:           workflowID,
+		RunID:                runID,
+		QueryType:            updateResultQueryType,
+		Args:                 []interface{}{requestID},
+		QueryRejectCondition: rejectCondition,
+	})
 	if err != nil {
 		return nil, err
 	}
-	return response, nil
+	if response.QueryRejected != nil {
+		return nil, nil
+	}
+	var result updateResult
+	if err := response.QueryResult.Get(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
 }
 
-// ListOpenWorkflow gets open workflow executions based on request filters
-// The errors it can throw:
+// QueryWorkflowWithOptionsRequest is the request to QueryWorkflowWithOptions
+type QueryWorkflowWithOptionsRequest struct {
+	// WorkflowID is a required field indicating the workflow which should be queried.
+	WorkflowID string
+
+	// RunID is an optional field used to identify a specific run of the queried workflow.
+	// If RunID is not provided the latest run will be used.
+	RunID string
+
+	// QueryType is a required field which specifies the query you want to run.
+	// By default, temporal supports "__stack_trace" as a standard query type, which will return string value
+	// representing the call stack of the target workflow. The target workflow could also setup different query handler to handle custom query types.
+	// See comments at workflow.SetQueryHandler(ctx Context, queryType string, handler interface{}) for more details on how to setup query handler within the target workflow.
+	QueryType string
+
+	// Args is an optional field used to identify the arguments passed to the query.
+	Args []interface{}
+
+	// QUERY_REJECT_CONDITION_UNSPECIFIED is an optional field used to reject queries based on workflow state.
+	// QUERY_REJECT_CONDITION_NOT_OPEN will reject queries to workflows which are not open.
+	// QUERY_REJECT_CONDITION_NOT_COMPLETED_CLEANLY will reject queries to workflows which completed in any state other than completed (e.g. terminated, canceled timeout etc...).
+	QueryRejectCondition enumspb.QueryRejectCondition
+}
+
+// QueryWorkflowWithOptionsResponse is the response to QueryWorkflowWithOptions
+type QueryWorkflowWithOptionsResponse struct {
+	// QueryResult contains the result of executing the query.
+	// This will only be set if the query was completed successfully and not rejected.
+	QueryResult Value
+
+	// QueryRejected contains information about the query rejection.
+	QueryRejected *querypb.QueryRejected
+}
+
+// QueryWorkflowWithOptions queries a given workflow execution and returns the query result synchronously.
+// See QueryWorkflowWithOptionsRequest and QueryWorkflowWithOptionsResult for more information.
+// The errors it can return:
 //  - BadRequestError
 //  - InternalServiceError
 //  - EntityNotExistError
-func (wc *WorkflowClient) ListOpenWorkflow(ctx context.Context, request *workflowservice.ListOpenWorkflowExecutionsRequest) (*workflowservice.ListOpenWorkflowExecutionsResponse, error) {
-	if request.GetNamespace() == "" {
-		request.Namespace = wc.namespace
+//  - QueryFailError
+func (wc *WorkflowClient) QueryWorkflowWithOptions(ctx context.Context, request *QueryWorkflowWithOptionsRequest) (*QueryWorkflowWithOptionsResponse, error) {
+	return wc.getInterceptor().QueryWorkflow(ctx, request)
+}
+
+// DescribeTaskList returns information about the target tasklist, right now this API returns the
+// pollers which polled this tasklist in last few minutes.
+// - tasklist name of tasklist
+// - tasklistType type of tasklist, can be decision or activity
+// The errors it can return:
+//  - BadRequestError
+//  - InternalServiceError
+//  - EntityNotExistError
+func (wc *WorkflowClient) DescribeTaskList(ctx context.Context, taskList string, taskListType enumspb.TaskListType) (*workflowservice.DescribeTaskListResponse, error) {
+	return wc.getInterceptor().DescribeTaskList(ctx, taskList, taskListType)
+}
+
+// Close client and clean up underlying resources.
+func (wc *WorkflowClient) Close() {
+	if wc.connectionCloser == nil {
+		return
 	}
-	var response *workflowservice.ListOpenWorkflowExecutionsResponse
-	err := backoff.Retry(ctx,
-		func() error {
-			var err1 error
-			tchCtx, cancel := newChannelContext(ctx)
-			defer cancel()
-			response, err1 = wc.workflowService.ListOpenWorkflowExecutions(tchCtx, request)
-			return err1
-		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	if err := wc.connectionCloser.Close(); err != nil {
+		wc.logger.Warn("unable to close connection", zap.Error(err))
+	}
+}
+
+// getInterceptor returns the head of the client interceptor chain. The chain without interceptors is created
+// lazily for clients which are not created by NewServiceClient.
+func (wc *WorkflowClient) getInterceptor() ClientInterceptor {
+	wc.interceptorOnce.Do(func() {
+		if wc.interceptor == nil {
+			wc.interceptor = newClientInterceptors(wc, nil)
+		}
+	})
+	return wc.interceptor
+}
+
+func newClientInterceptors(client *WorkflowClient, factories []ClientInterceptorFactory) ClientInterceptor {
+	var interceptor ClientInterceptor = &workflowClientInterceptor{client: client}
+	for i := len(factories) - 1; i >= 0; i-- {
+		interceptor = factories[i].NewInterceptor(interceptor)
+	}
+	return interceptor
+}
+
+func (wc *WorkflowClient) getWorkflowHeader(ctx context.Context) *commonpb.Header {
+	header := &commonpb.Header{
+		Fields: make(map[string]*commonpb.Payload),
+	}
+	writer := NewHeaderWriter(header)
+	for _, ctxProp := range wc.contextPropagators {
+		_ = ctxProp.Inject(ctx, writer)
+	}
+	return header
+}
+
+func (w *workflowClientInterceptor) ExecuteWorkflow(ctx context.Context, options *StartWorkflowOptions, workflowType string,
+	header *commonpb.Header, args ...interface{}) (WorkflowRun, error) {
+	wc := w.client
+
+	// start the workflow execution
+	var runID string
+	var workflowID string
+	executionInfo, err := wc.startWorkflow(ctx, options, workflowType, header, args)
 	if err != nil {
-		return nil, err
+		if e, ok := err.(*serviceerror.WorkflowExecutionAlreadyStarted); ok {
+			runID = e.RunId
+			workflowID = options.ID
+		} else {
+			return nil, err
+		}
+	} else {
+		runID = executionInfo.RunID
+		workflowID = executionInfo.ID
 	}
-	return response, nil
+
+	iterFn := func(fnCtx context.Context, fnRunID string) HistoryEventIterator {
+		return wc.GetWorkflowHistory(fnCtx, workflowID, fnRunID, true, enumspb.HISTORY_EVENT_FILTER_TYPE_CLOSE_EVENT)
+	}
+
+	return &workflowRunImpl{
+		workflowFn:    workflowType,
+		workflowID:    workflowID,
+		firstRunID:    runID,
+		currentRunID:  runID,
+		iterFn:        iterFn,
+		dataConverter: wc.dataConverter,
+		registry:      wc.registry,
+	}, nil
 }
 
-// ListWorkflow implementation
-func (wc *WorkflowClient) ListWorkflow(ctx context.Context, request *workflowservice.ListWorkflowExecutionsRequest) (*workflowservice.ListWorkflowExecutionsResponse, error) {
-	if request.GetNamespace() == "" {
-		request.Namespace = wc.namespace
+func (w *workflowClientInterceptor) StartWorkflow(ctx context.Context, options *StartWorkflowOptions, workflowType string,
+	header *commonpb.Header, args ...interface{}) (*WorkflowExecution, error) {
+	return w.client.startWorkflow(ctx, options, workflowType, header, args)
+}
+
+func (w *workflowClientInterceptor) SignalWithStartWorkflow(ctx context.Context, workflowID string, signalName string, signalArg interface{},
+	options *StartWorkflowOptions, workflowType string, header *commonpb.Header, workflowArgs ...interface{}) (*WorkflowExecution, error) {
+	wc := w.client
+
+	signalInput, err := encodeArg(wc.dataConverter, signalArg)
+	if err != nil {
+		return nil, err
 	}
-	var response *workflowservice.ListWorkflowExecutionsResponse
-	err := backoff.Retry(ctx,
-		func() error {
-			var err1 error
-			tchCtx, cancel := newChannelContext(ctx)
-			defer cancel()
-			response, err1 = wc.workflowService.ListWorkflowExecutions(tchCtx, request)
-			return err1
-		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+
+	executionTimeout := common.Int32Ceil(options.WorkflowExecutionTimeout.Seconds())
+	runTimeout := common.Int32Ceil(options.WorkflowRunTimeout.Seconds())
+	taskTimeout := common.Int32Ceil(options.WorkflowTaskTimeout.Seconds())
+
+	input, err := encodeArgs(wc.dataConverter, workflowArgs)
 	if err != nil {
 		return nil, err
 	}
-	return response, nil
-}
 
-// ListArchivedWorkflow implementation
-func (wc *WorkflowClient) ListArchivedWorkflow(ctx context.Context, request *workflowservice.ListArchivedWorkflowExecutionsRequest) (*workflowservice.ListArchivedWorkflowExecutionsResponse, error) {
-	if request.GetNamespace() == "" {
-		request.Namespace = wc.namespace
+	memo, err := getWorkflowMemo(options.Memo, wc.dataConverter)
+	if err != nil {
+		return nil, err
 	}
-	var response *workflowservice.ListArchivedWorkflowExecutionsResponse
-	err := backoff.Retry(ctx,
-		func() error {
-			var err1 error
-			timeout := maxListArchivedWorkflowTimeout
-			now := time.Now()
-			if ctx != nil {
-				if expiration, ok := ctx.Deadline(); ok && expiration.After(now) {
-					timeout = expiration.Sub(now)
-					if timeout > maxListArchivedWorkflowTimeout {
-						timeout = maxListArchivedWorkflowTimeout
-					} else if timeout < minRPCTimeout {
-						timeout = minRPCTimeout
-					}
-				}
-			}
-			tchCtx, cancel := newChannelContext(ctx, chanTimeout(timeout))
-			defer cancel()
-			response, err1 = wc.workflowService.ListArchivedWorkflowExecutions(tchCtx, request)
-			return err1
-		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+
+	searchAttr, err := serializeSearchAttributes(options.SearchAttributes)
 	if err != nil {
 		return nil, err
 	}
-	return response, nil
-}
 
-// ScanWorkflow implementation
-func (wc *WorkflowClient) ScanWorkflow(ctx context.Context, request *workflowservice.ScanWorkflowExecutionsRequest) (*workflowservice.ScanWorkflowExecutionsResponse, error) {
-	if request.GetNamespace() == "" {
-		request.Namespace = wc.namespace
+	if err := encodeHeader(wc.dataConverter, header); err != nil {
+		return nil, err
+	}
+
+	signalWithStartRequest := &workflowservice.SignalWithStartWorkflowExecutionRequest{
+		Namespace:                       wc.namespace,
+		RequestId:                       uuid.New(),
+		WorkflowId:                      workflowID,
+		WorkflowType:                    &commonpb.WorkflowType{Name: workflowType},
+		TaskList:                        &tasklistpb.TaskList{Name: options.TaskList},
+		Input:                           input,
+		WorkflowExecutionTimeoutSeconds: executionTimeout,
+		WorkflowRunTimeoutSeconds:       runTimeout,
+		WorkflowTaskTimeoutSeconds:      taskTimeout,
+		SignalName:                      signalName,
+		SignalInput:                     signalInput,
+		Identity:                        wc.identity,
+		RetryPolicy:                     convertRetryPolicy(options.RetryPolicy),
+		CronSchedule:                    options.CronSchedule,
+		Memo:                            memo,
+		SearchAttributes:                searchAttr,
+		WorkflowIdReusePolicy:           options.WorkflowIDReusePolicy.toProto(),
+		Header:                          header,
 	}
-	var response *workflowservice.ScanWorkflowExecutionsResponse
-	err := backoff.Retry(ctx,
+
+	var response *workflowservice.SignalWithStartWorkflowExecutionResponse
+
+	// Start creating workflow request.
+	err = backoff.Retry(ctx,
 		func() error {
-			var err1 error
 			tchCtx, cancel := newChannelContext(ctx)
 			defer cancel()
-			response, err1 = wc.workflowService.ScanWorkflowExecutions(tchCtx, request)
+
+			var err1 error
+			response, err1 = wc.workflowService.SignalWithStartWorkflowExecution(tchCtx, signalWithStartRequest)
 			return err1
 		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+
 	if err != nil {
 		return nil, err
 	}
-	return response, nil
+
+	if wc.metricsHandler != nil {
+		scope := wc.metricsHandler.GetTaggedHandler(tagTaskList, options.TaskList, tagWorkflowType, workflowType)
+		scope.Counter(metrics.WorkflowSignalWithStartCounter).Inc(1)
+	}
+
+	executionInfo := &WorkflowExecution{
+		ID:    options.ID,
+		RunID: response.GetRunId()}
+	return executionInfo, nil
 }
 
-// CountWorkflow implementation
-func (wc *WorkflowClient) CountWorkflow(ctx context.Context, request *workflowservice.CountWorkflowExecutionsRequest) (*workflowservice.CountWorkflowExecutionsResponse, error) {
-	if request.GetNamespace() == "" {
-		request.Namespace = wc.namespace
+func (w *workflowClientInterceptor) SignalWorkflow(ctx context.Context, workflowID string, runID string, signalName string, arg interface{}) error {
+	wc := w.client
+	input, err := encodeArg(wc.dataConverter, arg)
+	if err != nil {
+		return err
 	}
-	var response *workflowservice.CountWorkflowExecutionsResponse
-	err := backoff.Retry(ctx,
+
+	request := &workflowservice.SignalWorkflowExecutionRequest{
+		Namespace: wc.namespace,
+		WorkflowExecution: &commonpb.WorkflowExecution{
+			WorkflowId: workflowID,
+			RunId:      runID,
+		},
+		SignalName: signalName,
+		Input:      input,
+		Identity:   wc.identity,
+	}
+
+	return backoff.Retry(ctx,
 		func() error {
-			var err1 error
 			tchCtx, cancel := newChannelContext(ctx)
 			defer cancel()
-			response, err1 = wc.workflowService.CountWorkflowExecutions(tchCtx, request)
-			return err1
+			_, err := wc.workflowService.SignalWorkflowExecution(tchCtx, request)
+			return err
 		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
-	if err != nil {
-		return nil, err
-	}
-	return response, nil
 }
 
-// GetSearchAttributes implementation
-func (wc *WorkflowClient) GetSearchAttributes(ctx context.Context) (*workflowservice.GetSearchAttributesResponse, error) {
-	var response *workflowservice.GetSearchAttributesResponse
-	err := backoff.Retry(ctx,
+func (w *workflowClientInterceptor) CancelWorkflow(ctx context.Context, workflowID string, runID string) error {
+	wc := w.client
+	request := &workflowservice.RequestCancelWorkflowExecutionRequest{
+		Namespace: wc.namespace,
+		WorkflowExecution: &commonpb.WorkflowExecution{
+			WorkflowId: workflowID,
+			RunId:      runID,
+		},
+		Identity: wc.identity,
+	}
+
+	return backoff.Retry(ctx,
 		func() error {
-			var err1 error
 			tchCtx, cancel := newChannelContext(ctx)
 			defer cancel()
-			response, err1 = wc.workflowService.GetSearchAttributes(tchCtx, &workflowservice.GetSearchAttributesRequest{})
-			return err1
+			_, err := wc.workflowService.RequestCancelWorkflowExecution(tchCtx, request)
+			return err
 		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+}
+
+func (w *workflowClientInterceptor) TerminateWorkflow(ctx context.Context, workflowID string, runID string, reason string, details ...interface{}) error {
+	wc := w.client
+	datailsPayload, err := wc.dataConverter.ToPayloads(details...)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return response, nil
-}
 
-// DescribeWorkflowExecution returns information about the specified workflow execution.
-// The errors it can return:
-//  - BadRequestError
-//  - InternalServiceError
-//  - EntityNotExistError
-func (wc *WorkflowClient) DescribeWorkflowExecution(ctx context.Context, workflowID, runID string) (*workflowservice.DescribeWorkflowExecutionResponse, error) {
-	request := &workflowservice.DescribeWorkflowExecutionRequest{
+	request := &workflowservice.TerminateWorkflowExecutionRequest{
 		Namespace: wc.namespace,
-		Execution: &commonpb.WorkflowExecution{
+		WorkflowExecution: &commonpb.WorkflowExecution{
 			WorkflowId: workflowID,
 			RunId:      runID,
 		},
+		Reason:   reason,
+		Identity: wc.identity,
+		Details:  datailsPayload,
 	}
-	var response *workflowservice.DescribeWorkflowExecutionResponse
-	err := backoff.Retry(ctx,
+
+	err = backoff.Retry(ctx,
 		func() error {
-			var err1 error
 			tchCtx, cancel := newChannelContext(ctx)
 			defer cancel()
-			response, err1 = wc.workflowService.DescribeWorkflowExecution(tchCtx, request)
-			return err1
+			_, err := wc.workflowService.TerminateWorkflowExecution(tchCtx, request)
+			return err
 		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
-	if err != nil {
-		return nil, err
-	}
-	return response, nil
+
+	return err
 }
 
-// QueryWorkflow queries a given workflow execution
-// workflowID and queryType are required, other parameters are optional.
-// - workflow ID of the workflow.
-// - runID can be default(empty string). if empty string then it will pick the running execution of that workflow ID.
-// - taskList can be default(empty string). If empty string then it will pick the taskList of the running execution of that workflow ID.
-// - queryType is the type of the query.
-// - args... are the optional query parameters.
-// The errors it can return:
-//  - BadRequestError
-//  - InternalServiceError
-//  - EntityNotExistError
-//  - QueryFailError
-func (wc *WorkflowClient) QueryWorkflow(ctx context.Context, workflowID string, runID string, queryType string, args ...interface{}) (Value, error) {
-	queryWorkflowWithOptionsRequest := &QueryWorkflowWithOptionsRequest{
-		WorkflowID: workflowID,
-		RunID:      runID,
-		QueryType:  queryType,
-		Args:       args,
+func (w *workflowClientInterceptor) CompleteActivity(ctx context.Context, taskToken []byte, result interface{}, err error) error {
+	wc := w.client
+	if taskToken == nil {
+		return errors.New("invalid task token provided")
 	}
-	result, err := wc.QueryWorkflowWithOptions(ctx, queryWorkflowWithOptionsRequest)
-	if err != nil {
-		return nil, err
+
+	var data *commonpb.Payloads
+	if result != nil {
+		var err0 error
+		data, err0 = encodeArg(wc.dataConverter, result)
+		if err0 != nil {
+			return err0
+		}
 	}
-	return result.QueryResult, nil
+	request := convertActivityResultToRespondRequest(wc.identity, taskToken, data, err, wc.dataConverter)
+	return reportActivityComplete(ctx, wc.workflowService, request, wc.metricsHandler)
 }
 
-// QueryWorkflowWithOptionsRequest is the request to QueryWorkflowWithOptions
-type QueryWorkflowWithOptionsRequest struct {
-	// WorkflowID is a required field indicating the workflow which should be queried.
-	WorkflowID string
-
-	// RunID is an optional field used to identify a specific run of the queried workflow.
-	// If RunID is not provided the latest run will be used.
-	RunID string
+func (w *workflowClientInterceptor) CompleteActivityByID(ctx context.Context, namespace, workflowID, runID, activityID string,
+	result interface{}, err error) error {
+	wc := w.client
 
-	// QueryType is a required field which specifies the query you want to run.
-	// By default, temporal supports "__stack_trace" as a standard query type, which will return string value
-	// representing the call stack of the target workflow. The target workflow could also setup different query handler to handle custom query types.
-	// See comments at workflow.SetQueryHandler(ctx Context, queryType string, handler interface{}) for more details on how to setup query handler within the target workflow.
-	QueryType string
+	if activityID == "" || workflowID == "" || namespace == "" {
+		return errors.New("empty activity or workflow id or namespace")
+	}
 
-	// Args is an optional field used to identify the arguments passed to the query.
-	Args []interface{}
+	var data *commonpb.Payloads
+	if result != nil {
+		var err0 error
+		data, err0 = encodeArg(wc.dataConverter, result)
+		if err0 != nil {
+			return err0
+		}
+	}
 
-	// QUERY_REJECT_CONDITION_UNSPECIFIED is an optional field used to reject queries based on workflow state.
-	// QUERY_REJECT_CONDITION_NOT_OPEN will reject queries to workflows which are not open.
-	// QUERY_REJECT_CONDITION_NOT_COMPLETED_CLEANLY will reject queries to workflows which completed in any state other than completed (e.g. terminated, canceled timeout etc...).
-	QueryRejectCondition enumspb.QueryRejectCondition
+	request := convertActivityResultToRespondRequestByID(wc.identity, namespace, workflowID, runID, activityID, data, err, wc.dataConverter)
+	return reportActivityCompleteByID(ctx, wc.workflowService, request, wc.metricsHandler)
 }
 
-// QueryWorkflowWithOptionsResponse is the response to QueryWorkflowWithOptions
-type QueryWorkflowWithOptionsResponse struct {
-	// QueryResult contains the result of executing the query.
-	// This will only be set if the query was completed successfully and not rejected.
-	QueryResult Value
+func (w *workflowClientInterceptor) RecordActivityHeartbeat(ctx context.Context, taskToken []byte, details ...interface{}) error {
+	wc := w.client
+	data, err := encodeArgs(wc.dataConverter, details)
+	if err != nil {
+		return err
+	}
+	return recordActivityHeartbeat(ctx, wc.workflowService, wc.identity, taskToken, data)
+}
 
-	// QueryRejected contains information about the query rejection.
-	QueryRejected *querypb.QueryRejected
+func (w *workflowClientInterceptor) RecordActivityHeartbeatByID(ctx context.Context,
+	namespace, workflowID, runID, activityID string, details ...interface{}) error {
+	wc := w.client
+	data, err := encodeArgs(wc.dataConverter, details)
+	if err != nil {
+		return err
+	}
+	return recordActivityHeartbeatByID(ctx, wc.workflowService, wc.identity, namespace, workflowID, runID, activityID, data)
 }
 
-// QueryWorkflowWithOptions queries a given workflow execution and returns the query result synchronously.
-// See QueryWorkflowWithOptionsRequest and QueryWorkflowWithOptionsResult for more information.
-// The errors it can return:
-//  - BadRequestError
-//  - InternalServiceError
-//  - EntityNotExistError
-//  - QueryFailError
-func (wc *WorkflowClient) QueryWorkflowWithOptions(ctx context.Context, request *QueryWorkflowWithOptionsRequest) (*QueryWorkflowWithOptionsResponse, error) {
+func (w *workflowClientInterceptor) QueryWorkflow(ctx context.Context, request *QueryWorkflowWithOptionsRequest) (*QueryWorkflowWithOptionsResponse, error) {
+	wc := w.client
 	var input *commonpb.Payloads
 	if len(request.Args) > 0 {
 		var err error
@@ -901,61 +955,9 @@ func (wc *WorkflowClient) QueryWorkflowWithOptions(ctx context.Context, request
 		}, nil
 	}
 	return &QueryWorkflowWithOptionsResponse{
-		QueryRejected: nil,
-		QueryResult:   newEncodedValue(resp.QueryResult, wc.dataConverter),
-	}, nil
-}
-
-// DescribeTaskList returns information about the target tasklist, right now this API returns the
-// pollers which polled this tasklist in last few minutes.
-// - tasklist name of tasklist
-// - tasklistType type of tasklist, can be decision or activity
-// The errors it can return:
-//  - BadRequestError
-//  - InternalServiceError
-//  - EntityNotExistError
-func (wc *WorkflowClient) DescribeTaskList(ctx context.Context, taskList string, taskListType enumspb.TaskListType) (*workflowservice.DescribeTaskListResponse, error) {
-	request := &workflowservice.DescribeTaskListRequest{
-		Namespace:    wc.namespace,
-		TaskList:     &tasklistpb.TaskList{Name: taskList},
-		TaskListType: taskListType,
-	}
-
-	var resp *workflowservice.DescribeTaskListResponse
-	err := backoff.Retry(ctx,
-		func() error {
-			tchCtx, cancel := newChannelContext(ctx)
-			defer cancel()
-			var err error
-			resp, err = wc.workflowService.DescribeTaskList(tchCtx, request)
-			return err
-		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
-	if err != nil {
-		return nil, err
-	}
-
-	return resp, nil
-}
-
-// Close client and clean up underlying resources.
-func (wc *WorkflowClient) Close() {
-	if wc.connectionCloser == nil {
-		return
-	}
-	if err := wc.connectionCloser.Close(); err != nil {
-		wc.logger.Warn("unable to close connection", zap.Error(err))
-	}
-}
-
-func (wc *WorkflowClient) getWorkflowHeader(ctx context.Context) *commonpb.Header {
-	header := &commonpb.Header{
-		Fields: make(map[string]*commonpb.Payload),
-	}
-	writer := NewHeaderWriter(header)
-	for _, ctxProp := range wc.contextPropagators {
-		_ = ctxProp.Inject(ctx, writer)
-	}
-	return header
+		QueryRejected: nil,
+		QueryResult:   newEncodedValue(resp.QueryResult, wc.dataConverter),
+	}, nil
 }
 
 // Register a namespace with temporal server
@@ -1139,8 +1141,7 @@ func getWorkflowMemo(input map[string]interface{}, dc DataConverter) (*commonpb.
 
 	memo := make(map[string]*commonpb.Payload)
 	for k, v := range input {
-		// TODO (shtin): use dc here???
-		memoBytes, err := DefaultDataConverter.ToPayload(v)
+		memoBytes, err := dc.ToPayload(v)
 		if err != nil {
 			return nil, fmt.Errorf("encode workflow memo error: %v", err.Error())
 		}
@@ -1164,3 +1165,278 @@ func serializeSearchAttributes(input map[string]interface{}) (*commonpb.SearchAt
 	}
 	return &commonpb.SearchAttributes{IndexedFields: attr}, nil
 }
+
+func (w *workflowClientInterceptor) GetWorkflow(ctx context.Context, workflowID string, runID string) WorkflowRun {
+	wc := w.client
+	iterFn := func(fnCtx context.Context, fnRunID string) HistoryEventIterator {
+		return wc.GetWorkflowHistory(fnCtx, workflowID, fnRunID, true, enumspb.HISTORY_EVENT_FILTER_TYPE_CLOSE_EVENT)
+	}
+
+	return &workflowRunImpl{
+		workflowID:    workflowID,
+		firstRunID:    runID,
+		currentRunID:  runID,
+		iterFn:        iterFn,
+		dataConverter: wc.dataConverter,
+		registry:      wc.registry,
+	}
+}
+
+func (w *workflowClientInterceptor) GetWorkflowHistory(ctx context.Context, workflowID string, runID string,
+	isLongPoll bool, filterType enumspb.HistoryEventFilterType) HistoryEventIterator {
+	wc := w.client
+	namespace := wc.namespace
+	paginate := func(nexttoken []byte) (*workflowservice.GetWorkflowExecutionHistoryResponse, error) {
+		request := &workflowservice.GetWorkflowExecutionHistoryRequest{
+			Namespace: namespace,
+			Execution: &commonpb.WorkflowExecution{
+				WorkflowId: workflowID,
+				RunId:      runID,
+			},
+			WaitForNewEvent:        isLongPoll,
+			HistoryEventFilterType: filterType,
+			NextPageToken:          nexttoken,
+		}
+
+		var response *workflowservice.GetWorkflowExecutionHistoryResponse
+		var err error
+	Loop:
+		for {
+			err = backoff.Retry(ctx,
+				func() error {
+					var err1 error
+					tchCtx, cancel := newChannelContext(ctx, func(builder *contextBuilder) {
+						if isLongPoll {
+							builder.Timeout = defaultGetHistoryTimeoutInSecs * time.Second
+						}
+					})
+					defer cancel()
+					response, err1 = wc.workflowService.GetWorkflowExecutionHistory(tchCtx, request)
+
+					if err1 != nil {
+						return err1
+					}
+
+					if response.RawHistory != nil {
+						history, err := serializer.DeserializeBlobDataToHistoryEvents(response.RawHistory, filterType)
+						if err != nil {
+							return err
+						}
+						response.History = history
+					}
+					return err1
+				}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+
+			if err != nil {
+				return nil, err
+			}
+			if isLongPoll && len(response.History.Events) == 0 && len(response.NextPageToken) != 0 {
+				request.NextPageToken = response.NextPageToken
+				continue Loop
+			}
+			break Loop
+		}
+		return response, nil
+	}
+
+	return &historyEventIteratorImpl{
+		paginate: paginate,
+	}
+}
+
+func (w *workflowClientInterceptor) ListClosedWorkflow(ctx context.Context, request *workflowservice.ListClosedWorkflowExecutionsRequest) (*workflowservice.ListClosedWorkflowExecutionsResponse, error) {
+	wc := w.client
+	if request.GetNamespace() == "" {
+		request.Namespace = wc.namespace
+	}
+	var response *workflowservice.ListClosedWorkflowExecutionsResponse
+	err := backoff.Retry(ctx,
+		func() error {
+			var err1 error
+			tchCtx, cancel := newChannelContext(ctx)
+			defer cancel()
+			response, err1 = wc.workflowService.ListClosedWorkflowExecutions(tchCtx, request)
+			return err1
+		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (w *workflowClientInterceptor) ListOpenWorkflow(ctx context.Context, request *workflowservice.ListOpenWorkflowExecutionsRequest) (*workflowservice.ListOpenWorkflowExecutionsResponse, error) {
+	wc := w.client
+	if request.GetNamespace() == "" {
+		request.Namespace = wc.namespace
+	}
+	var response *workflowservice.ListOpenWorkflowExecutionsResponse
+	err := backoff.Retry(ctx,
+		func() error {
+			var err1 error
+			tchCtx, cancel := newChannelContext(ctx)
+			defer cancel()
+			response, err1 = wc.workflowService.ListOpenWorkflowExecutions(tchCtx, request)
+			return err1
+		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (w *workflowClientInterceptor) ListWorkflow(ctx context.Context, request *workflowservice.ListWorkflowExecutionsRequest) (*workflowservice.ListWorkflowExecutionsResponse, error) {
+	wc := w.client
+	if request.GetNamespace() == "" {
+		request.Namespace = wc.namespace
+	}
+	var response *workflowservice.ListWorkflowExecutionsResponse
+	err := backoff.Retry(ctx,
+		func() error {
+			var err1 error
+			tchCtx, cancel := newChannelContext(ctx)
+			defer cancel()
+			response, err1 = wc.workflowService.ListWorkflowExecutions(tchCtx, request)
+			return err1
+		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (w *workflowClientInterceptor) ListArchivedWorkflow(ctx context.Context, request *workflowservice.ListArchivedWorkflowExecutionsRequest) (*workflowservice.ListArchivedWorkflowExecutionsResponse, error) {
+	wc := w.client
+	if request.GetNamespace() == "" {
+		request.Namespace = wc.namespace
+	}
+	var response *workflowservice.ListArchivedWorkflowExecutionsResponse
+	err := backoff.Retry(ctx,
+		func() error {
+			var err1 error
+			timeout := maxListArchivedWorkflowTimeout
+			now := time.Now()
+			if ctx != nil {
+				if expiration, ok := ctx.Deadline(); ok && expiration.After(now) {
+					timeout = expiration.Sub(now)
+					if timeout > maxListArchivedWorkflowTimeout {
+						timeout = maxListArchivedWorkflowTimeout
+					} else if timeout < minRPCTimeout {
+						timeout = minRPCTimeout
+					}
+				}
+			}
+			tchCtx, cancel := newChannelContext(ctx, chanTimeout(timeout))
+			defer cancel()
+			response, err1 = wc.workflowService.ListArchivedWorkflowExecutions(tchCtx, request)
+			return err1
+		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (w *workflowClientInterceptor) ScanWorkflow(ctx context.Context, request *workflowservice.ScanWorkflowExecutionsRequest) (*workflowservice.ScanWorkflowExecutionsResponse, error) {
+	wc := w.client
+	if request.GetNamespace() == "" {
+		request.Namespace = wc.namespace
+	}
+	var response *workflowservice.ScanWorkflowExecutionsResponse
+	err := backoff.Retry(ctx,
+		func() error {
+			var err1 error
+			tchCtx, cancel := newChannelContext(ctx)
+			defer cancel()
+			response, err1 = wc.workflowService.ScanWorkflowExecutions(tchCtx, request)
+			return err1
+		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (w *workflowClientInterceptor) CountWorkflow(ctx context.Context, request *workflowservice.CountWorkflowExecutionsRequest) (*workflowservice.CountWorkflowExecutionsResponse, error) {
+	wc := w.client
+	if request.GetNamespace() == "" {
+		request.Namespace = wc.namespace
+	}
+	var response *workflowservice.CountWorkflowExecutionsResponse
+	err := backoff.Retry(ctx,
+		func() error {
+			var err1 error
+			tchCtx, cancel := newChannelContext(ctx)
+			defer cancel()
+			response, err1 = wc.workflowService.CountWorkflowExecutions(tchCtx, request)
+			return err1
+		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (w *workflowClientInterceptor) GetSearchAttributes(ctx context.Context) (*workflowservice.GetSearchAttributesResponse, error) {
+	wc := w.client
+	var response *workflowservice.GetSearchAttributesResponse
+	err := backoff.Retry(ctx,
+		func() error {
+			var err1 error
+			tchCtx, cancel := newChannelContext(ctx)
+			defer cancel()
+			response, err1 = wc.workflowService.GetSearchAttributes(tchCtx, &workflowservice.GetSearchAttributesRequest{})
+			return err1
+		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (w *workflowClientInterceptor) DescribeWorkflowExecution(ctx context.Context, workflowID, runID string) (*workflowservice.DescribeWorkflowExecutionResponse, error) {
+	wc := w.client
+	request := &workflowservice.DescribeWorkflowExecutionRequest{
+		Namespace: wc.namespace,
+		Execution: &commonpb.WorkflowExecution{
+			WorkflowId: workflowID,
+			RunId:      runID,
+		},
+	}
+	var response *workflowservice.DescribeWorkflowExecutionResponse
+	err := backoff.Retry(ctx,
+		func() error {
+			var err1 error
+			tchCtx, cancel := newChannelContext(ctx)
+			defer cancel()
+			response, err1 = wc.workflowService.DescribeWorkflowExecution(tchCtx, request)
+			return err1
+		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (w *workflowClientInterceptor) DescribeTaskList(ctx context.Context, taskList string, taskListType enumspb.TaskListType) (*workflowservice.DescribeTaskListResponse, error) {
+	wc := w.client
+	request := &workflowservice.DescribeTaskListRequest{
+		Namespace:    wc.namespace,
+		TaskList:     &tasklistpb.TaskList{Name: taskList},
+		TaskListType: taskListType,
+	}
+
+	var resp *workflowservice.DescribeTaskListResponse
+	err := backoff.Retry(ctx,
+		func() error {
+			tchCtx, cancel := newChannelContext(ctx)
+			defer cancel()
+			var err error
+			resp, err = wc.workflowService.DescribeTaskList(tchCtx, request)
+			return err
+		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
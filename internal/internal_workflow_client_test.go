@@ -39,6 +39,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/suite"
 	historypb "go.temporal.io/temporal-proto/history/v1"
+	querypb "go.temporal.io/temporal-proto/query/v1"
 	"go.temporal.io/temporal-proto/serviceerror"
 	"go.temporal.io/temporal-proto/workflowservice/v1"
 	"go.temporal.io/temporal-proto/workflowservicemock/v1"
@@ -348,10 +349,10 @@ func (s *workflowRunSuite) SetupTest() {
 	s.mockCtrl = gomock.NewController(s.T())
 	s.workflowServiceClient = workflowservicemock.NewMockWorkflowServiceClient(s.mockCtrl)
 
-	metricsScope := metrics.NewTaggedScope(nil)
+	metricsHandler := metrics.NewTaggedHandler(nil)
 	options := ClientOptions{
-		MetricsScope: metricsScope,
-		Identity:     identity,
+		MetricsHandler: metricsHandler,
+		Identity:       identity,
 	}
 	s.workflowClient = NewServiceClient(s.workflowServiceClient, nil, options)
 	s.dataConverter = getDefaultDataConverter()
@@ -997,6 +998,101 @@ func (s *workflowClientTestSuite) TearDownTest() {
 	s.mockCtrl.Finish() // assert mock’s expectations
 }
 
+func (s *workflowClientTestSuite) TestUpdateWorkflow() {
+	var request updateRequest
+	s.service.EXPECT().SignalWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ interface{}, req *workflowservice.SignalWorkflowExecutionRequest, _ ...interface{}) (*workflowservice.SignalWorkflowExecutionResponse, error) {
+			s.Equal(updateSignalName, req.GetSignalName())
+			s.NoError(s.dataConverter.FromPayloads(req.GetInput(), &request))
+			return &workflowservice.SignalWorkflowExecutionResponse{}, nil
+		})
+
+	queryResult := func(result updateResult) *workflowservice.QueryWorkflowResponse {
+		payloads, err := encodeArg(s.dataConverter, result)
+		s.NoError(err)
+		return &workflowservice.QueryWorkflowResponse{QueryResult: payloads}
+	}
+	value, err := encodeArg(s.dataConverter, 5)
+	s.NoError(err)
+	gomock.InOrder(
+		s.service.EXPECT().QueryWorkflow(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(queryResult(updateResult{}), nil),
+		s.service.EXPECT().QueryWorkflow(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ interface{}, req *workflowservice.QueryWorkflowRequest, _ ...interface{}) (*workflowservice.QueryWorkflowResponse, error) {
+				s.Equal(updateResultQueryType, req.GetQuery().GetQueryType())
+				var requestID string
+				s.NoError(s.dataConverter.FromPayloads(req.GetQuery().GetQueryArgs(), &requestID))
+				s.Equal(request.ID, requestID)
+				return queryResult(updateResult{Completed: true, Result: value}), nil
+			}),
+	)
+
+	result, err := s.client.UpdateWorkflow(context.Background(), workflowID, runID, "add", 2, 3)
+	s.NoError(err)
+	var total int
+	s.NoError(result.Get(&total))
+	s.Equal(5, total)
+	s.Equal("add", request.Name)
+	var a, b int
+	s.NoError(s.dataConverter.FromPayloads(request.Args, &a, &b))
+	s.Equal(2, a)
+	s.Equal(3, b)
+}
+
+func (s *workflowClientTestSuite) TestUpdateWorkflow_Error() {
+	s.service.EXPECT().SignalWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&workflowservice.SignalWorkflowExecutionResponse{}, nil)
+	result := updateResult{}
+	result.complete(nil, errors.New("n must be positive"), s.dataConverter)
+	payloads, err := encodeArg(s.dataConverter, result)
+	s.NoError(err)
+	s.service.EXPECT().QueryWorkflow(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&workflowservice.QueryWorkflowResponse{QueryResult: payloads}, nil)
+
+	_, err = s.client.UpdateWorkflow(context.Background(), workflowID, runID, "add", -1)
+	s.EqualError(err, "n must be positive")
+}
+
+func (s *workflowClientTestSuite) TestUpdateWorkflow_WorkflowClosed() {
+	s.service.EXPECT().SignalWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&workflowservice.SignalWorkflowExecutionResponse{}, nil).Times(2)
+	rejected := &workflowservice.QueryWorkflowResponse{
+		QueryRejected: &querypb.QueryRejected{Status: enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED},
+	}
+	queryResult := func(result updateResult) *workflowservice.QueryWorkflowResponse {
+		payloads, err := encodeArg(s.dataConverter, result)
+		s.NoError(err)
+		return &workflowservice.QueryWorkflowResponse{QueryResult: payloads}
+	}
+	value, err := encodeArg(s.dataConverter, 5)
+	s.NoError(err)
+	gomock.InOrder(
+		s.service.EXPECT().QueryWorkflow(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ interface{}, req *workflowservice.QueryWorkflowRequest, _ ...interface{}) (*workflowservice.QueryWorkflowResponse, error) {
+				s.Equal(enumspb.QUERY_REJECT_CONDITION_NOT_OPEN, req.GetQueryRejectCondition())
+				return rejected, nil
+			}),
+		s.service.EXPECT().QueryWorkflow(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ interface{}, req *workflowservice.QueryWorkflowRequest, _ ...interface{}) (*workflowservice.QueryWorkflowResponse, error) {
+				s.Equal(enumspb.QUERY_REJECT_CONDITION_UNSPECIFIED, req.GetQueryRejectCondition())
+				return queryResult(updateResult{}), nil
+			}),
+		// The update completed before the workflow closed.
+		s.service.EXPECT().QueryWorkflow(gomock.Any(), gomock.Any(), gomock.Any()).Return(rejected, nil),
+		s.service.EXPECT().QueryWorkflow(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(queryResult(updateResult{Completed: true, Result: value}), nil),
+	)
+
+	_, err = s.client.UpdateWorkflow(context.Background(), workflowID, runID, "add", 2)
+	s.EqualError(err, "workflow closed before update add completed")
+
+	result, err := s.client.UpdateWorkflow(context.Background(), workflowID, runID, "add", 3)
+	s.NoError(err)
+	var total int
+	s.NoError(result.Get(&total))
+	s.Equal(5, total)
+}
+
 func (s *workflowClientTestSuite) TestSignalWithStartWorkflow() {
 	signalName := "my signal"
 	signalInput := []byte("my signal input")
@@ -1078,6 +1174,42 @@ func (s *workflowClientTestSuite) TestStartWorkflow_WithContext() {
 	s.Equal(createResponse.GetRunId(), resp.RunID)
 }
 
+// failingHeaderDataConverter fails to encode header fields.
+type failingHeaderDataConverter struct {
+	DataConverter
+}
+
+func (dc *failingHeaderDataConverter) EncodeHeaderField(*commonpb.Payload) (*commonpb.Payload, error) {
+	return nil, errors.New("header encoding failed")
+}
+
+func (dc *failingHeaderDataConverter) DecodeHeaderField(payload *commonpb.Payload) (*commonpb.Payload, error) {
+	return payload, nil
+}
+
+func (s *workflowClientTestSuite) TestStartWorkflow_HeaderEncodeError() {
+	s.client = NewServiceClient(s.service, nil, ClientOptions{
+		DataConverter:      &failingHeaderDataConverter{getDefaultDataConverter()},
+		ContextPropagators: []ContextPropagator{NewStringMapPropagator([]string{testHeader})},
+	})
+	options := StartWorkflowOptions{
+		ID:                       workflowID,
+		TaskList:                 tasklist,
+		WorkflowExecutionTimeout: timeoutInSeconds,
+		WorkflowTaskTimeout:      timeoutInSeconds,
+	}
+	ctx := context.WithValue(context.Background(), contextKey(testHeader), "test-data")
+
+	client, ok := s.client.(*WorkflowClient)
+	s.True(ok)
+	_, err := client.StartWorkflow(ctx, options, "workflowType")
+	s.Error(err)
+	s.Contains(err.Error(), "header encoding failed")
+	_, err = s.client.SignalWithStartWorkflow(ctx, workflowID, "signal", nil, options, "workflowType")
+	s.Error(err)
+	s.Contains(err.Error(), "header encoding failed")
+}
+
 func (s *workflowClientTestSuite) TestStartWorkflowWithDataConverter() {
 	dc := newTestDataConverter()
 	s.client = NewServiceClient(s.service, nil, ClientOptions{DataConverter: dc})
@@ -1338,3 +1470,130 @@ func serializeEvents(events []*historypb.HistoryEvent) *commonpb.DataBlob {
 		Data:         blob.Data,
 	}
 }
+
+type tenantClientInterceptorFactory struct {
+	calls *[]string
+}
+
+type tenantClientInterceptor struct {
+	ClientInterceptorBase
+	calls *[]string
+}
+
+func (f *tenantClientInterceptorFactory) NewInterceptor(next ClientInterceptor) ClientInterceptor {
+	return &tenantClientInterceptor{ClientInterceptorBase: ClientInterceptorBase{Next: next}, calls: f.calls}
+}
+
+func (t *tenantClientInterceptor) ExecuteWorkflow(ctx context.Context, options *StartWorkflowOptions, workflowType string,
+	header *commonpb.Header, args ...interface{}) (WorkflowRun, error) {
+	*t.calls = append(*t.calls, "ExecuteWorkflow-"+workflowType)
+	payload, _ := getDefaultDataConverter().ToPayload("tenant-1")
+	header.Fields["tenant"] = payload
+	options.Memo = map[string]interface{}{"tenant": "tenant-1"}
+	return t.Next.ExecuteWorkflow(ctx, options, workflowType, header, append(args, "extra")...)
+}
+
+func (t *tenantClientInterceptor) SignalWorkflow(ctx context.Context, workflowID string, runID string, signalName string, arg interface{}) error {
+	*t.calls = append(*t.calls, "SignalWorkflow-"+signalName)
+	return t.Next.SignalWorkflow(ctx, workflowID, runID, signalName, fmt.Sprintf("%v-tenant-1", arg))
+}
+
+func (t *tenantClientInterceptor) StartWorkflow(ctx context.Context, options *StartWorkflowOptions, workflowType string,
+	header *commonpb.Header, args ...interface{}) (*WorkflowExecution, error) {
+	*t.calls = append(*t.calls, "StartWorkflow-"+workflowType)
+	return t.Next.StartWorkflow(ctx, options, workflowType, header, args...)
+}
+
+func (t *tenantClientInterceptor) ListWorkflow(ctx context.Context, request *workflowservice.ListWorkflowExecutionsRequest) (*workflowservice.ListWorkflowExecutionsResponse, error) {
+	*t.calls = append(*t.calls, "ListWorkflow")
+	request.Query = "Tenant = 'tenant-1' AND (" + request.Query + ")"
+	return t.Next.ListWorkflow(ctx, request)
+}
+
+func (t *tenantClientInterceptor) DescribeWorkflowExecution(ctx context.Context, workflowID, runID string) (*workflowservice.DescribeWorkflowExecutionResponse, error) {
+	*t.calls = append(*t.calls, "DescribeWorkflowExecution")
+	return t.Next.DescribeWorkflowExecution(ctx, workflowID, runID)
+}
+
+func (t *tenantClientInterceptor) TerminateWorkflow(ctx context.Context, workflowID string, runID string, reason string, details ...interface{}) error {
+	*t.calls = append(*t.calls, "TerminateWorkflow")
+	return errors.New("terminate is not allowed")
+}
+
+func (s *workflowClientTestSuite) TestClientInterceptor() {
+	var calls []string
+	client := NewServiceClient(s.service, nil, ClientOptions{
+		ClientInterceptorChainFactories: []ClientInterceptorFactory{&tenantClientInterceptorFactory{calls: &calls}},
+	})
+	options := StartWorkflowOptions{
+		ID:                       workflowID,
+		TaskList:                 tasklist,
+		WorkflowExecutionTimeout: timeoutInSeconds,
+		WorkflowTaskTimeout:      timeoutInSeconds,
+	}
+	var startRequest *workflowservice.StartWorkflowExecutionRequest
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, request *workflowservice.StartWorkflowExecutionRequest, _ ...interface{}) (*workflowservice.StartWorkflowExecutionResponse, error) {
+			startRequest = request
+			return &workflowservice.StartWorkflowExecutionResponse{RunId: runID}, nil
+		})
+	run, err := client.ExecuteWorkflow(context.Background(), options, workflowType, "input")
+	s.NoError(err)
+	s.Equal(runID, run.GetRunID())
+
+	var tenant string
+	s.NoError(s.dataConverter.FromPayload(startRequest.Header.Fields["tenant"], &tenant))
+	s.Equal("tenant-1", tenant)
+	s.NoError(s.dataConverter.FromPayload(startRequest.Memo.Fields["tenant"], &tenant))
+	s.Equal("tenant-1", tenant)
+	var input, extra string
+	s.NoError(s.dataConverter.FromPayloads(startRequest.Input, &input, &extra))
+	s.Equal("input", input)
+	s.Equal("extra", extra)
+
+	var signalRequest *workflowservice.SignalWorkflowExecutionRequest
+	s.service.EXPECT().SignalWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, request *workflowservice.SignalWorkflowExecutionRequest, _ ...interface{}) (*workflowservice.SignalWorkflowExecutionResponse, error) {
+			signalRequest = request
+			return &workflowservice.SignalWorkflowExecutionResponse{}, nil
+		})
+	s.NoError(client.SignalWorkflow(context.Background(), workflowID, runID, "my-signal", "value"))
+	var signalArg string
+	s.NoError(s.dataConverter.FromPayloads(signalRequest.Input, &signalArg))
+	s.Equal("value-tenant-1", signalArg)
+
+	// rejected call never reaches the service
+	err = client.TerminateWorkflow(context.Background(), workflowID, runID, "reason")
+	s.EqualError(err, "terminate is not allowed")
+
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&workflowservice.StartWorkflowExecutionResponse{RunId: runID}, nil)
+	_, err = client.StartWorkflow(context.Background(), options, workflowType, "input")
+	s.NoError(err)
+
+	var listRequest *workflowservice.ListWorkflowExecutionsRequest
+	s.service.EXPECT().ListWorkflowExecutions(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, request *workflowservice.ListWorkflowExecutionsRequest, _ ...interface{}) (*workflowservice.ListWorkflowExecutionsResponse, error) {
+			listRequest = request
+			return &workflowservice.ListWorkflowExecutionsResponse{}, nil
+		})
+	_, err = client.ListWorkflow(context.Background(), &workflowservice.ListWorkflowExecutionsRequest{Query: "WorkflowType = 'wf'"})
+	s.NoError(err)
+	s.Equal("Tenant = 'tenant-1' AND (WorkflowType = 'wf')", listRequest.Query)
+
+	s.service.EXPECT().DescribeWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&workflowservice.DescribeWorkflowExecutionResponse{}, nil)
+	_, err = client.DescribeWorkflowExecution(context.Background(), workflowID, runID)
+	s.NoError(err)
+
+	s.Equal([]string{"ExecuteWorkflow-" + workflowType, "SignalWorkflow-my-signal", "TerminateWorkflow",
+		"StartWorkflow-" + workflowType, "ListWorkflow", "DescribeWorkflowExecution"}, calls)
+}
+
+func (s *workflowClientTestSuite) TestClientInterceptor_DefaultChain() {
+	// client which is not created by NewServiceClient still has the chain that calls the service
+	client := &WorkflowClient{workflowService: s.service, namespace: DefaultNamespace, dataConverter: getDefaultDataConverter()}
+	s.service.EXPECT().SignalWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&workflowservice.SignalWorkflowExecutionResponse{}, nil)
+	s.NoError(client.SignalWorkflow(context.Background(), workflowID, runID, "my-signal", "value"))
+}
@@ -1189,6 +1189,42 @@ func (s *WorkflowUnitTest) Test_WaitGroupWorkflowTest() {
 	s.Equal(n, total)
 }
 
+func (s *WorkflowUnitTest) Test_InterceptorSetUpdateHandler() {
+	workflowFn := func(ctx Context) (int, error) {
+		total := 0
+		err := SetUpdateHandler(ctx, "add", func(ctx Context, n int) (int, error) {
+			total += n
+			return total, nil
+		}, nil)
+		if err != nil {
+			return 0, err
+		}
+		GetSignalChannel(ctx, "done").Receive(ctx, nil)
+		return total, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	tracer := tracingInterceptorFactory{}
+	// the inner interceptor doesn't implement WorkflowUpdateInterceptor, so the handler is set directly
+	env.SetWorkerOptions(WorkerOptions{WorkflowInterceptorChainFactories: []WorkflowInterceptorFactory{&tracer, &minimalInterceptorFactory{}}})
+	var updateResult int
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow("add", func(result Value, err error) {
+			s.NoError(err)
+			s.NoError(result.Get(&updateResult))
+		}, 2)
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("done", nil)
+	}, time.Minute*2)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal(2, updateResult)
+	s.Equal(1, len(tracer.instances))
+	s.Contains(tracer.instances[0].trace, "SetUpdateHandler add")
+}
+
 var _ WorkflowInterceptorFactory = (*tracingInterceptorFactory)(nil)
 
 type tracingInterceptorFactory struct {
@@ -1221,3 +1257,20 @@ func (t *tracingInterceptor) ExecuteWorkflow(ctx Context, workflowType string, a
 	t.trace = append(t.trace, "ExecuteWorkflow "+workflowType+" end")
 	return result
 }
+
+func (t *tracingInterceptor) SetUpdateHandler(ctx Context, updateName string, handler interface{}, validator interface{}) error {
+	t.trace = append(t.trace, "SetUpdateHandler "+updateName)
+	return t.WorkflowInterceptorBase.SetUpdateHandler(ctx, updateName, handler, validator)
+}
+
+type minimalInterceptorFactory struct{}
+
+func (f *minimalInterceptorFactory) NewInterceptor(_ *WorkflowInfo, next WorkflowInterceptor) WorkflowInterceptor {
+	return &minimalInterceptor{WorkflowInterceptor: next}
+}
+
+// minimalInterceptor implements just WorkflowInterceptor, like the interceptors that don't embed
+// WorkflowInterceptorBase.
+type minimalInterceptor struct {
+	WorkflowInterceptor
+}
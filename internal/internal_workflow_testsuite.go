@@ -36,6 +36,7 @@ import (
 	"github.com/facebookgo/clock"
 	"github.com/golang/mock/gomock"
 	"github.com/opentracing/opentracing-go"
+	"github.com/pborman/uuid"
 	"github.com/robfig/cron"
 	"github.com/stretchr/testify/mock"
 	"github.com/uber-go/tally"
@@ -60,6 +61,9 @@ const (
 	defaultTestRunID            = "default-test-run-id"
 	defaultTestWorkflowTypeName = "default-test-workflow-type-name"
 	workflowTypeNotSpecified    = "workflow-type-not-specified"
+	defaultTestCronMaxRuns      = 10
+
+	defaultTestDeadlockDetectionTimeout = 10 * time.Second
 
 	// These are copied from service implementation
 	reservedTaskListPrefix = "/__temporal_sys/"
@@ -77,6 +81,7 @@ type (
 		mockTimeToFire time.Time
 		wallTimeToFire time.Time
 		timerID        int64
+		workflowTimer  bool // timer of the workflow, not a delayed callback of the test environment
 	}
 
 	testActivityHandle struct {
@@ -85,6 +90,11 @@ type (
 		heartbeatDetails *commonpb.Payloads
 	}
 
+	testUpdateHandle struct {
+		requestID string
+		callback  func(result Value, err error)
+	}
+
 	testWorkflowHandle struct {
 		env      *testWorkflowEnvironmentImpl
 		callback ResultHandler
@@ -133,7 +143,7 @@ type (
 		mock               *mock.Mock
 		service            workflowservice.WorkflowServiceClient
 		logger             *zap.Logger
-		metricsScope       *metrics.TaggedScope
+		metricsHandler     *metrics.TaggedHandler
 		contextPropagators []ContextPropagator
 		identity           string
 		tracer             opentracing.Tracer
@@ -168,6 +178,7 @@ type (
 		onTimerScheduledListener         func(timerID string, duration time.Duration)
 		onTimerFiredListener             func(timerID string)
 		onTimerCancelledListener         func(timerID string)
+		onCronRunCompletedListener       func(workflowInfo *WorkflowInfo, result Value, err error)
 	}
 
 	// testWorkflowEnvironmentImpl is the environment that runs the workflow/activity unit tests.
@@ -180,12 +191,16 @@ type (
 		workflowDef    WorkflowDefinition
 		changeVersions map[string]Version
 		openSessions   map[string]*SessionInfo
+		history        *testWorkflowHistory
+
+		mutableSideEffect map[string]*commonpb.Payloads
 
 		workflowCancelHandler func()
 		signalHandler         func(name string, input *commonpb.Payloads)
 		queryHandler          func(string, *commonpb.Payloads) (*commonpb.Payloads, error)
 		startedHandler        func(r WorkflowExecution, e error)
 
+		isRunStarted    bool
 		isTestCompleted bool
 		testResult      Value
 		testError       error
@@ -195,6 +210,15 @@ type (
 		runTimeout      time.Duration
 
 		heartbeatDetails *commonpb.Payloads
+		updates          []*testUpdateHandle
+
+		// runs of a cron workflow
+		workflowInput      *commonpb.Payloads
+		cronStartTime      time.Time
+		cronRuns           int
+		cronMaxRuns        int
+		nextCronRunPending bool
+		nextCronRunDelay   time.Duration
 
 		workerStopChannel  chan struct{}
 		sessionEnvironment *testSessionEnvironmentImpl
@@ -220,7 +244,7 @@ func newTestWorkflowEnvironmentImpl(s *WorkflowTestSuite, parentRegistry *regist
 			taskListSpecificActivities: make(map[string]*taskListSpecificActivity),
 
 			logger:            s.logger,
-			metricsScope:      metrics.NewTaggedScope(s.scope),
+			metricsHandler:    metrics.NewTaggedHandler(NewTallyMetricsHandler(s.scope)),
 			tracer:            opentracing.NoopTracer{},
 			mockClock:         clock.NewMock(),
 			wallClock:         clock.New(),
@@ -250,12 +274,17 @@ func newTestWorkflowEnvironmentImpl(s *WorkflowTestSuite, parentRegistry *regist
 		changeVersions: make(map[string]Version),
 		openSessions:   make(map[string]*SessionInfo),
 
+		mutableSideEffect: make(map[string]*commonpb.Payloads),
+
 		doneChannel:       make(chan struct{}),
 		workerStopChannel: make(chan struct{}),
 		dataConverter:     getDefaultDataConverter(),
 		runTimeout:        maxWorkflowTimeout,
+		cronMaxRuns:       defaultTestCronMaxRuns,
 	}
 
+	env.history = newTestWorkflowHistory(env)
+
 	// move forward the mock clock to start time.
 	env.setStartTime(time.Now())
 
@@ -266,8 +295,8 @@ func newTestWorkflowEnvironmentImpl(s *WorkflowTestSuite, parentRegistry *regist
 		logger, _ := zap.NewDevelopment()
 		env.logger = logger
 	}
-	if env.metricsScope == nil {
-		env.metricsScope = metrics.NewTaggedScope(s.scope)
+	if env.metricsHandler == nil {
+		env.metricsHandler = metrics.NewTaggedHandler(NewTallyMetricsHandler(s.scope))
 	}
 	env.contextPropagators = s.contextPropagators
 	env.header = s.header
@@ -332,6 +361,8 @@ func (env *testWorkflowEnvironmentImpl) newTestWorkflowEnvironmentForChild(param
 	childEnv.workerOptions = env.workerOptions
 	childEnv.dataConverter = params.DataConverter
 	childEnv.registry = env.registry
+	// child workflow receives headers written by context propagators of the parent
+	childEnv.header = params.Header
 
 	if params.TaskListName == "" {
 		return nil, serviceerror.NewWorkflowExecutionAlreadyStarted("Empty task list name", "", "")
@@ -456,6 +487,24 @@ func (env *testWorkflowEnvironmentImpl) executeWorkflowInternal(delayStart time.
 	}
 	env.locker.Unlock()
 
+	env.workflowInput = input
+	if wInfo.CronSchedule != "" && !env.isChildWorkflow() {
+		// same as the server, the first run of a cron workflow starts at the first scheduled time
+		env.cronStartTime = env.Now()
+		delayStart = env.getNextCronRunDelay()
+	}
+	env.startWorkflowRun(delayStart)
+	env.startMainLoop()
+	// the main loop returns when a run is completed, a cron workflow continues with the next run
+	for env.nextCronRunPending {
+		env.startNextCronRun()
+		env.startMainLoop()
+	}
+}
+
+func (env *testWorkflowEnvironmentImpl) startWorkflowRun(delayStart time.Duration) {
+	wInfo := env.workflowInfo
+	input := env.workflowInput
 	workflowDefinition, err := env.getWorkflowDefinition(wInfo.WorkflowType)
 	if err != nil {
 		panic(err)
@@ -466,15 +515,24 @@ func (env *testWorkflowEnvironmentImpl) executeWorkflowInternal(delayStart time.
 	// In case of child workflow, this executeWorkflowInternal() is run in separate goroutinue, so use postCallback
 	// to make sure workflowDef.Execute() is run in main loop.
 	env.postCallback(func() {
+		env.history.workflowStarted(input, delayStart)
 		env.workflowDef.Execute(env, env.header, input)
 		// kick off first decision task to start the workflow
 		if delayStart == 0 {
+			env.isRunStarted = true
 			env.startDecisionTask()
 		} else {
-			// we need to delayStart start workflow, decrease runningCount so mockClock could auto forward
-			env.runningCount--
+			// we need to delayStart start workflow, decrease runningCount so mockClock could auto forward. Only child
+			// workflow counts as running before it starts, see workflowExecutorWrapper.Execute().
+			isChildWorkflow := env.isChildWorkflow()
+			if isChildWorkflow {
+				env.runningCount--
+			}
 			env.registerDelayedCallback(func() {
-				env.runningCount++
+				if isChildWorkflow {
+					env.runningCount++
+				}
+				env.isRunStarted = true
 				env.startDecisionTask()
 			}, delayStart)
 		}
@@ -482,13 +540,105 @@ func (env *testWorkflowEnvironmentImpl) executeWorkflowInternal(delayStart time.
 
 	if env.runTimeout > 0 {
 		timeoutDuration := env.runTimeout + delayStart
+		runID := wInfo.WorkflowExecution.RunID
 		env.registerDelayedCallback(func() {
-			if !env.isTestCompleted {
+			// the run could be already completed and followed by the next run of a cron workflow
+			if !env.isTestCompleted && env.workflowInfo.WorkflowExecution.RunID == runID {
+				env.history.workflowTimedOut()
 				env.Complete(nil, ErrDeadlineExceeded)
 			}
 		}, timeoutDuration)
 	}
-	env.startMainLoop()
+}
+
+// getNextCronRunDelay returns the delay of the next run of the cron workflow from now.
+func (env *testWorkflowEnvironmentImpl) getNextCronRunDelay() time.Duration {
+	schedule, err := cron.ParseStandard(env.workflowInfo.CronSchedule)
+	if err != nil {
+		panic(fmt.Errorf("invalid cron schedule %v, err: %v", env.workflowInfo.CronSchedule, err))
+	}
+	workflowNow := env.Now().In(time.UTC)
+	return schedule.Next(workflowNow).Sub(workflowNow)
+}
+
+// scheduleNextCronRun schedules the next run of a cron workflow after its current run is completed. Same as the
+// server, the cron workflow stops if it is canceled or continued as new, or when the workflow execution timeout
+// expires. It also stops after the maximum number of runs set for the test.
+func (env *testWorkflowEnvironmentImpl) scheduleNextCronRun(result *commonpb.Payloads, err error) bool {
+	var canceledErr *CanceledError
+	var continueAsNewErr *ContinueAsNewError
+	var workflowPanicErr *workflowPanicError
+	if errors.As(err, &canceledErr) || errors.As(err, &continueAsNewErr) || errors.As(err, &workflowPanicErr) {
+		return false
+	}
+	if env.cronMaxRuns > 0 && env.cronRuns >= env.cronMaxRuns {
+		return false
+	}
+	delay := env.getNextCronRunDelay()
+	executionTimeout := time.Duration(env.workflowInfo.WorkflowExecutionTimeoutSeconds) * time.Second
+	if env.Now().Add(delay).After(env.cronStartTime.Add(executionTimeout)) {
+		return false
+	}
+
+	if err == nil {
+		// a failed run carries over the result of the last successful run
+		env.workflowInfo.lastCompletionResult = result
+	}
+	env.nextCronRunPending = true
+	env.nextCronRunDelay = delay
+	return true
+}
+
+// cancelPendingWork drops the timers, activities and local activities of the completed run, so their results are
+// not delivered to the workflow of the run, same as the server drops them when a run completes.
+func (env *testWorkflowEnvironmentImpl) cancelPendingWork() {
+	for timerID, timerHandle := range env.timers {
+		if timerHandle.env != env || !timerHandle.workflowTimer {
+			continue
+		}
+		timerHandle.timer.Stop()
+		if timerHandle.wallTimer != nil {
+			timerHandle.wallTimer.Stop()
+		}
+		delete(env.timers, timerID)
+	}
+	// activity handles of all the running workflows share the map, see makeUniqueID
+	runPrefix := env.makeUniqueID("")
+	for activityID := range env.activities {
+		if strings.HasPrefix(activityID, runPrefix) {
+			delete(env.activities, activityID)
+		}
+	}
+	runID := env.workflowInfo.WorkflowExecution.RunID
+	for activityID, task := range env.localActivities {
+		if task.params.WorkflowInfo.WorkflowExecution.RunID == runID {
+			task.cancel()
+			delete(env.localActivities, activityID)
+		}
+	}
+}
+
+// startNextCronRun resets the state of the completed run and starts the next run of the cron workflow.
+func (env *testWorkflowEnvironmentImpl) startNextCronRun() {
+	if env.workflowDef != nil {
+		env.workflowDef.Close()
+	}
+	wInfo := env.workflowInfo
+	wInfo.ContinuedExecutionRunID = wInfo.WorkflowExecution.RunID
+	wInfo.WorkflowExecution.RunID = uuid.New()
+	wInfo.Attempt = 0
+
+	env.changeVersions = make(map[string]Version)
+	env.openSessions = make(map[string]*SessionInfo)
+	env.mutableSideEffect = make(map[string]*commonpb.Payloads)
+	env.history = newTestWorkflowHistory(env)
+	env.isRunStarted = false
+	env.isTestCompleted = false
+	env.testResult = nil
+	env.testError = nil
+	env.nextCronRunPending = false
+
+	env.startWorkflowRun(env.nextCronRunDelay)
 }
 
 func (env *testWorkflowEnvironmentImpl) getWorkflowDefinition(wt WorkflowType) (WorkflowDefinition, error) {
@@ -604,10 +754,10 @@ func (env *testWorkflowEnvironmentImpl) executeLocalActivity(
 		},
 	}
 	taskHandler := localActivityTaskHandler{
-		userContext:  env.workerOptions.BackgroundActivityContext,
-		metricsScope: env.metricsScope,
-		logger:       env.logger,
-		tracer:       opentracing.NoopTracer{},
+		userContext:    env.workerOptions.BackgroundActivityContext,
+		metricsHandler: env.metricsHandler,
+		logger:         env.logger,
+		tracer:         opentracing.NoopTracer{},
 	}
 
 	result := taskHandler.executeLocalActivityTask(task)
@@ -618,11 +768,39 @@ func (env *testWorkflowEnvironmentImpl) executeLocalActivity(
 }
 
 func (env *testWorkflowEnvironmentImpl) startDecisionTask() {
-	if !env.isTestCompleted {
+	if env.isRunStarted && !env.isTestCompleted {
+		env.history.startDecisionTask()
 		env.workflowDef.OnDecisionTaskStarted()
+		env.completeUpdates()
+		env.history.finishDecisionTask()
 	}
 }
 
+// completeUpdates reports outcome of the updates handled by the last decision task.
+func (env *testWorkflowEnvironmentImpl) completeUpdates() {
+	var pending []*testUpdateHandle
+	for _, u := range env.updates {
+		value, err := env.queryWorkflow(updateResultQueryType, u.requestID)
+		if err != nil {
+			u.callback(nil, err)
+			continue
+		}
+		var result updateResult
+		if err := value.Get(&result); err != nil {
+			u.callback(nil, err)
+			continue
+		}
+		if result.Completed {
+			u.callback(result.get(env.GetDataConverter()))
+		} else if env.isTestCompleted {
+			u.callback(nil, errors.New("workflow completed before update is handled"))
+		} else {
+			pending = append(pending, u)
+		}
+	}
+	env.updates = pending
+}
+
 func (env *testWorkflowEnvironmentImpl) isChildWorkflow() bool {
 	return env.parentEnv != nil
 }
@@ -770,7 +948,9 @@ func (env *testWorkflowEnvironmentImpl) RequestCancelActivity(activityID string)
 	activityInfo := env.getActivityInfo(activityID, handle.activityType)
 	env.logger.Debug("RequestCancelActivity", zap.String(tagActivityID, activityID))
 	env.deleteHandle(activityID)
+	env.history.requestCancelActivity(activityID)
 	env.postCallback(func() {
+		env.history.activityCanceled(activityID)
 		handle.callback(nil, NewCanceledError())
 		if env.onActivityCanceledListener != nil {
 			env.onActivityCanceledListener(activityInfo)
@@ -789,6 +969,7 @@ func (env *testWorkflowEnvironmentImpl) RequestCancelTimer(timerID string) {
 
 	delete(env.timers, timerID)
 	timerHandle.timer.Stop()
+	env.history.cancelTimer(timerID)
 	timerHandle.env.postCallback(func() {
 		timerHandle.callback(nil, NewCanceledError())
 		if timerHandle.env.onTimerCancelledListener != nil {
@@ -808,6 +989,7 @@ func (env *testWorkflowEnvironmentImpl) Complete(result *commonpb.Payloads, err
 	}
 
 	dc := env.GetDataConverter()
+	env.history.workflowClosed(result, err)
 	env.isTestCompleted = true
 
 	if err != nil {
@@ -826,6 +1008,17 @@ func (env *testWorkflowEnvironmentImpl) Complete(result *commonpb.Payloads, err
 		env.testResult = newEncodedValue(result, dc)
 	}
 
+	if env.workflowInfo.CronSchedule != "" && !env.isChildWorkflow() {
+		env.cronRuns++
+		if env.onCronRunCompletedListener != nil {
+			env.onCronRunCompletedListener(env.workflowInfo, env.testResult, env.testError)
+		}
+		if env.scheduleNextCronRun(result, err) {
+			env.cancelPendingWork()
+			return
+		}
+	}
+
 	close(env.doneChannel)
 
 	if env.isChildWorkflow() {
@@ -943,7 +1136,11 @@ func (env *testWorkflowEnvironmentImpl) GetLogger() *zap.Logger {
 }
 
 func (env *testWorkflowEnvironmentImpl) GetMetricsScope() tally.Scope {
-	return env.metricsScope
+	return metricsScopeFromHandler(env.metricsHandler.Handler)
+}
+
+func (env *testWorkflowEnvironmentImpl) GetMetricsHandler() MetricsHandler {
+	return env.metricsHandler
 }
 
 func (env *testWorkflowEnvironmentImpl) GetDataConverter() DataConverter {
@@ -982,6 +1179,7 @@ func (env *testWorkflowEnvironmentImpl) ExecuteActivity(parameters ExecuteActivi
 		callback(nil, err)
 		return activityInfo
 	}
+	env.history.scheduleActivity(activityID, parameters, scheduleTaskAttr)
 	task := newTestActivityTask(
 		defaultTestWorkflowID,
 		defaultTestRunID,
@@ -1286,14 +1484,16 @@ func (env *testWorkflowEnvironmentImpl) ExecuteLocalActivity(params ExecuteLocal
 	task := newLocalActivityTask(params, callback, activityID)
 	taskHandler := localActivityTaskHandler{
 		userContext:        env.workerOptions.BackgroundActivityContext,
-		metricsScope:       metrics.NewTaggedScope(env.metricsScope),
+		metricsHandler:     metrics.NewTaggedHandler(env.metricsHandler),
 		logger:             env.logger,
 		dataConverter:      env.dataConverter,
 		tracer:             env.tracer,
 		contextPropagators: env.contextPropagators,
+		interceptors:       env.workerOptions.ActivityInterceptorChainFactories,
 	}
 
 	env.localActivities[activityID] = task
+	env.history.scheduleLocalActivity(activityID)
 	env.runningCount++
 
 	go func() {
@@ -1340,6 +1540,7 @@ func (env *testWorkflowEnvironmentImpl) handleActivityResult(activityID string,
 	}
 
 	delete(env.activities, activityID)
+	env.history.activityResult(activityID, result)
 
 	var blob *commonpb.Payloads
 	var err error
@@ -1405,6 +1606,7 @@ func (env *testWorkflowEnvironmentImpl) handleLocalActivityResult(result *localA
 		lar.Backoff = getRetryBackoff(result, env.Now(), env.dataConverter)
 		lar.Attempt = task.attempt
 	}
+	env.history.localActivityResult(task, lar)
 	task.callback(lar)
 	var canceledErr *CanceledError
 	if errors.As(result.err, &canceledErr) {
@@ -1447,7 +1649,8 @@ func (env *testWorkflowEnvironmentImpl) runBeforeMockCallReturns(call *MockCallW
 
 // Execute executes the activity code.
 func (a *activityExecutorWrapper) Execute(ctx context.Context, input *commonpb.Payloads) (*commonpb.Payloads, error) {
-	activityInfo := GetActivityInfo(ctx)
+	// listeners get activity info bypassing activity interceptors
+	activityInfo := (&activityEnvironmentInterceptor{}).GetActivityInfo(ctx)
 	dc := getDataConverterFromActivityCtx(ctx)
 	if a.env.onActivityStartedListener != nil {
 		waitCh := make(chan struct{})
@@ -1468,7 +1671,8 @@ func (a *activityExecutorWrapper) Execute(ctx context.Context, input *commonpb.P
 
 // ExecuteWithActualArgs executes the activity code.
 func (a *activityExecutorWrapper) ExecuteWithActualArgs(ctx context.Context, inputArgs []interface{}) (*commonpb.Payloads, error) {
-	activityInfo := GetActivityInfo(ctx)
+	// listeners get activity info bypassing activity interceptors
+	activityInfo := (&activityEnvironmentInterceptor{}).GetActivityInfo(ctx)
 	if a.env.onLocalActivityStartedListener != nil {
 		waitCh := make(chan struct{})
 		a.env.postCallback(func() {
@@ -1710,15 +1914,16 @@ func (m *mockWrapper) executeMockWithActualArgs(ctx interface{}, inputArgs []int
 func (env *testWorkflowEnvironmentImpl) newTestActivityTaskHandler(taskList string, dataConverter DataConverter) ActivityTaskHandler {
 	setWorkerOptionsDefaults(&env.workerOptions)
 	params := workerExecutionParameters{
-		TaskList:           taskList,
-		Identity:           env.identity,
-		MetricsScope:       env.metricsScope,
-		Logger:             env.logger,
-		UserContext:        env.workerOptions.BackgroundActivityContext,
-		DataConverter:      dataConverter,
-		WorkerStopChannel:  env.workerStopChannel,
-		ContextPropagators: env.contextPropagators,
-		Tracer:             env.tracer,
+		TaskList:             taskList,
+		Identity:             env.identity,
+		MetricsHandler:       env.metricsHandler,
+		Logger:               env.logger,
+		UserContext:          env.workerOptions.BackgroundActivityContext,
+		DataConverter:        dataConverter,
+		WorkerStopChannel:    env.workerStopChannel,
+		ContextPropagators:   env.contextPropagators,
+		Tracer:               env.tracer,
+		ActivityInterceptors: env.workerOptions.ActivityInterceptorChainFactories,
 	}
 	ensureRequiredParams(&params)
 	if params.UserContext == nil {
@@ -1795,9 +2000,13 @@ func newTestActivityTask(workflowID, runID, workflowTypeName, namespace string,
 func (env *testWorkflowEnvironmentImpl) newTimer(d time.Duration, callback ResultHandler, notifyListener bool) *TimerInfo {
 	nextID := env.nextID()
 	timerInfo := &TimerInfo{timerID: getStringID(nextID)}
+	if notifyListener && d > 0 {
+		env.history.startTimer(timerInfo.timerID, d)
+	}
 	timer := env.mockClock.AfterFunc(d, func() {
 		delete(env.timers, timerInfo.timerID)
 		env.postCallback(func() {
+			env.history.timerFired(timerInfo.timerID)
 			callback(nil, nil)
 			if notifyListener && env.onTimerFiredListener != nil {
 				env.onTimerFiredListener(timerInfo.timerID)
@@ -1812,6 +2021,7 @@ func (env *testWorkflowEnvironmentImpl) newTimer(d time.Duration, callback Resul
 		wallTimeToFire: env.wallClock.Now().Add(d),
 		duration:       d,
 		timerID:        nextID,
+		workflowTimer:  notifyListener,
 	}
 	if notifyListener && env.onTimerScheduledListener != nil {
 		env.onTimerScheduledListener(timerInfo.timerID, d)
@@ -1853,7 +2063,10 @@ func (env *testWorkflowEnvironmentImpl) RegisterCancelHandler(handler func()) {
 }
 
 func (env *testWorkflowEnvironmentImpl) RegisterSignalHandler(handler func(name string, input *commonpb.Payloads)) {
-	env.signalHandler = handler
+	env.signalHandler = func(name string, input *commonpb.Payloads) {
+		env.history.workflowSignaled(name, input)
+		handler(name, input)
+	}
 }
 
 func (env *testWorkflowEnvironmentImpl) RegisterQueryHandler(handler func(string, *commonpb.Payloads) (*commonpb.Payloads, error)) {
@@ -1864,6 +2077,7 @@ func (env *testWorkflowEnvironmentImpl) RequestCancelChildWorkflow(_, workflowID
 	if childHandle, ok := env.runningWorkflows[workflowID]; ok && !childHandle.handled {
 		// current workflow is a parent workflow, and we are canceling a child workflow
 		childEnv := childHandle.env
+		env.history.requestCancelChildWorkflow(workflowID)
 		childEnv.cancelWorkflow(func(result *commonpb.Payloads, err error) {})
 		return
 	}
@@ -1872,6 +2086,7 @@ func (env *testWorkflowEnvironmentImpl) RequestCancelChildWorkflow(_, workflowID
 func (env *testWorkflowEnvironmentImpl) RequestCancelExternalWorkflow(namespace, workflowID, runID string, callback ResultHandler) {
 	if env.workflowInfo.WorkflowExecution.ID == workflowID {
 		// cancel current workflow
+		env.history.workflowCancelRequested()
 		env.workflowCancelHandler()
 		// check if current workflow is a child workflow
 		if env.isChildWorkflow() && env.onChildWorkflowCanceledListener != nil {
@@ -1880,7 +2095,10 @@ func (env *testWorkflowEnvironmentImpl) RequestCancelExternalWorkflow(namespace,
 			}, false)
 		}
 		return
-	} else if childHandle, ok := env.runningWorkflows[workflowID]; ok && !childHandle.handled {
+	}
+
+	callback = env.history.requestCancelExternalWorkflow(namespace, workflowID, runID, callback)
+	if childHandle, ok := env.runningWorkflows[workflowID]; ok && !childHandle.handled {
 		// current workflow is a parent workflow, and we are canceling a child workflow
 		if !childHandle.params.WaitForCancellation {
 			childHandle.env.Complete(nil, ErrCanceled)
@@ -1922,17 +2140,24 @@ func (env *testWorkflowEnvironmentImpl) IsReplaying() bool {
 }
 
 func (env *testWorkflowEnvironmentImpl) SignalExternalWorkflow(namespace, workflowID, runID, signalName string, input *commonpb.Payloads, arg interface{}, childWorkflowOnly bool, callback ResultHandler) {
+	callback = env.history.signalExternalWorkflow(namespace, workflowID, runID, signalName, input, childWorkflowOnly, callback)
 	// check if target workflow is a known workflow
 	if childHandle, ok := env.runningWorkflows[workflowID]; ok {
 		// target workflow is a child
 		childEnv := childHandle.env
+		// the outcome is reported to the workflow with the next decision task, same as the server does, so the recorded
+		// history replays. The future returned by SignalExternalWorkflow is not ready until then.
 		if childEnv.isTestCompleted {
 			// child already completed (NOTE: we have only one failed cause now)
 			err := newUnknownExternalWorkflowExecutionError()
-			callback(nil, err)
+			env.postCallback(func() {
+				callback(nil, err)
+			}, true)
 		} else {
 			childEnv.signalHandler(signalName, input)
-			callback(nil, nil)
+			env.postCallback(func() {
+				callback(nil, nil)
+			}, true)
 		}
 		childEnv.postCallback(func() {}, true) // resume child workflow since a signal is sent.
 		return
@@ -1973,6 +2198,10 @@ func (env *testWorkflowEnvironmentImpl) ExecuteChildWorkflow(params ExecuteWorkf
 }
 
 func (env *testWorkflowEnvironmentImpl) executeChildWorkflowWithDelay(delayStart time.Duration, params ExecuteWorkflowParams, callback ResultHandler, startedHandler func(r WorkflowExecution, e error)) {
+	if startedHandler != nil {
+		// retries and cron runs of a child workflow are started by the server, they are not in the parent's history
+		callback, startedHandler = env.history.startChildWorkflow(&params, callback, startedHandler)
+	}
 	childEnv, err := env.newTestWorkflowEnvironmentForChild(&params, callback, startedHandler)
 	if err != nil {
 		env.logger.Sugar().Infof("ExecuteChildWorkflow failed: %v", err)
@@ -1989,20 +2218,22 @@ func (env *testWorkflowEnvironmentImpl) executeChildWorkflowWithDelay(delayStart
 }
 
 func (env *testWorkflowEnvironmentImpl) SideEffect(f func() (*commonpb.Payloads, error), callback ResultHandler) {
-	callback(f())
+	result, err := f()
+	if err == nil {
+		env.history.sideEffect(result)
+	}
+	callback(result, err)
 }
 
 func (env *testWorkflowEnvironmentImpl) GetVersion(changeID string, minSupported, maxSupported Version) (retVersion Version) {
 	if mockVersion, ok := env.getMockedVersion(changeID, changeID, minSupported, maxSupported); ok {
 		// GetVersion for changeID is mocked
-		_ = env.UpsertSearchAttributes(createSearchAttributesForChangeVersion(changeID, mockVersion, env.changeVersions))
-		env.changeVersions[changeID] = mockVersion
+		env.setVersion(changeID, mockVersion)
 		return mockVersion
 	}
 	if mockVersion, ok := env.getMockedVersion(mock.Anything, changeID, minSupported, maxSupported); ok {
 		// GetVersion is mocked with any changeID.
-		_ = env.UpsertSearchAttributes(createSearchAttributesForChangeVersion(changeID, mockVersion, env.changeVersions))
-		env.changeVersions[changeID] = mockVersion
+		env.setVersion(changeID, mockVersion)
 		return mockVersion
 	}
 
@@ -2011,11 +2242,21 @@ func (env *testWorkflowEnvironmentImpl) GetVersion(changeID string, minSupported
 		validateVersion(changeID, version, minSupported, maxSupported)
 		return version
 	}
-	_ = env.UpsertSearchAttributes(createSearchAttributesForChangeVersion(changeID, maxSupported, env.changeVersions))
-	env.changeVersions[changeID] = maxSupported
+	env.setVersion(changeID, maxSupported)
 	return maxSupported
 }
 
+// setVersion upserts the change version search attribute. The version marker is recorded only the first time a version
+// is set for the changeID, same as the real workflow environment does.
+func (env *testWorkflowEnvironmentImpl) setVersion(changeID string, version Version) {
+	_, recorded := env.changeVersions[changeID]
+	if !recorded {
+		env.history.versionMarker(changeID, version)
+	}
+	_ = env.upsertSearchAttributes(createSearchAttributesForChangeVersion(changeID, version, env.changeVersions), !recorded)
+	env.changeVersions[changeID] = version
+}
+
 func (env *testWorkflowEnvironmentImpl) getMockedVersion(mockedChangeID, changeID string, minSupported, maxSupported Version) (Version, bool) {
 	mockMethod := getMockMethodForGetVersion(mockedChangeID)
 	if _, ok := env.expectedMockCalls[mockMethod]; !ok {
@@ -2049,7 +2290,14 @@ func getMockMethodForGetVersion(changeID string) string {
 }
 
 func (env *testWorkflowEnvironmentImpl) UpsertSearchAttributes(attributes map[string]interface{}) error {
+	return env.upsertSearchAttributes(attributes, true)
+}
+
+func (env *testWorkflowEnvironmentImpl) upsertSearchAttributes(attributes map[string]interface{}, record bool) error {
 	attr, err := validateAndSerializeSearchAttributes(attributes)
+	if err == nil && record {
+		env.history.upsertSearchAttributes(attr)
+	}
 
 	env.workflowInfo.SearchAttributes = mergeSearchAttributes(env.workflowInfo.SearchAttributes, attr)
 
@@ -2065,8 +2313,17 @@ func (env *testWorkflowEnvironmentImpl) UpsertSearchAttributes(attributes map[st
 	return err
 }
 
-func (env *testWorkflowEnvironmentImpl) MutableSideEffect(_ string, f func() interface{}, _ func(a, b interface{}) bool) Value {
-	return newEncodedValue(env.encodeValue(f()), env.GetDataConverter())
+func (env *testWorkflowEnvironmentImpl) MutableSideEffect(id string, f func() interface{}, equals func(a, b interface{}) bool) Value {
+	dc := env.GetDataConverter()
+	newValue := f()
+	if result, ok := env.mutableSideEffect[id]; ok && isEqualValue(newValue, result, equals, dc) {
+		return newEncodedValue(result, dc)
+	}
+
+	result := env.encodeValue(newValue)
+	env.mutableSideEffect[id] = result
+	env.history.mutableSideEffect(id, result)
+	return newEncodedValue(result, dc)
 }
 
 func (env *testWorkflowEnvironmentImpl) AddSession(sessionInfo *SessionInfo) {
@@ -2141,6 +2398,26 @@ func (env *testWorkflowEnvironmentImpl) signalWorkflowByID(workflowID, signalNam
 	return serviceerror.NewNotFound(fmt.Sprintf("Workflow %v not exists", workflowID))
 }
 
+func (env *testWorkflowEnvironmentImpl) updateWorkflow(updateName string, callback func(result Value, err error), args ...interface{}) {
+	input, err := encodeArgs(env.GetDataConverter(), args)
+	if err != nil {
+		panic(err)
+	}
+	env.postCallback(func() {
+		request := updateRequest{
+			ID:   fmt.Sprintf("%v-%v", updateName, env.nextID()),
+			Name: updateName,
+			Args: input,
+		}
+		data, err := encodeArg(env.GetDataConverter(), request)
+		if err != nil {
+			panic(err)
+		}
+		env.updates = append(env.updates, &testUpdateHandle{requestID: request.ID, callback: callback})
+		env.signalHandler(updateSignalName, data)
+	}, true)
+}
+
 func (env *testWorkflowEnvironmentImpl) queryWorkflow(queryType string, args ...interface{}) (Value, error) {
 	data, err := encodeArgs(env.GetDataConverter(), args)
 	if err != nil {
@@ -2183,6 +2460,17 @@ func (env *testWorkflowEnvironmentImpl) GetRegistry() *registry {
 	return env.registry
 }
 
+func (env *testWorkflowEnvironmentImpl) GetDeadlockDetectionTimeout() time.Duration {
+	timeout := env.workerOptions.DeadlockDetectionTimeout
+	if timeout == 0 {
+		return defaultTestDeadlockDetectionTimeout
+	}
+	if timeout < 0 {
+		return 0
+	}
+	return timeout
+}
+
 func (env *testWorkflowEnvironmentImpl) setStartWorkflowOptions(options StartWorkflowOptions) {
 	wf := env.workflowInfo
 	if options.WorkflowExecutionTimeout > 0 {
@@ -2197,6 +2485,9 @@ func (env *testWorkflowEnvironmentImpl) setStartWorkflowOptions(options StartWor
 	if len(options.TaskList) > 0 {
 		wf.TaskListName = options.TaskList
 	}
+	if len(options.CronSchedule) > 0 {
+		wf.CronSchedule = options.CronSchedule
+	}
 }
 
 func newTestSessionEnvironment(testWorkflowEnvironment *testWorkflowEnvironmentImpl,
@@ -0,0 +1,939 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	commonpb "go.temporal.io/temporal-proto/common/v1"
+	decisionpb "go.temporal.io/temporal-proto/decision/v1"
+	enumspb "go.temporal.io/temporal-proto/enums/v1"
+	failurepb "go.temporal.io/temporal-proto/failure/v1"
+	historypb "go.temporal.io/temporal-proto/history/v1"
+	tasklistpb "go.temporal.io/temporal-proto/tasklist/v1"
+	"go.temporal.io/temporal-proto/workflowservice/v1"
+
+	"go.temporal.io/temporal/internal/common"
+)
+
+type (
+	// testWorkflowHistory records a workflow run of the test environment as the history the server would have written
+	// for it, so the run can be replayed by WorkflowReplayer.
+	//
+	// A decision task is opened when the workflow resumes after new events, and it stays open while the test
+	// environment resumes the workflow without new events (e.g. to deliver a local activity result or a cancellation),
+	// which a real worker does within the same decision task. IDs of activities, timers, markers and child workflows are
+	// the ones the workflow generates when the history is replayed.
+	testWorkflowHistory struct {
+		env    *testWorkflowEnvironmentImpl
+		events []*historypb.HistoryEvent
+		// decisionTask is the decision task collecting decisions, nil if there is none.
+		decisionTask *testDecisionTask
+		// nextDecisionID mirrors decisionsHelper.getNextID of the replayed workflow.
+		nextDecisionID int64
+		closed         bool
+
+		// activities, timers, local activities and child workflows by their IDs in the test environment
+		activities      map[string]*testHistoryActivity
+		timers          map[string]*historypb.HistoryEvent
+		localActivities map[string]string
+		children        map[string]*testHistoryChild
+	}
+
+	testDecisionTask struct {
+		scheduledEventID int64
+		startedEventID   int64
+		running          bool
+		decisions        []*historypb.HistoryEvent
+		// pending events are written after the decisions. If flush is set, some of them were already delivered to the
+		// workflow, so the decision task is completed as soon as the workflow blocks.
+		pending []func() *historypb.HistoryEvent
+		flush   bool
+		failure *failurepb.Failure
+	}
+
+	testHistoryActivity struct {
+		scheduled           *historypb.HistoryEvent
+		started             *historypb.HistoryEvent
+		cancelRequested     *historypb.HistoryEvent
+		waitForCancellation bool
+	}
+
+	testHistoryChild struct {
+		namespace    string
+		workflowID   string
+		workflowType *commonpb.WorkflowType
+		execution    *commonpb.WorkflowExecution
+		initiated    *historypb.HistoryEvent
+		started      *historypb.HistoryEvent
+		failed       bool
+	}
+)
+
+func newTestWorkflowHistory(env *testWorkflowEnvironmentImpl) *testWorkflowHistory {
+	return &testWorkflowHistory{
+		env:             env,
+		activities:      make(map[string]*testHistoryActivity),
+		timers:          make(map[string]*historypb.HistoryEvent),
+		localActivities: make(map[string]string),
+		children:        make(map[string]*testHistoryChild),
+	}
+}
+
+// getHistory returns the events recorded so far, completing the open decision task unless the workflow is running.
+func (h *testWorkflowHistory) getHistory() *historypb.History {
+	if h.decisionTask != nil && !h.decisionTask.running {
+		h.flush()
+	}
+	events := make([]*historypb.HistoryEvent, len(h.events))
+	copy(events, h.events)
+	return &historypb.History{Events: events}
+}
+
+func (h *testWorkflowHistory) newEvent(eventType enumspb.EventType) *historypb.HistoryEvent {
+	return &historypb.HistoryEvent{EventType: eventType, Timestamp: h.env.Now().UnixNano()}
+}
+
+func (h *testWorkflowHistory) append(event *historypb.HistoryEvent) *historypb.HistoryEvent {
+	event.EventId = int64(len(h.events)) + 1
+	h.events = append(h.events, event)
+	return event
+}
+
+// startDecisionTask is called when the test environment resumes the workflow.
+func (h *testWorkflowHistory) startDecisionTask() {
+	if h.closed {
+		return
+	}
+	h.openDecisionTask().running = true
+}
+
+// finishDecisionTask is called when the workflow is blocked again.
+func (h *testWorkflowHistory) finishDecisionTask() {
+	dt := h.decisionTask
+	if dt == nil {
+		return
+	}
+	dt.running = false
+	if dt.flush || h.closed {
+		h.flush()
+	}
+}
+
+func (h *testWorkflowHistory) openDecisionTask() *testDecisionTask {
+	if h.decisionTask != nil {
+		return h.decisionTask
+	}
+	wInfo := h.env.workflowInfo
+	scheduled := h.newEvent(enumspb.EVENT_TYPE_DECISION_TASK_SCHEDULED)
+	scheduled.Attributes = &historypb.HistoryEvent_DecisionTaskScheduledEventAttributes{DecisionTaskScheduledEventAttributes: &historypb.DecisionTaskScheduledEventAttributes{
+		TaskList:                   &tasklistpb.TaskList{Name: wInfo.TaskListName},
+		StartToCloseTimeoutSeconds: wInfo.WorkflowTaskTimeoutSeconds,
+	}}
+	h.append(scheduled)
+	started := h.newEvent(enumspb.EVENT_TYPE_DECISION_TASK_STARTED)
+	started.Attributes = &historypb.HistoryEvent_DecisionTaskStartedEventAttributes{DecisionTaskStartedEventAttributes: &historypb.DecisionTaskStartedEventAttributes{
+		ScheduledEventId: scheduled.EventId,
+		Identity:         h.env.identity,
+	}}
+	h.append(started)
+
+	h.decisionTask = &testDecisionTask{scheduledEventID: scheduled.EventId, startedEventID: started.EventId}
+	// same as the decisionsHelper, which generates IDs starting after the DecisionTaskCompleted event
+	h.nextDecisionID = started.EventId + 2
+	return h.decisionTask
+}
+
+// completedEventID returns the ID of the DecisionTaskCompleted event, which is always written right after the
+// DecisionTaskStarted event.
+func (dt *testDecisionTask) completedEventID() int64 {
+	return dt.startedEventID + 1
+}
+
+// flush writes the completion of the open decision task followed by its decisions and the pending events.
+func (h *testWorkflowHistory) flush() {
+	dt := h.decisionTask
+	if dt == nil {
+		return
+	}
+	h.decisionTask = nil
+
+	if dt.failure != nil {
+		failed := h.newEvent(enumspb.EVENT_TYPE_DECISION_TASK_FAILED)
+		failed.Attributes = &historypb.HistoryEvent_DecisionTaskFailedEventAttributes{DecisionTaskFailedEventAttributes: &historypb.DecisionTaskFailedEventAttributes{
+			ScheduledEventId: dt.scheduledEventID,
+			StartedEventId:   dt.startedEventID,
+			Cause:            enumspb.DECISION_TASK_FAILED_CAUSE_WORKFLOW_WORKER_UNHANDLED_FAILURE,
+			Failure:          dt.failure,
+			Identity:         h.env.identity,
+		}}
+		h.append(failed)
+	} else {
+		completed := h.newEvent(enumspb.EVENT_TYPE_DECISION_TASK_COMPLETED)
+		completed.Attributes = &historypb.HistoryEvent_DecisionTaskCompletedEventAttributes{DecisionTaskCompletedEventAttributes: &historypb.DecisionTaskCompletedEventAttributes{
+			ScheduledEventId: dt.scheduledEventID,
+			StartedEventId:   dt.startedEventID,
+			Identity:         h.env.identity,
+		}}
+		h.append(completed)
+		for _, decision := range dt.decisions {
+			h.append(decision)
+		}
+	}
+	if h.closed {
+		// nothing is recorded after the workflow is closed
+		return
+	}
+	for _, newEvent := range dt.pending {
+		h.append(newEvent())
+	}
+}
+
+// addEvent records an event which the test environment delivers to the workflow. The event is created once its
+// position in the history is known, so it can refer to the IDs of the decisions of the open decision task.
+func (h *testWorkflowHistory) addEvent(newEvent func() *historypb.HistoryEvent) {
+	if h.closed {
+		return
+	}
+	if dt := h.decisionTask; dt != nil {
+		if dt.running {
+			dt.pending = append(dt.pending, newEvent)
+			dt.flush = true
+			return
+		}
+		h.flush()
+	}
+	h.append(newEvent())
+}
+
+// addDeferredEvent records an event which is not delivered to the workflow, so it does not complete the open
+// decision task.
+func (h *testWorkflowHistory) addDeferredEvent(newEvent func() *historypb.HistoryEvent) {
+	if h.closed {
+		return
+	}
+	if dt := h.decisionTask; dt != nil {
+		dt.pending = append(dt.pending, newEvent)
+		return
+	}
+	h.append(newEvent())
+}
+
+// decisionID returns the ID the next decision gets.
+func (h *testWorkflowHistory) decisionID() int64 {
+	h.openDecisionTask()
+	return h.nextDecisionID
+}
+
+// addDecision records a decision which takes the next decision ID.
+func (h *testWorkflowHistory) addDecision(event *historypb.HistoryEvent) {
+	dt := h.openDecisionTask()
+	dt.decisions = append(dt.decisions, event)
+	h.nextDecisionID++
+}
+
+// addCancelDecision records a cancellation decision, which does not take a decision ID.
+func (h *testWorkflowHistory) addCancelDecision(event *historypb.HistoryEvent) {
+	dt := h.openDecisionTask()
+	dt.decisions = append(dt.decisions, event)
+}
+
+// dropDecision removes a decision of the open decision task, which is what happens when a decision is canceled before
+// it is sent to the server.
+func (h *testWorkflowHistory) dropDecision(event *historypb.HistoryEvent) bool {
+	dt := h.decisionTask
+	if dt == nil {
+		return false
+	}
+	for i, decision := range dt.decisions {
+		if decision == event {
+			dt.decisions = append(dt.decisions[:i], dt.decisions[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (h *testWorkflowHistory) workflowStarted(input *commonpb.Payloads, delayStart time.Duration) {
+	wInfo := h.env.workflowInfo
+	attributes := &historypb.WorkflowExecutionStartedEventAttributes{
+		WorkflowType:                    &commonpb.WorkflowType{Name: wInfo.WorkflowType.Name},
+		ParentWorkflowNamespace:         wInfo.ParentWorkflowNamespace,
+		TaskList:                        &tasklistpb.TaskList{Name: wInfo.TaskListName},
+		Input:                           input,
+		WorkflowExecutionTimeoutSeconds: wInfo.WorkflowExecutionTimeoutSeconds,
+		WorkflowRunTimeoutSeconds:       wInfo.WorkflowRunTimeoutSeconds,
+		WorkflowTaskTimeoutSeconds:      wInfo.WorkflowTaskTimeoutSeconds,
+		ContinuedExecutionRunId:         wInfo.ContinuedExecutionRunID,
+		LastCompletionResult:            wInfo.lastCompletionResult,
+		OriginalExecutionRunId:          wInfo.WorkflowExecution.RunID,
+		Identity:                        h.env.identity,
+		FirstExecutionRunId:             wInfo.WorkflowExecution.RunID,
+		Attempt:                         wInfo.Attempt,
+		CronSchedule:                    wInfo.CronSchedule,
+		FirstDecisionTaskBackoffSeconds: common.Int32Ceil(delayStart.Seconds()),
+		Memo:                            wInfo.Memo,
+		SearchAttributes:                wInfo.SearchAttributes,
+		Header:                          h.env.header,
+	}
+	if parent := wInfo.ParentWorkflowExecution; parent != nil {
+		attributes.ParentWorkflowExecution = &commonpb.WorkflowExecution{WorkflowId: parent.ID, RunId: parent.RunID}
+	}
+	h.addEvent(func() *historypb.HistoryEvent {
+		event := h.newEvent(enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED)
+		event.Attributes = &historypb.HistoryEvent_WorkflowExecutionStartedEventAttributes{WorkflowExecutionStartedEventAttributes: attributes}
+		return event
+	})
+}
+
+func (h *testWorkflowHistory) workflowSignaled(signalName string, input *commonpb.Payloads) {
+	h.addEvent(func() *historypb.HistoryEvent {
+		event := h.newEvent(enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_SIGNALED)
+		event.Attributes = &historypb.HistoryEvent_WorkflowExecutionSignaledEventAttributes{WorkflowExecutionSignaledEventAttributes: &historypb.WorkflowExecutionSignaledEventAttributes{
+			SignalName: signalName,
+			Input:      input,
+			Identity:   h.env.identity,
+		}}
+		return event
+	})
+}
+
+func (h *testWorkflowHistory) workflowCancelRequested() {
+	h.addEvent(func() *historypb.HistoryEvent {
+		event := h.newEvent(enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CANCEL_REQUESTED)
+		event.Attributes = &historypb.HistoryEvent_WorkflowExecutionCancelRequestedEventAttributes{WorkflowExecutionCancelRequestedEventAttributes: &historypb.WorkflowExecutionCancelRequestedEventAttributes{
+			Identity: h.env.identity,
+		}}
+		return event
+	})
+}
+
+func (h *testWorkflowHistory) workflowTimedOut() {
+	h.addEvent(func() *historypb.HistoryEvent {
+		event := h.newEvent(enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_TIMED_OUT)
+		event.Attributes = &historypb.HistoryEvent_WorkflowExecutionTimedOutEventAttributes{WorkflowExecutionTimedOutEventAttributes: &historypb.WorkflowExecutionTimedOutEventAttributes{
+			RetryStatus: enumspb.RETRY_STATUS_TIMEOUT,
+		}}
+		return event
+	})
+	h.closed = true
+}
+
+// workflowClosed records the decision closing the workflow, or the failure of the decision task if the workflow
+// panicked.
+func (h *testWorkflowHistory) workflowClosed(result *commonpb.Payloads, err error) {
+	if h.closed {
+		return
+	}
+	dc := h.env.GetDataConverter()
+	dt := h.openDecisionTask()
+
+	var workflowPanicErr *workflowPanicError
+	var canceledErr *CanceledError
+	var contErr *ContinueAsNewError
+	var event *historypb.HistoryEvent
+	if errors.As(err, &workflowPanicErr) {
+		dt.failure = convertErrorToFailure(err, dc)
+	} else if errors.As(err, &canceledErr) {
+		event = h.newEvent(enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CANCELED)
+		event.Attributes = &historypb.HistoryEvent_WorkflowExecutionCanceledEventAttributes{WorkflowExecutionCanceledEventAttributes: &historypb.WorkflowExecutionCanceledEventAttributes{
+			DecisionTaskCompletedEventId: dt.completedEventID(),
+			Details:                      convertErrDetailsToPayloads(canceledErr.details, dc),
+		}}
+	} else if errors.As(err, &contErr) {
+		wInfo := h.env.workflowInfo
+		event = h.newEvent(enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CONTINUED_AS_NEW)
+		event.Attributes = &historypb.HistoryEvent_WorkflowExecutionContinuedAsNewEventAttributes{WorkflowExecutionContinuedAsNewEventAttributes: &historypb.WorkflowExecutionContinuedAsNewEventAttributes{
+			WorkflowType:                 &commonpb.WorkflowType{Name: contErr.params.WorkflowType.Name},
+			TaskList:                     &tasklistpb.TaskList{Name: contErr.params.TaskListName},
+			Input:                        contErr.params.Input,
+			WorkflowRunTimeoutSeconds:    contErr.params.WorkflowRunTimeoutSeconds,
+			WorkflowTaskTimeoutSeconds:   contErr.params.WorkflowTaskTimeoutSeconds,
+			DecisionTaskCompletedEventId: dt.completedEventID(),
+			Header:                       contErr.params.Header,
+			Memo:                         wInfo.Memo,
+			SearchAttributes:             wInfo.SearchAttributes,
+		}}
+	} else if err != nil {
+		event = h.newEvent(enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED)
+		event.Attributes = &historypb.HistoryEvent_WorkflowExecutionFailedEventAttributes{WorkflowExecutionFailedEventAttributes: &historypb.WorkflowExecutionFailedEventAttributes{
+			Failure:                      convertErrorToFailure(err, dc),
+			DecisionTaskCompletedEventId: dt.completedEventID(),
+		}}
+	} else {
+		event = h.newEvent(enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED)
+		event.Attributes = &historypb.HistoryEvent_WorkflowExecutionCompletedEventAttributes{WorkflowExecutionCompletedEventAttributes: &historypb.WorkflowExecutionCompletedEventAttributes{
+			Result:                       result,
+			DecisionTaskCompletedEventId: dt.completedEventID(),
+		}}
+	}
+	if event != nil {
+		h.addDecision(event)
+	}
+
+	h.closed = true
+	if !dt.running {
+		h.flush()
+	}
+}
+
+func (h *testWorkflowHistory) scheduleActivity(activityID string, parameters ExecuteActivityParams, attributes *decisionpb.ScheduleActivityTaskDecisionAttributes) {
+	if h.closed {
+		return
+	}
+	scheduledActivityID := parameters.ActivityID
+	if scheduledActivityID == "" {
+		scheduledActivityID = getStringID(h.decisionID())
+	}
+	scheduled := h.newEvent(enumspb.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED)
+	scheduled.Attributes = &historypb.HistoryEvent_ActivityTaskScheduledEventAttributes{ActivityTaskScheduledEventAttributes: &historypb.ActivityTaskScheduledEventAttributes{
+		ActivityId:                    scheduledActivityID,
+		ActivityType:                  attributes.ActivityType,
+		Namespace:                     attributes.Namespace,
+		TaskList:                      attributes.TaskList,
+		Header:                        attributes.Header,
+		Input:                         attributes.Input,
+		ScheduleToCloseTimeoutSeconds: attributes.ScheduleToCloseTimeoutSeconds,
+		ScheduleToStartTimeoutSeconds: attributes.ScheduleToStartTimeoutSeconds,
+		StartToCloseTimeoutSeconds:    attributes.StartToCloseTimeoutSeconds,
+		HeartbeatTimeoutSeconds:       attributes.HeartbeatTimeoutSeconds,
+		DecisionTaskCompletedEventId:  h.openDecisionTask().completedEventID(),
+		RetryPolicy:                   attributes.RetryPolicy,
+	}}
+	h.addDecision(scheduled)
+	h.activities[activityID] = &testHistoryActivity{scheduled: scheduled, waitForCancellation: parameters.WaitForCancellation}
+}
+
+// activityResult records the activity as started and closed with the result it responded with.
+func (h *testWorkflowHistory) activityResult(activityID string, result interface{}) {
+	activity, ok := h.activities[activityID]
+	if !ok {
+		return
+	}
+	delete(h.activities, activityID)
+
+	h.addEvent(func() *historypb.HistoryEvent {
+		activity.started = h.newEvent(enumspb.EVENT_TYPE_ACTIVITY_TASK_STARTED)
+		activity.started.Attributes = &historypb.HistoryEvent_ActivityTaskStartedEventAttributes{ActivityTaskStartedEventAttributes: &historypb.ActivityTaskStartedEventAttributes{
+			ScheduledEventId: activity.scheduled.EventId,
+			Identity:         h.env.identity,
+		}}
+		return activity.started
+	})
+	h.addEvent(func() *historypb.HistoryEvent {
+		var event *historypb.HistoryEvent
+		switch request := result.(type) {
+		case *workflowservice.RespondActivityTaskCanceledRequest:
+			event = h.newEvent(enumspb.EVENT_TYPE_ACTIVITY_TASK_CANCELED)
+			attributes := &historypb.ActivityTaskCanceledEventAttributes{
+				Details:          request.Details,
+				ScheduledEventId: activity.scheduled.EventId,
+				StartedEventId:   activity.started.EventId,
+				Identity:         h.env.identity,
+			}
+			if activity.cancelRequested != nil {
+				attributes.LatestCancelRequestedEventId = activity.cancelRequested.EventId
+			}
+			event.Attributes = &historypb.HistoryEvent_ActivityTaskCanceledEventAttributes{ActivityTaskCanceledEventAttributes: attributes}
+		case *workflowservice.RespondActivityTaskFailedRequest:
+			event = h.newEvent(enumspb.EVENT_TYPE_ACTIVITY_TASK_FAILED)
+			event.Attributes = &historypb.HistoryEvent_ActivityTaskFailedEventAttributes{ActivityTaskFailedEventAttributes: &historypb.ActivityTaskFailedEventAttributes{
+				Failure:          request.Failure,
+				ScheduledEventId: activity.scheduled.EventId,
+				StartedEventId:   activity.started.EventId,
+				Identity:         h.env.identity,
+				RetryStatus:      enumspb.RETRY_STATUS_NON_RETRYABLE_FAILURE,
+			}}
+		case *workflowservice.RespondActivityTaskCompletedRequest:
+			event = h.newEvent(enumspb.EVENT_TYPE_ACTIVITY_TASK_COMPLETED)
+			event.Attributes = &historypb.HistoryEvent_ActivityTaskCompletedEventAttributes{ActivityTaskCompletedEventAttributes: &historypb.ActivityTaskCompletedEventAttributes{
+				Result:           request.Result,
+				ScheduledEventId: activity.scheduled.EventId,
+				StartedEventId:   activity.started.EventId,
+				Identity:         h.env.identity,
+			}}
+		default:
+			timeoutErr := NewTimeoutError(enumspb.TIMEOUT_TYPE_START_TO_CLOSE, context.DeadlineExceeded)
+			event = h.newEvent(enumspb.EVENT_TYPE_ACTIVITY_TASK_TIMED_OUT)
+			event.Attributes = &historypb.HistoryEvent_ActivityTaskTimedOutEventAttributes{ActivityTaskTimedOutEventAttributes: &historypb.ActivityTaskTimedOutEventAttributes{
+				Failure:          convertErrorToFailure(timeoutErr, h.env.GetDataConverter()),
+				ScheduledEventId: activity.scheduled.EventId,
+				StartedEventId:   activity.started.EventId,
+				RetryStatus:      enumspb.RETRY_STATUS_TIMEOUT,
+			}}
+		}
+		return event
+	})
+}
+
+// requestCancelActivity records the cancellation request of the activity. Unless the workflow waits for the
+// cancellation, it is not interested in the cancellation outcome, which is recorded right away.
+func (h *testWorkflowHistory) requestCancelActivity(activityID string) {
+	activity, ok := h.activities[activityID]
+	if !ok || h.closed {
+		return
+	}
+	if h.dropDecision(activity.scheduled) {
+		delete(h.activities, activityID)
+		return
+	}
+
+	cancelRequested := h.newEvent(enumspb.EVENT_TYPE_ACTIVITY_TASK_CANCEL_REQUESTED)
+	cancelRequested.Attributes = &historypb.HistoryEvent_ActivityTaskCancelRequestedEventAttributes{ActivityTaskCancelRequestedEventAttributes: &historypb.ActivityTaskCancelRequestedEventAttributes{
+		ScheduledEventId:             activity.scheduled.EventId,
+		DecisionTaskCompletedEventId: h.openDecisionTask().completedEventID(),
+	}}
+	h.addCancelDecision(cancelRequested)
+	activity.cancelRequested = cancelRequested
+
+	if !activity.waitForCancellation {
+		delete(h.activities, activityID)
+		h.addDeferredEvent(func() *historypb.HistoryEvent {
+			return h.newActivityCanceledEvent(activity)
+		})
+	}
+}
+
+// activityCanceled records the cancellation of an activity the workflow waits for.
+func (h *testWorkflowHistory) activityCanceled(activityID string) {
+	activity, ok := h.activities[activityID]
+	if !ok || activity.cancelRequested == nil {
+		return
+	}
+	delete(h.activities, activityID)
+	h.addEvent(func() *historypb.HistoryEvent {
+		return h.newActivityCanceledEvent(activity)
+	})
+}
+
+func (h *testWorkflowHistory) newActivityCanceledEvent(activity *testHistoryActivity) *historypb.HistoryEvent {
+	event := h.newEvent(enumspb.EVENT_TYPE_ACTIVITY_TASK_CANCELED)
+	event.Attributes = &historypb.HistoryEvent_ActivityTaskCanceledEventAttributes{ActivityTaskCanceledEventAttributes: &historypb.ActivityTaskCanceledEventAttributes{
+		LatestCancelRequestedEventId: activity.cancelRequested.EventId,
+		ScheduledEventId:             activity.scheduled.EventId,
+		Identity:                     h.env.identity,
+	}}
+	return event
+}
+
+func (h *testWorkflowHistory) startTimer(timerID string, d time.Duration) {
+	if h.closed {
+		return
+	}
+	started := h.newEvent(enumspb.EVENT_TYPE_TIMER_STARTED)
+	started.Attributes = &historypb.HistoryEvent_TimerStartedEventAttributes{TimerStartedEventAttributes: &historypb.TimerStartedEventAttributes{
+		TimerId:                      getStringID(h.decisionID()),
+		StartToFireTimeoutSeconds:    common.Int64Ceil(d.Seconds()),
+		DecisionTaskCompletedEventId: h.openDecisionTask().completedEventID(),
+	}}
+	h.addDecision(started)
+	h.timers[timerID] = started
+}
+
+func (h *testWorkflowHistory) timerFired(timerID string) {
+	started, ok := h.timers[timerID]
+	if !ok {
+		return
+	}
+	delete(h.timers, timerID)
+	h.addEvent(func() *historypb.HistoryEvent {
+		event := h.newEvent(enumspb.EVENT_TYPE_TIMER_FIRED)
+		event.Attributes = &historypb.HistoryEvent_TimerFiredEventAttributes{TimerFiredEventAttributes: &historypb.TimerFiredEventAttributes{
+			TimerId:        started.GetTimerStartedEventAttributes().GetTimerId(),
+			StartedEventId: started.EventId,
+		}}
+		return event
+	})
+}
+
+func (h *testWorkflowHistory) cancelTimer(timerID string) {
+	started, ok := h.timers[timerID]
+	if !ok || h.closed {
+		return
+	}
+	delete(h.timers, timerID)
+	if h.dropDecision(started) {
+		return
+	}
+	canceled := h.newEvent(enumspb.EVENT_TYPE_TIMER_CANCELED)
+	canceled.Attributes = &historypb.HistoryEvent_TimerCanceledEventAttributes{TimerCanceledEventAttributes: &historypb.TimerCanceledEventAttributes{
+		TimerId:                      started.GetTimerStartedEventAttributes().GetTimerId(),
+		StartedEventId:               started.EventId,
+		DecisionTaskCompletedEventId: h.openDecisionTask().completedEventID(),
+		Identity:                     h.env.identity,
+	}}
+	h.addCancelDecision(canceled)
+}
+
+func (h *testWorkflowHistory) addMarker(attributes *decisionpb.RecordMarkerDecisionAttributes) {
+	marker := h.newEvent(enumspb.EVENT_TYPE_MARKER_RECORDED)
+	marker.Attributes = &historypb.HistoryEvent_MarkerRecordedEventAttributes{MarkerRecordedEventAttributes: &historypb.MarkerRecordedEventAttributes{
+		MarkerName:                   attributes.MarkerName,
+		Details:                      attributes.Details,
+		DecisionTaskCompletedEventId: h.openDecisionTask().completedEventID(),
+		Header:                       attributes.Header,
+		Failure:                      attributes.Failure,
+	}}
+	h.addDecision(marker)
+}
+
+func (h *testWorkflowHistory) sideEffect(data *commonpb.Payloads) {
+	if h.closed {
+		return
+	}
+	h.addMarker(newSideEffectMarkerAttributes(h.decisionID(), data, h.env.GetDataConverter()))
+}
+
+func (h *testWorkflowHistory) versionMarker(changeID string, version Version) {
+	if h.closed {
+		return
+	}
+	h.addMarker(newVersionMarkerAttributes(changeID, version, h.env.GetDataConverter()))
+}
+
+func (h *testWorkflowHistory) mutableSideEffect(id string, data *commonpb.Payloads) {
+	if h.closed {
+		return
+	}
+	details, err := encodeArgs(h.env.GetDataConverter(), []interface{}{id, data})
+	if err != nil {
+		panic(err)
+	}
+	h.addMarker(newMutableSideEffectMarkerAttributes(id, details, h.env.GetDataConverter()))
+}
+
+func (h *testWorkflowHistory) upsertSearchAttributes(searchAttributes *commonpb.SearchAttributes) {
+	if h.closed {
+		return
+	}
+	upserted := h.newEvent(enumspb.EVENT_TYPE_UPSERT_WORKFLOW_SEARCH_ATTRIBUTES)
+	upserted.Attributes = &historypb.HistoryEvent_UpsertWorkflowSearchAttributesEventAttributes{UpsertWorkflowSearchAttributesEventAttributes: &historypb.UpsertWorkflowSearchAttributesEventAttributes{
+		DecisionTaskCompletedEventId: h.openDecisionTask().completedEventID(),
+		SearchAttributes:             searchAttributes,
+	}}
+	h.addDecision(upserted)
+}
+
+// scheduleLocalActivity reserves the ID of a local activity, which is the next decision ID at the time it is scheduled.
+func (h *testWorkflowHistory) scheduleLocalActivity(activityID string) {
+	if h.closed {
+		return
+	}
+	h.localActivities[activityID] = getStringID(h.decisionID())
+}
+
+func (h *testWorkflowHistory) localActivityResult(task *localActivityTask, result *LocalActivityResultWrapper) {
+	activityID, ok := h.localActivities[task.activityID]
+	if !ok || h.closed {
+		return
+	}
+	delete(h.localActivities, task.activityID)
+
+	lamd := localActivityMarkerData{
+		ActivityID:   activityID,
+		ActivityType: task.params.ActivityType,
+		ReplayTime:   h.env.Now(),
+		Attempt:      task.attempt,
+	}
+	if result.Err != nil {
+		lamd.Backoff = result.Backoff
+	}
+	dc := h.env.GetDataConverter()
+	details, err := encodeLocalActivityMarkerDetails(lamd, result.Result, result.Err, dc)
+	if err != nil {
+		panic(err)
+	}
+	h.addMarker(&decisionpb.RecordMarkerDecisionAttributes{
+		MarkerName: localActivityMarkerName,
+		Details:    details,
+		Failure:    convertErrorToFailure(result.Err, dc),
+	})
+}
+
+// startChildWorkflow records the start of a child workflow and returns handlers recording its outcome.
+func (h *testWorkflowHistory) startChildWorkflow(params *ExecuteWorkflowParams, callback ResultHandler,
+	startedHandler func(r WorkflowExecution, e error)) (ResultHandler, func(r WorkflowExecution, e error)) {
+	if h.closed {
+		return callback, startedHandler
+	}
+	dc := h.env.GetDataConverter()
+	child := &testHistoryChild{
+		namespace:    params.Namespace,
+		workflowID:   params.WorkflowID,
+		workflowType: &commonpb.WorkflowType{Name: params.WorkflowType.Name},
+	}
+	if child.workflowID == "" {
+		child.workflowID = h.env.workflowInfo.WorkflowExecution.RunID + "_" + getStringID(h.decisionID())
+	}
+	memo, _ := getWorkflowMemo(params.Memo, dc)
+	searchAttr, _ := serializeSearchAttributes(params.SearchAttributes)
+
+	child.initiated = h.newEvent(enumspb.EVENT_TYPE_START_CHILD_WORKFLOW_EXECUTION_INITIATED)
+	child.initiated.Attributes = &historypb.HistoryEvent_StartChildWorkflowExecutionInitiatedEventAttributes{StartChildWorkflowExecutionInitiatedEventAttributes: &historypb.StartChildWorkflowExecutionInitiatedEventAttributes{
+		Namespace:                       params.Namespace,
+		WorkflowId:                      child.workflowID,
+		WorkflowType:                    child.workflowType,
+		TaskList:                        &tasklistpb.TaskList{Name: params.TaskListName},
+		Input:                           params.Input,
+		WorkflowExecutionTimeoutSeconds: params.WorkflowExecutionTimeoutSeconds,
+		WorkflowRunTimeoutSeconds:       params.WorkflowRunTimeoutSeconds,
+		WorkflowTaskTimeoutSeconds:      params.WorkflowTaskTimeoutSeconds,
+		ParentClosePolicy:               params.ParentClosePolicy.toProto(),
+		DecisionTaskCompletedEventId:    h.openDecisionTask().completedEventID(),
+		WorkflowIdReusePolicy:           params.WorkflowIDReusePolicy.toProto(),
+		RetryPolicy:                     params.RetryPolicy,
+		CronSchedule:                    params.CronSchedule,
+		Header:                          params.Header,
+		Memo:                            memo,
+		SearchAttributes:                searchAttr,
+	}}
+	h.addDecision(child.initiated)
+
+	recordingCallback := func(result *commonpb.Payloads, err error) {
+		h.childWorkflowClosed(child, result, err)
+		callback(result, err)
+	}
+	recordingStartedHandler := func(r WorkflowExecution, e error) {
+		if e != nil {
+			h.childWorkflowStartFailed(child)
+		} else {
+			h.childWorkflowStarted(child, r)
+		}
+		startedHandler(r, e)
+	}
+	return recordingCallback, recordingStartedHandler
+}
+
+func (h *testWorkflowHistory) childWorkflowStartFailed(child *testHistoryChild) {
+	if child.failed || child.started != nil {
+		return
+	}
+	child.failed = true
+	h.addEvent(func() *historypb.HistoryEvent {
+		event := h.newEvent(enumspb.EVENT_TYPE_START_CHILD_WORKFLOW_EXECUTION_FAILED)
+		event.Attributes = &historypb.HistoryEvent_StartChildWorkflowExecutionFailedEventAttributes{StartChildWorkflowExecutionFailedEventAttributes: &historypb.StartChildWorkflowExecutionFailedEventAttributes{
+			Namespace:                    child.namespace,
+			WorkflowId:                   child.workflowID,
+			WorkflowType:                 child.workflowType,
+			Cause:                        enumspb.START_CHILD_WORKFLOW_EXECUTION_FAILED_CAUSE_WORKFLOW_ALREADY_EXISTS,
+			InitiatedEventId:             child.initiated.EventId,
+			DecisionTaskCompletedEventId: child.initiated.GetStartChildWorkflowExecutionInitiatedEventAttributes().GetDecisionTaskCompletedEventId(),
+		}}
+		return event
+	})
+}
+
+func (h *testWorkflowHistory) childWorkflowStarted(child *testHistoryChild, execution WorkflowExecution) {
+	child.execution = &commonpb.WorkflowExecution{WorkflowId: child.workflowID, RunId: execution.RunID}
+	h.children[execution.ID] = child
+	h.addEvent(func() *historypb.HistoryEvent {
+		child.started = h.newEvent(enumspb.EVENT_TYPE_CHILD_WORKFLOW_EXECUTION_STARTED)
+		child.started.Attributes = &historypb.HistoryEvent_ChildWorkflowExecutionStartedEventAttributes{ChildWorkflowExecutionStartedEventAttributes: &historypb.ChildWorkflowExecutionStartedEventAttributes{
+			Namespace:         child.namespace,
+			InitiatedEventId:  child.initiated.EventId,
+			WorkflowExecution: child.execution,
+			WorkflowType:      child.workflowType,
+		}}
+		return child.started
+	})
+}
+
+func (h *testWorkflowHistory) childWorkflowClosed(child *testHistoryChild, result *commonpb.Payloads, err error) {
+	if child.execution == nil {
+		h.childWorkflowStartFailed(child)
+		return
+	}
+	h.addEvent(func() *historypb.HistoryEvent {
+		var canceledErr *CanceledError
+		var event *historypb.HistoryEvent
+		if errors.As(err, &canceledErr) {
+			event = h.newEvent(enumspb.EVENT_TYPE_CHILD_WORKFLOW_EXECUTION_CANCELED)
+			event.Attributes = &historypb.HistoryEvent_ChildWorkflowExecutionCanceledEventAttributes{ChildWorkflowExecutionCanceledEventAttributes: &historypb.ChildWorkflowExecutionCanceledEventAttributes{
+				Details:           convertErrDetailsToPayloads(canceledErr.details, h.env.GetDataConverter()),
+				Namespace:         child.namespace,
+				WorkflowExecution: child.execution,
+				WorkflowType:      child.workflowType,
+				InitiatedEventId:  child.initiated.EventId,
+				StartedEventId:    child.started.EventId,
+			}}
+		} else if errors.Is(err, ErrDeadlineExceeded) {
+			event = h.newEvent(enumspb.EVENT_TYPE_CHILD_WORKFLOW_EXECUTION_TIMED_OUT)
+			event.Attributes = &historypb.HistoryEvent_ChildWorkflowExecutionTimedOutEventAttributes{ChildWorkflowExecutionTimedOutEventAttributes: &historypb.ChildWorkflowExecutionTimedOutEventAttributes{
+				Namespace:         child.namespace,
+				WorkflowExecution: child.execution,
+				WorkflowType:      child.workflowType,
+				InitiatedEventId:  child.initiated.EventId,
+				StartedEventId:    child.started.EventId,
+				RetryStatus:       enumspb.RETRY_STATUS_TIMEOUT,
+			}}
+		} else if err != nil {
+			event = h.newEvent(enumspb.EVENT_TYPE_CHILD_WORKFLOW_EXECUTION_FAILED)
+			event.Attributes = &historypb.HistoryEvent_ChildWorkflowExecutionFailedEventAttributes{ChildWorkflowExecutionFailedEventAttributes: &historypb.ChildWorkflowExecutionFailedEventAttributes{
+				Failure:           convertErrorToFailure(err, h.env.GetDataConverter()),
+				Namespace:         child.namespace,
+				WorkflowExecution: child.execution,
+				WorkflowType:      child.workflowType,
+				InitiatedEventId:  child.initiated.EventId,
+				StartedEventId:    child.started.EventId,
+				RetryStatus:       enumspb.RETRY_STATUS_NON_RETRYABLE_FAILURE,
+			}}
+		} else {
+			event = h.newEvent(enumspb.EVENT_TYPE_CHILD_WORKFLOW_EXECUTION_COMPLETED)
+			event.Attributes = &historypb.HistoryEvent_ChildWorkflowExecutionCompletedEventAttributes{ChildWorkflowExecutionCompletedEventAttributes: &historypb.ChildWorkflowExecutionCompletedEventAttributes{
+				Result:            result,
+				Namespace:         child.namespace,
+				WorkflowExecution: child.execution,
+				WorkflowType:      child.workflowType,
+				InitiatedEventId:  child.initiated.EventId,
+				StartedEventId:    child.started.EventId,
+			}}
+		}
+		return event
+	})
+}
+
+// childWorkflowID returns the ID the child workflow with the test environment ID has in the history.
+func (h *testWorkflowHistory) childWorkflowID(workflowID string) string {
+	if child, ok := h.children[workflowID]; ok {
+		return child.workflowID
+	}
+	return workflowID
+}
+
+func (h *testWorkflowHistory) requestCancelChildWorkflow(workflowID string) {
+	child, ok := h.children[workflowID]
+	if !ok || h.closed {
+		return
+	}
+	execution := &commonpb.WorkflowExecution{WorkflowId: child.workflowID}
+	initiated := h.newEvent(enumspb.EVENT_TYPE_REQUEST_CANCEL_EXTERNAL_WORKFLOW_EXECUTION_INITIATED)
+	initiated.Attributes = &historypb.HistoryEvent_RequestCancelExternalWorkflowExecutionInitiatedEventAttributes{RequestCancelExternalWorkflowExecutionInitiatedEventAttributes: &historypb.RequestCancelExternalWorkflowExecutionInitiatedEventAttributes{
+		DecisionTaskCompletedEventId: h.openDecisionTask().completedEventID(),
+		Namespace:                    child.namespace,
+		WorkflowExecution:            execution,
+		ChildWorkflowOnly:            true,
+	}}
+	h.addCancelDecision(initiated)
+	h.addDeferredEvent(func() *historypb.HistoryEvent {
+		return h.newExternalWorkflowExecutionCancelRequestedEvent(initiated, child.namespace, execution)
+	})
+}
+
+// requestCancelExternalWorkflow records the cancellation request of another workflow and returns a handler recording
+// its outcome.
+func (h *testWorkflowHistory) requestCancelExternalWorkflow(namespace, workflowID, runID string, callback ResultHandler) ResultHandler {
+	if h.closed {
+		return callback
+	}
+	execution := &commonpb.WorkflowExecution{WorkflowId: h.childWorkflowID(workflowID), RunId: runID}
+	control := getStringID(h.decisionID())
+	initiated := h.newEvent(enumspb.EVENT_TYPE_REQUEST_CANCEL_EXTERNAL_WORKFLOW_EXECUTION_INITIATED)
+	initiated.Attributes = &historypb.HistoryEvent_RequestCancelExternalWorkflowExecutionInitiatedEventAttributes{RequestCancelExternalWorkflowExecutionInitiatedEventAttributes: &historypb.RequestCancelExternalWorkflowExecutionInitiatedEventAttributes{
+		DecisionTaskCompletedEventId: h.openDecisionTask().completedEventID(),
+		Namespace:                    namespace,
+		WorkflowExecution:            execution,
+		Control:                      control,
+	}}
+	h.addDecision(initiated)
+
+	return func(result *commonpb.Payloads, err error) {
+		h.addEvent(func() *historypb.HistoryEvent {
+			if err == nil {
+				return h.newExternalWorkflowExecutionCancelRequestedEvent(initiated, namespace, execution)
+			}
+			event := h.newEvent(enumspb.EVENT_TYPE_REQUEST_CANCEL_EXTERNAL_WORKFLOW_EXECUTION_FAILED)
+			event.Attributes = &historypb.HistoryEvent_RequestCancelExternalWorkflowExecutionFailedEventAttributes{RequestCancelExternalWorkflowExecutionFailedEventAttributes: &historypb.RequestCancelExternalWorkflowExecutionFailedEventAttributes{
+				Cause:                        enumspb.CANCEL_EXTERNAL_WORKFLOW_EXECUTION_FAILED_CAUSE_EXTERNAL_WORKFLOW_EXECUTION_NOT_FOUND,
+				DecisionTaskCompletedEventId: initiated.GetRequestCancelExternalWorkflowExecutionInitiatedEventAttributes().GetDecisionTaskCompletedEventId(),
+				Namespace:                    namespace,
+				WorkflowExecution:            execution,
+				InitiatedEventId:             initiated.EventId,
+				Control:                      control,
+			}}
+			return event
+		})
+		callback(result, err)
+	}
+}
+
+func (h *testWorkflowHistory) newExternalWorkflowExecutionCancelRequestedEvent(initiated *historypb.HistoryEvent,
+	namespace string, execution *commonpb.WorkflowExecution) *historypb.HistoryEvent {
+	event := h.newEvent(enumspb.EVENT_TYPE_EXTERNAL_WORKFLOW_EXECUTION_CANCEL_REQUESTED)
+	event.Attributes = &historypb.HistoryEvent_ExternalWorkflowExecutionCancelRequestedEventAttributes{ExternalWorkflowExecutionCancelRequestedEventAttributes: &historypb.ExternalWorkflowExecutionCancelRequestedEventAttributes{
+		InitiatedEventId:  initiated.EventId,
+		Namespace:         namespace,
+		WorkflowExecution: execution,
+	}}
+	return event
+}
+
+// signalExternalWorkflow records the signal sent to another workflow and returns a handler recording its outcome.
+func (h *testWorkflowHistory) signalExternalWorkflow(namespace, workflowID, runID, signalName string, input *commonpb.Payloads,
+	childWorkflowOnly bool, callback ResultHandler) ResultHandler {
+	if h.closed {
+		return callback
+	}
+	execution := &commonpb.WorkflowExecution{WorkflowId: h.childWorkflowID(workflowID), RunId: runID}
+	control := getStringID(h.decisionID())
+	initiated := h.newEvent(enumspb.EVENT_TYPE_SIGNAL_EXTERNAL_WORKFLOW_EXECUTION_INITIATED)
+	initiated.Attributes = &historypb.HistoryEvent_SignalExternalWorkflowExecutionInitiatedEventAttributes{SignalExternalWorkflowExecutionInitiatedEventAttributes: &historypb.SignalExternalWorkflowExecutionInitiatedEventAttributes{
+		DecisionTaskCompletedEventId: h.openDecisionTask().completedEventID(),
+		Namespace:                    namespace,
+		WorkflowExecution:            execution,
+		SignalName:                   signalName,
+		Input:                        input,
+		Control:                      control,
+		ChildWorkflowOnly:            childWorkflowOnly,
+	}}
+	h.addDecision(initiated)
+
+	return func(result *commonpb.Payloads, err error) {
+		h.addEvent(func() *historypb.HistoryEvent {
+			if err == nil {
+				event := h.newEvent(enumspb.EVENT_TYPE_EXTERNAL_WORKFLOW_EXECUTION_SIGNALED)
+				event.Attributes = &historypb.HistoryEvent_ExternalWorkflowExecutionSignaledEventAttributes{ExternalWorkflowExecutionSignaledEventAttributes: &historypb.ExternalWorkflowExecutionSignaledEventAttributes{
+					InitiatedEventId:  initiated.EventId,
+					Namespace:         namespace,
+					WorkflowExecution: execution,
+					Control:           control,
+				}}
+				return event
+			}
+			event := h.newEvent(enumspb.EVENT_TYPE_SIGNAL_EXTERNAL_WORKFLOW_EXECUTION_FAILED)
+			event.Attributes = &historypb.HistoryEvent_SignalExternalWorkflowExecutionFailedEventAttributes{SignalExternalWorkflowExecutionFailedEventAttributes: &historypb.SignalExternalWorkflowExecutionFailedEventAttributes{
+				Cause:                        enumspb.SIGNAL_EXTERNAL_WORKFLOW_EXECUTION_FAILED_CAUSE_EXTERNAL_WORKFLOW_EXECUTION_NOT_FOUND,
+				DecisionTaskCompletedEventId: initiated.GetSignalExternalWorkflowExecutionInitiatedEventAttributes().GetDecisionTaskCompletedEventId(),
+				Namespace:                    namespace,
+				WorkflowExecution:            execution,
+				InitiatedEventId:             initiated.EventId,
+				Control:                      control,
+			}}
+			return event
+		})
+		callback(result, err)
+	}
+}
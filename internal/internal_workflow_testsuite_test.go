@@ -28,6 +28,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"testing"
@@ -240,6 +243,115 @@ func (s *WorkflowTestSuiteUnitTest) Test_TimerWorkflow_ClockAutoFastForward() {
 	s.Equal([]string{"t2", "t3", "t1", "t4"}, firedTimerRecord)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_ReceiveWithTimeout() {
+	workflowFn := func(ctx Context) ([]string, error) {
+		var results []string
+		ch := GetSignalChannel(ctx, "test-signal")
+		for i := 0; i < 2; i++ {
+			var value string
+			ok, more := ReceiveWithTimeout(ctx, ch, time.Hour, &value)
+			results = append(results, fmt.Sprintf("ok=%v more=%v value=%v", ok, more, value))
+		}
+		return results, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	var firedTimers, cancelledTimers int
+	env.SetOnTimerFiredListener(func(timerID string) {
+		firedTimers++
+	})
+	env.SetOnTimerCancelledListener(func(timerID string) {
+		cancelledTimers++
+	})
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("test-signal", "signal-data")
+	}, 10*time.Minute)
+	startTime := env.Now()
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var results []string
+	s.NoError(env.GetWorkflowResult(&results))
+	s.Equal([]string{"ok=true more=true value=signal-data", "ok=false more=true value="}, results)
+	s.Equal(1, cancelledTimers)
+	s.Equal(1, firedTimers)
+	s.Equal(70*time.Minute, env.Now().Sub(startTime))
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ReceiveWithTimeout_ClosedChannel() {
+	workflowFn := func(ctx Context) ([]string, error) {
+		var results []string
+		closed := NewChannel(ctx)
+		closed.Close()
+		var value string
+		ok, more := ReceiveWithTimeout(ctx, closed, time.Hour, &value)
+		results = append(results, fmt.Sprintf("ok=%v more=%v", ok, more))
+
+		closedLater := NewChannel(ctx)
+		Go(ctx, func(ctx Context) {
+			_ = Sleep(ctx, time.Minute)
+			closedLater.Close()
+		})
+		ok, more = ReceiveWithTimeout(ctx, closedLater, time.Hour, &value)
+		results = append(results, fmt.Sprintf("ok=%v more=%v", ok, more))
+		return results, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var results []string
+	s.NoError(env.GetWorkflowResult(&results))
+	s.Equal([]string{"ok=false more=false", "ok=false more=false"}, results)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_GetWithTimeout() {
+	workflowFn := func(ctx Context) ([]string, error) {
+		var results []string
+		future, settable := NewFuture(ctx)
+		Go(ctx, func(ctx Context) {
+			_ = Sleep(ctx, 2*time.Hour)
+			settable.SetValue("future-value")
+		})
+
+		var value string
+		ok, err := GetWithTimeout(ctx, future, time.Hour, &value)
+		results = append(results, fmt.Sprintf("ok=%v err=%v value=%v", ok, err, value))
+		ok, err = GetWithTimeout(ctx, future, 2*time.Hour, &value)
+		results = append(results, fmt.Sprintf("ok=%v err=%v value=%v", ok, err, value))
+
+		ctx = WithActivityOptions(ctx, s.activityOptions)
+		ok, err = GetWithTimeout(ctx, ExecuteActivity(ctx, testActivityHello, "timeout"), time.Hour, &value)
+		results = append(results, fmt.Sprintf("ok=%v err=%v value=%v", ok, err, value))
+
+		canceledCtx, cancel := WithCancel(ctx)
+		cancel()
+		pending, _ := NewFuture(ctx)
+		ok, err = GetWithTimeout(canceledCtx, pending, time.Hour, nil)
+		_, isCanceled := err.(*CanceledError)
+		results = append(results, fmt.Sprintf("ok=%v canceled=%v", ok, isCanceled))
+		return results, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterActivity(testActivityHello)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var results []string
+	s.NoError(env.GetWorkflowResult(&results))
+	s.Equal([]string{
+		"ok=false err=<nil> value=",
+		"ok=true err=<nil> value=future-value",
+		"ok=true err=<nil> value=hello_timeout",
+		"ok=false canceled=true",
+	}, results)
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_WorkflowAutoForwardClock() {
 	workflowFn := func(ctx Context) (string, error) {
 		// Schedule a timer with long duration. In this test, we won't actually wait for that long, because the test suite
@@ -1708,6 +1820,214 @@ func (s *WorkflowTestSuiteUnitTest) Test_QueryWorkflow() {
 	verifyStateWithQuery(stateDone)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_QueryTypesQuery() {
+	var handlers []QueryHandlerInfo
+	workflowFn := func(ctx Context) error {
+		err := SetQueryHandler(ctx, "state", func() (string, error) {
+			return "state", nil
+		})
+		if err != nil {
+			return err
+		}
+		err = SetQueryHandler(ctx, "activity-result", func(activityID string, attempt int) (*commonpb.Payloads, error) {
+			return nil, nil
+		})
+		if err != nil {
+			return err
+		}
+		handlers = GetQueryHandlers(ctx)
+		return Sleep(ctx, time.Hour)
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	expected := []QueryHandlerInfo{
+		{QueryType: "activity-result", ArgTypes: []string{"string", "int"}, ResultType: "*common.Payloads"},
+		{QueryType: "state", ArgTypes: []string{}, ResultType: "string"},
+	}
+	env.RegisterDelayedCallback(func() {
+		encodedValue, err := env.QueryWorkflow(QueryTypeQueryTypes)
+		s.NoError(err)
+		var queried []QueryHandlerInfo
+		s.NoError(encodedValue.Get(&queried))
+		s.Equal(expected, queried)
+	}, time.Minute)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal(expected, handlers)
+
+	_, err := env.QueryWorkflow("unknown")
+	s.Error(err)
+	s.Contains(err.Error(), QueryTypeQueryTypes)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_WorkflowUpdate() {
+	workflowFn := func(ctx Context) (int, error) {
+		total := 0
+		err := SetUpdateHandler(ctx, "add", func(ctx Context, n int) (int, error) {
+			if err := Sleep(ctx, time.Minute); err != nil {
+				return 0, err
+			}
+			total += n
+			return total, nil
+		}, func(ctx Context, n int) error {
+			if n <= 0 {
+				return errors.New("n must be positive")
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		err = SetUpdateHandler(ctx, "reset", func(ctx Context) error {
+			if total == 0 {
+				return NewApplicationError("nothing to reset", false, nil)
+			}
+			total = 0
+			return nil
+		}, nil)
+		if err != nil {
+			return 0, err
+		}
+		GetSignalChannel(ctx, "done").Receive(ctx, nil)
+		return total, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	var results []int
+	var errs []error
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow("add", func(result Value, err error) {
+			s.NoError(err)
+			var total int
+			s.NoError(result.Get(&total))
+			results = append(results, total)
+		}, 2)
+		env.UpdateWorkflow("add", func(result Value, err error) {
+			errs = append(errs, err)
+		}, -1)
+		env.UpdateWorkflow("unknown", func(result Value, err error) {
+			errs = append(errs, err)
+		})
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow("add", func(result Value, err error) {
+			s.NoError(err)
+			var total int
+			s.NoError(result.Get(&total))
+			results = append(results, total)
+		}, 3)
+	}, time.Minute*2)
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow("reset", func(result Value, err error) {
+			s.NoError(err)
+			s.False(result.HasValue())
+		})
+	}, time.Minute*10)
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow("reset", func(result Value, err error) {
+			errs = append(errs, err)
+		})
+		env.SignalWorkflow("done", nil)
+	}, time.Minute*20)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]int{2, 5}, results)
+	s.Len(errs, 3)
+	s.EqualError(errs[0], "n must be positive")
+	s.Contains(errs[1].Error(), "unknown update unknown. KnownUpdates=[add reset]")
+	var applicationErr *ApplicationError
+	s.True(errors.As(errs[2], &applicationErr))
+	s.Equal("nothing to reset", applicationErr.Error())
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_WorkflowUpdate_NoHandlers() {
+	workflowFn := func(ctx Context) error {
+		GetSignalChannel(ctx, "done").Receive(ctx, nil)
+		return nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	var updateErr error
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow("add", func(result Value, err error) {
+			updateErr = err
+		}, 1)
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("done", nil)
+	}, time.Minute*2)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Error(updateErr)
+	s.Contains(updateErr.Error(), "workflow completed before update is handled")
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_WorkflowUpdate_HandlerSetAfterUpdate() {
+	workflowFn := func(ctx Context) (int, error) {
+		GetSignalChannel(ctx, "ready").Receive(ctx, nil)
+		total := 0
+		err := SetUpdateHandler(ctx, "add", func(ctx Context, n int) (int, error) {
+			total += n
+			return total, nil
+		}, nil)
+		if err != nil {
+			return 0, err
+		}
+		GetSignalChannel(ctx, "done").Receive(ctx, nil)
+		return total, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	var updateResult int
+	var updateErr error
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow("add", func(result Value, err error) {
+			updateErr = err
+			if err == nil {
+				updateErr = result.Get(&updateResult)
+			}
+		}, 2)
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("ready", nil)
+	}, time.Minute*2)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("done", nil)
+	}, time.Minute*3)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.NoError(updateErr)
+	s.Equal(2, updateResult)
+	var total int
+	s.NoError(env.GetWorkflowResult(&total))
+	s.Equal(2, total)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_WorkflowUpdate_InvalidHandler() {
+	workflowFn := func(ctx Context) error {
+		s.Error(SetUpdateHandler(ctx, "__update", func(ctx Context) error { return nil }, nil))
+		s.Error(SetUpdateHandler(ctx, "no-context", func(n int) error { return nil }, nil))
+		s.Error(SetUpdateHandler(ctx, "bad-validator", func(ctx Context, n int) error { return nil },
+			func(ctx Context, n string) error { return nil }))
+		s.Error(SetUpdateHandler(ctx, "bad-validator-result", func(ctx Context, n int) error { return nil },
+			func(ctx Context, n int) (int, error) { return 0, nil }))
+		return nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_WorkflowWithLocalActivity() {
 	localActivityFn := func(ctx context.Context, name string) (string, error) {
 		return "hello " + name, nil
@@ -1732,6 +2052,233 @@ func (s *WorkflowTestSuiteUnitTest) Test_WorkflowWithLocalActivity() {
 	s.Equal("hello local_activity", result)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_GetWorkflowHistory() {
+	localActivityFn := func(ctx context.Context, name string) (string, error) {
+		return "hello " + name, nil
+	}
+
+	workflowFn := func(ctx Context) (string, error) {
+		ctx = WithActivityOptions(ctx, s.activityOptions)
+		ctx = WithLocalActivityOptions(ctx, s.localActivityOptions)
+		ctx = WithChildWorkflowOptions(ctx, ChildWorkflowOptions{WorkflowRunTimeout: time.Minute})
+
+		var activityResult string
+		if err := ExecuteActivity(ctx, testActivityHello, "activity").Get(ctx, &activityResult); err != nil {
+			return "", err
+		}
+		var sideEffectResult int
+		if err := SideEffect(ctx, func(ctx Context) interface{} { return 1 }).Get(&sideEffectResult); err != nil {
+			return "", err
+		}
+		if v := GetVersion(ctx, "test_change_id", DefaultVersion, 1); v != 1 {
+			return "", errors.New("unexpected version")
+		}
+		if err := NewTimer(ctx, time.Hour).Get(ctx, nil); err != nil {
+			return "", err
+		}
+		var signal string
+		GetSignalChannel(ctx, "test-signal").Receive(ctx, &signal)
+		var localActivityResult string
+		if err := ExecuteLocalActivity(ctx, localActivityFn, "local_activity").Get(ctx, &localActivityResult); err != nil {
+			return "", err
+		}
+		var childResult string
+		if err := ExecuteChildWorkflow(ctx, testWorkflowHello).Get(ctx, &childResult); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v %v %v %v %v", activityResult, sideEffectResult, signal, localActivityResult, childResult), nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterWorkflow(testWorkflowHello)
+	env.RegisterActivity(testActivityHello)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("test-signal", "signal")
+	}, 2*time.Hour)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result string
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal("hello_activity 1 signal hello local_activity hello_world", result)
+
+	history := env.GetWorkflowHistory()
+	var eventTypes []enumspb.EventType
+	for i, event := range history.Events {
+		s.Equal(int64(i+1), event.GetEventId())
+		eventTypes = append(eventTypes, event.GetEventType())
+	}
+	s.Equal([]enumspb.EventType{
+		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED,
+		enumspb.EVENT_TYPE_DECISION_TASK_SCHEDULED,
+		enumspb.EVENT_TYPE_DECISION_TASK_STARTED,
+		enumspb.EVENT_TYPE_DECISION_TASK_COMPLETED,
+		enumspb.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED,
+		enumspb.EVENT_TYPE_ACTIVITY_TASK_STARTED,
+		enumspb.EVENT_TYPE_ACTIVITY_TASK_COMPLETED,
+		enumspb.EVENT_TYPE_DECISION_TASK_SCHEDULED,
+		enumspb.EVENT_TYPE_DECISION_TASK_STARTED,
+		enumspb.EVENT_TYPE_DECISION_TASK_COMPLETED,
+		enumspb.EVENT_TYPE_MARKER_RECORDED,
+		enumspb.EVENT_TYPE_MARKER_RECORDED,
+		enumspb.EVENT_TYPE_UPSERT_WORKFLOW_SEARCH_ATTRIBUTES,
+		enumspb.EVENT_TYPE_TIMER_STARTED,
+		enumspb.EVENT_TYPE_TIMER_FIRED,
+		enumspb.EVENT_TYPE_DECISION_TASK_SCHEDULED,
+		enumspb.EVENT_TYPE_DECISION_TASK_STARTED,
+		enumspb.EVENT_TYPE_DECISION_TASK_COMPLETED,
+		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_SIGNALED,
+		enumspb.EVENT_TYPE_DECISION_TASK_SCHEDULED,
+		enumspb.EVENT_TYPE_DECISION_TASK_STARTED,
+		enumspb.EVENT_TYPE_DECISION_TASK_COMPLETED,
+		enumspb.EVENT_TYPE_MARKER_RECORDED,
+		enumspb.EVENT_TYPE_START_CHILD_WORKFLOW_EXECUTION_INITIATED,
+		enumspb.EVENT_TYPE_CHILD_WORKFLOW_EXECUTION_STARTED,
+		enumspb.EVENT_TYPE_DECISION_TASK_SCHEDULED,
+		enumspb.EVENT_TYPE_DECISION_TASK_STARTED,
+		enumspb.EVENT_TYPE_DECISION_TASK_COMPLETED,
+		enumspb.EVENT_TYPE_CHILD_WORKFLOW_EXECUTION_COMPLETED,
+		enumspb.EVENT_TYPE_DECISION_TASK_SCHEDULED,
+		enumspb.EVENT_TYPE_DECISION_TASK_STARTED,
+		enumspb.EVENT_TYPE_DECISION_TASK_COMPLETED,
+		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED,
+	}, eventTypes)
+
+	replayer := NewWorkflowReplayerWithOptions(WorkflowReplayerOptions{EnableStrictReplay: true})
+	replayer.RegisterWorkflow(workflowFn)
+	s.NoError(replayer.ReplayWorkflowHistory(nil, history))
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_GetWorkflowHistory_Cancellation() {
+	workflowFn := func(ctx Context) error {
+		ctx = WithActivityOptions(ctx, s.activityOptions)
+
+		timerCtx, cancelTimer := WithCancel(ctx)
+		timer := NewTimer(timerCtx, time.Hour)
+		activityCtx, cancelActivity := WithCancel(ctx)
+		activity := ExecuteActivity(activityCtx, testActivityHeartbeat, "canceled", time.Hour)
+		if err := ExecuteActivity(ctx, testActivityHello, "fast").Get(ctx, nil); err != nil {
+			return err
+		}
+		cancelTimer()
+		cancelActivity()
+		var canceledErr *CanceledError
+		if err := timer.Get(ctx, nil); !errors.As(err, &canceledErr) {
+			return fmt.Errorf("unexpected timer error: %v", err)
+		}
+		if err := activity.Get(ctx, nil); !errors.As(err, &canceledErr) {
+			return fmt.Errorf("unexpected activity error: %v", err)
+		}
+		return nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(testActivityHeartbeat)
+	env.RegisterActivity(testActivityHello)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+
+	history := env.GetWorkflowHistory()
+	var eventTypes []enumspb.EventType
+	for _, event := range history.Events {
+		eventTypes = append(eventTypes, event.GetEventType())
+	}
+	s.Equal([]enumspb.EventType{
+		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED,
+		enumspb.EVENT_TYPE_DECISION_TASK_SCHEDULED,
+		enumspb.EVENT_TYPE_DECISION_TASK_STARTED,
+		enumspb.EVENT_TYPE_DECISION_TASK_COMPLETED,
+		enumspb.EVENT_TYPE_TIMER_STARTED,
+		enumspb.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED,
+		enumspb.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED,
+		enumspb.EVENT_TYPE_ACTIVITY_TASK_STARTED,
+		enumspb.EVENT_TYPE_ACTIVITY_TASK_COMPLETED,
+		enumspb.EVENT_TYPE_DECISION_TASK_SCHEDULED,
+		enumspb.EVENT_TYPE_DECISION_TASK_STARTED,
+		enumspb.EVENT_TYPE_DECISION_TASK_COMPLETED,
+		enumspb.EVENT_TYPE_TIMER_CANCELED,
+		enumspb.EVENT_TYPE_ACTIVITY_TASK_CANCEL_REQUESTED,
+		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED,
+	}, eventTypes)
+
+	replayer := NewWorkflowReplayerWithOptions(WorkflowReplayerOptions{EnableStrictReplay: true})
+	replayer.RegisterWorkflow(workflowFn)
+	s.NoError(replayer.ReplayWorkflowHistory(nil, history))
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_WriteHistoryToJSONFile() {
+	dir, err := ioutil.TempDir("", "golden")
+	s.NoError(err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(testWorkflowHello)
+	env.RegisterActivity(testActivityHello)
+	env.ExecuteWorkflow(testWorkflowHello)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+
+	s.NoError(WriteHistoryToJSONFile(env.GetWorkflowHistory(), filepath.Join(dir, "hello.json")))
+	history, err := extractHistoryFromFile(filepath.Join(dir, "hello.json"), 0)
+	s.NoError(err)
+	s.Equal(env.GetWorkflowHistory(), history)
+
+	replayer := NewWorkflowReplayerWithOptions(WorkflowReplayerOptions{EnableStrictReplay: true})
+	replayer.RegisterWorkflow(testWorkflowHello)
+	s.NoError(replayer.ReplayWorkflowHistoryFromJSONFile(nil, filepath.Join(dir, "hello.json")))
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ActivityInterceptor() {
+	localActivityFn := func(ctx context.Context, name string) (string, error) {
+		return GetActivityInfo(ctx).ActivityType.Name + " " + name, nil
+	}
+
+	workflowFn := func(ctx Context) (string, error) {
+		ctx = WithActivityOptions(ctx, s.activityOptions)
+		var result string
+		if err := ExecuteActivity(ctx, testActivityHello, "world").Get(ctx, &result); err != nil {
+			return "", err
+		}
+		ctx = WithLocalActivityOptions(ctx, s.localActivityOptions)
+		var laResult string
+		err := ExecuteLocalActivity(ctx, localActivityFn, "local_activity").Get(ctx, &laResult)
+		return result + ", " + laResult, err
+	}
+
+	interceptorFactory := &activityTracingInterceptorFactory{}
+	env := s.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(WorkerOptions{ActivityInterceptorChainFactories: []ActivityInterceptorFactory{interceptorFactory}})
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(testActivityHello)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result string
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal("hello_world, intercepted "+getFunctionName(localActivityFn)+" local_activity", result)
+	s.Equal([]string{
+		"ExecuteActivity testActivityHello",
+		"ExecuteActivity " + getFunctionName(localActivityFn),
+		"GetActivityInfo",
+	}, interceptorFactory.trace)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ActivityInterceptorTranslatesPanic() {
+	panicActivityFn := func(ctx context.Context) (string, error) {
+		panic("activity panic")
+	}
+
+	env := s.NewTestActivityEnvironment()
+	env.SetWorkerOptions(WorkerOptions{ActivityInterceptorChainFactories: []ActivityInterceptorFactory{&activityTracingInterceptorFactory{}}})
+	env.RegisterActivity(panicActivityFn)
+	_, err := env.ExecuteActivity(panicActivityFn)
+	s.Error(err)
+	s.Contains(err.Error(), "recovered: activity panic")
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_LocalActivity() {
 	localActivityFn := func(ctx context.Context, name string) (string, error) {
 		return "hello " + name, nil
@@ -1874,6 +2421,53 @@ func (s *WorkflowTestSuiteUnitTest) Test_SignalChildWorkflow() {
 	s.NoError(env.GetWorkflowError())
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_SignalChildWorkflow_OutcomeOnNextDecisionTask() {
+	// The outcome of a signal sent to a child is reported with the next decision task of the parent, same as the
+	// server does, not synchronously with the SignalChildWorkflow call.
+	signalName := "test-signal-name"
+	childWorkflowFn := func(ctx Context) (string, error) {
+		var data string
+		GetSignalChannel(ctx, signalName).Receive(ctx, &data)
+		return data + "-processed", nil
+	}
+
+	workflowFn := func(ctx Context) ([]string, error) {
+		ctx = WithChildWorkflowOptions(ctx, ChildWorkflowOptions{WorkflowRunTimeout: time.Minute})
+		childFuture := ExecuteChildWorkflow(ctx, childWorkflowFn)
+		if err := childFuture.GetChildWorkflowExecution().Get(ctx, nil); err != nil {
+			return nil, err
+		}
+
+		var events []string
+		signalFuture := childFuture.SignalChildWorkflow(ctx, signalName, "test-signal-data")
+		events = append(events, fmt.Sprintf("signal-ready-after-call=%v", signalFuture.IsReady()))
+		NewSelector(ctx).
+			AddFuture(signalFuture, func(f Future) {
+				events = append(events, fmt.Sprintf("signal-outcome=%v", f.Get(ctx, nil)))
+			}).
+			AddFuture(childFuture, func(f Future) {
+				events = append(events, "child-completed")
+			}).
+			Select(ctx)
+
+		var childResult string
+		if err := childFuture.Get(ctx, &childResult); err != nil {
+			return nil, err
+		}
+		return append(events, childResult), nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(childWorkflowFn)
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var events []string
+	s.NoError(env.GetWorkflowResult(&events))
+	s.Equal([]string{"signal-ready-after-call=false", "signal-outcome=<nil>", "test-signal-data-processed"}, events)
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_SignalExternalWorkflow() {
 	signalName := "test-signal-name"
 	signalData := "test-signal-data"
@@ -2814,6 +3408,137 @@ func (s *WorkflowTestSuiteUnitTest) Test_CronHasLastResult() {
 	s.Equal(lastResult+1, result)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_CronWorkflowRuns() {
+	runs := 0
+	cronWorkflow := func(ctx Context) (int, error) {
+		runs++
+		var result int
+		if HasLastCompletionResult(ctx) {
+			_ = GetLastCompletionResult(ctx, &result)
+		} else if runs > 1 {
+			return 0, errors.New("last completion result is not passed to the next run")
+		}
+		if runs == 3 {
+			// the next run gets the result of the last successful run
+			return 0, errors.New("cron run failed")
+		}
+		return result + 1, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(cronWorkflow)
+	startTime, _ := time.Parse(time.RFC3339, "2018-12-20T16:30:00+08:00")
+	env.SetStartTime(startTime)
+	env.SetStartWorkflowOptions(StartWorkflowOptions{CronSchedule: "0 * * * *"}) // hourly
+	env.SetCronMaxRuns(4)
+	var runIDs []string
+	var runTimes []time.Time
+	var runErrors []error
+	env.SetOnCronRunCompletedListener(func(workflowInfo *WorkflowInfo, result Value, err error) {
+		runIDs = append(runIDs, workflowInfo.WorkflowExecution.RunID)
+		runTimes = append(runTimes, env.Now())
+		runErrors = append(runErrors, err)
+	})
+	env.ExecuteWorkflow(cronWorkflow)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result int
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal(3, result)
+
+	s.Len(runIDs, 4)
+	s.Len(runTimes, 4)
+	for i, runTime := range runTimes {
+		s.True(startTime.Add(time.Duration(i)*time.Hour+30*time.Minute).Equal(runTime), runTime)
+		if i > 0 {
+			s.NotEqual(runIDs[i-1], runIDs[i])
+		}
+	}
+	s.NoError(runErrors[0])
+	s.NoError(runErrors[1])
+	s.Error(runErrors[2])
+	s.NoError(runErrors[3])
+
+	history := env.GetWorkflowHistory()
+	started := history.Events[0].GetWorkflowExecutionStartedEventAttributes()
+	s.Equal(runIDs[2], started.GetContinuedExecutionRunId())
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_CronWorkflowStops() {
+	cronWorkflow := func(ctx Context) error {
+		return NewTimer(ctx, time.Minute).Get(ctx, nil)
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(cronWorkflow)
+	runs := 0
+	env.SetOnCronRunCompletedListener(func(workflowInfo *WorkflowInfo, result Value, err error) {
+		runs++
+	})
+	env.SetStartTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	env.SetStartWorkflowOptions(StartWorkflowOptions{
+		CronSchedule:             "0 * * * *", // hourly
+		WorkflowExecutionTimeout: 5 * time.Hour,
+	})
+	env.SetCronMaxRuns(0)
+	env.ExecuteWorkflow(cronWorkflow)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal(5, runs)
+
+	env = s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(cronWorkflow)
+	runs = 0
+	env.SetOnCronRunCompletedListener(func(workflowInfo *WorkflowInfo, result Value, err error) {
+		runs++
+	})
+	env.SetStartTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	env.SetStartWorkflowOptions(StartWorkflowOptions{CronSchedule: "0 * * * *"})
+	env.RegisterDelayedCallback(func() {
+		env.CancelWorkflow()
+	}, 150*time.Minute)
+	env.ExecuteWorkflow(cronWorkflow)
+	s.True(env.IsWorkflowCompleted())
+	s.Error(env.GetWorkflowError())
+	var canceledErr *CanceledError
+	s.True(errors.As(env.GetWorkflowError(), &canceledErr))
+	s.Equal(3, runs)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_CronWorkflowRunCancelsPendingWork() {
+	activityFn := func(ctx context.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+	cronWorkflow := func(ctx Context) error {
+		// the run completes without waiting for its timer and activity
+		NewTimer(ctx, 10*time.Minute)
+		ExecuteActivity(WithActivityOptions(ctx, s.activityOptions), activityFn)
+		return nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(cronWorkflow)
+	env.RegisterActivity(activityFn)
+	env.SetStartTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	env.SetStartWorkflowOptions(StartWorkflowOptions{CronSchedule: "0 * * * *"})
+	env.SetCronMaxRuns(3)
+	var firedTimers, completedActivities int
+	env.SetOnTimerFiredListener(func(timerID string) {
+		firedTimers++
+	})
+	env.SetOnActivityCompletedListener(func(activityInfo *ActivityInfo, result Value, err error) {
+		completedActivities++
+	})
+	env.ExecuteWorkflow(cronWorkflow)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal(0, firedTimers)
+	s.Equal(0, completedActivities)
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_ActivityWithProgress() {
 	activityFn := func(ctx context.Context) (int, error) {
 		var progress int
@@ -2964,3 +3689,72 @@ func (s *WorkflowTestSuiteUnitTest) Test_AwaitWithTimeoutTimeout() {
 	_ = env.GetWorkflowResult(&result)
 	s.False(result)
 }
+
+func (s *WorkflowTestSuiteUnitTest) Test_DeadlockDetection() {
+	blocked := make(chan struct{})
+	defer close(blocked)
+	workflowFn := func(ctx Context) error {
+		<-blocked // native channel doesn't yield
+		return nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	s.Equal(defaultTestDeadlockDetectionTimeout, env.impl.GetDeadlockDetectionTimeout())
+	env.SetWorkerOptions(WorkerOptions{DeadlockDetectionTimeout: 10 * time.Millisecond})
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	err := env.GetWorkflowError()
+	s.Error(err)
+	var panicErr *PanicError
+	s.True(errors.As(err, &panicErr))
+	s.Contains(panicErr.Error(), "Potential deadlock detected")
+	s.Contains(panicErr.StackTrace(), "coroutine 1 [running]:")
+	s.Contains(panicErr.StackTrace(), "temporal/internal.(*WorkflowTestSuiteUnitTest).Test_DeadlockDetection")
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_DeadlockDetection_DebugMode() {
+	s.NoError(os.Setenv(debugModeEnv, "true"))
+	defer func() { s.NoError(os.Unsetenv(debugModeEnv)) }()
+	workflowFn := func(ctx Context) error {
+		time.Sleep(50 * time.Millisecond) // stopped at a breakpoint
+		return nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(WorkerOptions{DeadlockDetectionTimeout: 10 * time.Millisecond})
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+}
+
+type activityTracingInterceptorFactory struct {
+	trace []string
+}
+
+func (f *activityTracingInterceptorFactory) NewInterceptor(_ *ActivityInfo, next ActivityInterceptor) ActivityInterceptor {
+	return &activityTracingInterceptor{ActivityInterceptorBase: ActivityInterceptorBase{Next: next}, factory: f}
+}
+
+type activityTracingInterceptor struct {
+	ActivityInterceptorBase
+	factory *activityTracingInterceptorFactory
+}
+
+func (t *activityTracingInterceptor) ExecuteActivity(ctx context.Context, activityType string, args ...interface{}) (results []interface{}) {
+	t.factory.trace = append(t.factory.trace, "ExecuteActivity "+activityType)
+	defer func() {
+		if p := recover(); p != nil {
+			results = []interface{}{"", fmt.Errorf("recovered: %v", p)}
+		}
+	}()
+	return t.Next.ExecuteActivity(ctx, activityType, args...)
+}
+
+func (t *activityTracingInterceptor) GetActivityInfo(ctx context.Context) ActivityInfo {
+	t.factory.trace = append(t.factory.trace, "GetActivityInfo")
+	info := t.Next.GetActivityInfo(ctx)
+	info.ActivityType.Name = "intercepted " + info.ActivityType.Name
+	return info
+}
@@ -0,0 +1,160 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"time"
+
+	"github.com/uber-go/tally"
+
+	"go.temporal.io/temporal/internal/common/metrics"
+)
+
+type (
+	// MetricsHandler is an abstraction of a metrics backend used by the SDK to emit metrics.
+	// Use NewTallyMetricsHandler or NewPrometheusMetricsHandler to create one for a
+	// supported backend, or implement it to report metrics to any other system.
+	// Implementations must be safe for concurrent use.
+	MetricsHandler = metrics.Handler
+
+	// MetricsCounter is a monotonically increasing metric.
+	MetricsCounter = metrics.Counter
+
+	// MetricsGauge is a metric that reports the last set value.
+	MetricsGauge = metrics.Gauge
+
+	// MetricsTimer is a metric that records latencies.
+	MetricsTimer = metrics.Timer
+
+	// MetricsHistogram is a metric that counts observed values into buckets.
+	MetricsHistogram = metrics.Histogram
+
+	// metricsHandlerScope implements tally.Scope on top of a MetricsHandler for the APIs that expose
+	// tally.Scope to user code, such as workflow.GetMetricsScope.
+	metricsHandlerScope struct {
+		handler MetricsHandler
+	}
+
+	metricsHandlerTimer struct {
+		timer MetricsTimer
+	}
+
+	metricsHandlerHistogram struct {
+		histogram MetricsHistogram
+	}
+
+	metricsHandlerCapabilities struct{}
+)
+
+var _ tally.Scope = (*metricsHandlerScope)(nil)
+
+// NewTallyMetricsHandler creates a MetricsHandler that reports metrics to the tally scope.
+func NewTallyMetricsHandler(scope tally.Scope) MetricsHandler {
+	return metrics.NewTallyHandler(scope)
+}
+
+// metricsScopeFromHandler returns a tally.Scope that reports metrics to the handler.
+// The scope of a handler created by NewTallyMetricsHandler is returned as is.
+func metricsScopeFromHandler(handler MetricsHandler) tally.Scope {
+	if scope, ok := metrics.TallyScope(handler); ok {
+		return scope
+	}
+	return &metricsHandlerScope{handler: handler}
+}
+
+// Counter returns the Counter object corresponding to the name.
+func (s *metricsHandlerScope) Counter(name string) tally.Counter {
+	return s.handler.Counter(name)
+}
+
+// Gauge returns the Gauge object corresponding to the name.
+func (s *metricsHandlerScope) Gauge(name string) tally.Gauge {
+	return s.handler.Gauge(name)
+}
+
+// Timer returns the Timer object corresponding to the name.
+func (s *metricsHandlerScope) Timer(name string) tally.Timer {
+	return &metricsHandlerTimer{timer: s.handler.Timer(name)}
+}
+
+// Histogram returns the Histogram object corresponding to the name.
+func (s *metricsHandlerScope) Histogram(name string, buckets tally.Buckets) tally.Histogram {
+	var values []float64
+	if buckets != nil {
+		values = buckets.AsValues()
+	}
+	return &metricsHandlerHistogram{histogram: s.handler.Histogram(name, values)}
+}
+
+// Tagged returns a new child scope with the given tags and current tags.
+func (s *metricsHandlerScope) Tagged(tags map[string]string) tally.Scope {
+	return &metricsHandlerScope{handler: s.handler.WithTags(tags)}
+}
+
+// SubScope returns a new child scope appending a further name prefix.
+func (s *metricsHandlerScope) SubScope(name string) tally.Scope {
+	return &metricsHandlerScope{handler: metrics.WithPrefix(s.handler, name)}
+}
+
+// Capabilities returns a description of metrics reporting capabilities.
+func (s *metricsHandlerScope) Capabilities() tally.Capabilities {
+	return metricsHandlerCapabilities{}
+}
+
+func (t *metricsHandlerTimer) Record(d time.Duration) {
+	t.timer.Record(d)
+}
+
+func (t *metricsHandlerTimer) Start() tally.Stopwatch {
+	return tally.NewStopwatch(time.Now(), t)
+}
+
+func (t *metricsHandlerTimer) RecordStopwatch(stopwatchStart time.Time) {
+	t.timer.Record(time.Since(stopwatchStart))
+}
+
+func (h *metricsHandlerHistogram) RecordValue(value float64) {
+	h.histogram.RecordValue(value)
+}
+
+func (h *metricsHandlerHistogram) RecordDuration(d time.Duration) {
+	h.histogram.RecordDuration(d)
+}
+
+func (h *metricsHandlerHistogram) Start() tally.Stopwatch {
+	return tally.NewStopwatch(time.Now(), h)
+}
+
+func (h *metricsHandlerHistogram) RecordStopwatch(stopwatchStart time.Time) {
+	h.histogram.RecordDuration(time.Since(stopwatchStart))
+}
+
+func (metricsHandlerCapabilities) Reporting() bool {
+	return true
+}
+
+func (metricsHandlerCapabilities) Tagging() bool {
+	return true
+}
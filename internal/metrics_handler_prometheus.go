@@ -0,0 +1,351 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"go.temporal.io/temporal/internal/common/metrics"
+)
+
+const (
+	prometheusCounter prometheusMetricType = iota
+	prometheusGauge
+	prometheusHistogram
+)
+
+type (
+	// PrometheusMetricsHandlerOptions are optional parameters for NewPrometheusMetricsHandler.
+	PrometheusMetricsHandlerOptions struct {
+		// Optional: Bucket upper bounds in seconds of the histograms that timers are reported as.
+		// default: buckets from 1ms to 1h suitable for task and activity latencies.
+		TimerBuckets []float64
+
+		// Optional: Overrides of TimerBuckets for individual timers keyed by metric name,
+		// for example metrics.ActivityExecutionLatency.
+		// default: no overrides.
+		TimerBucketsByName map[string][]float64
+
+		// Optional: Names of the tags reported as labels in addition to the tags the SDK attaches to its metrics,
+		// such as the tags set with workflow.GetMetricsScope(ctx).Tagged.
+		// default: no additional tags.
+		AdditionalTagKeys []string
+
+		// Optional: Logger to report metrics that cannot be registered and tags that are dropped.
+		// default: zap production logger.
+		Logger *zap.Logger
+	}
+
+	prometheusMetricType int
+
+	prometheusMetricsHandler struct {
+		registry *prometheusRegistry
+		tags     map[string]string
+	}
+
+	// prometheusRegistry holds the collectors shared by all tagged handlers derived from the same root.
+	prometheusRegistry struct {
+		registerer prometheus.Registerer
+		options    PrometheusMetricsHandlerOptions
+		logger     *zap.Logger
+		// labels are the label names of every metric, sorted.
+		labels   []string
+		labelSet map[string]bool
+
+		lock       sync.Mutex
+		collectors map[string]*prometheusCollector
+		// reported holds the problems that are already logged, so each is logged once.
+		reported map[string]bool
+	}
+
+	prometheusCollector struct {
+		metricType prometheusMetricType
+		counter    *prometheus.CounterVec
+		gauge      *prometheus.GaugeVec
+		histogram  *prometheus.HistogramVec
+	}
+
+	prometheusCounterMetric struct {
+		counter prometheus.Counter
+	}
+
+	prometheusGaugeMetric struct {
+		gauge prometheus.Gauge
+	}
+
+	prometheusHistogramMetric struct {
+		histogram prometheus.Observer
+	}
+
+	// noopPrometheusObserver drops observations of histograms that cannot be reported.
+	noopPrometheusObserver struct{}
+)
+
+var defaultPrometheusTimerBuckets = []float64{
+	0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 600, 1800, 3600,
+}
+
+// prometheusTagKeys are the tags the SDK attaches to its metrics.
+var prometheusTagKeys = []string{
+	tagNamespace,
+	tagTaskList,
+	tagWorkflowType,
+	tagActivityType,
+	tagLocalActivityType,
+	tagWorkerType,
+	clientImplHeaderName,
+}
+
+var _ MetricsHandler = (*prometheusMetricsHandler)(nil)
+
+// NewPrometheusMetricsHandler creates a MetricsHandler that reports metrics as Prometheus collectors
+// registered with the registerer, prometheus.DefaultRegisterer if nil. Timers are reported as histograms
+// of seconds, so latency metrics can be aggregated across workers and used to compute quantiles.
+//
+// Prometheus requires every series of a metric to have the same label names, so every metric is labeled
+// with the tags the SDK attaches to its metrics and PrometheusMetricsHandlerOptions.AdditionalTagKeys.
+// Tags that are not set are reported as empty, other tags are dropped. A metric that cannot be registered,
+// for example because its name is already registered with a different type, is logged and not reported.
+func NewPrometheusMetricsHandler(registerer prometheus.Registerer, options PrometheusMetricsHandlerOptions) MetricsHandler {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	if len(options.TimerBuckets) == 0 {
+		options.TimerBuckets = defaultPrometheusTimerBuckets
+	}
+	logger := options.Logger
+	if logger == nil {
+		logger, _ = zap.NewProduction()
+	}
+	labelSet := make(map[string]bool)
+	for _, key := range append(prometheusTagKeys, options.AdditionalTagKeys...) {
+		labelSet[sanitizePrometheusName(key)] = true
+	}
+	labels := make([]string, 0, len(labelSet))
+	for label := range labelSet {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return &prometheusMetricsHandler{
+		registry: &prometheusRegistry{
+			registerer: registerer,
+			options:    options,
+			logger:     logger,
+			labels:     labels,
+			labelSet:   labelSet,
+			collectors: make(map[string]*prometheusCollector),
+			reported:   make(map[string]bool),
+		},
+	}
+}
+
+func (h *prometheusMetricsHandler) WithTags(tags map[string]string) MetricsHandler {
+	merged := make(map[string]string, len(h.tags)+len(tags))
+	for k, v := range h.tags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		label := sanitizePrometheusName(k)
+		if !h.registry.labelSet[label] {
+			h.registry.reportOnce("tag:"+label, "Prometheus metrics handler drops tag that is not declared in AdditionalTagKeys",
+				zap.String("Tag", k))
+			continue
+		}
+		merged[label] = v
+	}
+	return &prometheusMetricsHandler{registry: h.registry, tags: merged}
+}
+
+func (h *prometheusMetricsHandler) Counter(name string) MetricsCounter {
+	c := h.registry.collector(name, prometheusCounter, func(name string, labels []string) prometheus.Collector {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: name}, labels)
+	})
+	if c == nil {
+		return metrics.NoopHandler.Counter(name)
+	}
+	counter, err := c.counter.GetMetricWith(h.registry.labelValues(h.tags))
+	if err != nil {
+		h.registry.reportError(name, err)
+		return metrics.NoopHandler.Counter(name)
+	}
+	return &prometheusCounterMetric{counter: counter}
+}
+
+func (h *prometheusMetricsHandler) Gauge(name string) MetricsGauge {
+	c := h.registry.collector(name, prometheusGauge, func(name string, labels []string) prometheus.Collector {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: name}, labels)
+	})
+	if c == nil {
+		return metrics.NoopHandler.Gauge(name)
+	}
+	gauge, err := c.gauge.GetMetricWith(h.registry.labelValues(h.tags))
+	if err != nil {
+		h.registry.reportError(name, err)
+		return metrics.NoopHandler.Gauge(name)
+	}
+	return &prometheusGaugeMetric{gauge: gauge}
+}
+
+func (h *prometheusMetricsHandler) Timer(name string) MetricsTimer {
+	buckets, ok := h.registry.options.TimerBucketsByName[name]
+	if !ok {
+		buckets = h.registry.options.TimerBuckets
+	}
+	return h.histogram(name, buckets)
+}
+
+func (h *prometheusMetricsHandler) Histogram(name string, buckets []float64) MetricsHistogram {
+	return h.histogram(name, buckets)
+}
+
+func (h *prometheusMetricsHandler) histogram(name string, buckets []float64) *prometheusHistogramMetric {
+	c := h.registry.collector(name, prometheusHistogram, func(name string, labels []string) prometheus.Collector {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: name, Buckets: buckets}, labels)
+	})
+	if c == nil {
+		return &prometheusHistogramMetric{histogram: noopPrometheusObserver{}}
+	}
+	histogram, err := c.histogram.GetMetricWith(h.registry.labelValues(h.tags))
+	if err != nil {
+		h.registry.reportError(name, err)
+		return &prometheusHistogramMetric{histogram: noopPrometheusObserver{}}
+	}
+	return &prometheusHistogramMetric{histogram: histogram}
+}
+
+// collector returns the collector registered for the name, creating and registering it if needed.
+// Nil is returned if the collector cannot be registered or the name is registered with a different type.
+func (r *prometheusRegistry) collector(
+	name string,
+	metricType prometheusMetricType,
+	create func(name string, labels []string) prometheus.Collector,
+) *prometheusCollector {
+	name = sanitizePrometheusName(name)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if c, ok := r.collectors[name]; ok {
+		if c.metricType != metricType {
+			r.reportLocked("type:"+name, "Prometheus metric is already registered with a different type", zap.String("Name", name))
+			return nil
+		}
+		return c
+	}
+
+	collector := create(name, r.labels)
+	if err := r.registerer.Register(collector); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if !errors.As(err, &alreadyRegistered) {
+			r.reportLocked("register:"+name, "Unable to register Prometheus metric", zap.String("Name", name), zap.Error(err))
+			return nil
+		}
+		// The collector was registered outside of this handler, for example by another handler sharing
+		// the registerer. Reuse it so both report the same series.
+		collector = alreadyRegistered.ExistingCollector
+	}
+	c := &prometheusCollector{metricType: metricType}
+	var ok bool
+	switch metricType {
+	case prometheusCounter:
+		c.counter, ok = collector.(*prometheus.CounterVec)
+	case prometheusGauge:
+		c.gauge, ok = collector.(*prometheus.GaugeVec)
+	case prometheusHistogram:
+		c.histogram, ok = collector.(*prometheus.HistogramVec)
+	}
+	if !ok {
+		r.reportLocked("type:"+name, "Prometheus metric is already registered with a different type", zap.String("Name", name))
+		return nil
+	}
+	r.collectors[name] = c
+	return c
+}
+
+func (r *prometheusRegistry) labelValues(tags map[string]string) prometheus.Labels {
+	values := make(prometheus.Labels, len(r.labels))
+	for _, label := range r.labels {
+		values[label] = tags[label]
+	}
+	return values
+}
+
+func (r *prometheusRegistry) reportError(name string, err error) {
+	r.reportOnce("labels:"+sanitizePrometheusName(name), "Unable to create Prometheus metric", zap.String("Name", name), zap.Error(err))
+}
+
+func (r *prometheusRegistry) reportOnce(key string, msg string, fields ...zap.Field) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.reportLocked(key, msg, fields...)
+}
+
+func (r *prometheusRegistry) reportLocked(key string, msg string, fields ...zap.Field) {
+	if r.reported[key] {
+		return
+	}
+	r.reported[key] = true
+	r.logger.Error(msg, fields...)
+}
+
+func (c *prometheusCounterMetric) Inc(delta int64) {
+	// Prometheus counters cannot decrease.
+	if delta > 0 {
+		c.counter.Add(float64(delta))
+	}
+}
+
+func (g *prometheusGaugeMetric) Update(value float64) {
+	g.gauge.Set(value)
+}
+
+func (h *prometheusHistogramMetric) Record(d time.Duration) {
+	h.histogram.Observe(d.Seconds())
+}
+
+func (h *prometheusHistogramMetric) RecordValue(value float64) {
+	h.histogram.Observe(value)
+}
+
+func (h *prometheusHistogramMetric) RecordDuration(d time.Duration) {
+	h.histogram.Observe(d.Seconds())
+}
+
+func (noopPrometheusObserver) Observe(float64) {}
+
+// sanitizePrometheusName replaces characters that are not allowed in Prometheus metric and label names.
+func sanitizePrometheusName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,187 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.temporal.io/temporal/internal/common/metrics"
+)
+
+func gatherPrometheusMetric(t *testing.T, registry *prometheus.Registry, name string) *dto.MetricFamily {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == name {
+			return family
+		}
+	}
+	require.Failf(t, "metric not found", "metric %v is not registered", name)
+	return nil
+}
+
+func prometheusLabels(metric *dto.Metric) map[string]string {
+	labels := make(map[string]string)
+	for _, pair := range metric.GetLabel() {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+	return labels
+}
+
+func TestPrometheusMetricsHandler_TimerIsHistogram(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	handler := NewPrometheusMetricsHandler(registry, PrometheusMetricsHandlerOptions{
+		TimerBucketsByName: map[string][]float64{metrics.ActivityExecutionLatency: {1, 10}},
+	})
+	scope := metricsScopeFromHandler(tagMetricsHandler(handler, tagNamespace, "test-namespace", tagActivityType, "test-activity"))
+
+	scope.Timer(metrics.ActivityExecutionLatency).Record(2 * time.Second)
+	sw := scope.Timer(metrics.ActivityExecutionLatency).Start()
+	sw.Stop()
+
+	family := gatherPrometheusMetric(t, registry, metrics.ActivityExecutionLatency)
+	require.Equal(t, dto.MetricType_HISTOGRAM, family.GetType())
+	require.Len(t, family.GetMetric(), 1)
+	metric := family.GetMetric()[0]
+	labels := prometheusLabels(metric)
+	require.Equal(t, "test-namespace", labels[tagNamespace])
+	require.Equal(t, "test-activity", labels[tagActivityType])
+	histogram := metric.GetHistogram()
+	require.Equal(t, uint64(2), histogram.GetSampleCount())
+	require.Len(t, histogram.GetBucket(), 2)
+	require.Equal(t, uint64(1), histogram.GetBucket()[0].GetCumulativeCount())
+	require.Equal(t, uint64(2), histogram.GetBucket()[1].GetCumulativeCount())
+
+	scope.Timer(metrics.DecisionExecutionLatency).Record(time.Millisecond)
+	family = gatherPrometheusMetric(t, registry, metrics.DecisionExecutionLatency)
+	require.Len(t, family.GetMetric()[0].GetHistogram().GetBucket(), len(defaultPrometheusTimerBuckets))
+}
+
+func TestPrometheusMetricsHandler_DeclaredLabels(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	core, logs := observer.New(zapcore.InfoLevel)
+	handler := NewPrometheusMetricsHandler(registry, PrometheusMetricsHandlerOptions{
+		AdditionalTagKeys: []string{"custom-tag"},
+		Logger:            zap.New(core),
+	})
+	tagged := handler.WithTags(map[string]string{tagNamespace: "ns", tagTaskList: "tq"})
+
+	tagged.Counter(metrics.ActivityPollSucceedCounter).Inc(1)
+	tagged.WithTags(map[string]string{"undeclared": "dropped"}).Counter(metrics.ActivityPollSucceedCounter).Inc(2)
+	tagged.WithTags(map[string]string{"undeclared": "dropped"}).Counter(metrics.ActivityPollSucceedCounter).Inc(2)
+	handler.WithTags(map[string]string{tagNamespace: "ns", "custom-tag": "custom"}).Counter(metrics.ActivityPollSucceedCounter).Inc(3)
+	tagged.Counter(metrics.ActivityPollSucceedCounter).Inc(-1)
+
+	family := gatherPrometheusMetric(t, registry, metrics.ActivityPollSucceedCounter)
+	require.Equal(t, dto.MetricType_COUNTER, family.GetType())
+	values := make(map[string]float64)
+	for _, metric := range family.GetMetric() {
+		labels := prometheusLabels(metric)
+		require.Len(t, labels, len(prometheusTagKeys)+1)
+		require.Equal(t, "ns", labels[tagNamespace])
+		values[labels[tagTaskList]+"/"+labels["custom_tag"]] = metric.GetCounter().GetValue()
+	}
+	require.Equal(t, map[string]float64{"tq/": 5, "/custom": 3}, values)
+	require.Equal(t, 1, logs.FilterField(zap.String("Tag", "undeclared")).Len())
+
+	metricsScopeFromHandler(tagged).SubScope("sub").Gauge("gauge-name").Update(5)
+	family = gatherPrometheusMetric(t, registry, "sub_gauge_name")
+	require.Equal(t, float64(5), family.GetMetric()[0].GetGauge().GetValue())
+}
+
+func TestPrometheusMetricsHandler_ConflictingRegistration(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	core, logs := observer.New(zapcore.InfoLevel)
+	handler := NewPrometheusMetricsHandler(registry, PrometheusMetricsHandlerOptions{Logger: zap.New(core)})
+	handler.Counter(metrics.ActivityPollSucceedCounter).Inc(1)
+
+	require.NotPanics(t, func() {
+		handler.Gauge(metrics.ActivityPollSucceedCounter).Update(1)
+		handler.Timer(metrics.ActivityPollSucceedCounter).Record(time.Second)
+	})
+	require.Equal(t, 1, logs.FilterField(zap.String("Name", metrics.ActivityPollSucceedCounter)).Len())
+
+	// a collector with other labels is registered outside of the handler
+	otherLabels := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "other_counter", Help: "other"}, []string{"other"})
+	registry.MustRegister(otherLabels)
+	require.NotPanics(t, func() {
+		handler.Counter("other_counter").Inc(1)
+	})
+	require.Equal(t, 1, logs.FilterField(zap.String("Name", "other_counter")).Len())
+
+	family := gatherPrometheusMetric(t, registry, metrics.ActivityPollSucceedCounter)
+	require.Equal(t, dto.MetricType_COUNTER, family.GetType())
+	require.Equal(t, float64(1), family.GetMetric()[0].GetCounter().GetValue())
+}
+
+func TestPrometheusMetricsHandler_SharedRegisterer(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	first := NewPrometheusMetricsHandler(registry, PrometheusMetricsHandlerOptions{})
+	second := NewPrometheusMetricsHandler(registry, PrometheusMetricsHandlerOptions{})
+
+	first.Counter(metrics.WorkflowCompletedCounter).Inc(1)
+	second.Counter(metrics.WorkflowCompletedCounter).Inc(1)
+
+	family := gatherPrometheusMetric(t, registry, metrics.WorkflowCompletedCounter)
+	require.Equal(t, float64(2), family.GetMetric()[0].GetCounter().GetValue())
+}
+
+func TestTallyMetricsHandler(t *testing.T) {
+	testScope := tally.NewTestScope("", nil)
+	handler := NewTallyMetricsHandler(testScope).WithTags(map[string]string{tagNamespace: "ns"})
+
+	handler.Counter("counter").Inc(2)
+	handler.Gauge("gauge").Update(3)
+	handler.Timer("timer").Record(time.Second)
+	handler.Histogram("histogram", []float64{1, 10}).RecordDuration(5 * time.Second)
+
+	snapshot := testScope.Snapshot()
+	require.Equal(t, int64(2), snapshot.Counters()["counter+Namespace=ns"].Value())
+	require.Equal(t, float64(3), snapshot.Gauges()["gauge+Namespace=ns"].Value())
+	require.Equal(t, []time.Duration{time.Second}, snapshot.Timers()["timer+Namespace=ns"].Values())
+	require.Equal(t, int64(1), snapshot.Histograms()["histogram+Namespace=ns"].Values()[10])
+}
+
+func TestClientOptions_MetricsHandler(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	client := NewServiceClient(nil, nil, ClientOptions{
+		MetricsScope:   tally.NoopScope,
+		MetricsHandler: NewPrometheusMetricsHandler(registry, PrometheusMetricsHandlerOptions{}),
+	})
+
+	client.metricsHandler.Counter(metrics.WorkflowStartCounter).Inc(1)
+
+	family := gatherPrometheusMetric(t, registry, metrics.WorkflowStartCounter)
+	require.Equal(t, float64(1), family.GetMetric()[0].GetCounter().GetValue())
+}
@@ -0,0 +1,390 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	commonpb "go.temporal.io/temporal-proto/common/v1"
+)
+
+const (
+	metadataEncodingGzip      = "binary/gzip"
+	metadataEncodingEncrypted = "binary/encrypted"
+	metadataEncryptionKeyID   = "encryption-key-id"
+)
+
+type (
+	// PayloadCodec transforms payload bytes and metadata after a value is converted to payload and
+	// before a payload is converted back to a value. Typical use cases are compression and encryption.
+	// Codec must return payload unchanged from Decode if the payload was not encoded by it
+	// (i.e. payload encoding metadata doesn't match), so histories written before codec was added can still be read.
+	PayloadCodec interface {
+		// Encode transforms payload produced by DataConverter.
+		Encode(payload *commonpb.Payload) (*commonpb.Payload, error)
+		// Decode reverts transformation done by Encode.
+		Decode(payload *commonpb.Payload) (*commonpb.Payload, error)
+	}
+
+	// HeaderCodec is implemented by DataConverters which transform header fields written by context propagators,
+	// i.e. to encrypt them. Header fields are not converted by DataConverter, so a DataConverter which wraps
+	// another one must implement HeaderCodec by delegating to the wrapped DataConverter,
+	// otherwise header fields are sent as is.
+	HeaderCodec interface {
+		// EncodeHeaderField transforms header field payload before it is sent to the server.
+		EncodeHeaderField(payload *commonpb.Payload) (*commonpb.Payload, error)
+		// DecodeHeaderField reverts transformation done by EncodeHeaderField.
+		DecodeHeaderField(payload *commonpb.Payload) (*commonpb.Payload, error)
+	}
+
+	// CodecDataConverter wraps DataConverter and applies a chain of PayloadCodecs to every payload
+	// which goes through it, including header fields.
+	CodecDataConverter struct {
+		parent DataConverter
+		codecs []PayloadCodec
+	}
+
+	// GzipPayloadCodec compresses payloads using gzip. Payloads which don't become smaller after compression are left as is.
+	GzipPayloadCodec struct {
+		level int
+	}
+
+	// AESGCMPayloadCodec encrypts payloads using AES-GCM. Key ID is written to payload metadata,
+	// so keys can be rotated without breaking decryption of previously encrypted payloads.
+	AESGCMPayloadCodec struct {
+		keyID string
+		aeads map[string]cipher.AEAD
+	}
+)
+
+var (
+	// ErrUnableToEncodePayload is returned when payload codec fails to encode payload.
+	ErrUnableToEncodePayload = errors.New("unable to encode payload")
+	// ErrUnableToDecodePayload is returned when payload codec fails to decode payload.
+	ErrUnableToDecodePayload = errors.New("unable to decode payload")
+	// ErrEncryptionKeyNotFound is returned when there is no encryption key for key ID.
+	ErrEncryptionKeyNotFound = errors.New("encryption key not found")
+)
+
+// NewCodecDataConverter creates new instance of CodecDataConverter. On encoding codecs are applied in specified order,
+// on decoding in reverse order, i.e. NewCodecDataConverter(dc, gzipCodec, aesCodec) compresses and then encrypts payloads.
+func NewCodecDataConverter(parent DataConverter, codecs ...PayloadCodec) *CodecDataConverter {
+	return &CodecDataConverter{
+		parent: parent,
+		codecs: codecs,
+	}
+}
+
+// ToPayload converts single value to payload and encodes it with codecs.
+func (dc *CodecDataConverter) ToPayload(value interface{}) (*commonpb.Payload, error) {
+	payload, err := dc.parent.ToPayload(value)
+	if err != nil || payload == nil {
+		return payload, err
+	}
+	return dc.encode(payload)
+}
+
+// FromPayload decodes payload with codecs and converts it to single value.
+func (dc *CodecDataConverter) FromPayload(payload *commonpb.Payload, valuePtr interface{}) error {
+	if payload == nil {
+		return nil
+	}
+	decodedPayload, err := dc.decode(payload)
+	if err != nil {
+		return err
+	}
+	return dc.parent.FromPayload(decodedPayload, valuePtr)
+}
+
+// ToPayloads converts a list of values and encodes every payload with codecs.
+func (dc *CodecDataConverter) ToPayloads(values ...interface{}) (*commonpb.Payloads, error) {
+	payloads, err := dc.parent.ToPayloads(values...)
+	if err != nil || payloads == nil {
+		return payloads, err
+	}
+
+	for i, payload := range payloads.Payloads {
+		if payloads.Payloads[i], err = dc.encode(payload); err != nil {
+			return nil, fmt.Errorf("values[%d]: %w", i, err)
+		}
+	}
+	return payloads, nil
+}
+
+// FromPayloads decodes every payload with codecs and converts them to a list of values.
+func (dc *CodecDataConverter) FromPayloads(payloads *commonpb.Payloads, valuePtrs ...interface{}) error {
+	if payloads == nil {
+		return nil
+	}
+
+	decodedPayloads := &commonpb.Payloads{Payloads: make([]*commonpb.Payload, len(payloads.GetPayloads()))}
+	for i, payload := range payloads.GetPayloads() {
+		var err error
+		if decodedPayloads.Payloads[i], err = dc.decode(payload); err != nil {
+			return fmt.Errorf("payload item %d: %w", i, err)
+		}
+	}
+	return dc.parent.FromPayloads(decodedPayloads, valuePtrs...)
+}
+
+// EncodeHeaderField encodes header field payload with codecs.
+// If parent DataConverter implements HeaderCodec, it is applied first.
+func (dc *CodecDataConverter) EncodeHeaderField(payload *commonpb.Payload) (*commonpb.Payload, error) {
+	if headerCodec, ok := dc.parent.(HeaderCodec); ok {
+		var err error
+		if payload, err = headerCodec.EncodeHeaderField(payload); err != nil {
+			return nil, err
+		}
+	}
+	return dc.encode(payload)
+}
+
+// DecodeHeaderField decodes header field payload with codecs.
+// If parent DataConverter implements HeaderCodec, it is applied last.
+func (dc *CodecDataConverter) DecodeHeaderField(payload *commonpb.Payload) (*commonpb.Payload, error) {
+	payload, err := dc.decode(payload)
+	if err != nil {
+		return nil, err
+	}
+	if headerCodec, ok := dc.parent.(HeaderCodec); ok {
+		return headerCodec.DecodeHeaderField(payload)
+	}
+	return payload, nil
+}
+
+func (dc *CodecDataConverter) encode(payload *commonpb.Payload) (*commonpb.Payload, error) {
+	var err error
+	for _, codec := range dc.codecs {
+		if payload, err = codec.Encode(payload); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnableToEncodePayload, err)
+		}
+	}
+	return payload, nil
+}
+
+func (dc *CodecDataConverter) decode(payload *commonpb.Payload) (*commonpb.Payload, error) {
+	var err error
+	for i := len(dc.codecs) - 1; i >= 0; i-- {
+		if payload, err = dc.codecs[i].Decode(payload); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnableToDecodePayload, err)
+		}
+	}
+	return payload, nil
+}
+
+// encodeHeader encodes header fields written by context propagators if data converter implements HeaderCodec.
+// Fields which can't be encoded are removed from header, so they are never sent to the server unencoded.
+func encodeHeader(dc DataConverter, header *commonpb.Header) error {
+	headerCodec, ok := dc.(HeaderCodec)
+	if !ok || header == nil {
+		return nil
+	}
+
+	var encodeErr error
+	for key, payload := range header.Fields {
+		encodedPayload, err := headerCodec.EncodeHeaderField(payload)
+		if err != nil {
+			delete(header.Fields, key)
+			encodeErr = fmt.Errorf("header %s: %w", key, err)
+			continue
+		}
+		header.Fields[key] = encodedPayload
+	}
+	return encodeErr
+}
+
+// decodeHeader returns a copy of header with header fields decoded if data converter implements HeaderCodec.
+func decodeHeader(dc DataConverter, header *commonpb.Header) (*commonpb.Header, error) {
+	headerCodec, ok := dc.(HeaderCodec)
+	if !ok || header == nil {
+		return header, nil
+	}
+
+	decodedHeader := &commonpb.Header{Fields: make(map[string]*commonpb.Payload, len(header.Fields))}
+	for key, payload := range header.Fields {
+		decodedPayload, err := headerCodec.DecodeHeaderField(payload)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", key, err)
+		}
+		decodedHeader.Fields[key] = decodedPayload
+	}
+	return decodedHeader, nil
+}
+
+// NewGzipPayloadCodec creates new instance of GzipPayloadCodec with default compression level.
+func NewGzipPayloadCodec() *GzipPayloadCodec {
+	return &GzipPayloadCodec{level: gzip.DefaultCompression}
+}
+
+// NewGzipPayloadCodecWithLevel creates new instance of GzipPayloadCodec with specified compression level.
+func NewGzipPayloadCodecWithLevel(level int) (*GzipPayloadCodec, error) {
+	if _, err := gzip.NewWriterLevel(ioutil.Discard, level); err != nil {
+		return nil, err
+	}
+	return &GzipPayloadCodec{level: level}, nil
+}
+
+// Encode compresses payload.
+func (c *GzipPayloadCodec) Encode(payload *commonpb.Payload) (*commonpb.Payload, error) {
+	data, err := payload.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	if buf.Len() >= len(data) {
+		return payload, nil
+	}
+	return newEncodedPayload(metadataEncodingGzip, buf.Bytes()), nil
+}
+
+// Decode decompresses payload if it was compressed by GzipPayloadCodec.
+func (c *GzipPayloadCodec) Decode(payload *commonpb.Payload) (*commonpb.Payload, error) {
+	if !isEncodedWith(payload, metadataEncodingGzip) {
+		return payload, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(payload.GetData()))
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.Close(); err != nil {
+		return nil, err
+	}
+	return unmarshalPayload(data)
+}
+
+// NewAESGCMPayloadCodec creates new instance of AESGCMPayloadCodec. Payloads are encrypted with the key
+// identified by keyID. keys must contain all keys which might be needed to decrypt existing payloads.
+// Every key must be 16, 24, or 32 bytes long to select AES-128, AES-192, or AES-256.
+func NewAESGCMPayloadCodec(keyID string, keys map[string][]byte) (*AESGCMPayloadCodec, error) {
+	if _, ok := keys[keyID]; !ok {
+		return nil, fmt.Errorf("key ID %s: %w", keyID, ErrEncryptionKeyNotFound)
+	}
+
+	aeads := make(map[string]cipher.AEAD, len(keys))
+	for id, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key ID %s: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key ID %s: %w", id, err)
+		}
+		aeads[id] = aead
+	}
+
+	return &AESGCMPayloadCodec{
+		keyID: keyID,
+		aeads: aeads,
+	}, nil
+}
+
+// Encode encrypts payload with the current key.
+func (c *AESGCMPayloadCodec) Encode(payload *commonpb.Payload) (*commonpb.Payload, error) {
+	data, err := payload.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	aead := c.aeads[c.keyID]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	encryptedPayload := newEncodedPayload(metadataEncodingEncrypted, aead.Seal(nonce, nonce, data, []byte(c.keyID)))
+	encryptedPayload.Metadata[metadataEncryptionKeyID] = []byte(c.keyID)
+	return encryptedPayload, nil
+}
+
+// Decode decrypts payload if it was encrypted by AESGCMPayloadCodec.
+func (c *AESGCMPayloadCodec) Decode(payload *commonpb.Payload) (*commonpb.Payload, error) {
+	if !isEncodedWith(payload, metadataEncodingEncrypted) {
+		return payload, nil
+	}
+
+	keyID := string(payload.GetMetadata()[metadataEncryptionKeyID])
+	aead, ok := c.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("key ID %s: %w", keyID, ErrEncryptionKeyNotFound)
+	}
+
+	encrypted := payload.GetData()
+	if len(encrypted) < aead.NonceSize() {
+		return nil, errors.New("encrypted payload is too short")
+	}
+	nonce, ciphertext := encrypted[:aead.NonceSize()], encrypted[aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, ciphertext, []byte(keyID))
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalPayload(data)
+}
+
+func newEncodedPayload(encoding string, data []byte) *commonpb.Payload {
+	return &commonpb.Payload{
+		Metadata: map[string][]byte{
+			metadataEncoding: []byte(encoding),
+		},
+		Data: data,
+	}
+}
+
+func isEncodedWith(payload *commonpb.Payload, encoding string) bool {
+	return string(payload.GetMetadata()[metadataEncoding]) == encoding
+}
+
+func unmarshalPayload(data []byte) (*commonpb.Payload, error) {
+	payload := &commonpb.Payload{}
+	if err := payload.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
@@ -0,0 +1,196 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/temporal-proto/common/v1"
+)
+
+func newTestAESGCMPayloadCodec(t *testing.T, keyID string) *AESGCMPayloadCodec {
+	codec, err := NewAESGCMPayloadCodec(keyID, map[string][]byte{
+		"key1": []byte("0123456789abcdef0123456789abcdef"),
+		"key2": []byte("fedcba9876543210fedcba9876543210"),
+	})
+	require.NoError(t, err)
+	return codec
+}
+
+func TestCodecDataConverter_RoundTrip(t *testing.T) {
+	t.Parallel()
+	dc := NewCodecDataConverter(getDefaultDataConverter(), NewGzipPayloadCodec(), newTestAESGCMPayloadCodec(t, "key1"))
+
+	longString := strings.Repeat("temporal", 100)
+	payloads, err := dc.ToPayloads(longString, 42, []byte("bytes"))
+	require.NoError(t, err)
+	for _, payload := range payloads.Payloads {
+		require.Equal(t, metadataEncodingEncrypted, string(payload.Metadata[metadataEncoding]))
+		require.Equal(t, "key1", string(payload.Metadata[metadataEncryptionKeyID]))
+		require.NotContains(t, string(payload.Data), "temporal")
+	}
+
+	var s string
+	var i int
+	var b []byte
+	require.NoError(t, dc.FromPayloads(payloads, &s, &i, &b))
+	require.Equal(t, longString, s)
+	require.Equal(t, 42, i)
+	require.Equal(t, []byte("bytes"), b)
+}
+
+func TestCodecDataConverter_ReadsUnencodedPayloads(t *testing.T) {
+	t.Parallel()
+	dc := NewCodecDataConverter(getDefaultDataConverter(), NewGzipPayloadCodec(), newTestAESGCMPayloadCodec(t, "key1"))
+
+	payload, err := getDefaultDataConverter().ToPayload("plain")
+	require.NoError(t, err)
+	var s string
+	require.NoError(t, dc.FromPayload(payload, &s))
+	require.Equal(t, "plain", s)
+}
+
+func TestGzipPayloadCodec(t *testing.T) {
+	t.Parallel()
+	codec := NewGzipPayloadCodec()
+
+	small := &commonpb.Payload{Data: []byte("x")}
+	encoded, err := codec.Encode(small)
+	require.NoError(t, err)
+	require.Equal(t, small, encoded, "payloads which don't benefit from compression must be left as is")
+
+	large := &commonpb.Payload{Metadata: map[string][]byte{metadataEncoding: []byte(metadataEncodingJSON)}, Data: []byte(strings.Repeat("a", 1024))}
+	encoded, err = codec.Encode(large)
+	require.NoError(t, err)
+	require.Equal(t, metadataEncodingGzip, string(encoded.Metadata[metadataEncoding]))
+	require.True(t, len(encoded.Data) < len(large.Data))
+
+	decoded, err := codec.Decode(encoded)
+	require.NoError(t, err)
+	require.Equal(t, large, decoded)
+
+	_, err = NewGzipPayloadCodecWithLevel(42)
+	require.Error(t, err)
+}
+
+func TestAESGCMPayloadCodec_KeyRotation(t *testing.T) {
+	t.Parallel()
+	payload := &commonpb.Payload{Metadata: map[string][]byte{metadataEncoding: []byte(metadataEncodingJSON)}, Data: []byte(`"secret"`)}
+
+	encoded, err := newTestAESGCMPayloadCodec(t, "key1").Encode(payload)
+	require.NoError(t, err)
+
+	decoded, err := newTestAESGCMPayloadCodec(t, "key2").Decode(encoded)
+	require.NoError(t, err)
+	require.Equal(t, payload, decoded)
+
+	onlyKey2, err := NewAESGCMPayloadCodec("key2", map[string][]byte{"key2": []byte("fedcba9876543210fedcba9876543210")})
+	require.NoError(t, err)
+	_, err = onlyKey2.Decode(encoded)
+	require.True(t, errors.Is(err, ErrEncryptionKeyNotFound))
+
+	encoded.Metadata[metadataEncryptionKeyID] = []byte("key2")
+	_, err = newTestAESGCMPayloadCodec(t, "key1").Decode(encoded)
+	require.Error(t, err, "key ID is authenticated as additional data")
+
+	_, err = NewAESGCMPayloadCodec("unknown", map[string][]byte{"key1": []byte("0123456789abcdef")})
+	require.True(t, errors.Is(err, ErrEncryptionKeyNotFound))
+	_, err = NewAESGCMPayloadCodec("key1", map[string][]byte{"key1": []byte("short")})
+	require.Error(t, err)
+}
+
+func TestEncodeDecodeHeader(t *testing.T) {
+	t.Parallel()
+	dc := NewCodecDataConverter(getDefaultDataConverter(), newTestAESGCMPayloadCodec(t, "key1"))
+
+	value, err := getDefaultDataConverter().ToPayload("tenant")
+	require.NoError(t, err)
+	header := &commonpb.Header{Fields: map[string]*commonpb.Payload{"tenant": value}}
+	require.NoError(t, encodeHeader(dc, header))
+	require.Equal(t, metadataEncodingEncrypted, string(header.Fields["tenant"].Metadata[metadataEncoding]))
+
+	decodedHeader, err := decodeHeader(dc, header)
+	require.NoError(t, err)
+	require.Equal(t, value, decodedHeader.Fields["tenant"])
+	require.Equal(t, metadataEncodingEncrypted, string(header.Fields["tenant"].Metadata[metadataEncoding]), "original header must not be modified")
+
+	sameHeader, err := decodeHeader(getDefaultDataConverter(), header)
+	require.NoError(t, err)
+	require.Equal(t, header, sameHeader)
+}
+
+// wrappingDataConverter wraps DataConverter and passes header fields to it.
+type wrappingDataConverter struct {
+	DataConverter
+}
+
+func (dc *wrappingDataConverter) EncodeHeaderField(payload *commonpb.Payload) (*commonpb.Payload, error) {
+	return dc.DataConverter.(HeaderCodec).EncodeHeaderField(payload)
+}
+
+func (dc *wrappingDataConverter) DecodeHeaderField(payload *commonpb.Payload) (*commonpb.Payload, error) {
+	return dc.DataConverter.(HeaderCodec).DecodeHeaderField(payload)
+}
+
+func TestEncodeDecodeHeader_WrappedDataConverter(t *testing.T) {
+	t.Parallel()
+	dc := &wrappingDataConverter{NewCodecDataConverter(getDefaultDataConverter(), newTestAESGCMPayloadCodec(t, "key1"))}
+
+	value, err := getDefaultDataConverter().ToPayload("tenant")
+	require.NoError(t, err)
+	header := &commonpb.Header{Fields: map[string]*commonpb.Payload{"tenant": value}}
+	require.NoError(t, encodeHeader(dc, header))
+	require.Equal(t, metadataEncodingEncrypted, string(header.Fields["tenant"].Metadata[metadataEncoding]))
+
+	decodedHeader, err := decodeHeader(dc, header)
+	require.NoError(t, err)
+	require.Equal(t, value, decodedHeader.Fields["tenant"])
+}
+
+func TestWorkflowHeaderDecodeError(t *testing.T) {
+	t.Parallel()
+	codec, err := NewAESGCMPayloadCodec("key2", map[string][]byte{"key2": []byte("fedcba9876543210fedcba9876543210")})
+	require.NoError(t, err)
+	value, err := getDefaultDataConverter().ToPayload("tenant")
+	require.NoError(t, err)
+	encryptedValue, err := newTestAESGCMPayloadCodec(t, "key1").Encode(value)
+	require.NoError(t, err)
+
+	workflowFn := func(ctx Context) error {
+		return nil
+	}
+	s := &WorkflowTestSuite{}
+	s.SetHeader(&commonpb.Header{Fields: map[string]*commonpb.Payload{"tenant": encryptedValue}})
+	env := s.NewTestWorkflowEnvironment()
+	env.SetDataConverter(NewCodecDataConverter(getDefaultDataConverter(), codec))
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	require.Contains(t, env.GetWorkflowError().Error(), "unable to decode header")
+}
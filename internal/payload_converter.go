@@ -0,0 +1,317 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/gogo/protobuf/jsonpb"
+	gogoproto "github.com/gogo/protobuf/proto"
+	commonpb "go.temporal.io/temporal-proto/common/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// PayloadConverter is used by CompositeDataConverter to convert single value of specific type to/from payload.
+	// Every PayloadConverter handles exactly one encoding which is written to payload "encoding" metadata.
+	PayloadConverter interface {
+		// ToPayload converts single value to payload. It must return nil payload and nil error
+		// if value is not supported by this converter, so the next converter in the chain can be tried.
+		ToPayload(value interface{}) (*commonpb.Payload, error)
+		// FromPayload converts single value from payload. It is called only for payloads with Encoding() encoding.
+		FromPayload(payload *commonpb.Payload, valuePtr interface{}) error
+		// Encoding returns encoding supported by this converter.
+		Encoding() string
+	}
+
+	// NilPayloadConverter converts nil values.
+	NilPayloadConverter struct{}
+
+	// ByteSlicePayloadConverter passes []byte values as is.
+	ByteSlicePayloadConverter struct{}
+
+	// ProtoPayloadConverter converts gogo and golang protobuf messages using protobuf binary format.
+	ProtoPayloadConverter struct{}
+
+	// ProtoJSONPayloadConverter converts gogo and golang protobuf messages using protobuf JSON mapping.
+	ProtoJSONPayloadConverter struct {
+		gogoMarshaler   jsonpb.Marshaler
+		gogoUnmarshaler jsonpb.Unmarshaler
+	}
+
+	// JSONPayloadConverter converts any value using encoding/json package.
+	JSONPayloadConverter struct{}
+)
+
+// NewNilPayloadConverter creates new instance of NilPayloadConverter.
+func NewNilPayloadConverter() *NilPayloadConverter {
+	return &NilPayloadConverter{}
+}
+
+// ToPayload converts nil value to payload.
+func (c *NilPayloadConverter) ToPayload(value interface{}) (*commonpb.Payload, error) {
+	if !isNilValue(value) {
+		return nil, nil
+	}
+	return newPayload(nil, c), nil
+}
+
+// FromPayload sets value pointed by valuePtr to its zero value.
+func (c *NilPayloadConverter) FromPayload(_ *commonpb.Payload, valuePtr interface{}) error {
+	value := reflect.ValueOf(valuePtr)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("type %T: %w", valuePtr, ErrValuePtrIsNotPointer)
+	}
+	value.Elem().Set(reflect.Zero(value.Elem().Type()))
+	return nil
+}
+
+// Encoding returns binary/null encoding.
+func (c *NilPayloadConverter) Encoding() string {
+	return metadataEncodingNil
+}
+
+// NewByteSlicePayloadConverter creates new instance of ByteSlicePayloadConverter.
+func NewByteSlicePayloadConverter() *ByteSlicePayloadConverter {
+	return &ByteSlicePayloadConverter{}
+}
+
+// ToPayload converts []byte value to payload.
+func (c *ByteSlicePayloadConverter) ToPayload(value interface{}) (*commonpb.Payload, error) {
+	if valueBytes, isByteSlice := value.([]byte); isByteSlice {
+		return newPayload(valueBytes, c), nil
+	}
+	return nil, nil
+}
+
+// FromPayload sets payload data to []byte value pointed by valuePtr.
+func (c *ByteSlicePayloadConverter) FromPayload(payload *commonpb.Payload, valuePtr interface{}) error {
+	value := reflect.ValueOf(valuePtr)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("type %T: %w", valuePtr, ErrValuePtrIsNotPointer)
+	}
+	valueBytes := value.Elem()
+	if !valueBytes.CanSet() || valueBytes.Kind() != reflect.Slice || valueBytes.Type().Elem().Kind() != reflect.Uint8 {
+		return ErrUnableToSetBytes
+	}
+	valueBytes.SetBytes(payload.GetData())
+	return nil
+}
+
+// Encoding returns raw encoding.
+func (c *ByteSlicePayloadConverter) Encoding() string {
+	return metadataEncodingRaw
+}
+
+// NewProtoPayloadConverter creates new instance of ProtoPayloadConverter.
+func NewProtoPayloadConverter() *ProtoPayloadConverter {
+	return &ProtoPayloadConverter{}
+}
+
+// ToPayload converts protobuf message to payload using binary protobuf format.
+func (c *ProtoPayloadConverter) ToPayload(value interface{}) (*commonpb.Payload, error) {
+	var data []byte
+	var err error
+	switch message := value.(type) {
+	case protoreflect.ProtoMessage:
+		data, err = proto.Marshal(message)
+	case gogoproto.Message:
+		data, err = gogoproto.Marshal(message)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnableToEncodeProto, err)
+	}
+	return newProtoPayload(data, c, value), nil
+}
+
+// FromPayload converts binary protobuf payload to protobuf message pointed by valuePtr.
+func (c *ProtoPayloadConverter) FromPayload(payload *commonpb.Payload, valuePtr interface{}) error {
+	message, err := newProtoMessageValue(valuePtr)
+	if err != nil {
+		return err
+	}
+	switch message := message.(type) {
+	case protoreflect.ProtoMessage:
+		err = proto.Unmarshal(payload.GetData(), message)
+	case gogoproto.Message:
+		err = gogoproto.Unmarshal(payload.GetData(), message)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnableToDecodeProto, err)
+	}
+	return nil
+}
+
+// Encoding returns binary/protobuf encoding.
+func (c *ProtoPayloadConverter) Encoding() string {
+	return metadataEncodingProto
+}
+
+// NewProtoJSONPayloadConverter creates new instance of ProtoJSONPayloadConverter.
+func NewProtoJSONPayloadConverter() *ProtoJSONPayloadConverter {
+	return &ProtoJSONPayloadConverter{
+		gogoMarshaler:   jsonpb.Marshaler{},
+		gogoUnmarshaler: jsonpb.Unmarshaler{AllowUnknownFields: true},
+	}
+}
+
+// ToPayload converts protobuf message to payload using protobuf JSON mapping.
+func (c *ProtoJSONPayloadConverter) ToPayload(value interface{}) (*commonpb.Payload, error) {
+	var data []byte
+	var err error
+	switch message := value.(type) {
+	case protoreflect.ProtoMessage:
+		data, err = protojson.Marshal(message)
+	case gogoproto.Message:
+		var buf bytes.Buffer
+		err = c.gogoMarshaler.Marshal(&buf, message)
+		data = buf.Bytes()
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnableToEncodeProtoJSON, err)
+	}
+	return newProtoPayload(data, c, value), nil
+}
+
+// FromPayload converts protobuf JSON payload to protobuf message pointed by valuePtr.
+func (c *ProtoJSONPayloadConverter) FromPayload(payload *commonpb.Payload, valuePtr interface{}) error {
+	message, err := newProtoMessageValue(valuePtr)
+	if err != nil {
+		return err
+	}
+	switch message := message.(type) {
+	case protoreflect.ProtoMessage:
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(payload.GetData(), message)
+	case gogoproto.Message:
+		err = c.gogoUnmarshaler.Unmarshal(bytes.NewReader(payload.GetData()), message)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnableToDecodeProtoJSON, err)
+	}
+	return nil
+}
+
+// Encoding returns json/protobuf encoding.
+func (c *ProtoJSONPayloadConverter) Encoding() string {
+	return metadataEncodingProtoJSON
+}
+
+// NewJSONPayloadConverter creates new instance of JSONPayloadConverter.
+func NewJSONPayloadConverter() *JSONPayloadConverter {
+	return &JSONPayloadConverter{}
+}
+
+// ToPayload converts any value to JSON payload.
+func (c *JSONPayloadConverter) ToPayload(value interface{}) (*commonpb.Payload, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnableToEncodeJSON, err)
+	}
+	return newPayload(data, c), nil
+}
+
+// FromPayload converts JSON payload to value pointed by valuePtr.
+func (c *JSONPayloadConverter) FromPayload(payload *commonpb.Payload, valuePtr interface{}) error {
+	err := json.Unmarshal(payload.GetData(), valuePtr)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnableToDecodeJSON, err)
+	}
+	return nil
+}
+
+// Encoding returns json encoding.
+func (c *JSONPayloadConverter) Encoding() string {
+	return metadataEncodingJSON
+}
+
+func newPayload(data []byte, c PayloadConverter) *commonpb.Payload {
+	return &commonpb.Payload{
+		Metadata: map[string][]byte{
+			metadataEncoding: []byte(c.Encoding()),
+		},
+		Data: data,
+	}
+}
+
+func newProtoPayload(data []byte, c PayloadConverter, value interface{}) *commonpb.Payload {
+	payload := newPayload(data, c)
+	if messageType := protoMessageType(value); messageType != "" {
+		payload.Metadata[metadataMessageType] = []byte(messageType)
+	}
+	return payload
+}
+
+func protoMessageType(value interface{}) string {
+	switch message := value.(type) {
+	case protoreflect.ProtoMessage:
+		return string(message.ProtoReflect().Descriptor().FullName())
+	case gogoproto.Message:
+		return gogoproto.MessageName(message)
+	}
+	return ""
+}
+
+// newProtoMessageValue returns protobuf message which can be unmarshaled into.
+// valuePtr can be either pointer to message struct (i.e. *commonpb.Payloads)
+// or pointer to pointer to message struct (i.e. **commonpb.Payloads). In the last case message is allocated if needed.
+func newProtoMessageValue(valuePtr interface{}) (interface{}, error) {
+	value := reflect.ValueOf(valuePtr)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return nil, fmt.Errorf("type %T: %w", valuePtr, ErrValuePtrIsNotPointer)
+	}
+	if elem := value.Elem(); elem.Kind() == reflect.Ptr {
+		if elem.IsNil() {
+			elem.Set(reflect.New(elem.Type().Elem()))
+		}
+		value = elem
+	}
+
+	switch message := value.Interface().(type) {
+	case protoreflect.ProtoMessage, gogoproto.Message:
+		return message, nil
+	}
+	return nil, fmt.Errorf("type %T: %w", valuePtr, ErrValuePtrIsNotProtoMessage)
+}
+
+func isNilValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Chan, reflect.Func:
+		return v.IsNil()
+	}
+	return false
+}
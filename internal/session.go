@@ -35,6 +35,8 @@ import (
 	"github.com/pborman/uuid"
 	commonpb "go.temporal.io/temporal-proto/common/v1"
 	"go.uber.org/zap"
+
+	"go.temporal.io/temporal/internal/common/metrics"
 )
 
 type (
@@ -76,6 +78,8 @@ type (
 	sessionTokenBucket struct {
 		*sync.Cond
 		availableToken int
+		totalToken     int
+		metricsHandler MetricsHandler
 	}
 
 	sessionEnvironment interface {
@@ -456,20 +460,32 @@ func newSessionTokenBucket(concurrentSessionExecutionSize int) *sessionTokenBuck
 	return &sessionTokenBucket{
 		Cond:           sync.NewCond(&sync.Mutex{}),
 		availableToken: concurrentSessionExecutionSize,
+		totalToken:     concurrentSessionExecutionSize,
+		metricsHandler: metrics.NoopHandler,
 	}
 }
 
+func (t *sessionTokenBucket) setMetricsHandler(metricsHandler MetricsHandler) {
+	t.L.Lock()
+	defer t.L.Unlock()
+	t.metricsHandler = metricsHandler
+	t.updateGaugesLocked()
+}
+
 func (t *sessionTokenBucket) waitForAvailableToken() {
 	t.L.Lock()
 	defer t.L.Unlock()
+	waitStartTime := time.Now()
 	for t.availableToken == 0 {
 		t.Wait()
 	}
+	t.metricsHandler.Timer(metrics.WorkerTaskSlotWaitLatency).Record(time.Since(waitStartTime))
 }
 
 func (t *sessionTokenBucket) addToken() {
 	t.L.Lock()
 	t.availableToken++
+	t.updateGaugesLocked()
 	t.L.Unlock()
 	t.Signal()
 }
@@ -481,9 +497,15 @@ func (t *sessionTokenBucket) getToken() bool {
 		return false
 	}
 	t.availableToken--
+	t.updateGaugesLocked()
 	return true
 }
 
+func (t *sessionTokenBucket) updateGaugesLocked() {
+	t.metricsHandler.Gauge(metrics.WorkerTaskSlotsAvailable).Update(float64(t.availableToken))
+	t.metricsHandler.Gauge(metrics.WorkerTaskSlotsUsed).Update(float64(t.totalToken - t.availableToken))
+}
+
 func newSessionEnvironment(resourceID string, concurrentSessionExecutionSize int) sessionEnvironment {
 	return &sessionEnvironmentImpl{
 		Mutex:                    &sync.Mutex{},
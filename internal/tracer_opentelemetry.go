@@ -0,0 +1,351 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/propagators"
+	commonpb "go.temporal.io/temporal-proto/common/v1"
+)
+
+const (
+	openTelemetryInstrumentationName = "go.temporal.io/temporal"
+
+	activityTag = "temporalActivityID"
+
+	otelCarrierContextKey contextKey = "otelCarrier"
+)
+
+type (
+	// OpenTelemetryTracingOptions are optional parameters for NewOpenTelemetryTracing.
+	OpenTelemetryTracingOptions struct {
+		// Optional: Tracer that is used to create spans.
+		// default: tracer of the global TracerProvider
+		Tracer trace.Tracer
+
+		// Optional: TextMapPropagator that writes span context into Temporal headers.
+		// default: W3C trace context propagator (traceparent and tracestate headers)
+		TextMapPropagator otel.TextMapPropagator
+	}
+
+	// OpenTelemetryTracing creates OpenTelemetry spans for StartWorkflow, SignalWithStartWorkflow, SignalWorkflow
+	// and QueryWorkflow client calls, RunWorkflow, HandleSignal, ExecuteActivity, ExecuteLocalActivity,
+	// ExecuteChildWorkflow, SignalExternalWorkflow and NewTimer workflow operations and RunActivity activity
+	// executions. Span context is carried between them through Temporal headers, so the ContextPropagator has to be
+	// set in ClientOptions.ContextPropagators while the interceptor factories are set in ClientOptions and
+	// WorkerOptions correspondingly.
+	// Spans are not emitted while a workflow is replaying. The RunWorkflow span is finished as soon as the workflow
+	// starts, its span context is recorded into the workflow history, so the spans emitted after the workflow is
+	// replayed are parented by it as well.
+	OpenTelemetryTracing struct {
+		tracer     trace.Tracer
+		propagator otel.TextMapPropagator
+	}
+
+	// textMapCarrier holds span context serialized by the TextMapPropagator.
+	textMapCarrier map[string]string
+
+	openTelemetryContextPropagator struct {
+		tracing *OpenTelemetryTracing
+	}
+
+	openTelemetryClientInterceptorFactory struct {
+		tracing *OpenTelemetryTracing
+	}
+
+	openTelemetryClientInterceptor struct {
+		ClientInterceptorBase
+		tracing *OpenTelemetryTracing
+	}
+
+	openTelemetryWorkflowTracer struct {
+		tracing *OpenTelemetryTracing
+	}
+
+	openTelemetryWorkflowSpan struct {
+		span trace.Span
+	}
+
+	openTelemetryActivityInterceptorFactory struct {
+		tracing *OpenTelemetryTracing
+	}
+
+	openTelemetryActivityInterceptor struct {
+		ActivityInterceptorBase
+		tracing *OpenTelemetryTracing
+		info    *ActivityInfo
+	}
+)
+
+var _ otel.TextMapCarrier = textMapCarrier{}
+
+// NewOpenTelemetryTracing creates OpenTelemetryTracing.
+func NewOpenTelemetryTracing(options OpenTelemetryTracingOptions) *OpenTelemetryTracing {
+	if options.Tracer == nil {
+		options.Tracer = global.Tracer(openTelemetryInstrumentationName)
+	}
+	if options.TextMapPropagator == nil {
+		options.TextMapPropagator = propagators.TraceContext{}
+	}
+	return &OpenTelemetryTracing{
+		tracer:     options.Tracer,
+		propagator: options.TextMapPropagator,
+	}
+}
+
+// ContextPropagator returns the propagator that moves span context through Temporal headers.
+func (t *OpenTelemetryTracing) ContextPropagator() ContextPropagator {
+	return &openTelemetryContextPropagator{tracing: t}
+}
+
+// ClientInterceptorFactory returns the factory of interceptors that trace client calls.
+func (t *OpenTelemetryTracing) ClientInterceptorFactory() ClientInterceptorFactory {
+	return &openTelemetryClientInterceptorFactory{tracing: t}
+}
+
+// WorkflowInterceptorFactory returns the factory of interceptors that trace workflow execution.
+func (t *OpenTelemetryTracing) WorkflowInterceptorFactory() WorkflowInterceptorFactory {
+	return &tracingWorkflowInterceptorFactory{tracer: &openTelemetryWorkflowTracer{tracing: t}}
+}
+
+// ActivityInterceptorFactory returns the factory of interceptors that trace activity execution.
+func (t *OpenTelemetryTracing) ActivityInterceptorFactory() ActivityInterceptorFactory {
+	return &openTelemetryActivityInterceptorFactory{tracing: t}
+}
+
+// startSpan starts a span which is a child of the span context serialized in the parent carrier.
+func (t *OpenTelemetryTracing) startSpan(parent textMapCarrier, name string, kind trace.SpanKind, attributes ...label.KeyValue) (trace.Span, textMapCarrier) {
+	ctx := t.propagator.Extract(context.Background(), parent)
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attributes...))
+	carrier := textMapCarrier{}
+	t.propagator.Inject(ctx, carrier)
+	return span, carrier
+}
+
+func (t *OpenTelemetryTracing) readHeader(hr HeaderReader) (textMapCarrier, error) {
+	carrier := textMapCarrier{}
+	fields := make(map[string]bool)
+	for _, field := range t.propagator.Fields() {
+		fields[field] = true
+	}
+	err := hr.ForEachKey(func(key string, value *commonpb.Payload) error {
+		if !fields[key] {
+			return nil
+		}
+		var decodedValue string
+		if err := DefaultDataConverter.FromPayload(value, &decodedValue); err != nil {
+			return err
+		}
+		carrier[key] = decodedValue
+		return nil
+	})
+	return carrier, err
+}
+
+func (t *OpenTelemetryTracing) writeHeader(carrier textMapCarrier, hw HeaderWriter) error {
+	for key, value := range carrier {
+		encodedValue, err := DefaultDataConverter.ToPayload(value)
+		if err != nil {
+			return err
+		}
+		hw.Set(key, encodedValue)
+	}
+	return nil
+}
+
+func (c textMapCarrier) Get(key string) string {
+	return c[key]
+}
+
+func (c textMapCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+func otelCarrierFromWorkflowContext(ctx Context) textMapCarrier {
+	if carrier, ok := ctx.Value(otelCarrierContextKey).(textMapCarrier); ok {
+		return carrier
+	}
+	return textMapCarrier{}
+}
+
+func finishSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(context.Background(), err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (p *openTelemetryContextPropagator) Inject(ctx context.Context, hw HeaderWriter) error {
+	carrier := textMapCarrier{}
+	p.tracing.propagator.Inject(ctx, carrier)
+	return p.tracing.writeHeader(carrier, hw)
+}
+
+func (p *openTelemetryContextPropagator) Extract(ctx context.Context, hr HeaderReader) (context.Context, error) {
+	carrier, err := p.tracing.readHeader(hr)
+	if err != nil {
+		return ctx, err
+	}
+	return p.tracing.propagator.Extract(ctx, carrier), nil
+}
+
+func (p *openTelemetryContextPropagator) InjectFromWorkflow(ctx Context, hw HeaderWriter) error {
+	return p.tracing.writeHeader(otelCarrierFromWorkflowContext(ctx), hw)
+}
+
+func (p *openTelemetryContextPropagator) ExtractToWorkflow(ctx Context, hr HeaderReader) (Context, error) {
+	carrier, err := p.tracing.readHeader(hr)
+	if err != nil {
+		return ctx, err
+	}
+	return WithValue(ctx, otelCarrierContextKey, carrier), nil
+}
+
+func (f *openTelemetryClientInterceptorFactory) NewInterceptor(next ClientInterceptor) ClientInterceptor {
+	return &openTelemetryClientInterceptor{ClientInterceptorBase: ClientInterceptorBase{Next: next}, tracing: f.tracing}
+}
+
+func (t *openTelemetryClientInterceptor) startSpan(ctx context.Context, name string, attributes ...label.KeyValue) (context.Context, trace.Span) {
+	return t.tracing.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+func (t *openTelemetryClientInterceptor) ExecuteWorkflow(ctx context.Context, options *StartWorkflowOptions, workflowType string,
+	header *commonpb.Header, args ...interface{}) (WorkflowRun, error) {
+	ctx, span := t.startSpan(ctx, fmt.Sprintf("StartWorkflow:%s", workflowType), label.String(workflowTag, options.ID))
+	if err := (&openTelemetryContextPropagator{tracing: t.tracing}).Inject(ctx, NewHeaderWriter(header)); err != nil {
+		finishSpan(span, err)
+		return nil, err
+	}
+	run, err := t.Next.ExecuteWorkflow(ctx, options, workflowType, header, args...)
+	if err == nil {
+		span.SetAttributes(label.String(runTag, run.GetRunID()))
+	}
+	finishSpan(span, err)
+	return run, err
+}
+
+func (t *openTelemetryClientInterceptor) StartWorkflow(ctx context.Context, options *StartWorkflowOptions, workflowType string,
+	header *commonpb.Header, args ...interface{}) (*WorkflowExecution, error) {
+	ctx, span := t.startSpan(ctx, fmt.Sprintf("StartWorkflow:%s", workflowType), label.String(workflowTag, options.ID))
+	if err := (&openTelemetryContextPropagator{tracing: t.tracing}).Inject(ctx, NewHeaderWriter(header)); err != nil {
+		finishSpan(span, err)
+		return nil, err
+	}
+	execution, err := t.Next.StartWorkflow(ctx, options, workflowType, header, args...)
+	if err == nil {
+		span.SetAttributes(label.String(runTag, execution.RunID))
+	}
+	finishSpan(span, err)
+	return execution, err
+}
+
+func (t *openTelemetryClientInterceptor) SignalWithStartWorkflow(ctx context.Context, workflowID string, signalName string, signalArg interface{},
+	options *StartWorkflowOptions, workflowType string, header *commonpb.Header, workflowArgs ...interface{}) (*WorkflowExecution, error) {
+	ctx, span := t.startSpan(ctx, fmt.Sprintf("SignalWithStartWorkflow:%s", workflowType), label.String(workflowTag, workflowID))
+	if err := (&openTelemetryContextPropagator{tracing: t.tracing}).Inject(ctx, NewHeaderWriter(header)); err != nil {
+		finishSpan(span, err)
+		return nil, err
+	}
+	execution, err := t.Next.SignalWithStartWorkflow(ctx, workflowID, signalName, signalArg, options, workflowType, header, workflowArgs...)
+	if err == nil {
+		span.SetAttributes(label.String(runTag, execution.RunID))
+	}
+	finishSpan(span, err)
+	return execution, err
+}
+
+func (t *openTelemetryClientInterceptor) SignalWorkflow(ctx context.Context, workflowID string, runID string, signalName string, arg interface{}) error {
+	ctx, span := t.startSpan(ctx, fmt.Sprintf("SignalWorkflow:%s", signalName), label.String(workflowTag, workflowID), label.String(runTag, runID))
+	err := t.Next.SignalWorkflow(ctx, workflowID, runID, signalName, arg)
+	finishSpan(span, err)
+	return err
+}
+
+func (t *openTelemetryClientInterceptor) QueryWorkflow(ctx context.Context, request *QueryWorkflowWithOptionsRequest) (*QueryWorkflowWithOptionsResponse, error) {
+	ctx, span := t.startSpan(ctx, fmt.Sprintf("QueryWorkflow:%s", request.QueryType),
+		label.String(workflowTag, request.WorkflowID), label.String(runTag, request.RunID))
+	response, err := t.Next.QueryWorkflow(ctx, request)
+	finishSpan(span, err)
+	return response, err
+}
+
+func (o *openTelemetryWorkflowTracer) startSpan(ctx Context, operationName string, kind workflowSpanKind, tags map[string]string) (Context, workflowSpan) {
+	spanKind := trace.SpanKindInternal
+	switch kind {
+	case workflowSpanKindClient:
+		spanKind = trace.SpanKindClient
+	case workflowSpanKindServer:
+		spanKind = trace.SpanKindServer
+	}
+	attributes := make([]label.KeyValue, 0, len(tags))
+	for k, v := range tags {
+		attributes = append(attributes, label.String(k, v))
+	}
+	span, carrier := o.tracing.startSpan(otelCarrierFromWorkflowContext(ctx), operationName, spanKind, attributes...)
+	return WithValue(ctx, otelCarrierContextKey, carrier), &openTelemetryWorkflowSpan{span: span}
+}
+
+func (o *openTelemetryWorkflowTracer) spanContext(ctx Context) map[string]string {
+	return otelCarrierFromWorkflowContext(ctx)
+}
+
+func (o *openTelemetryWorkflowTracer) contextWithSpanContext(ctx Context, spanContext map[string]string) Context {
+	if len(spanContext) == 0 {
+		return ctx
+	}
+	return WithValue(ctx, otelCarrierContextKey, textMapCarrier(spanContext))
+}
+
+func (s *openTelemetryWorkflowSpan) finish(err error) {
+	finishSpan(s.span, err)
+}
+
+func (f *openTelemetryActivityInterceptorFactory) NewInterceptor(info *ActivityInfo, next ActivityInterceptor) ActivityInterceptor {
+	return &openTelemetryActivityInterceptor{ActivityInterceptorBase: ActivityInterceptorBase{Next: next}, tracing: f.tracing, info: info}
+}
+
+func (t *openTelemetryActivityInterceptor) ExecuteActivity(ctx context.Context, activityType string, args ...interface{}) []interface{} {
+	ctx, span := t.tracing.tracer.Start(ctx, fmt.Sprintf("RunActivity:%s", activityType), trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			label.String(workflowTag, t.info.WorkflowExecution.ID),
+			label.String(runTag, t.info.WorkflowExecution.RunID),
+			label.String(activityTag, t.info.ActivityID),
+		))
+	results := t.Next.ExecuteActivity(ctx, activityType, args...)
+	var err error
+	if len(results) > 0 {
+		err, _ = results[len(results)-1].(error)
+	}
+	finishSpan(span, err)
+	return results
+}
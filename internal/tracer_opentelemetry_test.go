@@ -0,0 +1,204 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/api/trace/tracetest"
+	commonpb "go.temporal.io/temporal-proto/common/v1"
+	"go.temporal.io/temporal-proto/workflowservice/v1"
+	"go.temporal.io/temporal-proto/workflowservicemock/v1"
+)
+
+func newTestOpenTelemetryTracing() (*OpenTelemetryTracing, *tracetest.StandardSpanRecorder) {
+	recorder := &tracetest.StandardSpanRecorder{}
+	tracer := tracetest.NewTracerProvider(tracetest.WithSpanRecorder(recorder)).Tracer("test")
+	return NewOpenTelemetryTracing(OpenTelemetryTracingOptions{Tracer: tracer}), recorder
+}
+
+func TestOpenTelemetryContextPropagator(t *testing.T) {
+	t.Parallel()
+	tracing, _ := newTestOpenTelemetryTracing()
+	ctxProp := tracing.ContextPropagator()
+
+	ctx, span := tracing.tracer.Start(context.Background(), "test-operation")
+	header := &commonpb.Header{
+		Fields: map[string]*commonpb.Payload{},
+	}
+	require.NoError(t, ctxProp.Inject(ctx, NewHeaderWriter(header)))
+	require.Contains(t, header.Fields, "traceparent")
+
+	returnCtx, err := ctxProp.Extract(context.Background(), NewHeaderReader(header))
+	require.NoError(t, err)
+	require.Equal(t, span.SpanContext(), trace.RemoteSpanContextFromContext(returnCtx))
+}
+
+func TestOpenTelemetryContextPropagatorNoSpan(t *testing.T) {
+	t.Parallel()
+	tracing, _ := newTestOpenTelemetryTracing()
+	ctxProp := tracing.ContextPropagator()
+
+	header := &commonpb.Header{
+		Fields: map[string]*commonpb.Payload{},
+	}
+	require.NoError(t, ctxProp.Inject(context.Background(), NewHeaderWriter(header)))
+	require.Empty(t, header.Fields)
+
+	returnCtx, err := ctxProp.Extract(context.Background(), NewHeaderReader(header))
+	require.NoError(t, err)
+	require.False(t, trace.RemoteSpanContextFromContext(returnCtx).IsValid())
+}
+
+func TestOpenTelemetryWorkflowAndActivitySpans(t *testing.T) {
+	tracing, recorder := newTestOpenTelemetryTracing()
+	activityFn := func(ctx context.Context) error {
+		return nil
+	}
+	workflowFn := func(ctx Context) error {
+		ctx = WithActivityOptions(ctx, ActivityOptions{ScheduleToCloseTimeout: 10e9})
+		return ExecuteActivity(ctx, activityFn).Get(ctx, nil)
+	}
+
+	var suite WorkflowTestSuite
+	suite.SetContextPropagators([]ContextPropagator{tracing.ContextPropagator()})
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(WorkerOptions{
+		WorkflowInterceptorChainFactories: []WorkflowInterceptorFactory{tracing.WorkflowInterceptorFactory()},
+		ActivityInterceptorChainFactories: []ActivityInterceptorFactory{tracing.ActivityInterceptorFactory()},
+	})
+	env.RegisterWorkflowWithOptions(workflowFn, RegisterWorkflowOptions{Name: "tracedWorkflow"})
+	env.RegisterActivityWithOptions(activityFn, RegisterActivityOptions{Name: "tracedActivity"})
+	env.ExecuteWorkflow("tracedWorkflow")
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	spans := make(map[string]*tracetest.Span)
+	for _, span := range recorder.Completed() {
+		spans[span.Name()] = span
+	}
+	require.Len(t, spans, 3)
+	runWorkflow := spans["RunWorkflow:tracedWorkflow"]
+	executeActivity := spans["ExecuteActivity:tracedActivity"]
+	runActivity := spans["RunActivity:tracedActivity"]
+	require.NotNil(t, runWorkflow)
+	require.NotNil(t, executeActivity)
+	require.NotNil(t, runActivity)
+	require.Equal(t, runWorkflow.SpanContext().SpanID, executeActivity.ParentSpanID())
+	require.Equal(t, executeActivity.SpanContext().SpanID, runActivity.ParentSpanID())
+	require.Equal(t, runWorkflow.SpanContext().TraceID, runActivity.SpanContext().TraceID)
+}
+
+func TestOpenTelemetryLocalActivitySpans(t *testing.T) {
+	tracing, recorder := newTestOpenTelemetryTracing()
+	localActivityFn := func(ctx context.Context) error {
+		return nil
+	}
+	workflowFn := func(ctx Context) error {
+		ctx = WithLocalActivityOptions(ctx, LocalActivityOptions{ScheduleToCloseTimeout: 10e9})
+		return ExecuteLocalActivity(ctx, localActivityFn).Get(ctx, nil)
+	}
+
+	var suite WorkflowTestSuite
+	suite.SetContextPropagators([]ContextPropagator{tracing.ContextPropagator()})
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(WorkerOptions{
+		WorkflowInterceptorChainFactories: []WorkflowInterceptorFactory{tracing.WorkflowInterceptorFactory()},
+		ActivityInterceptorChainFactories: []ActivityInterceptorFactory{tracing.ActivityInterceptorFactory()},
+	})
+	env.RegisterWorkflowWithOptions(workflowFn, RegisterWorkflowOptions{Name: "tracedWorkflow"})
+	env.ExecuteWorkflow("tracedWorkflow")
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	spans := make(map[string]*tracetest.Span)
+	for _, span := range recorder.Completed() {
+		spans[span.Name()] = span
+	}
+	executeLocalActivity := spans["ExecuteLocalActivity:"+getFunctionName(localActivityFn)]
+	runLocalActivity := spans["RunActivity:"+getFunctionName(localActivityFn)]
+	require.NotNil(t, executeLocalActivity, spans)
+	require.NotNil(t, runLocalActivity, spans)
+	require.Equal(t, executeLocalActivity.SpanContext().SpanID, runLocalActivity.ParentSpanID())
+	require.Equal(t, executeLocalActivity.SpanContext().TraceID, runLocalActivity.SpanContext().TraceID)
+}
+
+func TestOpenTelemetryClientExecuteWorkflowSpan(t *testing.T) {
+	tracing, recorder := newTestOpenTelemetryTracing()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicemock.NewMockWorkflowServiceClient(mockCtrl)
+	client := NewServiceClient(service, nil, ClientOptions{
+		ContextPropagators:              []ContextPropagator{tracing.ContextPropagator()},
+		ClientInterceptorChainFactories: []ClientInterceptorFactory{tracing.ClientInterceptorFactory()},
+	})
+
+	var header *commonpb.Header
+	service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, request *workflowservice.StartWorkflowExecutionRequest, _ ...interface{}) (*workflowservice.StartWorkflowExecutionResponse, error) {
+			header = request.Header
+			return &workflowservice.StartWorkflowExecutionResponse{RunId: runID}, nil
+		})
+	_, err := client.ExecuteWorkflow(context.Background(), StartWorkflowOptions{ID: workflowID, TaskList: tasklist}, "tracedWorkflow")
+	require.NoError(t, err)
+
+	spans := recorder.Completed()
+	require.Len(t, spans, 1)
+	require.Equal(t, "StartWorkflow:tracedWorkflow", spans[0].Name())
+	ctx, err := tracing.ContextPropagator().Extract(context.Background(), NewHeaderReader(header))
+	require.NoError(t, err)
+	require.Equal(t, spans[0].SpanContext(), trace.RemoteSpanContextFromContext(ctx))
+}
+
+func TestOpenTelemetryClientStartWorkflowSpan(t *testing.T) {
+	tracing, recorder := newTestOpenTelemetryTracing()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicemock.NewMockWorkflowServiceClient(mockCtrl)
+	client := NewServiceClient(service, nil, ClientOptions{
+		ContextPropagators:              []ContextPropagator{tracing.ContextPropagator()},
+		ClientInterceptorChainFactories: []ClientInterceptorFactory{tracing.ClientInterceptorFactory()},
+	})
+
+	var header *commonpb.Header
+	service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, request *workflowservice.StartWorkflowExecutionRequest, _ ...interface{}) (*workflowservice.StartWorkflowExecutionResponse, error) {
+			header = request.Header
+			return &workflowservice.StartWorkflowExecutionResponse{RunId: runID}, nil
+		})
+	_, err := client.StartWorkflow(context.Background(), StartWorkflowOptions{ID: workflowID, TaskList: tasklist}, "tracedWorkflow")
+	require.NoError(t, err)
+
+	spans := recorder.Completed()
+	require.Len(t, spans, 1)
+	require.Equal(t, "StartWorkflow:tracedWorkflow", spans[0].Name())
+	ctx, err := tracing.ContextPropagator().Extract(context.Background(), NewHeaderReader(header))
+	require.NoError(t, err)
+	require.Equal(t, spans[0].SpanContext(), trace.RemoteSpanContextFromContext(ctx))
+}
@@ -0,0 +1,239 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	commonpb "go.temporal.io/temporal-proto/common/v1"
+)
+
+const (
+	workflowSpanKindInternal workflowSpanKind = iota
+	workflowSpanKindClient
+	workflowSpanKindServer
+)
+
+const (
+	signalNameTag    = "temporalSignalName"
+	timerDurationTag = "temporalTimerDuration"
+)
+
+const (
+	// tracingChangeID versions recording of the RunWorkflow span context, so workflows started
+	// before the tracing was enabled are replayed without the marker.
+	tracingChangeID = "temporal-tracing"
+	// runWorkflowSpanID is the ID of the MutableSideEffect that records the RunWorkflow span context.
+	runWorkflowSpanID = "temporal-tracing-run-workflow-span"
+)
+
+type (
+	workflowSpanKind int
+
+	// workflowTracer creates spans for operations performed by workflow code. The span context of
+	// the parent span is carried in the workflow context, so it is available to the ContextPropagator
+	// that writes it to the headers of activities and child workflows.
+	workflowTracer interface {
+		startSpan(ctx Context, operationName string, kind workflowSpanKind, tags map[string]string) (Context, workflowSpan)
+		// spanContext serializes the span context carried in the workflow context.
+		spanContext(ctx Context) map[string]string
+		// contextWithSpanContext returns a copy of the workflow context that carries the serialized span context.
+		contextWithSpanContext(ctx Context, spanContext map[string]string) Context
+	}
+
+	workflowSpan interface {
+		finish(err error)
+	}
+
+	tracingWorkflowInterceptorFactory struct {
+		tracer workflowTracer
+	}
+
+	// tracingWorkflowInterceptor emits spans for workflow execution and the operations workflow code performs.
+	// Nothing is emitted while the workflow is replaying, so each span is reported only once.
+	// Spans are finished as soon as the operation is requested, as a workflow can be evicted
+	// from the cache before the operation completes.
+	tracingWorkflowInterceptor struct {
+		WorkflowInterceptorBase
+		tracer workflowTracer
+		info   *WorkflowInfo
+		// workflowCtx is the context of the RunWorkflow span, used as a parent of spans created
+		// outside of the workflow function (i.e. signals).
+		workflowCtx Context
+	}
+
+	openTracingWorkflowTracer struct {
+		tracer opentracing.Tracer
+	}
+
+	openTracingWorkflowSpan struct {
+		span opentracing.Span
+	}
+)
+
+// NewTracingWorkflowInterceptorFactory returns a factory of interceptors that emit opentracing spans for
+// workflow execution, activities, local activities, child workflows, external signals, timers and received signals.
+// The span context is propagated by the context propagator created with NewTracingContextPropagator.
+func NewTracingWorkflowInterceptorFactory(tracer opentracing.Tracer) WorkflowInterceptorFactory {
+	return &tracingWorkflowInterceptorFactory{tracer: &openTracingWorkflowTracer{tracer: tracer}}
+}
+
+func (f *tracingWorkflowInterceptorFactory) NewInterceptor(info *WorkflowInfo, next WorkflowInterceptor) WorkflowInterceptor {
+	return &tracingWorkflowInterceptor{WorkflowInterceptorBase: WorkflowInterceptorBase{Next: next}, tracer: f.tracer, info: info}
+}
+
+func (t *tracingWorkflowInterceptor) tags(tags ...string) map[string]string {
+	result := map[string]string{
+		workflowTag: t.info.WorkflowExecution.ID,
+		runTag:      t.info.WorkflowExecution.RunID,
+	}
+	for i := 0; i+1 < len(tags); i += 2 {
+		result[tags[i]] = tags[i+1]
+	}
+	return result
+}
+
+// startOperationSpan creates and immediately finishes a span that marks the operation.
+// The returned context carries the span, so the operation is parented by it.
+func (t *tracingWorkflowInterceptor) startOperationSpan(ctx Context, operationName string, kind workflowSpanKind, tags ...string) Context {
+	if t.Next.IsReplaying(ctx) {
+		return ctx
+	}
+	ctx, span := t.tracer.startSpan(ctx, operationName, kind, t.tags(tags...))
+	span.finish(nil)
+	return ctx
+}
+
+func (t *tracingWorkflowInterceptor) ExecuteWorkflow(ctx Context, workflowType string, args ...interface{}) []interface{} {
+	ctx = t.startWorkflowSpan(ctx, workflowType)
+	t.workflowCtx = ctx
+	return t.Next.ExecuteWorkflow(ctx, workflowType, args...)
+}
+
+// startWorkflowSpan emits the RunWorkflow span and records its span context into the workflow history,
+// so the spans emitted after the workflow is replayed on this or another worker have the same parent.
+// Workflows started before the tracing was enabled have no RunWorkflow span, their spans are parented
+// by the span that started the workflow.
+func (t *tracingWorkflowInterceptor) startWorkflowSpan(ctx Context, workflowType string) Context {
+	if t.Next.GetVersion(ctx, tracingChangeID, DefaultVersion, 1) == DefaultVersion {
+		return ctx
+	}
+	value := t.Next.MutableSideEffect(ctx, runWorkflowSpanID, func(ctx Context) interface{} {
+		ctx, span := t.tracer.startSpan(ctx, fmt.Sprintf("RunWorkflow:%s", workflowType), workflowSpanKindServer, t.tags())
+		span.finish(nil)
+		return t.tracer.spanContext(ctx)
+	}, func(a, b interface{}) bool {
+		return true
+	})
+	var spanContext map[string]string
+	if err := value.Get(&spanContext); err != nil {
+		return ctx
+	}
+	return t.tracer.contextWithSpanContext(ctx, spanContext)
+}
+
+func (t *tracingWorkflowInterceptor) HandleSignal(ctx Context, signalName string, arg *commonpb.Payloads) {
+	if t.workflowCtx != nil {
+		// signal can be delivered before workflow function is started
+		ctx = t.workflowCtx
+	}
+	ctx = t.startOperationSpan(ctx, fmt.Sprintf("HandleSignal:%s", signalName), workflowSpanKindServer, signalNameTag, signalName)
+	handleSignal(ctx, t.Next, signalName, arg)
+}
+
+func (t *tracingWorkflowInterceptor) ExecuteActivity(ctx Context, activityType string, args ...interface{}) Future {
+	ctx = t.startOperationSpan(ctx, fmt.Sprintf("ExecuteActivity:%s", activityType), workflowSpanKindClient)
+	return t.Next.ExecuteActivity(ctx, activityType, args...)
+}
+
+func (t *tracingWorkflowInterceptor) ExecuteLocalActivity(ctx Context, activityType string, args ...interface{}) Future {
+	ctx = t.startOperationSpan(ctx, fmt.Sprintf("ExecuteLocalActivity:%s", activityType), workflowSpanKindClient)
+	return t.Next.ExecuteLocalActivity(ctx, activityType, args...)
+}
+
+func (t *tracingWorkflowInterceptor) ExecuteChildWorkflow(ctx Context, childWorkflowType string, args ...interface{}) ChildWorkflowFuture {
+	ctx = t.startOperationSpan(ctx, fmt.Sprintf("ExecuteChildWorkflow:%s", childWorkflowType), workflowSpanKindClient)
+	return t.Next.ExecuteChildWorkflow(ctx, childWorkflowType, args...)
+}
+
+func (t *tracingWorkflowInterceptor) SignalExternalWorkflow(ctx Context, workflowID, runID, signalName string, arg interface{}) Future {
+	ctx = t.startOperationSpan(ctx, fmt.Sprintf("SignalExternalWorkflow:%s", signalName), workflowSpanKindClient, signalNameTag, signalName)
+	return t.Next.SignalExternalWorkflow(ctx, workflowID, runID, signalName, arg)
+}
+
+func (t *tracingWorkflowInterceptor) NewTimer(ctx Context, d time.Duration) Future {
+	ctx = t.startOperationSpan(ctx, "NewTimer", workflowSpanKindInternal, timerDurationTag, d.String())
+	return t.Next.NewTimer(ctx, d)
+}
+
+func (o *openTracingWorkflowTracer) startSpan(ctx Context, operationName string, kind workflowSpanKind, tags map[string]string) (Context, workflowSpan) {
+	var options []opentracing.StartSpanOption
+	if parent := spanFromContext(ctx); parent != nil {
+		options = append(options, opentracing.ChildOf(parent))
+	}
+	for k, v := range tags {
+		options = append(options, opentracing.Tag{Key: k, Value: v})
+	}
+	switch kind {
+	case workflowSpanKindClient:
+		options = append(options, ext.SpanKindRPCClient)
+	case workflowSpanKindServer:
+		options = append(options, ext.SpanKindRPCServer)
+	}
+	span := o.tracer.StartSpan(operationName, options...)
+	return contextWithSpan(ctx, span.Context()), &openTracingWorkflowSpan{span: span}
+}
+
+func (o *openTracingWorkflowTracer) spanContext(ctx Context) map[string]string {
+	carrier := opentracing.TextMapCarrier{}
+	if spanContext := spanFromContext(ctx); spanContext != nil {
+		if err := o.tracer.Inject(spanContext, opentracing.TextMap, carrier); err != nil {
+			return nil
+		}
+	}
+	return carrier
+}
+
+func (o *openTracingWorkflowTracer) contextWithSpanContext(ctx Context, spanContext map[string]string) Context {
+	if len(spanContext) == 0 {
+		return ctx
+	}
+	parent, err := o.tracer.Extract(opentracing.TextMap, opentracing.TextMapCarrier(spanContext))
+	if err != nil {
+		return ctx
+	}
+	return contextWithSpan(ctx, parent)
+}
+
+func (s *openTracingWorkflowSpan) finish(err error) {
+	if err != nil {
+		ext.Error.Set(s.span, true)
+		s.span.LogKV("error", err.Error())
+	}
+	s.span.Finish()
+}
@@ -0,0 +1,198 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/temporal-proto/common/v1"
+	"go.uber.org/zap"
+)
+
+func TestTracingWorkflowInterceptor(t *testing.T) {
+	tracer := mocktracer.New()
+	activityFn := func(ctx context.Context) error {
+		return nil
+	}
+	childFn := func(ctx Context) error {
+		return nil
+	}
+	workflowFn := func(ctx Context) error {
+		var signal string
+		GetSignalChannel(ctx, "start").Receive(ctx, &signal)
+
+		ctx = WithActivityOptions(ctx, ActivityOptions{ScheduleToCloseTimeout: 10 * time.Second})
+		if err := ExecuteActivity(ctx, activityFn).Get(ctx, nil); err != nil {
+			return err
+		}
+		ctx = WithLocalActivityOptions(ctx, LocalActivityOptions{ScheduleToCloseTimeout: 10 * time.Second})
+		if err := ExecuteLocalActivity(ctx, activityFn).Get(ctx, nil); err != nil {
+			return err
+		}
+		ctx = WithChildWorkflowOptions(ctx, ChildWorkflowOptions{WorkflowRunTimeout: time.Minute})
+		if err := ExecuteChildWorkflow(ctx, "tracedChild").Get(ctx, nil); err != nil {
+			return err
+		}
+		if err := NewTimer(ctx, time.Minute).Get(ctx, nil); err != nil {
+			return err
+		}
+		return SignalExternalWorkflow(ctx, "other-workflow", "", "ping", nil).Get(ctx, nil)
+	}
+
+	var suite WorkflowTestSuite
+	suite.SetContextPropagators([]ContextPropagator{NewTracingContextPropagator(zap.NewNop(), tracer)})
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(WorkerOptions{
+		WorkflowInterceptorChainFactories: []WorkflowInterceptorFactory{NewTracingWorkflowInterceptorFactory(tracer)},
+	})
+	env.RegisterWorkflowWithOptions(workflowFn, RegisterWorkflowOptions{Name: "tracedWorkflow"})
+	env.RegisterWorkflowWithOptions(childFn, RegisterWorkflowOptions{Name: "tracedChild"})
+	env.RegisterActivityWithOptions(activityFn, RegisterActivityOptions{Name: "tracedActivity"})
+	env.OnSignalExternalWorkflow(mock.Anything, "other-workflow", "", "ping", mock.Anything).Return(nil)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("start", "go")
+	}, time.Second)
+	env.ExecuteWorkflow("tracedWorkflow")
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	spans := make(map[string]*mocktracer.MockSpan)
+	for _, span := range tracer.FinishedSpans() {
+		spans[span.OperationName] = span
+	}
+	runWorkflow := spans["RunWorkflow:tracedWorkflow"]
+	require.NotNil(t, runWorkflow)
+	for _, name := range []string{
+		"HandleSignal:start",
+		"ExecuteActivity:tracedActivity",
+		"ExecuteLocalActivity:tracedActivity",
+		"ExecuteChildWorkflow:tracedChild",
+		"NewTimer",
+		"SignalExternalWorkflow:ping",
+	} {
+		require.Contains(t, spans, name)
+		require.Equal(t, runWorkflow.SpanContext.SpanID, spans[name].ParentID, name)
+	}
+	require.Equal(t, "start", spans["HandleSignal:start"].Tag(signalNameTag))
+	require.Equal(t, "1m0s", spans["NewTimer"].Tag(timerDurationTag))
+
+	childRun := spans["RunWorkflow:tracedChild"]
+	require.NotNil(t, childRun)
+	require.Equal(t, spans["ExecuteChildWorkflow:tracedChild"].SpanContext.SpanID, childRun.ParentID)
+}
+
+type replayingWorkflowInterceptor struct {
+	WorkflowInterceptorBase
+}
+
+func (r *replayingWorkflowInterceptor) IsReplaying(Context) bool {
+	return true
+}
+
+func (r *replayingWorkflowInterceptor) NewTimer(Context, time.Duration) Future {
+	return nil
+}
+
+func TestTracingWorkflowInterceptor_NoSpansWhileReplaying(t *testing.T) {
+	tracer := mocktracer.New()
+	info := &WorkflowInfo{}
+	interceptor := NewTracingWorkflowInterceptorFactory(tracer).NewInterceptor(info, &replayingWorkflowInterceptor{})
+	interceptor.NewTimer(Background(), time.Minute)
+	require.Empty(t, tracer.FinishedSpans())
+}
+
+// historyWorkflowInterceptor stands for the workflow environment, it keeps side effects
+// across workflow executions as the history does.
+type historyWorkflowInterceptor struct {
+	WorkflowInterceptorBase
+	replaying   bool
+	sideEffects map[string]*commonpb.Payloads
+	workflowCtx Context
+	signalCtx   Context
+}
+
+func (h *historyWorkflowInterceptor) ExecuteWorkflow(ctx Context, _ string, _ ...interface{}) []interface{} {
+	h.workflowCtx = ctx
+	return nil
+}
+
+func (h *historyWorkflowInterceptor) HandleSignal(ctx Context, _ string, _ *commonpb.Payloads) {
+	h.signalCtx = ctx
+}
+
+func (h *historyWorkflowInterceptor) IsReplaying(Context) bool {
+	return h.replaying
+}
+
+func (h *historyWorkflowInterceptor) GetVersion(Context, string, Version, Version) Version {
+	return 1
+}
+
+func (h *historyWorkflowInterceptor) MutableSideEffect(ctx Context, id string, f func(ctx Context) interface{}, _ func(a, b interface{}) bool) Value {
+	if _, ok := h.sideEffects[id]; !ok {
+		result, err := encodeArg(getDefaultDataConverter(), f(ctx))
+		if err != nil {
+			panic(err)
+		}
+		h.sideEffects[id] = result
+	}
+	return newEncodedValue(h.sideEffects[id], nil)
+}
+
+func (h *historyWorkflowInterceptor) NewTimer(Context, time.Duration) Future {
+	return nil
+}
+
+func TestTracingWorkflowInterceptor_ParentAfterReplay(t *testing.T) {
+	tracer := mocktracer.New()
+	info := &WorkflowInfo{}
+	history := &historyWorkflowInterceptor{sideEffects: make(map[string]*commonpb.Payloads)}
+	NewTracingWorkflowInterceptorFactory(tracer).NewInterceptor(info, history).ExecuteWorkflow(Background(), "tracedWorkflow")
+	require.Len(t, tracer.FinishedSpans(), 1)
+	runWorkflow := tracer.FinishedSpans()[0]
+	require.Equal(t, "RunWorkflow:tracedWorkflow", runWorkflow.OperationName)
+
+	// the workflow is evicted and replayed by another worker
+	history.replaying = true
+	interceptor := NewTracingWorkflowInterceptorFactory(tracer).NewInterceptor(info, history)
+	interceptor.ExecuteWorkflow(Background(), "tracedWorkflow")
+	require.Len(t, tracer.FinishedSpans(), 1)
+
+	history.replaying = false
+	interceptor.NewTimer(history.workflowCtx, time.Minute)
+	interceptor.(WorkflowSignalInterceptor).HandleSignal(Background(), "ping", nil)
+	spans := tracer.FinishedSpans()
+	require.Len(t, spans, 3)
+	require.Equal(t, "NewTimer", spans[1].OperationName)
+	require.Equal(t, runWorkflow.SpanContext.SpanID, spans[1].ParentID)
+	require.Equal(t, "HandleSignal:ping", spans[2].OperationName)
+	require.Equal(t, runWorkflow.SpanContext.SpanID, spans[2].ParentID)
+	require.Equal(t, spans[2].SpanContext.SpanID, spanFromContext(history.signalCtx).(mocktracer.MockSpanContext).SpanID)
+}
@@ -95,6 +95,24 @@ type (
 		// default: 2
 		MaxConcurrentDecisionTaskPollers int
 
+		// Optional: Enables adaptive poller mode. Instead of always running MaxConcurrentActivityTaskPollers and
+		// MaxConcurrentDecisionTaskPollers pollers, the worker starts with the minimum number of pollers, adds pollers
+		// while polls keep returning tasks and there are free execution slots, and removes pollers when polls come back
+		// empty. Decision pollers are only added while the service reports a backlog for the task list.
+		// The Max* poller options are upper bounds in this mode, so they usually need to be raised along with it.
+		// default: false
+		EnablePollerAutoScaling bool
+
+		// Optional: Sets the minimum number of goroutines that will concurrently poll the temporal-server to retrieve
+		// activity tasks when EnablePollerAutoScaling is set.
+		// default: 1
+		MinConcurrentActivityTaskPollers int
+
+		// Optional: Sets the minimum number of goroutines that will concurrently poll the temporal-server to retrieve
+		// decision tasks when EnablePollerAutoScaling is set.
+		// default: 1
+		MinConcurrentDecisionTaskPollers int
+
 		// Optional: Enable logging in replay.
 		// In the workflow code you can use workflow.GetLogger(ctx) to write logs. By default, the logger will skip log
 		// entry during replay mode so you won't see duplicate logs. This option will enable the logging in replay mode.
@@ -134,6 +152,16 @@ type (
 		// default: BlockWorkflow, which just logs error but reply nothing back to server
 		NonDeterministicWorkflowPolicy WorkflowPanicPolicy
 
+		// Optional: Sets the maximum time a workflow coroutine can run without yielding, that is without blocking on
+		// a Future, Channel, Selector or another workflow function. A coroutine that doesn't yield within the timeout is
+		// considered deadlocked, for example blocked on a native mutex, channel or a network call. The decision task
+		// then fails with an error of PanicError type that contains the stack traces of all the workflow coroutines.
+		// The goroutine of the deadlocked coroutine is abandoned and exits when it yields. A negative value disables the
+		// detection. The detection is also disabled when the TEMPORAL_DEBUG environment variable is set to true, for
+		// example to stop the workflow code at debugger breakpoints.
+		// default: 0 - disabled in workers, 10 seconds in TestWorkflowEnvironment
+		DeadlockDetectionTimeout time.Duration
+
 		// Optional: worker graceful stop timeout
 		// default: 0s
 		WorkerStopTimeout time.Duration
@@ -157,6 +185,10 @@ type (
 		// Optional: Specifies factories used to instantiate workflow interceptor chain
 		// The chain is instantiated per each replay of a workflow execution
 		WorkflowInterceptorChainFactories []WorkflowInterceptorFactory
+
+		// Optional: Specifies factories used to instantiate activity interceptor chain
+		// The chain is instantiated per each execution of an activity or a local activity
+		ActivityInterceptorChainFactories []ActivityInterceptorFactory
 	}
 )
 
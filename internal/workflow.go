@@ -74,6 +74,7 @@ type (
 		// ReceiveAsyncWithMoreFlag is same as ReceiveAsync with extra return value more to indicate if there could be
 		// more value from the Channel. The more is false when Channel is closed.
 		ReceiveAsyncWithMoreFlag(valuePtr interface{}) (ok bool, more bool)
+
 	}
 
 	// Channel must be used instead of native go channel by workflow code.
@@ -102,6 +103,38 @@ type (
 		Wait(ctx Context)
 	}
 
+	// Mutex must be used instead of native go sync.Mutex by workflow code
+	// to protect state shared by coroutines across blocking calls.
+	// Use workflow.NewMutex(ctx) method to create a new Mutex instance.
+	// Waiting coroutines acquire the Mutex in the order they called Lock.
+	Mutex interface {
+		// Lock blocks until the Mutex is acquired.
+		// Returns CanceledError if the ctx is canceled before the Mutex is acquired.
+		Lock(ctx Context) error
+		// TryLock acquires the Mutex without blocking. Returns false if the Mutex is locked
+		// or other coroutines are waiting for it.
+		TryLock() bool
+		// Unlock releases the Mutex. It panics if the Mutex is not locked.
+		Unlock()
+	}
+
+	// Semaphore must be used instead of a buffered channel used as a semaphore
+	// to limit the number of coroutines working on something at the same time.
+	// Use workflow.NewSemaphore(ctx, n) method to create a new Semaphore instance.
+	// Waiting coroutines acquire the Semaphore in the order they called Acquire.
+	Semaphore interface {
+		// Acquire blocks until n permits are acquired. It panics if n is not positive or is larger than
+		// the Semaphore size.
+		// Returns CanceledError if the ctx is canceled before the permits are acquired.
+		Acquire(ctx Context, n int64) error
+		// TryAcquire acquires n permits without blocking. Returns false if there are not enough permits
+		// or other coroutines are waiting for them. It panics if n is not positive.
+		TryAcquire(n int64) bool
+		// Release releases n permits. It panics if n is not positive or more permits are released
+		// than acquired.
+		Release(n int64)
+	}
+
 	// Future represents the result of an asynchronous computation.
 	Future interface {
 		// Get blocks until the future is ready. When ready it either returns non nil error or assigns result value to
@@ -288,6 +321,56 @@ func AwaitWithTimeout(ctx Context, timeout time.Duration, condition func() bool)
 	return true, nil
 }
 
+// GetWithTimeout blocks until the future is ready or the timeout expires.
+// Returns ok equals to false if timed out and err equals to CanceledError if the ctx is canceled.
+// Otherwise err is the result of future.Get.
+func GetWithTimeout(ctx Context, future Future, timeout time.Duration, valuePtr interface{}) (ok bool, err error) {
+	if !future.IsReady() {
+		timer, cancelTimer := newTimeoutTimer(ctx, timeout)
+		defer cancelTimer()
+		NewSelector(ctx).
+			AddFuture(future, func(f Future) {}).
+			AddFuture(timer, func(f Future) {}).
+			Select(ctx)
+		if !future.IsReady() {
+			return false, timer.Get(ctx, nil)
+		}
+	}
+	return true, future.Get(ctx, valuePtr)
+}
+
+// ReceiveWithTimeout blocks until a value is received from the channel or the timeout expires.
+// Returns ok equals to false if timed out, the ctx is canceled or the channel is closed,
+// and more equals to false when the channel is closed.
+func ReceiveWithTimeout(ctx Context, c ReceiveChannel, timeout time.Duration, valuePtr interface{}) (ok, more bool) {
+	if ok, more := c.ReceiveAsyncWithMoreFlag(valuePtr); ok || !more {
+		return ok, more
+	}
+
+	timer, cancelTimer := newTimeoutTimer(ctx, timeout)
+	defer cancelTimer()
+	more = true
+	NewSelector(ctx).
+		AddReceive(c, func(c ReceiveChannel, channelMore bool) {
+			// The channel closed while waiting has no value to receive.
+			more = channelMore
+			if more {
+				c.Receive(ctx, valuePtr)
+				ok = true
+			}
+		}).
+		AddFuture(timer, func(f Future) {}).
+		Select(ctx)
+	return ok, more
+}
+
+// newTimeoutTimer starts a timer that is canceled by the returned function,
+// so the timer doesn't outlive the wait it limits.
+func newTimeoutTimer(ctx Context, timeout time.Duration) (Future, CancelFunc) {
+	timerCtx, cancelTimer := WithCancel(ctx)
+	return NewTimer(timerCtx, timeout), cancelTimer
+}
+
 // NewChannel create new Channel instance
 func NewChannel(ctx Context) Channel {
 	state := getState(ctx)
@@ -334,6 +417,23 @@ func NewWaitGroup(ctx Context) WaitGroup {
 	return &waitGroupImpl{future: f, settable: s}
 }
 
+// NewMutex creates a new Mutex instance.
+func NewMutex(ctx Context) Mutex {
+	state := getState(ctx)
+	state.dispatcher.mutexSequence++
+	return &mutexImpl{semaphore: semaphoreImpl{name: fmt.Sprintf("mutex-%v", state.dispatcher.mutexSequence), size: 1}}
+}
+
+// NewSemaphore creates a new Semaphore instance with n permits. It panics if n is not positive.
+func NewSemaphore(ctx Context, n int64) Semaphore {
+	if n <= 0 {
+		panic(fmt.Sprintf("NewSemaphore of %v permits, the number of permits must be positive", n))
+	}
+	state := getState(ctx)
+	state.dispatcher.semaphoreSequence++
+	return &semaphoreImpl{name: fmt.Sprintf("semaphore-%v", state.dispatcher.semaphoreSequence), size: n}
+}
+
 // Go creates a new coroutine. It has similar semantic to goroutine in a context of the workflow.
 func Go(ctx Context, f func(ctx Context)) {
 	state := getState(ctx)
@@ -544,6 +644,7 @@ func (wc *workflowEnvironmentInterceptor) ExecuteLocalActivity(ctx Context, acti
 		WorkflowInfo:                GetWorkflowInfo(ctx),
 		DataConverter:               getDataConverterFromWorkflowContext(ctx),
 		ScheduledTime:               Now(ctx), // initial scheduled time
+		Header:                      getLocalActivityHeader(ctx),
 	}
 
 	Go(ctx, func(ctx Context) {
@@ -705,6 +806,18 @@ func (wc *workflowEnvironmentInterceptor) ExecuteChildWorkflow(ctx Context, chil
 	return result
 }
 
+// getLocalActivityHeader returns the header written by context propagators for a local activity.
+func getLocalActivityHeader(ctx Context) *commonpb.Header {
+	header := &commonpb.Header{
+		Fields: make(map[string]*commonpb.Payload),
+	}
+	writer := NewHeaderWriter(header)
+	for _, ctxProp := range getContextPropagatorsFromWorkflowContext(ctx) {
+		_ = ctxProp.InjectFromWorkflow(ctx, writer)
+	}
+	return header
+}
+
 func getWorkflowHeader(ctx Context, ctxProps []ContextPropagator) *commonpb.Header {
 	header := &commonpb.Header{
 		Fields: make(map[string]*commonpb.Payload),
@@ -713,6 +826,10 @@ func getWorkflowHeader(ctx Context, ctxProps []ContextPropagator) *commonpb.Head
 	for _, ctxProp := range ctxProps {
 		_ = ctxProp.InjectFromWorkflow(ctx, writer)
 	}
+	if err := encodeHeader(getDataConverterFromWorkflowContext(ctx), header); err != nil {
+		// the header fields that failed to encode are dropped, the call proceeds without them
+		GetLogger(ctx).Error("Unable to encode header.", zap.Error(err))
+	}
 	return header
 }
 
@@ -1062,6 +1179,30 @@ func (wc *workflowEnvironmentInterceptor) GetSignalChannel(ctx Context, signalNa
 	return getWorkflowEnvOptions(ctx).getSignalChannel(ctx, signalName)
 }
 
+func (wc *workflowEnvironmentInterceptor) HandleSignal(ctx Context, signalName string, arg *commonpb.Payloads) {
+	deliverSignal(ctx, signalName, arg)
+}
+
+// handleSignal passes the signal to the interceptor if it implements WorkflowSignalInterceptor,
+// otherwise it is delivered to the signal channel bypassing the rest of the interceptor chain.
+func handleSignal(ctx Context, interceptor WorkflowInterceptor, signalName string, arg *commonpb.Payloads) {
+	if signalInterceptor, ok := interceptor.(WorkflowSignalInterceptor); ok {
+		signalInterceptor.HandleSignal(ctx, signalName, arg)
+		return
+	}
+	deliverSignal(ctx, signalName, arg)
+}
+
+func deliverSignal(ctx Context, signalName string, arg *commonpb.Payloads) {
+	eo := getWorkflowEnvOptions(ctx)
+	// We don't want this code to be blocked ever, using sendAsync().
+	ch := eo.getSignalChannel(ctx, signalName).(*channelImpl)
+	ok := ch.SendAsync(arg)
+	if !ok {
+		panic(fmt.Sprintf("Exceeded channel buffer size for signal: %v", signalName))
+	}
+}
+
 func newEncodedValue(value *commonpb.Payloads, dc DataConverter) Value {
 	if dc == nil {
 		dc = getDefaultDataConverter()
@@ -1290,6 +1431,68 @@ func (wc *workflowEnvironmentInterceptor) SetQueryHandler(ctx Context, queryType
 	return setQueryHandler(ctx, queryType, handler)
 }
 
+// SetUpdateHandler sets the handler of the workflow update with given updateName. Updates are sent by
+// Client.UpdateWorkflow(), which blocks until the handler completes and returns its result or error to the caller.
+// The handler must be a function that takes workflow.Context as its first parameter followed by any number of
+// serializable parameters, and returns either a serializable result and an error or just an error. Unlike query
+// handlers, the update handler runs in its own coroutine, so it can mutate workflow state and call blocking functions
+// like ExecuteActivity(...).Get().
+// The validator is optional and can be nil. It must accept the same parameters as the handler and return just an
+// error. It is called before the handler, and an update it rejects is not handled and its error is returned to the
+// caller. The validator must not block or mutate workflow state.
+// Updates are delivered as signals, so they are recorded in the workflow history even when rejected. Outcomes of the
+// last 1000 completed updates are kept in workflow memory for the callers to read. Call SetUpdateHandler at the
+// beginning of the workflow code. Updates received before the first handler is set are handled once it is set,
+// updates received after that fail as unknown if their handler is not set.
+// Example of workflow code that supports update "add":
+//  func MyWorkflow(ctx workflow.Context) error {
+//    total := 0
+//    err := workflow.SetUpdateHandler(ctx, "add", func(ctx workflow.Context, n int) (int, error) {
+//      total += n
+//      return total, nil
+//    }, func(ctx workflow.Context, n int) error {
+//      if n <= 0 {
+//        return errors.New("n must be positive")
+//      }
+//      return nil
+//    })
+//    if err != nil {
+//      return err
+//    }
+//    // your normal workflow code begins here
+//  }
+func SetUpdateHandler(ctx Context, updateName string, handler interface{}, validator interface{}) error {
+	i := getWorkflowInterceptor(ctx)
+	return interceptSetUpdateHandler(ctx, i, updateName, handler, validator)
+}
+
+func (wc *workflowEnvironmentInterceptor) SetUpdateHandler(ctx Context, updateName string, handler interface{}, validator interface{}) error {
+	return setUpdateHandler(ctx, updateName, handler, validator)
+}
+
+// interceptSetUpdateHandler passes the call to the interceptor if it implements WorkflowUpdateInterceptor,
+// otherwise the handler is set bypassing the rest of the interceptor chain.
+func interceptSetUpdateHandler(ctx Context, interceptor WorkflowInterceptor, updateName string, handler interface{},
+	validator interface{}) error {
+	if updateInterceptor, ok := interceptor.(WorkflowUpdateInterceptor); ok {
+		return updateInterceptor.SetUpdateHandler(ctx, updateName, handler, validator)
+	}
+	return setUpdateHandler(ctx, updateName, handler, validator)
+}
+
+// QueryHandlerInfo describes a query handler registered with SetQueryHandler.
+type QueryHandlerInfo struct {
+	QueryType  string
+	ArgTypes   []string // Go types of the handler arguments
+	ResultType string   // Go type of the handler result
+}
+
+// GetQueryHandlers returns the query handlers registered by the workflow sorted by query type.
+// The same list is returned by the built-in QueryTypeQueryTypes query.
+func GetQueryHandlers(ctx Context) []QueryHandlerInfo {
+	return getWorkflowEnvOptions(ctx).getQueryHandlers()
+}
+
 // IsReplaying returns whether the current workflow code is replaying.
 //
 // Warning! Never make decisions, like schedule activity/childWorkflow/timer or send/wait on future/channel, based on
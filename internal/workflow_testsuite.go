@@ -36,6 +36,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/uber-go/tally"
 	enumspb "go.temporal.io/temporal-proto/enums/v1"
+	historypb "go.temporal.io/temporal-proto/history/v1"
 	"go.uber.org/zap"
 
 	commonpb "go.temporal.io/temporal-proto/common/v1"
@@ -183,7 +184,8 @@ func (t *TestActivityEnvironment) ExecuteLocalActivity(activityFn interface{}, a
 }
 
 // SetWorkerOptions sets the WorkerOptions that will be use by TestActivityEnvironment. TestActivityEnvironment will
-// use options of BackgroundActivityContext, MaxConcurrentSessionExecutionSize, and WorkflowInterceptorChainFactories on the WorkerOptions.
+// use options of BackgroundActivityContext, MaxConcurrentSessionExecutionSize, WorkflowInterceptorChainFactories
+// and ActivityInterceptorChainFactories on the WorkerOptions.
 // Other options are ignored.
 // Note: WorkerOptions is defined in internal package, use public type worker.Options instead.
 func (t *TestActivityEnvironment) SetWorkerOptions(options WorkerOptions) *TestActivityEnvironment {
@@ -482,7 +484,8 @@ func (e *TestWorkflowEnvironment) Now() time.Time {
 }
 
 // SetWorkerOptions sets the WorkerOptions that will be use by TestActivityEnvironment. TestActivityEnvironment will
-// use options of BackgroundActivityContext, MaxConcurrentSessionExecutionSize, and WorkflowInterceptorChainFactories on the WorkerOptions.
+// use options of BackgroundActivityContext, MaxConcurrentSessionExecutionSize, WorkflowInterceptorChainFactories,
+// ActivityInterceptorChainFactories and DeadlockDetectionTimeout on the WorkerOptions.
 // Other options are ignored.
 // Note: WorkerOptions is defined in internal package, use public type worker.Options instead.
 func (e *TestWorkflowEnvironment) SetWorkerOptions(options WorkerOptions) *TestWorkflowEnvironment {
@@ -490,13 +493,25 @@ func (e *TestWorkflowEnvironment) SetWorkerOptions(options WorkerOptions) *TestW
 	return e
 }
 
-// SetStartWorkflowOptions sets StartWorkflowOptions used to specify workflow execution timeout and task list.
+// SetStartWorkflowOptions sets StartWorkflowOptions used to specify workflow execution timeout, task list and cron
+// schedule.
+// If a cron schedule is set, the workflow runs on the mocked clock at the scheduled times, each run getting the result
+// of the last successful run from GetLastCompletionResult. The runs continue until the workflow is canceled, the
+// workflow execution timeout expires or the maximum number of runs set by SetCronMaxRuns is reached. The result of the
+// test is the result of the last run.
 // Note that StartWorkflowOptions is defined in an internal package, use client.StartWorkflowOptions instead.
 func (e *TestWorkflowEnvironment) SetStartWorkflowOptions(options StartWorkflowOptions) *TestWorkflowEnvironment {
 	e.impl.setStartWorkflowOptions(options)
 	return e
 }
 
+// SetCronMaxRuns sets the maximum number of runs of a cron workflow, 0 means no limit other than the workflow
+// execution timeout. The default is 10 runs.
+func (e *TestWorkflowEnvironment) SetCronMaxRuns(maxRuns int) *TestWorkflowEnvironment {
+	e.impl.cronMaxRuns = maxRuns
+	return e
+}
+
 // SetDataConverter sets data converter.
 func (e *TestWorkflowEnvironment) SetDataConverter(dataConverter DataConverter) *TestWorkflowEnvironment {
 	e.impl.setDataConverter(dataConverter)
@@ -639,11 +654,28 @@ func (e *TestWorkflowEnvironment) SetOnLocalActivityCanceledListener(
 	return e
 }
 
+// SetOnCronRunCompletedListener sets a listener that will be called after each run of a cron workflow is completed.
+// Note: WorkflowInfo is defined in internal package, use public type workflow.Info instead.
+func (e *TestWorkflowEnvironment) SetOnCronRunCompletedListener(
+	listener func(workflowInfo *WorkflowInfo, result Value, err error)) *TestWorkflowEnvironment {
+	e.impl.onCronRunCompletedListener = listener
+	return e
+}
+
 // IsWorkflowCompleted check if test is completed or not
 func (e *TestWorkflowEnvironment) IsWorkflowCompleted() bool {
 	return e.impl.isTestCompleted
 }
 
+// GetWorkflowHistory returns the history of the workflow executed by the test environment, as the server would have
+// recorded it. The history contains the events of activities, local activities, timers, markers, signals and child
+// workflows of the execution, so it can be replayed using WorkflowReplayer, e.g. to keep it as a golden history which
+// future changes of the workflow code must stay compatible with. The history of a cron workflow is the history of its
+// last run.
+func (e *TestWorkflowEnvironment) GetWorkflowHistory() *historypb.History {
+	return e.impl.history.getHistory()
+}
+
 // GetWorkflowResult extracts the encoded result from test workflow, it returns error if the extraction failed.
 func (e *TestWorkflowEnvironment) GetWorkflowResult(valuePtr interface{}) error {
 	if !e.impl.isTestCompleted {
@@ -692,6 +724,13 @@ func (e *TestWorkflowEnvironment) QueryWorkflow(queryType string, args ...interf
 	return e.impl.queryWorkflow(queryType, args...)
 }
 
+// UpdateWorkflow sends an update to the currently running test workflow. The callback is called with the result or
+// error of the update handler once the update is handled, or with an error if the workflow completes first.
+// Use it with RegisterDelayedCallback to send the update at a certain point of the workflow execution.
+func (e *TestWorkflowEnvironment) UpdateWorkflow(updateName string, callback func(result Value, err error), args ...interface{}) {
+	e.impl.updateWorkflow(updateName, callback, args...)
+}
+
 // RegisterDelayedCallback creates a new timer with specified delayDuration using workflow clock (not wall clock). When
 // the timer fires, the callback will be called. By default, this test suite uses mock clock which automatically move
 // forward to fire next timer when workflow is blocked. Use this API to make some event (like activity completion,
@@ -33,6 +33,7 @@ import (
 
 	"go.temporal.io/temporal-proto/workflowservicemock/v1"
 
+	"go.temporal.io/temporal/testsuite"
 	"go.temporal.io/temporal/worker"
 
 	"go.uber.org/zap"
@@ -72,3 +73,11 @@ func (s *replayTestSuite) TestReplayWorkflowHistoryFromFile() {
 		require.NoError(s.T(), err, "file: %s", testFile)
 	}
 }
+
+func TestReplayGoldenHistories(t *testing.T) {
+	replayer := worker.NewWorkflowReplayer()
+	replayer.RegisterWorkflow(Workflow)
+	replayer.RegisterWorkflow(Workflow2)
+
+	testsuite.ReplayGoldenHistories(t, replayer, ".")
+}
@@ -26,7 +26,14 @@
 package testsuite
 
 import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
 	"go.temporal.io/temporal/internal"
+	"go.temporal.io/temporal/worker"
 )
 
 type (
@@ -45,3 +52,64 @@ type (
 
 // ErrMockStartChildWorkflowFailed is special error used to indicate the mocked child workflow should fail to start.
 var ErrMockStartChildWorkflowFailed = internal.ErrMockStartChildWorkflowFailed
+
+// updateGoldenHistoriesEnv is the environment variable that enables regeneration of the golden histories
+// recorded by RecordGoldenHistory.
+const updateGoldenHistoriesEnv = "TEMPORAL_UPDATE_GOLDEN"
+
+// ReplayGoldenHistories replays every JSON history file in dir with the replayer, reporting each history as a subtest
+// named after its file. Use it to verify that changes of the workflow code stay compatible with the histories of
+// workflows which may still be open in production:
+//
+//	func TestGoldenHistories(t *testing.T) {
+//	  replayer := worker.NewWorkflowReplayer()
+//	  replayer.RegisterWorkflow(MyWorkflow)
+//	  testsuite.ReplayGoldenHistories(t, replayer, "testdata/histories")
+//	}
+//
+// The histories can be downloaded from the cli (temporal workflow showid <workflow_id> -of <output_filename>) or
+// recorded from unit tests with RecordGoldenHistory.
+func ReplayGoldenHistories(t *testing.T, replayer worker.WorkflowReplayer, dir string) {
+	t.Helper()
+	iterator, err := worker.NewReplayHistoryIteratorFromDirectory(dir)
+	if err != nil {
+		t.Fatalf("unable to read golden histories from %v: %v", dir, err)
+	}
+	if !iterator.HasNext() {
+		t.Fatalf("no golden history found in %v", dir)
+	}
+	for iterator.HasNext() {
+		history, err := iterator.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a history the workflow code is no longer compatible with fails only its subtest
+		t.Run(strings.TrimSuffix(history.Name, ".json"), func(t *testing.T) {
+			if err := replayer.ReplayWorkflowHistory(nil, history.History); err != nil {
+				t.Errorf("replay of golden history %v failed: %v", filepath.Join(dir, history.Name), err)
+			}
+		})
+	}
+}
+
+// RecordGoldenHistory writes the history of the workflow executed by env as the JSON file <name>.json in dir when
+// the test runs with the TEMPORAL_UPDATE_GOLDEN=1 environment variable, and does nothing otherwise:
+//
+//	env.ExecuteWorkflow(MyWorkflow)
+//	testsuite.RecordGoldenHistory(t, env, "testdata/histories", "my_workflow")
+//
+// Golden histories are regenerated with: TEMPORAL_UPDATE_GOLDEN=1 go test -run <tests>
+// As tests of a package run in source order, tests recording golden histories should precede the test replaying
+// them, so an update is verified by the same run.
+func RecordGoldenHistory(t *testing.T, env *TestWorkflowEnvironment, dir, name string) {
+	t.Helper()
+	if update, _ := strconv.ParseBool(os.Getenv(updateGoldenHistoriesEnv)); !update {
+		return
+	}
+	if !env.IsWorkflowCompleted() {
+		t.Fatalf("golden history %v is not recorded, workflow is not completed", name)
+	}
+	if err := internal.WriteHistoryToJSONFile(env.GetWorkflowHistory(), filepath.Join(dir, name+".json")); err != nil {
+		t.Fatalf("unable to record golden history %v: %v", name, err)
+	}
+}
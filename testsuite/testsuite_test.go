@@ -0,0 +1,69 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testsuite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.temporal.io/temporal/worker"
+	"go.temporal.io/temporal/workflow"
+)
+
+func goldenWorkflow(ctx workflow.Context, name string) (string, error) {
+	return "Hello " + name + "!", nil
+}
+
+func TestRecordAndReplayGoldenHistories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golden")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	var s WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(goldenWorkflow)
+	env.ExecuteWorkflow(goldenWorkflow, "golden")
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	require.NoError(t, os.Unsetenv(updateGoldenHistoriesEnv))
+	RecordGoldenHistory(t, env, dir, "golden")
+	_, err = os.Stat(filepath.Join(dir, "golden.json"))
+	require.True(t, os.IsNotExist(err))
+
+	require.NoError(t, os.Setenv(updateGoldenHistoriesEnv, "1"))
+	defer func() { _ = os.Unsetenv(updateGoldenHistoriesEnv) }()
+	RecordGoldenHistory(t, env, dir, "golden")
+	_, err = os.Stat(filepath.Join(dir, "golden.json"))
+	require.NoError(t, err)
+
+	replayer := worker.NewWorkflowReplayer()
+	replayer.RegisterWorkflow(goldenWorkflow)
+	ReplayGoldenHistories(t, replayer, dir)
+}
@@ -157,11 +157,55 @@ type (
 		ReplayPartialWorkflowHistoryFromJSONFile(logger *zap.Logger, jsonfileName string, lastEventID int64) error
 
 		// ReplayWorkflowExecution loads a workflow execution history from the Temporal service and executes a single decision task for it.
+		// The complete history is loaded page by page, see WorkflowReplayerOptions.HistoryPageSize. The loaded history
+		// can be cached on disk to reproduce the replay offline, see WorkflowReplayerOptions.HistoryCacheDir.
 		// Use for testing the backwards compatibility of code changes and troubleshooting workflows in a debugger.
 		// The logger is the only optional parameter. Defaults to the noop logger.
 		ReplayWorkflowExecution(ctx context.Context, service workflowservice.WorkflowServiceClient, logger *zap.Logger, namespace string, execution workflow.Execution) error
+
+		// ReplayWorkflowHistories replays every history provided by the iterator and reports the result of each replay.
+		// Histories are replayed in parallel. Use it to verify that a new version of the workflow code is compatible
+		// with a large number of histories before deploying it.
+		// The replay stops on the first iterator error or when ctx is done, and the report of the histories replayed
+		// so far is returned together with the error.
+		// The logger is an optional parameter. Defaults to the noop logger.
+		ReplayWorkflowHistories(ctx context.Context, logger *zap.Logger, iterator ReplayHistoryIterator, options ReplayWorkflowHistoriesOptions) (*ReplayReport, error)
 	}
 
+	// WorkflowReplayerOptions are optional parameters of the WorkflowReplayer.
+	WorkflowReplayerOptions = internal.WorkflowReplayerOptions
+
+	// ReplayHistory is a workflow history to replay. Name identifies the history in the replay report.
+	ReplayHistory = internal.ReplayHistory
+
+	// ReplayHistoryIterator provides histories to WorkflowReplayer.ReplayWorkflowHistories.
+	ReplayHistoryIterator = internal.ReplayHistoryIterator
+
+	// ReplayWorkflowHistoriesOptions configure WorkflowReplayer.ReplayWorkflowHistories.
+	ReplayWorkflowHistoriesOptions = internal.ReplayWorkflowHistoriesOptions
+
+	// ReplayReport is the report of WorkflowReplayer.ReplayWorkflowHistories.
+	ReplayReport = internal.ReplayReport
+
+	// ReplayResult is the result of replaying a single workflow history.
+	ReplayResult = internal.ReplayResult
+
+	// ReplayOutcome is the outcome of replaying a single workflow history.
+	ReplayOutcome = internal.ReplayOutcome
+
+	// ReplayDebugger is invoked by WorkflowReplayer at every step of the replay, see
+	// WorkflowReplayerOptions.ReplayDebugger. Returning an error stops the replay.
+	ReplayDebugger = internal.ReplayDebugger
+
+	// ReplayStep is the state of the replayed workflow at a single step of the replay.
+	ReplayStep = internal.ReplayStep
+
+	// ReplayStepType is the type of the replay step.
+	ReplayStepType = internal.ReplayStepType
+
+	// ReplayDecisionState is the state of a decision state machine of the replayed workflow.
+	ReplayDecisionState = internal.ReplayDecisionState
+
 	// Options is used to configure a worker instance.
 	Options = internal.WorkerOptions
 
@@ -184,6 +228,29 @@ const (
 	FailWorkflow = internal.FailWorkflow
 )
 
+const (
+	// ReplayOutcomeOK means the workflow code replayed the history successfully.
+	ReplayOutcomeOK = internal.ReplayOutcomeOK
+	// ReplayOutcomeNondeterminism means the decisions made by the workflow code do not match the history.
+	// ReplayResult.Decision and ReplayResult.Event contain the mismatching decision and event.
+	ReplayOutcomeNondeterminism = internal.ReplayOutcomeNondeterminism
+	// ReplayOutcomePanic means the workflow code panicked during the replay.
+	ReplayOutcomePanic = internal.ReplayOutcomePanic
+	// ReplayOutcomeResultMismatch means the replay completed the workflow differently than the history did.
+	ReplayOutcomeResultMismatch = internal.ReplayOutcomeResultMismatch
+	// ReplayOutcomeError means the history could not be replayed, for example it is corrupted or its workflow type
+	// is not registered.
+	ReplayOutcomeError = internal.ReplayOutcomeError
+)
+
+const (
+	// ReplayStepEvent is taken after a history event is applied to the replayed workflow.
+	ReplayStepEvent = internal.ReplayStepEvent
+	// ReplayStepDecision is taken for every decision the replayed workflow code produces, including the decision
+	// that closes the workflow.
+	ReplayStepDecision = internal.ReplayStepDecision
+)
+
 // New creates an instance of worker for managing workflow and activity executions.
 //    namespace   - the name of the temporal namespace
 //    taskList - is the task list name you use to identify your client worker, also
@@ -203,6 +270,29 @@ func NewWorkflowReplayer() WorkflowReplayer {
 	return internal.NewWorkflowReplayer()
 }
 
+// NewWorkflowReplayerWithOptions creates a WorkflowReplayer instance with the provided options.
+func NewWorkflowReplayerWithOptions(options WorkflowReplayerOptions) WorkflowReplayer {
+	return internal.NewWorkflowReplayerWithOptions(options)
+}
+
+// NewReplayHistoryIterator returns an iterator over the given histories.
+func NewReplayHistoryIterator(histories ...*ReplayHistory) ReplayHistoryIterator {
+	return internal.NewReplayHistoryIterator(histories...)
+}
+
+// NewReplayHistoryIteratorFromDirectory returns an iterator over the JSON history files in the given directory sorted
+// by name. The name of a history is the name of its file.
+// To download the history file: temporal workflow showid <workflow_id> -of <output_filename>
+func NewReplayHistoryIteratorFromDirectory(dir string) (ReplayHistoryIterator, error) {
+	return internal.NewReplayHistoryIteratorFromDirectory(dir)
+}
+
+// NewReplayHistoryIteratorFromListWorkflow returns an iterator over the histories of the workflow executions returned
+// by client.ListWorkflow for the given request. The name of a history is "<workflow ID>/<run ID>".
+func NewReplayHistoryIteratorFromListWorkflow(ctx context.Context, c client.Client, request *workflowservice.ListWorkflowExecutionsRequest) ReplayHistoryIterator {
+	return internal.NewReplayHistoryIteratorFromListWorkflow(ctx, c, request)
+}
+
 // EnableVerboseLogging enable or disable verbose logging of internal Temporal library components.
 // Most customers don't need this feature, unless advised by the Temporal team member.
 // Also there is no guarantee that this API is not going to change.
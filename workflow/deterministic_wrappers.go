@@ -58,6 +58,14 @@ type (
 	// WaitGroup is used to wait for a collection of
 	// coroutines to finish
 	WaitGroup = internal.WaitGroup
+
+	// Mutex is used to give coroutines exclusive access to shared state
+	// across blocking calls.
+	Mutex = internal.Mutex
+
+	// Semaphore is used to limit the number of coroutines that work
+	// on something at the same time.
+	Semaphore = internal.Semaphore
 )
 
 // Await blocks the calling thread until condition() returns true
@@ -86,6 +94,32 @@ func AwaitWithTimeout(ctx Context, timeout time.Duration, condition func() bool)
 	return internal.AwaitWithTimeout(ctx, timeout, condition)
 }
 
+// GetWithTimeout blocks until the future is ready or blocking time exceeds the passed timeout value.
+// The timeout is a durable timer that is canceled when the future becomes ready first.
+// Returns ok equals to false if timed out and err equals to CanceledError if the ctx is canceled.
+// Otherwise err is the result of future.Get.
+// It is a function rather than a method of Future, as adding a method to the interface would break its
+// implementations outside of the SDK.
+// The following code waits for an activity result for at most one hour.
+//
+// ok, err := workflow.GetWithTimeout(ctx, workflow.ExecuteActivity(ctx, activity), time.Hour, &result)
+func GetWithTimeout(ctx Context, future Future, timeout time.Duration, valuePtr interface{}) (ok bool, err error) {
+	return internal.GetWithTimeout(ctx, future, timeout, valuePtr)
+}
+
+// ReceiveWithTimeout blocks until a value is received from the channel or blocking time exceeds the passed timeout value.
+// The timeout is a durable timer that is canceled when a value is received first.
+// Returns ok equals to false if timed out, the ctx is canceled or the channel is closed,
+// and more equals to false when the channel is closed.
+// It is a function rather than a method of ReceiveChannel, as adding a method to the interface would break its
+// implementations outside of the SDK.
+// The following code waits for a signal for at most one hour.
+//
+// ok, _ := workflow.ReceiveWithTimeout(ctx, workflow.GetSignalChannel(ctx, "signal"), time.Hour, &value)
+func ReceiveWithTimeout(ctx Context, c ReceiveChannel, timeout time.Duration, valuePtr interface{}) (ok, more bool) {
+	return internal.ReceiveWithTimeout(ctx, c, timeout, valuePtr)
+}
+
 // NewChannel create new Channel instance
 func NewChannel(ctx Context) Channel {
 	return internal.NewChannel(ctx)
@@ -124,6 +158,29 @@ func NewWaitGroup(ctx Context) WaitGroup {
 	return internal.NewWaitGroup(ctx)
 }
 
+// NewMutex creates a new Mutex instance.
+// The following code makes sure that only one coroutine at a time updates the balance,
+// even though the update blocks on an activity.
+//
+// mutex := workflow.NewMutex(ctx)
+// workflow.Go(ctx, func(ctx workflow.Context) {
+//   if err := mutex.Lock(ctx); err != nil {
+//     return
+//   }
+//   defer mutex.Unlock()
+//   balance, err = updateBalance(ctx, balance)
+// })
+func NewMutex(ctx Context) Mutex {
+	return internal.NewMutex(ctx)
+}
+
+// NewSemaphore creates a new Semaphore instance with n permits.
+// A coroutine blocked on Lock or Acquire is reported in the __stack_trace query
+// as blocked on the mutex or semaphore.
+func NewSemaphore(ctx Context, n int64) Semaphore {
+	return internal.NewSemaphore(ctx, n)
+}
+
 // Go creates a new coroutine. It has similar semantic to goroutine in a context of the workflow.
 func Go(ctx Context, f func(ctx Context)) {
 	internal.Go(ctx, f)
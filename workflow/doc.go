@@ -121,6 +121,14 @@ A simplistic way to think about these requirements is that the workflow code:
   - Should not iterate over maps using range as order of map iteration is
     randomized
 
+The workflowcheck vet tool reports the code of the workflow functions and the functions they call that breaks these
+rules. It is a separate module that requires Go 1.22 or later to build:
+
+	go install go.temporal.io/temporal/internal/cmd/tools/workflowcheck@latest
+	go vet -vettool=$(go env GOPATH)/bin/workflowcheck ./...
+
+A report is suppressed by the //workflowcheck:ignore comment on the reported line or the line above it.
+
 Now that we laid out the ground rules we can take a look at how to implement some common patterns inside workflows.
 
 Special Temporal client library functions and types
@@ -440,6 +448,35 @@ cli:
 
 Besides using tctl, you can also issue query from code using QueryWorkflow() API on temporal Client object.
 
+To find out which query types a running workflow supports, use the built-in __query_types query type. It returns the
+query types registered with workflow.SetQueryHandler together with their argument and result types:
+
+	tctl --namespace samples-namespace workflow query -w my_workflow_id -r my_run_id -qt __query_types
+
+Update API
+
+An update combines a signal and a query: it mutates workflow state and returns a result to the caller. The workflow
+registers a handler and an optional validator for each update name:
+
+	total := 0
+	err := workflow.SetUpdateHandler(ctx, "add", func(ctx workflow.Context, n int) (int, error) {
+		total += n
+		return total, nil
+	}, func(ctx workflow.Context, n int) error {
+		if n <= 0 {
+			return errors.New("n must be positive")
+		}
+		return nil
+	})
+
+The client sends the update and blocks until the handler completes:
+
+	value, err := c.UpdateWorkflow(ctx, "my_workflow_id", "", "add", 5)
+
+The error is either the error returned by the validator, which rejects the update before the handler runs, or the
+error returned by the handler. Updates are delivered as signals, so every update, including rejected ones, is recorded
+in the workflow history.
+
 Registration
 
 For some client code to be able to invoke a workflow type, the worker process needs to be aware of all the
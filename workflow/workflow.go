@@ -53,6 +53,9 @@ type (
 	// RegisterOptions consists of options for registering a workflow
 	RegisterOptions = internal.RegisterWorkflowOptions
 
+	// QueryHandlerInfo describes a query handler registered with SetQueryHandler.
+	QueryHandlerInfo = internal.QueryHandlerInfo
+
 	// Info information about currently executing workflow
 	Info = internal.WorkflowInfo
 
@@ -369,6 +372,44 @@ func SetQueryHandler(ctx Context, queryType string, handler interface{}) error {
 	return internal.SetQueryHandler(ctx, queryType, handler)
 }
 
+// SetUpdateHandler sets the handler of the workflow update with given updateName. Updates are sent by
+// client.Client.UpdateWorkflow(), which blocks until the handler completes and returns its result or error.
+// The handler must be a function that takes workflow.Context as its first parameter followed by any number of
+// serializable parameters, and returns either a serializable result and an error or just an error. The handler runs
+// in its own coroutine, so it can mutate workflow state and call blocking functions.
+// The validator is optional and can be nil. It must accept the same parameters as the handler and return just an
+// error. An update rejected by the validator is not handled and its error is returned to the caller. The validator
+// must not block or mutate workflow state.
+// Updates are delivered as signals, so they are recorded in the workflow history even when rejected. Outcomes of the
+// last 1000 completed updates are kept in workflow memory for the callers to read.
+// Example of workflow code that supports update "add":
+//  func MyWorkflow(ctx workflow.Context) error {
+//    total := 0
+//    err := workflow.SetUpdateHandler(ctx, "add", func(ctx workflow.Context, n int) (int, error) {
+//      total += n
+//      return total, nil
+//    }, func(ctx workflow.Context, n int) error {
+//      if n <= 0 {
+//        return errors.New("n must be positive")
+//      }
+//      return nil
+//    })
+//    if err != nil {
+//      return err
+//    }
+//    // your normal workflow code begins here
+//  }
+func SetUpdateHandler(ctx Context, updateName string, handler interface{}, validator interface{}) error {
+	return internal.SetUpdateHandler(ctx, updateName, handler, validator)
+}
+
+// GetQueryHandlers returns the query handlers registered with SetQueryHandler sorted by query type, including
+// their argument and result types. Clients get the same list through the built-in client.QueryTypeQueryTypes
+// ("__query_types") query, which lets tools discover the queries supported by a running workflow.
+func GetQueryHandlers(ctx Context) []QueryHandlerInfo {
+	return internal.GetQueryHandlers(ctx)
+}
+
 // IsReplaying returns whether the current workflow code is replaying.
 //
 // Warning! Never make decisions, like schedule activity/childWorkflow/timer or send/wait on future/channel, based on